@@ -17,6 +17,15 @@
 // handlers on the specified dispatch queue.
 - (instancetype)initWithHandlerDispatchQueue:(dispatch_queue_t)dispatchQueue;
 
+// Designated initializer.  This will create a connection manager that invokes
+// handlers on the specified dispatch queue and only permits connections and
+// listeners on endpoints matching one of the specified patterns (an array of
+// NSString).  Other requests fail with the error "endpoint not permitted".  See
+// the C++ IPCConnectionManager for pattern syntax.  Passing nil permits all
+// endpoints.
+- (instancetype)initWithHandlerDispatchQueue:(dispatch_queue_t)dispatchQueue
+                            allowedEndpoints:(NSArray *)allowedEndpoints;
+
 // Asynchronously create a new connection
 - (void)connectAsync:(NSString *)endpoint
              handler:(void (^)(NSNumber *, NSString *))handler;
@@ -35,6 +44,14 @@
 - (void)connectionCloseAsync:(NSNumber *)connectionId
                      handler:(void (^)(NSString *))handler;
 
+// Asynchronously shut down the read side of a connection
+- (void)connectionCloseReadAsync:(NSNumber *)connectionId
+                         handler:(void (^)(NSString *))handler;
+
+// Asynchronously shut down the write side of a connection
+- (void)connectionCloseWriteAsync:(NSNumber *)connectionId
+                          handler:(void (^)(NSString *))handler;
+
 // Asynchronously begin listening
 - (void)listenAsync:(NSString *)endpoint
             handler:(void (^)(NSNumber *, NSString *))handler;
@@ -47,4 +64,27 @@
 - (void)listenerCloseAsync:(NSNumber *)listenerId
                    handler:(void (^)(NSString *))handler;
 
+// Asynchronously verify that the connection manager is responsive
+- (void)pingAsync:(void (^)(NSString *))handler;
+
+// Asynchronously create a new packet connection.  Packet connections preserve
+// message boundaries but aren't supported on OS X/iOS, so this currently always
+// fails.  See the C++ IPCConnectionManager for details.
+- (void)connectPacketAsync:(NSString *)endpoint
+                   handler:(void (^)(NSNumber *, NSString *))handler;
+
+// Asynchronously read a single message from a packet connection.  An empty
+// message with no error indicates end-of-stream.
+- (void)connectionReadMessageAsync:(NSNumber *)connectionId
+                           handler:(void (^)(NSData *, NSString *))handler;
+
+// Asynchronously write a single message to a packet connection
+- (void)connectionWriteMessageAsync:(NSNumber *)connectionId
+                               data:(NSData *)data
+                            handler:(void (^)(NSNumber *, NSString *))handler;
+
+// Asynchronously begin listening for packet connections
+- (void)listenPacketAsync:(NSString *)endpoint
+                  handler:(void (^)(NSNumber *, NSString *))handler;
+
 @end
@@ -26,6 +26,13 @@
 }
 
 - (instancetype)initWithHandlerDispatchQueue:(dispatch_queue_t)dispatchQueue {
+    // Call the more general initializer
+    return [self initWithHandlerDispatchQueue:dispatchQueue
+                             allowedEndpoints:nil];
+}
+
+- (instancetype)initWithHandlerDispatchQueue:(dispatch_queue_t)dispatchQueue
+                            allowedEndpoints:(NSArray *)allowedEndpoints {
     // Call the superclass initializer
     if ((self = [super init]) == nil) {
         return nil;
@@ -34,8 +41,17 @@
     // Store the dispatch queue
     self.dispatchQueue = dispatchQueue;
 
-    // Create the connection manager
-    self.connectionManager = new gib::IPCConnectionManager();
+    // Create the connection manager, converting the allowlist if one has been
+    // specified
+    if (allowedEndpoints == nil) {
+        self.connectionManager = new gib::IPCConnectionManager();
+    } else {
+        std::vector<std::string> patterns;
+        for (NSString *pattern in allowedEndpoints) {
+            patterns.push_back([pattern UTF8String]);
+        }
+        self.connectionManager = new gib::IPCConnectionManager(patterns);
+    }
 
     // All done
     return self;
@@ -175,6 +191,48 @@
     );
 }
 
+- (void)connectionCloseReadAsync:(NSNumber *)connectionId
+                         handler:(void (^)(NSString *))handler {
+    // Get dispatch queue
+    dispatch_queue_t queue = self.dispatchQueue;
+
+    // Dispatch the request with a wrapper handler
+    self.connectionManager->connection_close_read_async(
+        [connectionId intValue],
+        [queue, handler](const std::string & error) {
+            // Convert the error since it is a reference and may not exist when
+            // the handler is invoked
+            NSString *errCocoa = [NSString stringWithUTF8String:error.c_str()];
+
+            // Call the Objective-C handler on the dispatch queue
+            dispatch_async(queue, ^{
+                handler(errCocoa);
+            });
+        }
+    );
+}
+
+- (void)connectionCloseWriteAsync:(NSNumber *)connectionId
+                          handler:(void (^)(NSString *))handler {
+    // Get dispatch queue
+    dispatch_queue_t queue = self.dispatchQueue;
+
+    // Dispatch the request with a wrapper handler
+    self.connectionManager->connection_close_write_async(
+        [connectionId intValue],
+        [queue, handler](const std::string & error) {
+            // Convert the error since it is a reference and may not exist when
+            // the handler is invoked
+            NSString *errCocoa = [NSString stringWithUTF8String:error.c_str()];
+
+            // Call the Objective-C handler on the dispatch queue
+            dispatch_async(queue, ^{
+                handler(errCocoa);
+            });
+        }
+    );
+}
+
 - (void)listenAsync:(NSString *)endpoint
             handler:(void (^)(NSNumber *, NSString *))handler {
     // Get dispatch queue
@@ -238,4 +296,139 @@
     );
 }
 
+- (void)pingAsync:(void (^)(NSString *))handler {
+    // Get dispatch queue
+    dispatch_queue_t queue = self.dispatchQueue;
+
+    // Dispatch the request with a wrapper handler
+    self.connectionManager->ping_async(
+        [queue, handler](const std::string & error) {
+            // Convert the error since it is a reference and may not exist when
+            // the handler is invoked
+            NSString *errCocoa = [NSString stringWithUTF8String:error.c_str()];
+
+            // Call the Objective-C handler on the dispatch queue
+            dispatch_async(queue, ^{
+                handler(errCocoa);
+            });
+        }
+    );
+}
+
+- (void)connectPacketAsync:(NSString *)endpoint
+                   handler:(void (^)(NSNumber *, NSString *))handler {
+    // Get dispatch queue
+    dispatch_queue_t queue = self.dispatchQueue;
+
+    // Dispatch the request with a wrapper handler
+    self.connectionManager->connect_packet_async(
+        [endpoint UTF8String],
+        [queue, handler](std::int32_t connectionId, const std::string & error) {
+            // Convert the error since it is a reference and may not exist when
+            // the handler is invoked
+            NSString *errCocoa = [NSString stringWithUTF8String:error.c_str()];
+
+            // Call the Objective-C handler on the dispatch queue
+            dispatch_async(queue, ^{
+                handler([NSNumber numberWithInt:connectionId], errCocoa);
+            });
+        }
+    );
+}
+
+- (void)connectionReadMessageAsync:(NSNumber *)connectionId
+                           handler:(void (^)(NSData *, NSString *))handler {
+    // Create a read buffer one byte larger than the maximum message size so
+    // that the connection manager can detect oversized messages
+    NSMutableData *buffer = [NSMutableData
+        dataWithLength:gib::IPCConnectionManager::maximum_message_size + 1];
+
+    // Verify that the allocation succeeded
+    if (!buffer) {
+        // Call the Objective-C handler on the dispatch queue
+        dispatch_async(self.dispatchQueue, ^{
+            handler([NSData data], @"read buffer allocation failed");
+        });
+
+        // Bail
+        return;
+    }
+
+    // Get dispatch queue
+    dispatch_queue_t queue = self.dispatchQueue;
+
+    // Dispatch the request with a wrapper handler
+    // NOTE: We capture the buffer in our handler lambda to keep it alive for
+    // the duration of the read (see connectionReadAsync:length:handler:).
+    self.connectionManager->connection_read_message_async(
+        [connectionId intValue],
+        buffer.mutableBytes,
+        buffer.length,
+        [queue, handler, buffer](std::size_t count, const std::string & error) {
+            // Truncate the buffer to the length read
+            [buffer replaceBytesInRange:NSMakeRange(count,
+                                                    buffer.length - count)
+                              withBytes:NULL
+                                 length:0];
+
+            // Convert the error since it is a reference and may not exist when
+            // the handler is invoked
+            NSString *errCocoa = [NSString stringWithUTF8String:error.c_str()];
+
+            // Call the Objective-C handler on the dispatch queue
+            dispatch_async(queue, ^{
+                handler(buffer, errCocoa);
+            });
+        }
+    );
+}
+
+- (void)connectionWriteMessageAsync:(NSNumber *)connectionId
+                               data:(NSData *)data
+                            handler:(void (^)(NSNumber *, NSString *))handler {
+    // Get dispatch queue
+    dispatch_queue_t queue = self.dispatchQueue;
+
+    // Dispatch the request with a wrapper handler
+    // NOTE: We explicitly capture the data in our handler lambda to keep it
+    // alive for the duration of the write (see
+    // connectionWriteAsync:data:handler:).
+    self.connectionManager->connection_write_message_async(
+        [connectionId intValue],
+        data.bytes,
+        data.length,
+        [queue, data, handler](std::size_t count, const std::string & error) {
+            // Convert the error since it is a reference and may not exist when
+            // the handler is invoked
+            NSString *errCocoa = [NSString stringWithUTF8String:error.c_str()];
+
+            // Call the Objective-C handler on the dispatch queue
+            dispatch_async(queue, ^{
+                handler([NSNumber numberWithUnsignedInteger:count], errCocoa);
+            });
+        }
+    );
+}
+
+- (void)listenPacketAsync:(NSString *)endpoint
+                  handler:(void (^)(NSNumber *, NSString *))handler {
+    // Get dispatch queue
+    dispatch_queue_t queue = self.dispatchQueue;
+
+    // Dispatch the request with a wrapper handler
+    self.connectionManager->listen_packet_async(
+        [endpoint UTF8String],
+        [queue, handler](std::int32_t listenerId, const std::string & error) {
+            // Convert the error since it is a reference and may not exist when
+            // the handler is invoked
+            NSString *errCocoa = [NSString stringWithUTF8String:error.c_str()];
+
+            // Call the Objective-C handler on the dispatch queue
+            dispatch_async(queue, ^{
+                handler([NSNumber numberWithInt:listenerId], errCocoa);
+            });
+        }
+    );
+}
+
 @end
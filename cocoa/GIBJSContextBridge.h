@@ -21,4 +21,15 @@
                  interactionQueue:(dispatch_queue_t)queue
             initializationMessage:(NSString *)initializationMessage;
 
+// Designated initializer.  Like
+// initWithJSContext:interactionQueue:initializationMessage:, but the bridge
+// will only permit connections and listeners on endpoints matching one of the
+// specified patterns (an array of NSString).  This should mirror the policy
+// provided in ClientOptions in GopherJS, since any script in the context can
+// message the bridge directly.  Passing nil permits all endpoints.
+- (instancetype)initWithJSContext:(JSContext *)context
+                 interactionQueue:(dispatch_queue_t)queue
+            initializationMessage:(NSString *)initializationMessage
+                 allowedEndpoints:(NSArray *)allowedEndpoints;
+
 @end
@@ -38,6 +38,15 @@
 - (void)connectionClose:(NSNumber *)connectionId
            withCallback:(JSValue *)callback;
 
+// Bridge method for asynchronously shutting down the read side of a connection
+- (void)connectionCloseRead:(NSNumber *)connectionId
+               withCallback:(JSValue *)callback;
+
+// Bridge method for asynchronously shutting down the write side of a
+// connection
+- (void)connectionCloseWrite:(NSNumber *)connectionId
+                withCallback:(JSValue *)callback;
+
 // Bridge method for asynchronously starting a listener
 - (void)listen:(NSString *)endpoint withCallback:(JSValue *)callback;
 
@@ -47,6 +56,24 @@
 // Bridge method for asynchronously closing a listener
 - (void)listenerClose:(NSNumber *)listenerId withCallback:(JSValue *)callback;
 
+// Bridge method for asynchronously pinging the host
+- (void)pingWithCallback:(JSValue *)callback;
+
+// Bridge method for asynchronously creating a packet connection
+- (void)connectPacket:(NSString *)endpoint withCallback:(JSValue *)callback;
+
+// Bridge method for asynchronously reading a message from a packet connection
+- (void)connectionReadMessage:(NSNumber *)connectionId
+                 withCallback:(JSValue *)callback;
+
+// Bridge method for asynchronously writing a message to a packet connection
+- (void)connectionWriteMessage:(NSNumber *)connectionId
+                      withData:(NSString *)data64
+                  withCallback:(JSValue *)callback;
+
+// Bridge method for asynchronously starting a packet listener
+- (void)listenPacket:(NSString *)endpoint withCallback:(JSValue *)callback;
+
 @end
 
 
@@ -60,14 +87,16 @@
 @property (nonatomic) GIBIPCConnectionManager *connectionManager;
 
 // Designated initializer
-- (instancetype)initWithInteractionQueue:(dispatch_queue_t)queue;
+- (instancetype)initWithInteractionQueue:(dispatch_queue_t)queue
+                        allowedEndpoints:(NSArray *)allowedEndpoints;
 
 @end
 
 
 @implementation GIBJSContextBridgeProxy
 
-- (instancetype)initWithInteractionQueue:(dispatch_queue_t)queue {
+- (instancetype)initWithInteractionQueue:(dispatch_queue_t)queue
+                        allowedEndpoints:(NSArray *)allowedEndpoints {
     // Call the superclass initializer
     if ((self = [super init]) == nil) {
         return nil;
@@ -75,7 +104,8 @@
 
     // Create the connection manager
     self.connectionManager =
-        [[GIBIPCConnectionManager alloc] initWithHandlerDispatchQueue:queue];
+        [[GIBIPCConnectionManager alloc] initWithHandlerDispatchQueue:queue
+                                                     allowedEndpoints:allowedEndpoints];
 
     // All done
     return self;
@@ -123,6 +153,24 @@
     }];
 }
 
+- (void)connectionCloseRead:(NSNumber *)connectionId
+               withCallback:(JSValue *)callback {
+    // Dispatch the request to the connection manager with a callback adapter
+    [self.connectionManager connectionCloseReadAsync:connectionId
+                                             handler:^(NSString *error) {
+        [callback callWithArguments:@[error]];
+    }];
+}
+
+- (void)connectionCloseWrite:(NSNumber *)connectionId
+                withCallback:(JSValue *)callback {
+    // Dispatch the request to the connection manager with a callback adapter
+    [self.connectionManager connectionCloseWriteAsync:connectionId
+                                              handler:^(NSString *error) {
+        [callback callWithArguments:@[error]];
+    }];
+}
+
 - (void)listen:(NSString *)endpoint
   withCallback:(JSValue *)callback {
     // Dispatch the request to the connection manager with a callback adapter
@@ -152,6 +200,53 @@
     }];
 }
 
+- (void)pingWithCallback:(JSValue *)callback {
+    // Dispatch the request to the connection manager with a callback adapter
+    [self.connectionManager pingAsync:^(NSString *error) {
+        [callback callWithArguments:@[error]];
+    }];
+}
+
+- (void)connectPacket:(NSString *)endpoint withCallback:(JSValue *)callback {
+    // Dispatch the request to the connection manager with a callback adapter
+    [self.connectionManager connectPacketAsync:endpoint
+                                       handler:^(NSNumber *connectionId,
+                                                 NSString *error) {
+        [callback callWithArguments:@[connectionId, error]];
+    }];
+}
+
+- (void)connectionReadMessage:(NSNumber *)connectionId
+                 withCallback:(JSValue *)callback {
+    // Dispatch the request to the connection manager with a callback adapter
+    [self.connectionManager
+     connectionReadMessageAsync:connectionId
+                        handler:^(NSData *data, NSString *error) {
+        [callback callWithArguments:@[[data base64EncodedString], error]];
+    }];
+}
+
+- (void)connectionWriteMessage:(NSNumber *)connectionId
+                      withData:(NSString *)data64
+                  withCallback:(JSValue *)callback {
+    // Dispatch the request to the connection manager with a callback adapter
+    [self.connectionManager connectionWriteMessageAsync:connectionId
+                                                   data:[data64 base64DecodeBytes]
+                                                handler:^(NSNumber *count,
+                                                          NSString *error) {
+        [callback callWithArguments:@[count, error]];
+    }];
+}
+
+- (void)listenPacket:(NSString *)endpoint withCallback:(JSValue *)callback {
+    // Dispatch the request to the connection manager with a callback adapter
+    [self.connectionManager listenPacketAsync:endpoint
+                                      handler:^(NSNumber *listenerId,
+                                                NSString *error) {
+        [callback callWithArguments:@[listenerId, error]];
+    }];
+}
+
 @end
 
 
@@ -160,6 +255,17 @@
 - (instancetype)initWithJSContext:(JSContext *)context
                  interactionQueue:(dispatch_queue_t)queue
             initializationMessage:(NSString *)initializationMessage {
+    // Call the more general initializer
+    return [self initWithJSContext:context
+                  interactionQueue:queue
+             initializationMessage:initializationMessage
+                  allowedEndpoints:nil];
+}
+
+- (instancetype)initWithJSContext:(JSContext *)context
+                 interactionQueue:(dispatch_queue_t)queue
+            initializationMessage:(NSString *)initializationMessage
+                 allowedEndpoints:(NSArray *)allowedEndpoints {
     // Call the superclass initializer
     if ((self = [super init]) == nil) {
         return nil;
@@ -167,7 +273,8 @@
 
     // Create the proxy
     GIBJSContextBridgeProxy *proxy =
-        [[GIBJSContextBridgeProxy alloc] initWithInteractionQueue:queue];
+        [[GIBJSContextBridgeProxy alloc] initWithInteractionQueue:queue
+                                                 allowedEndpoints:allowedEndpoints];
 
     // Install the proxy
     [context[@"_GIBJSContextBridgeInitialize"]
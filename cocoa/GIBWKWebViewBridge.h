@@ -13,4 +13,14 @@
 - (instancetype)initWithWKWebView:(WKWebView *)webView
             initializationMessage:(NSString *)initializationMessage;
 
+// Designated initializer.  Like initWithWKWebView:initializationMessage:, but
+// the bridge will only permit connections and listeners on endpoints matching
+// one of the specified patterns (an array of NSString).  This should mirror the
+// policy provided in ClientOptions in GopherJS, since any script in the web
+// view can message the bridge directly.  Passing nil permits all
+// endpoints.
+- (instancetype)initWithWKWebView:(WKWebView *)webView
+            initializationMessage:(NSString *)initializationMessage
+                 allowedEndpoints:(NSArray *)allowedEndpoints;
+
 @end
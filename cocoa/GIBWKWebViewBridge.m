@@ -13,7 +13,14 @@ typedef NS_ENUM(NSUInteger, WKWebViewBridgeAction) {
     WKWebViewBridgeActionConnectionClose,
     WKWebViewBridgeActionListen,
     WKWebViewBridgeActionListenerAccept,
-    WKWebViewBridgeActionListenerClose
+    WKWebViewBridgeActionListenerClose,
+    WKWebViewBridgeActionPing,
+    WKWebViewBridgeActionConnectionCloseRead,
+    WKWebViewBridgeActionConnectionCloseWrite,
+    WKWebViewBridgeActionConnectPacket,
+    WKWebViewBridgeActionConnectionReadMessage,
+    WKWebViewBridgeActionConnectionWriteMessage,
+    WKWebViewBridgeActionListenPacket
 };
 
 
@@ -54,6 +61,14 @@ typedef NS_ENUM(NSUInteger, WKWebViewBridgeAction) {
 - (void)connectionClose:(NSNumber *)connectionId
            withSequence:(NSNumber *)sequence;
 
+// Handler for asynchronously shutting down the read side of a connection
+- (void)connectionCloseRead:(NSNumber *)connectionId
+               withSequence:(NSNumber *)sequence;
+
+// Handler for asynchronously shutting down the write side of a connection
+- (void)connectionCloseWrite:(NSNumber *)connectionId
+                withSequence:(NSNumber *)sequence;
+
 // Handler for asynchronously starting a listener
 - (void)listen:(NSString *)endpoint withSequence:(NSNumber *)sequence;
 
@@ -63,6 +78,24 @@ typedef NS_ENUM(NSUInteger, WKWebViewBridgeAction) {
 // Handler for asynchronously closing a listener
 - (void)listenerClose:(NSNumber *)listenerId withSequence:(NSNumber *)sequence;
 
+// Handler for asynchronously pinging the host
+- (void)pingWithSequence:(NSNumber *)sequence;
+
+// Handler for asynchronously creating a packet connection
+- (void)connectPacket:(NSString *)endpoint withSequence:(NSNumber *)sequence;
+
+// Handler for asynchronously reading a message from a packet connection
+- (void)connectionReadMessage:(NSNumber *)connectionId
+                 withSequence:(NSNumber *)sequence;
+
+// Handler for asynchronously writing a message to a packet connection
+- (void)connectionWriteMessage:(NSNumber *)connectionId
+                      withData:(NSString *)data64
+                  withSequence:(NSNumber *)sequence;
+
+// Handler for asynchronously starting a packet listener
+- (void)listenPacket:(NSString *)endpoint withSequence:(NSNumber *)sequence;
+
 @end
 
 
@@ -70,14 +103,25 @@ typedef NS_ENUM(NSUInteger, WKWebViewBridgeAction) {
 
 - (instancetype)initWithWKWebView:(WKWebView *)webView
             initializationMessage:(NSString *)initializationMessage; {
+    // Call the more general initializer
+    return [self initWithWKWebView:webView
+             initializationMessage:initializationMessage
+                  allowedEndpoints:nil];
+}
+
+- (instancetype)initWithWKWebView:(WKWebView *)webView
+            initializationMessage:(NSString *)initializationMessage
+                 allowedEndpoints:(NSArray *)allowedEndpoints {
     // Call the superclass initializer
     if ((self = [super init]) == nil) {
         return nil;
     }
 
     // Create the connection manager.  Enforce that all callbacks take place on
-    // the main thread (the default if not specified).
-    self.connectionManager = [[GIBIPCConnectionManager alloc] init];
+    // the main thread.
+    self.connectionManager = [[GIBIPCConnectionManager alloc]
+                              initWithHandlerDispatchQueue:dispatch_get_main_queue()
+                                          allowedEndpoints:allowedEndpoints];
 
     // Store the web view
     self.webView = webView;
@@ -135,6 +179,32 @@ typedef NS_ENUM(NSUInteger, WKWebViewBridgeAction) {
         case WKWebViewBridgeActionListenerClose:
             [self listenerClose:body[@"listenerId"] withSequence:sequence];
             break;
+        case WKWebViewBridgeActionPing:
+            [self pingWithSequence:sequence];
+            break;
+        case WKWebViewBridgeActionConnectionCloseRead:
+            [self connectionCloseRead:body[@"connectionId"]
+                         withSequence:sequence];
+            break;
+        case WKWebViewBridgeActionConnectionCloseWrite:
+            [self connectionCloseWrite:body[@"connectionId"]
+                          withSequence:sequence];
+            break;
+        case WKWebViewBridgeActionConnectPacket:
+            [self connectPacket:body[@"endpoint"] withSequence:sequence];
+            break;
+        case WKWebViewBridgeActionConnectionReadMessage:
+            [self connectionReadMessage:body[@"connectionId"]
+                           withSequence:sequence];
+            break;
+        case WKWebViewBridgeActionConnectionWriteMessage:
+            [self connectionWriteMessage:body[@"connectionId"]
+                                withData:body[@"data64"]
+                            withSequence:sequence];
+            break;
+        case WKWebViewBridgeActionListenPacket:
+            [self listenPacket:body[@"endpoint"] withSequence:sequence];
+            break;
         default:
             break;
     }
@@ -239,6 +309,32 @@ typedef NS_ENUM(NSUInteger, WKWebViewBridgeAction) {
     }];
 }
 
+- (void)connectionCloseRead:(NSNumber *)connectionId
+               withSequence:(NSNumber *)sequence {
+    // Get a weak reference to self to avoid retain cycles
+    __weak GIBWKWebViewBridge *weakSelf = self;
+
+    // Dispatch the request to the connection manager with a callback adapter
+    [self.connectionManager connectionCloseReadAsync:connectionId
+                                             handler:^(NSString *error) {
+        [weakSelf callTarget:@"_GIBWKWebViewBridge.RespondConnectionCloseRead"
+               withArguments:@[sequence, [error base64EncodedString]]];
+    }];
+}
+
+- (void)connectionCloseWrite:(NSNumber *)connectionId
+                withSequence:(NSNumber *)sequence {
+    // Get a weak reference to self to avoid retain cycles
+    __weak GIBWKWebViewBridge *weakSelf = self;
+
+    // Dispatch the request to the connection manager with a callback adapter
+    [self.connectionManager connectionCloseWriteAsync:connectionId
+                                              handler:^(NSString *error) {
+        [weakSelf callTarget:@"_GIBWKWebViewBridge.RespondConnectionCloseWrite"
+               withArguments:@[sequence, [error base64EncodedString]]];
+    }];
+}
+
 - (void)listen:(NSString *)endpoint withSequence:(NSNumber *)sequence {
     // Get a weak reference to self to avoid retain cycles
     __weak GIBWKWebViewBridge *weakSelf = self;
@@ -282,4 +378,77 @@ typedef NS_ENUM(NSUInteger, WKWebViewBridgeAction) {
     }];
 }
 
+- (void)pingWithSequence:(NSNumber *)sequence {
+    // Get a weak reference to self to avoid retain cycles
+    __weak GIBWKWebViewBridge *weakSelf = self;
+
+    // Dispatch the request to the connection manager with a callback adapter
+    [self.connectionManager pingAsync:^(NSString *error) {
+        [weakSelf callTarget:@"_GIBWKWebViewBridge.RespondPing"
+               withArguments:@[sequence, [error base64EncodedString]]];
+    }];
+}
+
+- (void)connectPacket:(NSString *)endpoint withSequence:(NSNumber *)sequence {
+    // Get a weak reference to self to avoid retain cycles
+    __weak GIBWKWebViewBridge *weakSelf = self;
+
+    // Dispatch the request to the connection manager with a callback adapter
+    [self.connectionManager connectPacketAsync:endpoint
+                                       handler:^(NSNumber *connectionId,
+                                                 NSString *error) {
+        [weakSelf callTarget:@"_GIBWKWebViewBridge.RespondConnectPacket"
+               withArguments:@[sequence,
+                               connectionId,
+                               [error base64EncodedString]]];
+    }];
+}
+
+- (void)connectionReadMessage:(NSNumber *)connectionId
+                 withSequence:(NSNumber *)sequence {
+    // Get a weak reference to self to avoid retain cycles
+    __weak GIBWKWebViewBridge *weakSelf = self;
+
+    // Dispatch the request to the connection manager with a callback adapter
+    [self.connectionManager connectionReadMessageAsync:connectionId
+                                               handler:^(NSData *data,
+                                                         NSString *error) {
+        [weakSelf callTarget:@"_GIBWKWebViewBridge.RespondConnectionReadMessage"
+               withArguments:@[sequence,
+                               [data base64EncodedString],
+                               [error base64EncodedString]]];
+    }];
+}
+
+- (void)connectionWriteMessage:(NSNumber *)connectionId
+                      withData:(NSString *)data64
+                  withSequence:(NSNumber *)sequence {
+    // Get a weak reference to self to avoid retain cycles
+    __weak GIBWKWebViewBridge *weakSelf = self;
+
+    // Dispatch the request to the connection manager with a callback adapter
+    [self.connectionManager connectionWriteMessageAsync:connectionId
+                                                   data:[data64 base64DecodeBytes]
+                                                handler:^(NSNumber *count,
+                                                          NSString *error) {
+        [weakSelf callTarget:@"_GIBWKWebViewBridge.RespondConnectionWriteMessage"
+               withArguments:@[sequence, count, [error base64EncodedString]]];
+    }];
+}
+
+- (void)listenPacket:(NSString *)endpoint withSequence:(NSNumber *)sequence {
+    // Get a weak reference to self to avoid retain cycles
+    __weak GIBWKWebViewBridge *weakSelf = self;
+
+    // Dispatch the request to the connection manager with a callback adapter
+    [self.connectionManager listenPacketAsync:endpoint
+                                      handler:^(NSNumber *listenerId,
+                                                NSString *error) {
+        [weakSelf callTarget:@"_GIBWKWebViewBridge.RespondListenPacket"
+               withArguments:@[sequence,
+                               listenerId,
+                               [error base64EncodedString]]];
+    }];
+}
+
 @end
@@ -17,4 +17,12 @@
 - (instancetype)initWithWebView:(WebView *)webView
           initializationMessage:(NSString *)initializationMessage;
 
+// Designated initializer.  Like initWithWebView:initializationMessage:, but the
+// bridge will only permit connections and listeners on endpoints matching one
+// of the specified patterns (an array of NSString).  Passing nil permits all
+// endpoints.
+- (instancetype)initWithWebView:(WebView *)webView
+          initializationMessage:(NSString *)initializationMessage
+               allowedEndpoints:(NSArray *)allowedEndpoints;
+
 @end
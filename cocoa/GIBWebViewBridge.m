@@ -8,6 +8,15 @@
 
 - (instancetype)initWithWebView:(WebView *)webView
           initializationMessage:(NSString *)initializationMessage {
+    // Call the more general initializer
+    return [self initWithWebView:webView
+           initializationMessage:initializationMessage
+                allowedEndpoints:nil];
+}
+
+- (instancetype)initWithWebView:(WebView *)webView
+          initializationMessage:(NSString *)initializationMessage
+               allowedEndpoints:(NSArray *)allowedEndpoints {
     // Extract the JSContext
     JSContext *context =
         [JSContext
@@ -16,7 +25,8 @@
     // Call the superclass initializer
     return [super initWithJSContext:context
                    interactionQueue:dispatch_get_main_queue()
-              initializationMessage:initializationMessage];
+              initializationMessage:initializationMessage
+                   allowedEndpoints:allowedEndpoints];
 }
 
 @end
@@ -9,7 +9,10 @@ import (
 )
 
 // GopherJSIPCBridge imports
-import ipc "github.com/havoc-io/gopherjsipcbridge/go"
+import (
+	ipc "github.com/havoc-io/gopherjsipcbridge/go"
+	"github.com/havoc-io/gopherjsipcbridge/go/supervisor"
+)
 
 func main() {
 	// Parse command line arguments - there should be only one (aside from the
@@ -19,16 +22,29 @@ func main() {
 		return
 	}
 
-	// Create a listener
+	// Create a listener, reclaiming the socket if a previous instance of the
+	// server crashed and left it behind
 	fmt.Println("Listening on IPC path:", os.Args[1])
-	listener, err := ipc.ListenIPC(os.Args[1])
+	listener, err := ipc.ListenIPCWithOptions(
+		os.Args[1],
+		&ipc.ListenOptions{ReclaimStale: true},
+	)
 	if err != nil {
 		fmt.Println("error: IPC listening failed:", err)
 		return
 	}
 
-	// Print a message to stderr so that the managing process knows it can start
-	// creating bridge instances
+	// If we're being run by a supervisor, report readiness over its control
+	// connection
+	notifier, err := supervisor.NotifyReady(os.Args[1])
+	if err != nil {
+		fmt.Println("error: unable to notify supervisor:", err)
+		return
+	}
+	defer notifier.Close()
+
+	// Print a message to stderr so that managing processes that don't use a
+	// supervisor know they can start creating bridge instances
 	fmt.Fprintln(os.Stderr, "ready")
 
 	// Accept the first connection
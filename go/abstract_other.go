@@ -0,0 +1,7 @@
+// +build !linux,!windows,!js
+
+package ipc
+
+// abstractNamespaceSupported indicates whether or not the platform supports
+// Unix domain sockets in the abstract namespace.
+const abstractNamespaceSupported = false
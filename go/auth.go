@@ -0,0 +1,323 @@
+package ipc
+
+// System imports
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// This file implements a token-based challenge-response authentication layer
+// for IPC connections.  The backend generates a secret token (see
+// GenerateAuthenticationToken), listens with ListenIPCAuthenticated, and hands
+// the token to the host, which passes it to the web view (e.g. as part of the
+// HostInitialize message).  The client then dials with DialIPCAuthenticated.
+// The token itself is never sent over the connection - instead, each side
+// proves knowledge of it by computing an HMAC over random nonces chosen by both
+// sides.  The exchange is:
+//
+//	server -> client: server nonce
+//	client -> server: client nonce, HMAC(token, client label, nonces)
+//	server -> client: HMAC(token, server label, nonces)
+//
+// After a successful exchange, the connection is returned to the caller and
+// carries application data unmodified.  This provides authentication only -
+// see the TLS helpers for encryption.
+
+const (
+	// authenticationNonceLength is the length of the nonces exchanged during
+	// authentication.
+	authenticationNonceLength = 32
+
+	// authenticationTokenLength is the number of random bytes in generated
+	// authentication tokens.
+	authenticationTokenLength = 32
+
+	// authenticationTimeout is the time allowed for a peer to complete the
+	// authentication exchange on platforms that support deadlines.
+	authenticationTimeout = 10 * time.Second
+
+	// authenticationClientLabel and authenticationServerLabel distinguish the
+	// client and server proofs so that one can't be reflected as the other.
+	authenticationClientLabel = "gib-authentication-client"
+	authenticationServerLabel = "gib-authentication-server"
+)
+
+// ErrAuthenticationFailed is returned when the peer fails to prove knowledge
+// of the authentication token.
+var ErrAuthenticationFailed = errors.New("IPC authentication failed")
+
+// ErrAuthenticatedListenerClosed is returned by the Accept method of
+// authenticated listeners once they have been closed.
+var ErrAuthenticatedListenerClosed = errors.New(
+	"authenticated listener closed",
+)
+
+// GenerateAuthenticationToken generates a new random authentication token
+// suitable for passing to the client through the bridge initialization
+// message.
+func GenerateAuthenticationToken() (string, error) {
+	token := make([]byte, authenticationTokenLength)
+	if _, err := io.ReadFull(rand.Reader, token); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(token), nil
+}
+
+// authenticationProof computes the proof for the specified label and nonces.
+func authenticationProof(token, label string, nonces ...[]byte) []byte {
+	mac := hmac.New(sha256.New, []byte(token))
+	mac.Write([]byte(label))
+	for _, nonce := range nonces {
+		mac.Write(nonce)
+	}
+	return mac.Sum(nil)
+}
+
+// newAuthenticationNonce generates a random nonce.
+func newAuthenticationNonce() ([]byte, error) {
+	nonce := make([]byte, authenticationNonceLength)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return nonce, nil
+}
+
+// AuthenticateClient performs the client side of the authentication exchange
+// on an established connection.  If it returns an error, the connection
+// should be closed.
+func AuthenticateClient(connection net.Conn, token string) error {
+	// Receive the server nonce
+	serverNonce := make([]byte, authenticationNonceLength)
+	if _, err := io.ReadFull(connection, serverNonce); err != nil {
+		return err
+	}
+
+	// Generate our nonce and send it along with our proof
+	clientNonce, err := newAuthenticationNonce()
+	if err != nil {
+		return err
+	}
+	message := append(
+		clientNonce,
+		authenticationProof(
+			token,
+			authenticationClientLabel,
+			serverNonce,
+			clientNonce,
+		)...,
+	)
+	if _, err := connection.Write(message); err != nil {
+		return err
+	}
+
+	// Receive and verify the server's proof.  If the server rejected us, it
+	// will have closed the connection.
+	serverProof := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(connection, serverProof); err == io.EOF {
+		return ErrAuthenticationFailed
+	} else if err != nil {
+		return err
+	}
+	expected := authenticationProof(
+		token,
+		authenticationServerLabel,
+		serverNonce,
+		clientNonce,
+	)
+	if !hmac.Equal(serverProof, expected) {
+		return ErrAuthenticationFailed
+	}
+
+	// Success
+	return nil
+}
+
+// AuthenticateServer performs the server side of the authentication exchange
+// on an established connection.  If it returns an error, the connection should
+// be closed.
+func AuthenticateServer(connection net.Conn, token string) error {
+	// Generate and send our nonce
+	serverNonce, err := newAuthenticationNonce()
+	if err != nil {
+		return err
+	}
+	if _, err := connection.Write(serverNonce); err != nil {
+		return err
+	}
+
+	// Receive the client's nonce and proof
+	message := make([]byte, authenticationNonceLength+sha256.Size)
+	if _, err := io.ReadFull(connection, message); err != nil {
+		return err
+	}
+	clientNonce := message[:authenticationNonceLength]
+	clientProof := message[authenticationNonceLength:]
+
+	// Verify the client's proof
+	expected := authenticationProof(
+		token,
+		authenticationClientLabel,
+		serverNonce,
+		clientNonce,
+	)
+	if !hmac.Equal(clientProof, expected) {
+		return ErrAuthenticationFailed
+	}
+
+	// Send our proof
+	_, err = connection.Write(authenticationProof(
+		token,
+		authenticationServerLabel,
+		serverNonce,
+		clientNonce,
+	))
+	return err
+}
+
+// DialIPCAuthenticated establishes a new IPC connection using DialIPC and
+// performs the client side of the authentication exchange on it.
+func DialIPCAuthenticated(endpoint, token string) (net.Conn, error) {
+	// Connect
+	connection, err := DialIPC(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	// Authenticate.  Deadlines aren't supported by all connection types, so
+	// we ignore errors setting them.
+	connection.SetDeadline(time.Now().Add(authenticationTimeout))
+	if err := AuthenticateClient(connection, token); err != nil {
+		connection.Close()
+		return nil, err
+	}
+	connection.SetDeadline(time.Time{})
+
+	// All done
+	return connection, nil
+}
+
+// authenticatedListener wraps a listener to authenticate accepted connections.
+// Authentication is performed concurrently for each connection so that a slow
+// or malicious peer can't block others from connecting.
+type authenticatedListener struct {
+	net.Listener
+
+	// The authentication token
+	token string
+
+	// Authenticated connections
+	connections chan net.Conn
+
+	// Channel closed when the listener is closed
+	closed chan struct{}
+
+	// Ensures that the closed channel is only closed once
+	closeOnce sync.Once
+
+	// The error that terminated the underlying accept loop, set before the
+	// connections channel is closed
+	err error
+}
+
+// NewAuthenticatedListener wraps an existing listener so that Accept only
+// returns connections that have completed the server side of the
+// authentication exchange.  Connections that fail authentication are closed
+// and never returned.
+func NewAuthenticatedListener(listener net.Listener, token string) net.Listener {
+	l := &authenticatedListener{
+		Listener:    listener,
+		token:       token,
+		connections: make(chan net.Conn),
+		closed:      make(chan struct{}),
+	}
+	go l.run()
+	return l
+}
+
+// run accepts connections from the underlying listener and dispatches
+// authentication for each.
+func (l *authenticatedListener) run() {
+	for {
+		// Accept a connection
+		connection, err := l.Listener.Accept()
+		if err != nil {
+			l.err = err
+			close(l.connections)
+			return
+		}
+
+		// Authenticate it in the background
+		go func() {
+			// Perform authentication
+			connection.SetDeadline(time.Now().Add(authenticationTimeout))
+			if AuthenticateServer(connection, l.token) != nil {
+				connection.Close()
+				return
+			}
+			connection.SetDeadline(time.Time{})
+
+			// Hand it off
+			select {
+			case l.connections <- connection:
+			case <-l.closed:
+				connection.Close()
+			}
+		}()
+	}
+}
+
+func (l *authenticatedListener) Accept() (net.Conn, error) {
+	// Fail immediately if the listener is closed, even if authenticated
+	// connections are still waiting to be handed off
+	select {
+	case <-l.closed:
+		return nil, ErrAuthenticatedListenerClosed
+	default:
+	}
+
+	// Wait for an authenticated connection.  Closing the listener also
+	// terminates the underlying accept loop, in which case we report the
+	// closure rather than the underlying error.
+	select {
+	case connection, ok := <-l.connections:
+		if ok {
+			return connection, nil
+		}
+		select {
+		case <-l.closed:
+			return nil, ErrAuthenticatedListenerClosed
+		default:
+			return nil, l.err
+		}
+	case <-l.closed:
+		return nil, ErrAuthenticatedListenerClosed
+	}
+}
+
+func (l *authenticatedListener) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.closed)
+	})
+	return l.Listener.Close()
+}
+
+// ListenIPCAuthenticated establishes a new IPC connection listener using
+// ListenIPC whose accepted connections must complete the authentication
+// exchange before being returned from Accept.
+func ListenIPCAuthenticated(endpoint, token string) (net.Listener, error) {
+	// Create the listener
+	listener, err := ListenIPC(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	// Wrap it
+	return NewAuthenticatedListener(listener, token), nil
+}
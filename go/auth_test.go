@@ -0,0 +1,153 @@
+// +build !windows,!js
+
+package ipc
+
+// System imports
+import (
+	"crypto/sha256"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// testAuthenticationToken is the token used by authentication tests.
+const testAuthenticationToken = "test-token"
+
+// authenticatePipe performs both sides of the authentication exchange over an
+// in-memory pipe, closing the server end if server authentication fails (as
+// authenticated listeners do), and returns the client and server errors.
+func authenticatePipe(clientToken, serverToken string) (error, error) {
+	client, server := net.Pipe()
+	defer client.Close()
+	serverErrors := make(chan error, 1)
+	go func() {
+		err := AuthenticateServer(server, serverToken)
+		if err != nil {
+			server.Close()
+		}
+		serverErrors <- err
+	}()
+	clientErr := AuthenticateClient(client, clientToken)
+	serverErr := <-serverErrors
+	server.Close()
+	return clientErr, serverErr
+}
+
+func TestAuthentication(t *testing.T) {
+	// Matching tokens should succeed
+	if clientErr, serverErr := authenticatePipe("token", "token"); clientErr != nil {
+		t.Error("client authentication failed:", clientErr)
+	} else if serverErr != nil {
+		t.Error("server authentication failed:", serverErr)
+	}
+
+	// A wrong token on either side should fail on both sides
+	for _, tokens := range [][2]string{{"wrong", "token"}, {"token", "wrong"}} {
+		clientErr, serverErr := authenticatePipe(tokens[0], tokens[1])
+		if clientErr != ErrAuthenticationFailed {
+			t.Errorf("tokens %v: unexpected client error: %v", tokens, clientErr)
+		}
+		if serverErr != ErrAuthenticationFailed {
+			t.Errorf("tokens %v: unexpected server error: %v", tokens, serverErr)
+		}
+	}
+}
+
+func TestAuthenticationBadServerProof(t *testing.T) {
+	// Run a server that doesn't know the token and instead responds with
+	// either garbage or the client's own proof reflected back
+	for _, reflect := range []bool{false, true} {
+		client, server := net.Pipe()
+		go func() {
+			defer server.Close()
+			server.Write(make([]byte, authenticationNonceLength))
+			message := make([]byte, authenticationNonceLength+sha256.Size)
+			if _, err := io.ReadFull(server, message); err != nil {
+				return
+			}
+			if reflect {
+				server.Write(message[authenticationNonceLength:])
+			} else {
+				server.Write(make([]byte, sha256.Size))
+			}
+		}()
+		err := AuthenticateClient(client, testAuthenticationToken)
+		client.Close()
+		if err != ErrAuthenticationFailed {
+			t.Errorf("reflect %t: unexpected error: %v", reflect, err)
+		}
+	}
+}
+
+func TestAuthenticatedListener(t *testing.T) {
+	endpoint := testEndpoint(t)
+	listener, err := ListenIPCAuthenticated(endpoint, testAuthenticationToken)
+	if err != nil {
+		t.Fatal("unable to listen:", err)
+	}
+	defer listener.Close()
+	accepted := acceptOne(listener)
+
+	// Connect a silent peer that never authenticates
+	silent, err := DialIPC(endpoint)
+	if err != nil {
+		t.Fatal("unable to connect silent peer:", err)
+	}
+	defer silent.Close()
+
+	// Connect a peer with the wrong token, which should be rejected
+	if connection, err := DialIPCAuthenticated(endpoint, "wrong"); err == nil {
+		connection.Close()
+		t.Fatal("peer with wrong token authenticated")
+	} else if err != ErrAuthenticationFailed {
+		t.Fatal("unexpected error for wrong token:", err)
+	}
+
+	// A legitimate peer should be accepted despite the silent peer, and it
+	// should be the connection returned by Accept
+	connection, err := DialIPCAuthenticated(endpoint, testAuthenticationToken)
+	if err != nil {
+		t.Fatal("unable to authenticate:", err)
+	}
+	defer connection.Close()
+	server := receiveConn(t, accepted)
+	defer server.Close()
+	if _, err := connection.Write([]byte("x")); err != nil {
+		t.Fatal("unable to write:", err)
+	}
+	buffer := make([]byte, 1)
+	server.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.ReadFull(server, buffer); err != nil || buffer[0] != 'x' {
+		t.Fatal("accepted connection isn't the authenticated peer:", err)
+	}
+}
+
+func TestAuthenticatedListenerClose(t *testing.T) {
+	listener, err := ListenIPCAuthenticated(testEndpoint(t), testAuthenticationToken)
+	if err != nil {
+		t.Fatal("unable to listen:", err)
+	}
+
+	// Closing the listener should wake a blocked Accept
+	errs := make(chan error, 1)
+	go func() {
+		_, err := listener.Accept()
+		errs <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	listener.Close()
+	select {
+	case err := <-errs:
+		if err != ErrAuthenticatedListenerClosed {
+			t.Fatal("unexpected error from blocked accept:", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("blocked accept not woken by close")
+	}
+
+	// Subsequent accepts should fail immediately
+	if _, err := listener.Accept(); err != ErrAuthenticatedListenerClosed {
+		t.Fatal("unexpected error from accept after close:", err)
+	}
+}
@@ -0,0 +1,119 @@
+package ipc
+
+// The forwarding functions below implement the common body of Bridge wrappers
+// that observe results: each returns a channel that receives the result from
+// an underlying bridge's channel after it has been passed to an observer.  The
+// observer runs on a separate goroutine, so the caller is never blocked
+// waiting for the result.
+
+// forwardConnect is the forwarding function for ConnectResult.
+func forwardConnect(
+	innerChannel chan ConnectResult,
+	observe func(ConnectResult),
+) chan ConnectResult {
+	resultChannel := make(chan ConnectResult, 1)
+	go func() {
+		result := <-innerChannel
+		observe(result)
+		resultChannel <- result
+	}()
+	return resultChannel
+}
+
+// forwardConnectionRead is the forwarding function for ConnectionReadResult.
+func forwardConnectionRead(
+	innerChannel chan ConnectionReadResult,
+	observe func(ConnectionReadResult),
+) chan ConnectionReadResult {
+	resultChannel := make(chan ConnectionReadResult, 1)
+	go func() {
+		result := <-innerChannel
+		observe(result)
+		resultChannel <- result
+	}()
+	return resultChannel
+}
+
+// forwardConnectionWrite is the forwarding function for ConnectionWriteResult.
+func forwardConnectionWrite(
+	innerChannel chan ConnectionWriteResult,
+	observe func(ConnectionWriteResult),
+) chan ConnectionWriteResult {
+	resultChannel := make(chan ConnectionWriteResult, 1)
+	go func() {
+		result := <-innerChannel
+		observe(result)
+		resultChannel <- result
+	}()
+	return resultChannel
+}
+
+// forwardConnectionClose is the forwarding function for ConnectionCloseResult.
+func forwardConnectionClose(
+	innerChannel chan ConnectionCloseResult,
+	observe func(ConnectionCloseResult),
+) chan ConnectionCloseResult {
+	resultChannel := make(chan ConnectionCloseResult, 1)
+	go func() {
+		result := <-innerChannel
+		observe(result)
+		resultChannel <- result
+	}()
+	return resultChannel
+}
+
+// forwardListen is the forwarding function for ListenResult.
+func forwardListen(
+	innerChannel chan ListenResult,
+	observe func(ListenResult),
+) chan ListenResult {
+	resultChannel := make(chan ListenResult, 1)
+	go func() {
+		result := <-innerChannel
+		observe(result)
+		resultChannel <- result
+	}()
+	return resultChannel
+}
+
+// forwardListenerAccept is the forwarding function for ListenerAcceptResult.
+func forwardListenerAccept(
+	innerChannel chan ListenerAcceptResult,
+	observe func(ListenerAcceptResult),
+) chan ListenerAcceptResult {
+	resultChannel := make(chan ListenerAcceptResult, 1)
+	go func() {
+		result := <-innerChannel
+		observe(result)
+		resultChannel <- result
+	}()
+	return resultChannel
+}
+
+// forwardListenerClose is the forwarding function for ListenerCloseResult.
+func forwardListenerClose(
+	innerChannel chan ListenerCloseResult,
+	observe func(ListenerCloseResult),
+) chan ListenerCloseResult {
+	resultChannel := make(chan ListenerCloseResult, 1)
+	go func() {
+		result := <-innerChannel
+		observe(result)
+		resultChannel <- result
+	}()
+	return resultChannel
+}
+
+// forwardPing is the forwarding function for PingResult.
+func forwardPing(
+	innerChannel chan PingResult,
+	observe func(PingResult),
+) chan PingResult {
+	resultChannel := make(chan PingResult, 1)
+	go func() {
+		result := <-innerChannel
+		observe(result)
+		resultChannel <- result
+	}()
+	return resultChannel
+}
@@ -2,47 +2,11 @@
 
 package ipc
 
-// ConnectResult represents the result from a connect operation.
-type ConnectResult struct {
-    connectionId int
-    err error
-}
-
-// ConnectionReadResult represents the result from a connection read operation.
-type ConnectionReadResult struct {
-    data []byte
-    err error
-}
-
-// ConnectionWriteResult represents the result from a connection write
-// operation.
-type ConnectionWriteResult struct {
-    count int
-    err error
-}
-
-// ConnectionCloseResult represents the result from a connection close
-// operation.
-type ConnectionCloseResult struct {
-    err error
-}
-
-// ListenResult represents the result from a listen operation.
-type ListenResult struct {
-    listenerId int
-    err error
-}
-
-// ListenerAcceptResult represents the result from a listener accept operation.
-type ListenerAcceptResult struct {
-    connectionId int
-    err error
-}
-
-// ListenerCloseResult represents the result from a listener close operation.
-type ListenerCloseResult struct {
-    err error
-}
+// System imports
+import (
+	"io"
+	"time"
+)
 
 // Bridge represents the GopherJS interface to the host environment's connection
 // management facilities.  All methods are asynchronous, and the underlying
@@ -50,7 +14,7 @@ type ListenerCloseResult struct {
 // the bridge implementation.
 type Bridge interface {
 	// Connect requests that an IPC connection be made to the specified endpoint
-	// (either a socket path or named pipe name).
+	// (either a socket path, named pipe name, or logical endpoint).
 	Connect(endpoint string) chan ConnectResult
 
 	// ConnectionRead requests that data be read from an IPC connection.  The
@@ -65,8 +29,17 @@ type Bridge interface {
 	// are those of net.Conn.Close.
 	ConnectionClose(connectionId int) chan ConnectionCloseResult
 
+	// ConnectionCloseRead requests that the read side of an IPC connection be
+	// shut down.  The semantics are those of net.UnixConn.CloseRead.
+	ConnectionCloseRead(connectionId int) chan ConnectionCloseResult
+
+	// ConnectionCloseWrite requests that the write side of an IPC connection be
+	// shut down, signaling end-of-stream to the peer.  The semantics are those
+	// of net.UnixConn.CloseWrite.
+	ConnectionCloseWrite(connectionId int) chan ConnectionCloseResult
+
 	// Listen requests that an IPC listener be established on the specified
-	// endpoint (either a socket path or a named pipe name).
+	// endpoint (either a socket path, named pipe name, or logical endpoint).
 	Listen(endpoint string) chan ListenResult
 
 	// ListenerAccept requests that an IPC listener accept a connection.  The
@@ -76,6 +49,47 @@ type Bridge interface {
 	// ListenerClose requests that an IPC listener be closed.  The semantics
 	// are those of net.Listener.Close.
 	ListenerClose(listenerId int) chan ListenerCloseResult
+
+	// Ping requests that the host respond immediately, without performing any
+	// I/O, and measures the round-trip time.  It is used for health checks and
+	// for measuring bridge latency independently of socket I/O.
+	Ping() chan PingResult
+
+	// ConnectPacket requests that a message-oriented IPC connection be made to
+	// the specified endpoint.  The semantics are those of DialIPCPacket.  The
+	// resulting connection is read and written using ConnectionReadMessage and
+	// ConnectionWriteMessage and closed using ConnectionClose.
+	ConnectPacket(endpoint string) chan ConnectResult
+
+	// ConnectionReadMessage requests that a single message be read from a
+	// message-oriented IPC connection.  The semantics are those of
+	// PacketConn.ReadMessage.
+	ConnectionReadMessage(connectionId int) chan ConnectionReadResult
+
+	// ConnectionWriteMessage requests that a single message be written to a
+	// message-oriented IPC connection.  The semantics are those of
+	// PacketConn.WriteMessage.
+	ConnectionWriteMessage(
+		connectionId int,
+		data []byte,
+	) chan ConnectionWriteResult
+
+	// ListenPacket requests that a message-oriented IPC listener be established
+	// on the specified endpoint.  The semantics are those of ListenIPCPacket.
+	// Connections are accepted using ListenerAccept and the listener is closed
+	// using ListenerClose.
+	ListenPacket(endpoint string) chan ListenResult
+}
+
+// errorHandlingBridge is implemented by bridges with configurable error
+// handlers.
+type errorHandlingBridge interface {
+	SetErrorHandler(ErrorHandler)
+}
+
+// timeoutBridge is implemented by bridges with configurable request timeouts.
+type timeoutBridge interface {
+	SetRequestTimeout(time.Duration)
 }
 
 // Global variables used by the package.
@@ -86,6 +100,53 @@ var global struct {
 	// The control channel used to send the initialization message and shutdown
 	// signal
 	controlChannel chan string
+
+	// The client options, if any
+	options *ClientOptions
+}
+
+// ClientOptions configures the bridge installed by HostInitialize.  The host's
+// bridge is wrapped with the facilities requested here before being used by the
+// connection/listener API.
+type ClientOptions struct {
+	// Policy, if non-nil, restricts the endpoints that may be connected to or
+	// listened on.  The bridge is wrapped in a PolicyBridge, so requests for
+	// endpoints outside of the policy fail with ErrEndpointNotPermitted.
+	Policy *EndpointPolicy
+
+	// Metrics, if non-nil, receives metrics for all bridge requests.  The
+	// bridge is wrapped in a MetricsBridge.  Requests rejected by Policy are
+	// included.
+	Metrics *Registry
+
+	// Tracer, if non-nil, receives start and finish events for all bridge
+	// requests.  The bridge is wrapped in a TracingBridge.
+	Tracer Tracer
+
+	// Recording, if non-nil, receives a recording of all traffic between the
+	// client and the host.  The host's bridge is wrapped in a RecordingBridge
+	// before any other wrappers are applied, so requests rejected by Policy
+	// never appear in the recording.
+	Recording io.Writer
+
+	// ErrorHandler, if non-nil, is installed as the error handler of host
+	// bridges that support one (see WKWebViewBridge.SetErrorHandler).
+	ErrorHandler ErrorHandler
+
+	// RequestTimeout, if non-zero, is installed as the request timeout of host
+	// bridges that support one (see WKWebViewBridge.SetRequestTimeout), so
+	// requests that exceed it fail with ErrRequestTimeout.
+	RequestTimeout time.Duration
+
+	// WatchdogLimit, if non-zero, is the time limit for the host to respond to
+	// requests before the bridge is considered unhealthy.  The bridge is
+	// wrapped in a WatchdogBridge, which doesn't fail slow requests.
+	WatchdogLimit time.Duration
+
+	// OnUnhealthy, if non-nil, is invoked with the operation name of the first
+	// request to exceed WatchdogLimit.  It is invoked at most once, on its own
+	// goroutine.
+	OnUnhealthy func(operation string)
 }
 
 // ClientInitialize starts the IPC bridge initialization sequence, and should be
@@ -94,6 +155,16 @@ var global struct {
 // single initialization message after bridge initialization is complete and
 // will be closed when bridge shutdown begins.
 func ClientInitialize() chan string {
+	return ClientInitializeWithOptions(nil)
+}
+
+// ClientInitializeWithOptions is like ClientInitialize, but additionally
+// configures the bridge installed by HostInitialize.  Nil options are
+// equivalent to ClientInitialize.
+func ClientInitializeWithOptions(options *ClientOptions) chan string {
+	// Store the options
+	global.options = options
+
 	// Create the control channel with a single-item buffer so the
 	// HostInitialize function doesn't block
 	global.controlChannel = make(chan string, 1)
@@ -109,6 +180,39 @@ func ClientInitialize() chan string {
 // can be invoked from JavaScript and will create an instance of the bridge
 // implementation to pass to this function.
 func HostInitialize(bridge Bridge, message string) {
+	// Wrap the bridge as requested by the client options
+	if options := global.options; options != nil {
+		if options.ErrorHandler != nil {
+			if b, ok := bridge.(errorHandlingBridge); ok {
+				b.SetErrorHandler(options.ErrorHandler)
+			}
+		}
+		if options.RequestTimeout != 0 {
+			if b, ok := bridge.(timeoutBridge); ok {
+				b.SetRequestTimeout(options.RequestTimeout)
+			}
+		}
+		if options.Recording != nil {
+			bridge = NewRecordingBridge(bridge, options.Recording)
+		}
+		if options.Policy != nil {
+			bridge = NewPolicyBridge(bridge, options.Policy)
+		}
+		if options.WatchdogLimit != 0 {
+			bridge = NewWatchdogBridge(
+				bridge,
+				options.WatchdogLimit,
+				options.OnUnhealthy,
+			)
+		}
+		if options.Metrics != nil {
+			bridge = NewMetricsBridge(bridge, options.Metrics)
+		}
+		if options.Tracer != nil {
+			bridge = NewTracingBridge(bridge, options.Tracer)
+		}
+	}
+
 	// Set the global bridge
 	global.bridge = bridge
 
@@ -5,6 +5,7 @@ package ipc
 // System imports
 import (
 	"encoding/base64"
+	"time"
 )
 
 // GopherJS imports
@@ -67,16 +68,18 @@ func (b *JSContextBridge) ConnectionRead(
 		connectionId,
 		length,
 		func (data64, errorMessage string) {
-			// Decode the data
-			data, err := base64.StdEncoding.DecodeString(data64)
-			if err != nil {
-				panic("host sent gibberish data")
+			// Decode the data.  If it's invalid, the connection's stream is
+			// corrupt, so report that in preference to any host error.
+			err := ErrorFromErrorMessage(errorMessage)
+			data, decodeErr := decodeResponseData(data64)
+			if decodeErr != nil {
+				err = decodeErr
 			}
 
 			// Create and send the result
 			resultChannel <- ConnectionReadResult{
 				data: data,
-				err: ErrorFromErrorMessage(errorMessage),
+				err: err,
 			}
 		},
 	)
@@ -135,6 +138,50 @@ func (b *JSContextBridge) ConnectionClose(
 	return resultChannel
 }
 
+func (b *JSContextBridge) ConnectionCloseRead(
+	connectionId int,
+) chan ConnectionCloseResult {
+	// Create a buffered (non-blocking) result channel
+	resultChannel := make(chan ConnectionCloseResult, 1)
+
+	// Forward the request to the host with a callback it can use to write to
+	// the result channel
+	b.hostProxy.Call(
+		"connectionCloseReadWithCallback",
+		connectionId,
+		func (errorMessage string) {
+			resultChannel <- ConnectionCloseResult{
+				err: ErrorFromErrorMessage(errorMessage),
+			}
+		},
+	)
+
+	// Return the result channel for the caller to wait on
+	return resultChannel
+}
+
+func (b *JSContextBridge) ConnectionCloseWrite(
+	connectionId int,
+) chan ConnectionCloseResult {
+	// Create a buffered (non-blocking) result channel
+	resultChannel := make(chan ConnectionCloseResult, 1)
+
+	// Forward the request to the host with a callback it can use to write to
+	// the result channel
+	b.hostProxy.Call(
+		"connectionCloseWriteWithCallback",
+		connectionId,
+		func (errorMessage string) {
+			resultChannel <- ConnectionCloseResult{
+				err: ErrorFromErrorMessage(errorMessage),
+			}
+		},
+	)
+
+	// Return the result channel for the caller to wait on
+	return resultChannel
+}
+
 func (b *JSContextBridge) Listen(endpoint string) chan ListenResult {
 	// Create a buffered (non-blocking) result channel
 	resultChannel := make(chan ListenResult, 1)
@@ -200,3 +247,126 @@ func (b *JSContextBridge) ListenerClose(
 	// Return the result channel for the caller to wait on
 	return resultChannel
 }
+
+func (b *JSContextBridge) Ping() chan PingResult {
+	// Create a buffered (non-blocking) result channel
+	resultChannel := make(chan PingResult, 1)
+
+	// Forward the request to the host with a callback it can use to write to
+	// the result channel
+	start := time.Now()
+	b.hostProxy.Call(
+		"pingWithCallback",
+		func (errorMessage string) {
+			resultChannel <- PingResult{
+				roundTrip: time.Since(start),
+				err: ErrorFromErrorMessage(errorMessage),
+			}
+		},
+	)
+
+	// Return the result channel for the caller to wait on
+	return resultChannel
+}
+
+func (b *JSContextBridge) ConnectPacket(endpoint string) chan ConnectResult {
+	// Create a buffered (non-blocking) result channel
+	resultChannel := make(chan ConnectResult, 1)
+
+	// Forward the request to the host with a callback it can use to write to
+	// the result channel
+	b.hostProxy.Call(
+		"connectPacketWithCallback",
+		endpoint,
+		func (connectionId int, errorMessage string) {
+			resultChannel <- ConnectResult{
+				connectionId: connectionId,
+				err: ErrorFromErrorMessage(errorMessage),
+			}
+		},
+	)
+
+	// Return the result channel for the caller to wait on
+	return resultChannel
+}
+
+func (b *JSContextBridge) ConnectionReadMessage(
+	connectionId int,
+) chan ConnectionReadResult {
+	// Create a buffered (non-blocking) result channel
+	resultChannel := make(chan ConnectionReadResult, 1)
+
+	// Forward the request to the host with a callback it can use to write to
+	// the result channel
+	b.hostProxy.Call(
+		"connectionReadMessageWithCallback",
+		connectionId,
+		func (data64, errorMessage string) {
+			// Decode the message.  If it's invalid, report that in preference
+			// to any host error.
+			err := ErrorFromErrorMessage(errorMessage)
+			data, decodeErr := decodeResponseData(data64)
+			if decodeErr != nil {
+				err = decodeErr
+			}
+
+			// Create and send the result
+			resultChannel <- ConnectionReadResult{
+				data: data,
+				err: err,
+			}
+		},
+	)
+
+	// Return the result channel for the caller to wait on
+	return resultChannel
+}
+
+func (b *JSContextBridge) ConnectionWriteMessage(
+	connectionId int,
+	data []byte,
+) chan ConnectionWriteResult {
+	// Create a buffered (non-blocking) result channel
+	resultChannel := make(chan ConnectionWriteResult, 1)
+
+	// Encode the data
+	data64 := base64.StdEncoding.EncodeToString(data)
+
+	// Forward the request to the host with a callback it can use to write to
+	// the result channel
+	b.hostProxy.Call(
+		"connectionWriteMessageWithDataWithCallback",
+		connectionId,
+		data64,
+		func (count int, errorMessage string) {
+			resultChannel <- ConnectionWriteResult{
+				count: count,
+				err: ErrorFromErrorMessage(errorMessage),
+			}
+		},
+	)
+
+	// Return the result channel for the caller to wait on
+	return resultChannel
+}
+
+func (b *JSContextBridge) ListenPacket(endpoint string) chan ListenResult {
+	// Create a buffered (non-blocking) result channel
+	resultChannel := make(chan ListenResult, 1)
+
+	// Forward the request to the host with a callback it can use to write to
+	// the result channel
+	b.hostProxy.Call(
+		"listenPacketWithCallback",
+		endpoint,
+		func (listenerId int, errorMessage string) {
+			resultChannel <- ListenResult{
+				listenerId: listenerId,
+				err: ErrorFromErrorMessage(errorMessage),
+			}
+		},
+	)
+
+	// Return the result channel for the caller to wait on
+	return resultChannel
+}
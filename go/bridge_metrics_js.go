@@ -0,0 +1,323 @@
+// +build js
+
+package ipc
+
+// System imports
+import "time"
+
+// MetricsBridge wraps another Bridge implementation and records metrics for
+// every request in a Registry: operation counts, errors by kind, latency
+// histograms, in-flight requests, connection lifecycle counts, and bytes
+// transferred.  It is installed automatically by HostInitialize when a registry
+// is provided in ClientOptions, but it can also be used directly.
+type MetricsBridge struct {
+	// The underlying bridge
+	bridge Bridge
+
+	// The registry receiving metrics
+	registry *Registry
+}
+
+// NewMetricsBridge creates a new MetricsBridge that records metrics for
+// requests to the specified bridge in the specified registry (or
+// DefaultRegistry if nil).
+func NewMetricsBridge(bridge Bridge, registry *Registry) *MetricsBridge {
+	// Use the default registry if none was specified
+	if registry == nil {
+		registry = DefaultRegistry
+	}
+
+	// Create the bridge
+	return &MetricsBridge{
+		bridge: bridge,
+		registry: registry,
+	}
+}
+
+func (b *MetricsBridge) Connect(endpoint string) chan ConnectResult {
+	// Dispatch the request
+	start := time.Now()
+	b.registry.startOperation()
+	innerChannel := b.bridge.Connect(endpoint)
+
+	// Record the result when it arrives and forward it
+	return forwardConnect(innerChannel, func(result ConnectResult) {
+		b.registry.finishOperation(OperationConnect, start, result.err)
+		if result.err == nil {
+			b.registry.connectionOpened()
+		}
+	})
+}
+
+func (b *MetricsBridge) ConnectionRead(
+	connectionId,
+	length int,
+) chan ConnectionReadResult {
+	// Dispatch the request
+	start := time.Now()
+	b.registry.startOperation()
+	innerChannel := b.bridge.ConnectionRead(connectionId, length)
+
+	// Record the result when it arrives and forward it
+	return forwardConnectionRead(
+		innerChannel,
+		func(result ConnectionReadResult) {
+			b.registry.finishOperation(
+				OperationConnectionRead,
+				start,
+				result.err,
+			)
+			b.registry.connections.recordRead(
+				len(result.data),
+				result.err,
+			)
+		},
+	)
+}
+
+func (b *MetricsBridge) ConnectionWrite(
+	connectionId int,
+	data []byte,
+) chan ConnectionWriteResult {
+	// Dispatch the request
+	start := time.Now()
+	b.registry.startOperation()
+	innerChannel := b.bridge.ConnectionWrite(connectionId, data)
+
+	// Record the result when it arrives and forward it
+	return forwardConnectionWrite(
+		innerChannel,
+		func(result ConnectionWriteResult) {
+			b.registry.finishOperation(
+				OperationConnectionWrite,
+				start,
+				result.err,
+			)
+			b.registry.connections.recordWrite(
+				result.count,
+				result.err,
+			)
+		},
+	)
+}
+
+func (b *MetricsBridge) ConnectionClose(
+	connectionId int,
+) chan ConnectionCloseResult {
+	// Dispatch the request
+	start := time.Now()
+	b.registry.startOperation()
+	innerChannel := b.bridge.ConnectionClose(connectionId)
+
+	// Record the result when it arrives and forward it
+	return forwardConnectionClose(
+		innerChannel,
+		func(result ConnectionCloseResult) {
+			b.registry.finishOperation(
+				OperationConnectionClose,
+				start,
+				result.err,
+			)
+			if result.err == nil {
+				b.registry.connectionClosed()
+			}
+		},
+	)
+}
+
+func (b *MetricsBridge) ConnectionCloseRead(
+	connectionId int,
+) chan ConnectionCloseResult {
+	// Dispatch the request
+	start := time.Now()
+	b.registry.startOperation()
+	innerChannel := b.bridge.ConnectionCloseRead(connectionId)
+
+	// Record the result when it arrives and forward it
+	return forwardConnectionClose(
+		innerChannel,
+		func(result ConnectionCloseResult) {
+			b.registry.finishOperation(
+				OperationConnectionCloseRead,
+				start,
+				result.err,
+			)
+		},
+	)
+}
+
+func (b *MetricsBridge) ConnectionCloseWrite(
+	connectionId int,
+) chan ConnectionCloseResult {
+	// Dispatch the request
+	start := time.Now()
+	b.registry.startOperation()
+	innerChannel := b.bridge.ConnectionCloseWrite(connectionId)
+
+	// Record the result when it arrives and forward it
+	return forwardConnectionClose(
+		innerChannel,
+		func(result ConnectionCloseResult) {
+			b.registry.finishOperation(
+				OperationConnectionCloseWrite,
+				start,
+				result.err,
+			)
+		},
+	)
+}
+
+func (b *MetricsBridge) Listen(endpoint string) chan ListenResult {
+	// Dispatch the request
+	start := time.Now()
+	b.registry.startOperation()
+	innerChannel := b.bridge.Listen(endpoint)
+
+	// Record the result when it arrives and forward it
+	return forwardListen(innerChannel, func(result ListenResult) {
+		b.registry.finishOperation(OperationListen, start, result.err)
+	})
+}
+
+func (b *MetricsBridge) ListenerAccept(
+	listenerId int,
+) chan ListenerAcceptResult {
+	// Dispatch the request
+	start := time.Now()
+	b.registry.startOperation()
+	innerChannel := b.bridge.ListenerAccept(listenerId)
+
+	// Record the result when it arrives and forward it
+	return forwardListenerAccept(
+		innerChannel,
+		func(result ListenerAcceptResult) {
+			b.registry.finishOperation(
+				OperationListenerAccept,
+				start,
+				result.err,
+			)
+			if result.err == nil {
+				b.registry.connectionOpened()
+			}
+		},
+	)
+}
+
+func (b *MetricsBridge) ListenerClose(
+	listenerId int,
+) chan ListenerCloseResult {
+	// Dispatch the request
+	start := time.Now()
+	b.registry.startOperation()
+	innerChannel := b.bridge.ListenerClose(listenerId)
+
+	// Record the result when it arrives and forward it
+	return forwardListenerClose(
+		innerChannel,
+		func(result ListenerCloseResult) {
+			b.registry.finishOperation(
+				OperationListenerClose,
+				start,
+				result.err,
+			)
+		},
+	)
+}
+
+func (b *MetricsBridge) Ping() chan PingResult {
+	// Dispatch the request
+	start := time.Now()
+	b.registry.startOperation()
+	innerChannel := b.bridge.Ping()
+
+	// Record the result when it arrives and forward it
+	return forwardPing(innerChannel, func(result PingResult) {
+		b.registry.finishOperation(OperationPing, start, result.err)
+	})
+}
+
+func (b *MetricsBridge) ConnectPacket(endpoint string) chan ConnectResult {
+	// Dispatch the request
+	start := time.Now()
+	b.registry.startOperation()
+	innerChannel := b.bridge.ConnectPacket(endpoint)
+
+	// Record the result when it arrives and forward it
+	return forwardConnect(innerChannel, func(result ConnectResult) {
+		b.registry.finishOperation(
+			OperationConnectPacket,
+			start,
+			result.err,
+		)
+		if result.err == nil {
+			b.registry.connectionOpened()
+		}
+	})
+}
+
+func (b *MetricsBridge) ConnectionReadMessage(
+	connectionId int,
+) chan ConnectionReadResult {
+	// Dispatch the request
+	start := time.Now()
+	b.registry.startOperation()
+	innerChannel := b.bridge.ConnectionReadMessage(connectionId)
+
+	// Record the result when it arrives and forward it
+	return forwardConnectionRead(
+		innerChannel,
+		func(result ConnectionReadResult) {
+			b.registry.finishOperation(
+				OperationConnectionReadMessage,
+				start,
+				result.err,
+			)
+			b.registry.connections.recordRead(
+				len(result.data),
+				result.err,
+			)
+		},
+	)
+}
+
+func (b *MetricsBridge) ConnectionWriteMessage(
+	connectionId int,
+	data []byte,
+) chan ConnectionWriteResult {
+	// Dispatch the request
+	start := time.Now()
+	b.registry.startOperation()
+	innerChannel := b.bridge.ConnectionWriteMessage(connectionId, data)
+
+	// Record the result when it arrives and forward it
+	return forwardConnectionWrite(
+		innerChannel,
+		func(result ConnectionWriteResult) {
+			b.registry.finishOperation(
+				OperationConnectionWriteMessage,
+				start,
+				result.err,
+			)
+			b.registry.connections.recordWrite(
+				result.count,
+				result.err,
+			)
+		},
+	)
+}
+
+func (b *MetricsBridge) ListenPacket(endpoint string) chan ListenResult {
+	// Dispatch the request
+	start := time.Now()
+	b.registry.startOperation()
+	innerChannel := b.bridge.ListenPacket(endpoint)
+
+	// Record the result when it arrives and forward it
+	return forwardListen(innerChannel, func(result ListenResult) {
+		b.registry.finishOperation(
+			OperationListenPacket,
+			start,
+			result.err,
+		)
+	})
+}
@@ -0,0 +1,150 @@
+// +build js
+
+package ipc
+
+// PolicyBridge wraps another Bridge implementation and rejects connect and
+// listen requests for endpoints not permitted by an EndpointPolicy, without
+// ever forwarding them to the host.  All other requests are passed through
+// unmodified.  It is installed automatically by HostInitialize when a policy
+// is provided in ClientOptions, but it can also be used directly.
+// Since scripts in the web view can bypass the GopherJS side and message the
+// host directly, hosts should be configured with the same allowlist - this
+// wrapper simply catches violations early and gives GopherJS code a consistent
+// view of the policy.
+type PolicyBridge struct {
+	// The underlying bridge
+	bridge Bridge
+
+	// The endpoint policy
+	policy *EndpointPolicy
+}
+
+// NewPolicyBridge creates a new PolicyBridge that enforces the specified policy
+// on requests to the specified bridge.
+func NewPolicyBridge(bridge Bridge, policy *EndpointPolicy) *PolicyBridge {
+	return &PolicyBridge{
+		bridge: bridge,
+		policy: policy,
+	}
+}
+
+func (b *PolicyBridge) Connect(endpoint string) chan ConnectResult {
+	// If the endpoint is permitted, forward the request
+	if b.policy.Permits(endpoint) {
+		return b.bridge.Connect(endpoint)
+	}
+
+	// Otherwise respond immediately with a permission error
+	resultChannel := make(chan ConnectResult, 1)
+	resultChannel <- ConnectResult{
+		connectionId: -1,
+		err: ErrEndpointNotPermitted,
+	}
+	return resultChannel
+}
+
+func (b *PolicyBridge) ConnectionRead(
+	connectionId,
+	length int,
+) chan ConnectionReadResult {
+	return b.bridge.ConnectionRead(connectionId, length)
+}
+
+func (b *PolicyBridge) ConnectionWrite(
+	connectionId int,
+	data []byte,
+) chan ConnectionWriteResult {
+	return b.bridge.ConnectionWrite(connectionId, data)
+}
+
+func (b *PolicyBridge) ConnectionClose(
+	connectionId int,
+) chan ConnectionCloseResult {
+	return b.bridge.ConnectionClose(connectionId)
+}
+
+func (b *PolicyBridge) ConnectionCloseRead(
+	connectionId int,
+) chan ConnectionCloseResult {
+	return b.bridge.ConnectionCloseRead(connectionId)
+}
+
+func (b *PolicyBridge) ConnectionCloseWrite(
+	connectionId int,
+) chan ConnectionCloseResult {
+	return b.bridge.ConnectionCloseWrite(connectionId)
+}
+
+func (b *PolicyBridge) Listen(endpoint string) chan ListenResult {
+	// If the endpoint is permitted, forward the request
+	if b.policy.Permits(endpoint) {
+		return b.bridge.Listen(endpoint)
+	}
+
+	// Otherwise respond immediately with a permission error
+	resultChannel := make(chan ListenResult, 1)
+	resultChannel <- ListenResult{
+		listenerId: -1,
+		err: ErrEndpointNotPermitted,
+	}
+	return resultChannel
+}
+
+func (b *PolicyBridge) ListenerAccept(
+	listenerId int,
+) chan ListenerAcceptResult {
+	return b.bridge.ListenerAccept(listenerId)
+}
+
+func (b *PolicyBridge) ListenerClose(
+	listenerId int,
+) chan ListenerCloseResult {
+	return b.bridge.ListenerClose(listenerId)
+}
+
+func (b *PolicyBridge) Ping() chan PingResult {
+	return b.bridge.Ping()
+}
+
+func (b *PolicyBridge) ConnectPacket(endpoint string) chan ConnectResult {
+	// If the endpoint is permitted, forward the request
+	if b.policy.Permits(endpoint) {
+		return b.bridge.ConnectPacket(endpoint)
+	}
+
+	// Otherwise respond immediately with a permission error
+	resultChannel := make(chan ConnectResult, 1)
+	resultChannel <- ConnectResult{
+		connectionId: -1,
+		err: ErrEndpointNotPermitted,
+	}
+	return resultChannel
+}
+
+func (b *PolicyBridge) ConnectionReadMessage(
+	connectionId int,
+) chan ConnectionReadResult {
+	return b.bridge.ConnectionReadMessage(connectionId)
+}
+
+func (b *PolicyBridge) ConnectionWriteMessage(
+	connectionId int,
+	data []byte,
+) chan ConnectionWriteResult {
+	return b.bridge.ConnectionWriteMessage(connectionId, data)
+}
+
+func (b *PolicyBridge) ListenPacket(endpoint string) chan ListenResult {
+	// If the endpoint is permitted, forward the request
+	if b.policy.Permits(endpoint) {
+		return b.bridge.ListenPacket(endpoint)
+	}
+
+	// Otherwise respond immediately with a permission error
+	resultChannel := make(chan ListenResult, 1)
+	resultChannel <- ListenResult{
+		listenerId: -1,
+		err: ErrEndpointNotPermitted,
+	}
+	return resultChannel
+}
@@ -0,0 +1,709 @@
+// +build js
+
+package ipc
+
+// System imports
+import "io"
+
+// RecordingBridge wraps another Bridge implementation and records every
+// request and response to a writer (see BridgeRecord for the format).  It is
+// installed automatically by HostInitialize when a writer is provided in
+// ClientOptions, in which case it sits directly on top of the host's bridge so
+// that it records exactly what the host saw.  It can also be used directly.
+// Recordings can be fed back with ReplayBridge.
+type RecordingBridge struct {
+	// The underlying bridge
+	bridge Bridge
+
+	// The record writer
+	writer *bridgeRecordWriter
+}
+
+// NewRecordingBridge creates a new RecordingBridge that records requests to
+// the specified bridge (and their responses) to the specified writer.
+func NewRecordingBridge(bridge Bridge, writer io.Writer) *RecordingBridge {
+	return &RecordingBridge{
+		bridge: bridge,
+		writer: newBridgeRecordWriter(writer),
+	}
+}
+
+// Err returns the first error encountered while writing the recording, if
+// any.  Recording stops after an error, but requests continue to be forwarded.
+func (b *RecordingBridge) Err() error {
+	return b.writer.failure()
+}
+
+func (b *RecordingBridge) Connect(endpoint string) chan ConnectResult {
+	// Record and dispatch the request
+	sequence := b.writer.request(&BridgeRecord{
+		Operation: OperationConnect,
+		Endpoint: endpoint,
+	})
+	innerChannel := b.bridge.Connect(endpoint)
+
+	// Record the response when it arrives and forward it
+	return forwardConnect(innerChannel, func(result ConnectResult) {
+		b.writer.response(sequence, &BridgeRecord{
+			Operation: OperationConnect,
+			ConnectionId: result.connectionId,
+			Error: errorMessage(result.err),
+		})
+	})
+}
+
+func (b *RecordingBridge) ConnectionRead(
+	connectionId,
+	length int,
+) chan ConnectionReadResult {
+	// Record and dispatch the request
+	sequence := b.writer.request(&BridgeRecord{
+		Operation: OperationConnectionRead,
+		ConnectionId: connectionId,
+		Length: length,
+	})
+	innerChannel := b.bridge.ConnectionRead(connectionId, length)
+
+	// Record the response when it arrives and forward it
+	return forwardConnectionRead(
+		innerChannel,
+		func(result ConnectionReadResult) {
+			b.writer.response(sequence, &BridgeRecord{
+				Operation: OperationConnectionRead,
+				Data: result.data,
+				Error: errorMessage(result.err),
+			})
+		},
+	)
+}
+
+func (b *RecordingBridge) ConnectionWrite(
+	connectionId int,
+	data []byte,
+) chan ConnectionWriteResult {
+	// Record and dispatch the request
+	sequence := b.writer.request(&BridgeRecord{
+		Operation: OperationConnectionWrite,
+		ConnectionId: connectionId,
+		Data: data,
+	})
+	innerChannel := b.bridge.ConnectionWrite(connectionId, data)
+
+	// Record the response when it arrives and forward it
+	return forwardConnectionWrite(
+		innerChannel,
+		func(result ConnectionWriteResult) {
+			b.writer.response(sequence, &BridgeRecord{
+				Operation: OperationConnectionWrite,
+				Count: result.count,
+				Error: errorMessage(result.err),
+			})
+		},
+	)
+}
+
+func (b *RecordingBridge) ConnectionClose(
+	connectionId int,
+) chan ConnectionCloseResult {
+	// Record and dispatch the request
+	sequence := b.writer.request(&BridgeRecord{
+		Operation: OperationConnectionClose,
+		ConnectionId: connectionId,
+	})
+	innerChannel := b.bridge.ConnectionClose(connectionId)
+
+	// Record the response when it arrives and forward it
+	return forwardConnectionClose(
+		innerChannel,
+		func(result ConnectionCloseResult) {
+			b.writer.response(sequence, &BridgeRecord{
+				Operation: OperationConnectionClose,
+				Error: errorMessage(result.err),
+			})
+		},
+	)
+}
+
+func (b *RecordingBridge) ConnectionCloseRead(
+	connectionId int,
+) chan ConnectionCloseResult {
+	// Record and dispatch the request
+	sequence := b.writer.request(&BridgeRecord{
+		Operation: OperationConnectionCloseRead,
+		ConnectionId: connectionId,
+	})
+	innerChannel := b.bridge.ConnectionCloseRead(connectionId)
+
+	// Record the response when it arrives and forward it
+	return forwardConnectionClose(
+		innerChannel,
+		func(result ConnectionCloseResult) {
+			b.writer.response(sequence, &BridgeRecord{
+				Operation: OperationConnectionCloseRead,
+				Error: errorMessage(result.err),
+			})
+		},
+	)
+}
+
+func (b *RecordingBridge) ConnectionCloseWrite(
+	connectionId int,
+) chan ConnectionCloseResult {
+	// Record and dispatch the request
+	sequence := b.writer.request(&BridgeRecord{
+		Operation: OperationConnectionCloseWrite,
+		ConnectionId: connectionId,
+	})
+	innerChannel := b.bridge.ConnectionCloseWrite(connectionId)
+
+	// Record the response when it arrives and forward it
+	return forwardConnectionClose(
+		innerChannel,
+		func(result ConnectionCloseResult) {
+			b.writer.response(sequence, &BridgeRecord{
+				Operation: OperationConnectionCloseWrite,
+				Error: errorMessage(result.err),
+			})
+		},
+	)
+}
+
+func (b *RecordingBridge) Listen(endpoint string) chan ListenResult {
+	// Record and dispatch the request
+	sequence := b.writer.request(&BridgeRecord{
+		Operation: OperationListen,
+		Endpoint: endpoint,
+	})
+	innerChannel := b.bridge.Listen(endpoint)
+
+	// Record the response when it arrives and forward it
+	return forwardListen(innerChannel, func(result ListenResult) {
+		b.writer.response(sequence, &BridgeRecord{
+			Operation: OperationListen,
+			ListenerId: result.listenerId,
+			Error: errorMessage(result.err),
+		})
+	})
+}
+
+func (b *RecordingBridge) ListenerAccept(
+	listenerId int,
+) chan ListenerAcceptResult {
+	// Record and dispatch the request
+	sequence := b.writer.request(&BridgeRecord{
+		Operation: OperationListenerAccept,
+		ListenerId: listenerId,
+	})
+	innerChannel := b.bridge.ListenerAccept(listenerId)
+
+	// Record the response when it arrives and forward it
+	return forwardListenerAccept(
+		innerChannel,
+		func(result ListenerAcceptResult) {
+			b.writer.response(sequence, &BridgeRecord{
+				Operation: OperationListenerAccept,
+				ConnectionId: result.connectionId,
+				Error: errorMessage(result.err),
+			})
+		},
+	)
+}
+
+func (b *RecordingBridge) ListenerClose(
+	listenerId int,
+) chan ListenerCloseResult {
+	// Record and dispatch the request
+	sequence := b.writer.request(&BridgeRecord{
+		Operation: OperationListenerClose,
+		ListenerId: listenerId,
+	})
+	innerChannel := b.bridge.ListenerClose(listenerId)
+
+	// Record the response when it arrives and forward it
+	return forwardListenerClose(
+		innerChannel,
+		func(result ListenerCloseResult) {
+			b.writer.response(sequence, &BridgeRecord{
+				Operation: OperationListenerClose,
+				Error: errorMessage(result.err),
+			})
+		},
+	)
+}
+
+func (b *RecordingBridge) Ping() chan PingResult {
+	// Record and dispatch the request
+	sequence := b.writer.request(&BridgeRecord{
+		Operation: OperationPing,
+	})
+	innerChannel := b.bridge.Ping()
+
+	// Record the response when it arrives and forward it
+	return forwardPing(innerChannel, func(result PingResult) {
+		b.writer.response(sequence, &BridgeRecord{
+			Operation: OperationPing,
+			RoundTrip: result.roundTrip,
+			Error: errorMessage(result.err),
+		})
+	})
+}
+
+func (b *RecordingBridge) ConnectPacket(endpoint string) chan ConnectResult {
+	// Record and dispatch the request
+	sequence := b.writer.request(&BridgeRecord{
+		Operation: OperationConnectPacket,
+		Endpoint: endpoint,
+	})
+	innerChannel := b.bridge.ConnectPacket(endpoint)
+
+	// Record the response when it arrives and forward it
+	return forwardConnect(innerChannel, func(result ConnectResult) {
+		b.writer.response(sequence, &BridgeRecord{
+			Operation: OperationConnectPacket,
+			ConnectionId: result.connectionId,
+			Error: errorMessage(result.err),
+		})
+	})
+}
+
+func (b *RecordingBridge) ConnectionReadMessage(
+	connectionId int,
+) chan ConnectionReadResult {
+	// Record and dispatch the request
+	sequence := b.writer.request(&BridgeRecord{
+		Operation: OperationConnectionReadMessage,
+		ConnectionId: connectionId,
+	})
+	innerChannel := b.bridge.ConnectionReadMessage(connectionId)
+
+	// Record the response when it arrives and forward it
+	return forwardConnectionRead(
+		innerChannel,
+		func(result ConnectionReadResult) {
+			b.writer.response(sequence, &BridgeRecord{
+				Operation: OperationConnectionReadMessage,
+				Data: result.data,
+				Error: errorMessage(result.err),
+			})
+		},
+	)
+}
+
+func (b *RecordingBridge) ConnectionWriteMessage(
+	connectionId int,
+	data []byte,
+) chan ConnectionWriteResult {
+	// Record and dispatch the request
+	sequence := b.writer.request(&BridgeRecord{
+		Operation: OperationConnectionWriteMessage,
+		ConnectionId: connectionId,
+		Data: data,
+	})
+	innerChannel := b.bridge.ConnectionWriteMessage(connectionId, data)
+
+	// Record the response when it arrives and forward it
+	return forwardConnectionWrite(
+		innerChannel,
+		func(result ConnectionWriteResult) {
+			b.writer.response(sequence, &BridgeRecord{
+				Operation: OperationConnectionWriteMessage,
+				Count: result.count,
+				Error: errorMessage(result.err),
+			})
+		},
+	)
+}
+
+func (b *RecordingBridge) ListenPacket(endpoint string) chan ListenResult {
+	// Record and dispatch the request
+	sequence := b.writer.request(&BridgeRecord{
+		Operation: OperationListenPacket,
+		Endpoint: endpoint,
+	})
+	innerChannel := b.bridge.ListenPacket(endpoint)
+
+	// Record the response when it arrives and forward it
+	return forwardListen(innerChannel, func(result ListenResult) {
+		b.writer.response(sequence, &BridgeRecord{
+			Operation: OperationListenPacket,
+			ListenerId: result.listenerId,
+			Error: errorMessage(result.err),
+		})
+	})
+}
+
+// ReplayBridge implements the Bridge interface by feeding back a recording
+// made with RecordingBridge, without any host.  Requests for each connection
+// and listener must arrive in the same order as they were recorded, as must
+// connect, listen, and ping requests for each endpoint, but requests in
+// different streams may interleave differently than they did when recorded
+// (as they do when driven by separate goroutines).  Each request receives the
+// response recorded for its counterpart (immediately, regardless of the
+// original latency).  A request that doesn't match its counterpart fails with
+// ErrReplayMismatch, at which point the session has diverged from the
+// recording, so the replay fails and all subsequent requests also fail with
+// ErrReplayMismatch.  Pass a ReplayBridge to HostInitialize to run client code
+// against a recorded session.
+type ReplayBridge struct {
+	// The replay state
+	*bridgeReplayer
+}
+
+// NewReplayBridge creates a new ReplayBridge from a recording.  If strict is
+// true, write request payloads must match the recording exactly, otherwise only
+// operations, endpoints, identifiers, and lengths are compared (which allows
+// replaying sessions whose payloads contain e.g. random nonces).
+func NewReplayBridge(reader io.Reader, strict bool) (*ReplayBridge, error) {
+	// Read the recording
+	records, err := ReadBridgeRecords(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the bridge
+	return &ReplayBridge{newBridgeReplayer(records, strict)}, nil
+}
+
+// Remaining returns the number of recorded requests that have not yet been
+// replayed.  If the replay has failed, this includes the request at which the
+// session diverged.
+func (b *ReplayBridge) Remaining() int {
+	return b.remaining()
+}
+
+// replayError reconstructs a recorded error.  Errors are reconstructed in the
+// same way that bridges decode host error messages, so replayed errors behave
+// identically to the recorded ones.
+func replayError(message string) error {
+	if message == "" {
+		return nil
+	}
+	return errorFromMessage(message)
+}
+
+func (b *ReplayBridge) Connect(endpoint string) chan ConnectResult {
+	// Create a buffered (non-blocking) result channel
+	resultChannel := make(chan ConnectResult, 1)
+
+	// Replay the response
+	response, err := b.replay(&BridgeRecord{
+		Operation: OperationConnect,
+		Endpoint: endpoint,
+	})
+	if err != nil {
+		resultChannel <- ConnectResult{connectionId: -1, err: err}
+	} else {
+		resultChannel <- ConnectResult{
+			connectionId: response.ConnectionId,
+			err: replayError(response.Error),
+		}
+	}
+
+	// Return the result channel for the caller to wait on
+	return resultChannel
+}
+
+func (b *ReplayBridge) ConnectionRead(
+	connectionId,
+	length int,
+) chan ConnectionReadResult {
+	// Create a buffered (non-blocking) result channel
+	resultChannel := make(chan ConnectionReadResult, 1)
+
+	// Replay the response
+	response, err := b.replay(&BridgeRecord{
+		Operation: OperationConnectionRead,
+		ConnectionId: connectionId,
+		Length: length,
+	})
+	if err != nil {
+		resultChannel <- ConnectionReadResult{err: err}
+	} else {
+		resultChannel <- ConnectionReadResult{
+			data: response.Data,
+			err: replayError(response.Error),
+		}
+	}
+
+	// Return the result channel for the caller to wait on
+	return resultChannel
+}
+
+func (b *ReplayBridge) ConnectionWrite(
+	connectionId int,
+	data []byte,
+) chan ConnectionWriteResult {
+	// Create a buffered (non-blocking) result channel
+	resultChannel := make(chan ConnectionWriteResult, 1)
+
+	// Replay the response
+	response, err := b.replay(&BridgeRecord{
+		Operation: OperationConnectionWrite,
+		ConnectionId: connectionId,
+		Data: data,
+	})
+	if err != nil {
+		resultChannel <- ConnectionWriteResult{err: err}
+	} else {
+		resultChannel <- ConnectionWriteResult{
+			count: response.Count,
+			err: replayError(response.Error),
+		}
+	}
+
+	// Return the result channel for the caller to wait on
+	return resultChannel
+}
+
+func (b *ReplayBridge) ConnectionClose(
+	connectionId int,
+) chan ConnectionCloseResult {
+	// Create a buffered (non-blocking) result channel
+	resultChannel := make(chan ConnectionCloseResult, 1)
+
+	// Replay the response
+	response, err := b.replay(&BridgeRecord{
+		Operation: OperationConnectionClose,
+		ConnectionId: connectionId,
+	})
+	if err != nil {
+		resultChannel <- ConnectionCloseResult{err: err}
+	} else {
+		resultChannel <- ConnectionCloseResult{
+			err: replayError(response.Error),
+		}
+	}
+
+	// Return the result channel for the caller to wait on
+	return resultChannel
+}
+
+func (b *ReplayBridge) ConnectionCloseRead(
+	connectionId int,
+) chan ConnectionCloseResult {
+	// Create a buffered (non-blocking) result channel
+	resultChannel := make(chan ConnectionCloseResult, 1)
+
+	// Replay the response
+	response, err := b.replay(&BridgeRecord{
+		Operation: OperationConnectionCloseRead,
+		ConnectionId: connectionId,
+	})
+	if err != nil {
+		resultChannel <- ConnectionCloseResult{err: err}
+	} else {
+		resultChannel <- ConnectionCloseResult{
+			err: replayError(response.Error),
+		}
+	}
+
+	// Return the result channel for the caller to wait on
+	return resultChannel
+}
+
+func (b *ReplayBridge) ConnectionCloseWrite(
+	connectionId int,
+) chan ConnectionCloseResult {
+	// Create a buffered (non-blocking) result channel
+	resultChannel := make(chan ConnectionCloseResult, 1)
+
+	// Replay the response
+	response, err := b.replay(&BridgeRecord{
+		Operation: OperationConnectionCloseWrite,
+		ConnectionId: connectionId,
+	})
+	if err != nil {
+		resultChannel <- ConnectionCloseResult{err: err}
+	} else {
+		resultChannel <- ConnectionCloseResult{
+			err: replayError(response.Error),
+		}
+	}
+
+	// Return the result channel for the caller to wait on
+	return resultChannel
+}
+
+func (b *ReplayBridge) Listen(endpoint string) chan ListenResult {
+	// Create a buffered (non-blocking) result channel
+	resultChannel := make(chan ListenResult, 1)
+
+	// Replay the response
+	response, err := b.replay(&BridgeRecord{
+		Operation: OperationListen,
+		Endpoint: endpoint,
+	})
+	if err != nil {
+		resultChannel <- ListenResult{listenerId: -1, err: err}
+	} else {
+		resultChannel <- ListenResult{
+			listenerId: response.ListenerId,
+			err: replayError(response.Error),
+		}
+	}
+
+	// Return the result channel for the caller to wait on
+	return resultChannel
+}
+
+func (b *ReplayBridge) ListenerAccept(
+	listenerId int,
+) chan ListenerAcceptResult {
+	// Create a buffered (non-blocking) result channel
+	resultChannel := make(chan ListenerAcceptResult, 1)
+
+	// Replay the response
+	response, err := b.replay(&BridgeRecord{
+		Operation: OperationListenerAccept,
+		ListenerId: listenerId,
+	})
+	if err != nil {
+		resultChannel <- ListenerAcceptResult{connectionId: -1, err: err}
+	} else {
+		resultChannel <- ListenerAcceptResult{
+			connectionId: response.ConnectionId,
+			err: replayError(response.Error),
+		}
+	}
+
+	// Return the result channel for the caller to wait on
+	return resultChannel
+}
+
+func (b *ReplayBridge) ListenerClose(
+	listenerId int,
+) chan ListenerCloseResult {
+	// Create a buffered (non-blocking) result channel
+	resultChannel := make(chan ListenerCloseResult, 1)
+
+	// Replay the response
+	response, err := b.replay(&BridgeRecord{
+		Operation: OperationListenerClose,
+		ListenerId: listenerId,
+	})
+	if err != nil {
+		resultChannel <- ListenerCloseResult{err: err}
+	} else {
+		resultChannel <- ListenerCloseResult{
+			err: replayError(response.Error),
+		}
+	}
+
+	// Return the result channel for the caller to wait on
+	return resultChannel
+}
+
+func (b *ReplayBridge) Ping() chan PingResult {
+	// Create a buffered (non-blocking) result channel
+	resultChannel := make(chan PingResult, 1)
+
+	// Replay the response
+	response, err := b.replay(&BridgeRecord{
+		Operation: OperationPing,
+	})
+	if err != nil {
+		resultChannel <- PingResult{err: err}
+	} else {
+		resultChannel <- PingResult{
+			roundTrip: response.RoundTrip,
+			err: replayError(response.Error),
+		}
+	}
+
+	// Return the result channel for the caller to wait on
+	return resultChannel
+}
+
+func (b *ReplayBridge) ConnectPacket(endpoint string) chan ConnectResult {
+	// Create a buffered (non-blocking) result channel
+	resultChannel := make(chan ConnectResult, 1)
+
+	// Replay the response
+	response, err := b.replay(&BridgeRecord{
+		Operation: OperationConnectPacket,
+		Endpoint: endpoint,
+	})
+	if err != nil {
+		resultChannel <- ConnectResult{connectionId: -1, err: err}
+	} else {
+		resultChannel <- ConnectResult{
+			connectionId: response.ConnectionId,
+			err: replayError(response.Error),
+		}
+	}
+
+	// Return the result channel for the caller to wait on
+	return resultChannel
+}
+
+func (b *ReplayBridge) ConnectionReadMessage(
+	connectionId int,
+) chan ConnectionReadResult {
+	// Create a buffered (non-blocking) result channel
+	resultChannel := make(chan ConnectionReadResult, 1)
+
+	// Replay the response
+	response, err := b.replay(&BridgeRecord{
+		Operation: OperationConnectionReadMessage,
+		ConnectionId: connectionId,
+	})
+	if err != nil {
+		resultChannel <- ConnectionReadResult{err: err}
+	} else {
+		resultChannel <- ConnectionReadResult{
+			data: response.Data,
+			err: replayError(response.Error),
+		}
+	}
+
+	// Return the result channel for the caller to wait on
+	return resultChannel
+}
+
+func (b *ReplayBridge) ConnectionWriteMessage(
+	connectionId int,
+	data []byte,
+) chan ConnectionWriteResult {
+	// Create a buffered (non-blocking) result channel
+	resultChannel := make(chan ConnectionWriteResult, 1)
+
+	// Replay the response
+	response, err := b.replay(&BridgeRecord{
+		Operation: OperationConnectionWriteMessage,
+		ConnectionId: connectionId,
+		Data: data,
+	})
+	if err != nil {
+		resultChannel <- ConnectionWriteResult{err: err}
+	} else {
+		resultChannel <- ConnectionWriteResult{
+			count: response.Count,
+			err: replayError(response.Error),
+		}
+	}
+
+	// Return the result channel for the caller to wait on
+	return resultChannel
+}
+
+func (b *ReplayBridge) ListenPacket(endpoint string) chan ListenResult {
+	// Create a buffered (non-blocking) result channel
+	resultChannel := make(chan ListenResult, 1)
+
+	// Replay the response
+	response, err := b.replay(&BridgeRecord{
+		Operation: OperationListenPacket,
+		Endpoint: endpoint,
+	})
+	if err != nil {
+		resultChannel <- ListenResult{listenerId: -1, err: err}
+	} else {
+		resultChannel <- ListenResult{
+			listenerId: response.ListenerId,
+			err: replayError(response.Error),
+		}
+	}
+
+	// Return the result channel for the caller to wait on
+	return resultChannel
+}
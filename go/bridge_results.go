@@ -0,0 +1,52 @@
+package ipc
+
+// System imports
+import "time"
+
+// ConnectResult represents the result from a connect operation.
+type ConnectResult struct {
+	connectionId int
+	err          error
+}
+
+// ConnectionReadResult represents the result from a connection read operation.
+type ConnectionReadResult struct {
+	data []byte
+	err  error
+}
+
+// ConnectionWriteResult represents the result from a connection write
+// operation.
+type ConnectionWriteResult struct {
+	count int
+	err   error
+}
+
+// ConnectionCloseResult represents the result from a connection close
+// operation.
+type ConnectionCloseResult struct {
+	err error
+}
+
+// ListenResult represents the result from a listen operation.
+type ListenResult struct {
+	listenerId int
+	err        error
+}
+
+// ListenerAcceptResult represents the result from a listener accept operation.
+type ListenerAcceptResult struct {
+	connectionId int
+	err          error
+}
+
+// ListenerCloseResult represents the result from a listener close operation.
+type ListenerCloseResult struct {
+	err error
+}
+
+// PingResult represents the result from a ping operation.
+type PingResult struct {
+	roundTrip time.Duration
+	err       error
+}
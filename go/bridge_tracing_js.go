@@ -0,0 +1,301 @@
+// +build js
+
+package ipc
+
+// System imports
+import "time"
+
+// TracingBridge wraps another Bridge implementation and reports start and
+// finish events for every request to a Tracer.  It is installed automatically
+// by HostInitialize when a tracer is provided in ClientOptions, but it can also
+// be used directly.
+type TracingBridge struct {
+	// The underlying bridge
+	bridge Bridge
+
+	// The tracer receiving events
+	tracer Tracer
+}
+
+// NewTracingBridge creates a new TracingBridge that reports requests to the
+// specified bridge to the specified tracer.
+func NewTracingBridge(bridge Bridge, tracer Tracer) *TracingBridge {
+	return &TracingBridge{
+		bridge: bridge,
+		tracer: tracer,
+	}
+}
+
+// start creates an event and reports it to the tracer.
+func (b *TracingBridge) start(event *TraceEvent) *TraceEvent {
+	event.Start = time.Now()
+	b.tracer.StartOperation(event)
+	return event
+}
+
+// finish completes an event and reports it to the tracer.
+func (b *TracingBridge) finish(event *TraceEvent, err error) {
+	event.Finish = time.Now()
+	event.Err = err
+	b.tracer.FinishOperation(event)
+}
+
+func (b *TracingBridge) Connect(endpoint string) chan ConnectResult {
+	// Dispatch the request
+	event := b.start(&TraceEvent{
+		Operation: OperationConnect,
+		Endpoint: endpoint,
+		ConnectionId: -1,
+	})
+	innerChannel := b.bridge.Connect(endpoint)
+
+	// Report the result when it arrives and forward it
+	return forwardConnect(innerChannel, func(result ConnectResult) {
+		event.ConnectionId = result.connectionId
+		b.finish(event, result.err)
+	})
+}
+
+func (b *TracingBridge) ConnectionRead(
+	connectionId,
+	length int,
+) chan ConnectionReadResult {
+	// Dispatch the request
+	event := b.start(&TraceEvent{
+		Operation: OperationConnectionRead,
+		ConnectionId: connectionId,
+		Length: length,
+	})
+	innerChannel := b.bridge.ConnectionRead(connectionId, length)
+
+	// Report the result when it arrives and forward it
+	return forwardConnectionRead(
+		innerChannel,
+		func(result ConnectionReadResult) {
+			event.Count = len(result.data)
+			b.finish(event, result.err)
+		},
+	)
+}
+
+func (b *TracingBridge) ConnectionWrite(
+	connectionId int,
+	data []byte,
+) chan ConnectionWriteResult {
+	// Dispatch the request
+	event := b.start(&TraceEvent{
+		Operation: OperationConnectionWrite,
+		ConnectionId: connectionId,
+		Length: len(data),
+	})
+	innerChannel := b.bridge.ConnectionWrite(connectionId, data)
+
+	// Report the result when it arrives and forward it
+	return forwardConnectionWrite(
+		innerChannel,
+		func(result ConnectionWriteResult) {
+			event.Count = result.count
+			b.finish(event, result.err)
+		},
+	)
+}
+
+func (b *TracingBridge) ConnectionClose(
+	connectionId int,
+) chan ConnectionCloseResult {
+	// Dispatch the request
+	event := b.start(&TraceEvent{
+		Operation: OperationConnectionClose,
+		ConnectionId: connectionId,
+	})
+	innerChannel := b.bridge.ConnectionClose(connectionId)
+
+	// Report the result when it arrives and forward it
+	return forwardConnectionClose(
+		innerChannel,
+		func(result ConnectionCloseResult) {
+			b.finish(event, result.err)
+		},
+	)
+}
+
+func (b *TracingBridge) ConnectionCloseRead(
+	connectionId int,
+) chan ConnectionCloseResult {
+	// Dispatch the request
+	event := b.start(&TraceEvent{
+		Operation: OperationConnectionCloseRead,
+		ConnectionId: connectionId,
+	})
+	innerChannel := b.bridge.ConnectionCloseRead(connectionId)
+
+	// Report the result when it arrives and forward it
+	return forwardConnectionClose(
+		innerChannel,
+		func(result ConnectionCloseResult) {
+			b.finish(event, result.err)
+		},
+	)
+}
+
+func (b *TracingBridge) ConnectionCloseWrite(
+	connectionId int,
+) chan ConnectionCloseResult {
+	// Dispatch the request
+	event := b.start(&TraceEvent{
+		Operation: OperationConnectionCloseWrite,
+		ConnectionId: connectionId,
+	})
+	innerChannel := b.bridge.ConnectionCloseWrite(connectionId)
+
+	// Report the result when it arrives and forward it
+	return forwardConnectionClose(
+		innerChannel,
+		func(result ConnectionCloseResult) {
+			b.finish(event, result.err)
+		},
+	)
+}
+
+func (b *TracingBridge) Listen(endpoint string) chan ListenResult {
+	// Dispatch the request
+	event := b.start(&TraceEvent{
+		Operation: OperationListen,
+		Endpoint: endpoint,
+		ListenerId: -1,
+	})
+	innerChannel := b.bridge.Listen(endpoint)
+
+	// Report the result when it arrives and forward it
+	return forwardListen(innerChannel, func(result ListenResult) {
+		event.ListenerId = result.listenerId
+		b.finish(event, result.err)
+	})
+}
+
+func (b *TracingBridge) ListenerAccept(
+	listenerId int,
+) chan ListenerAcceptResult {
+	// Dispatch the request
+	event := b.start(&TraceEvent{
+		Operation: OperationListenerAccept,
+		ListenerId: listenerId,
+		ConnectionId: -1,
+	})
+	innerChannel := b.bridge.ListenerAccept(listenerId)
+
+	// Report the result when it arrives and forward it
+	return forwardListenerAccept(
+		innerChannel,
+		func(result ListenerAcceptResult) {
+			event.ConnectionId = result.connectionId
+			b.finish(event, result.err)
+		},
+	)
+}
+
+func (b *TracingBridge) ListenerClose(
+	listenerId int,
+) chan ListenerCloseResult {
+	// Dispatch the request
+	event := b.start(&TraceEvent{
+		Operation: OperationListenerClose,
+		ListenerId: listenerId,
+	})
+	innerChannel := b.bridge.ListenerClose(listenerId)
+
+	// Report the result when it arrives and forward it
+	return forwardListenerClose(
+		innerChannel,
+		func(result ListenerCloseResult) {
+			b.finish(event, result.err)
+		},
+	)
+}
+
+func (b *TracingBridge) Ping() chan PingResult {
+	// Dispatch the request
+	event := b.start(&TraceEvent{
+		Operation: OperationPing,
+	})
+	innerChannel := b.bridge.Ping()
+
+	// Report the result when it arrives and forward it
+	return forwardPing(innerChannel, func(result PingResult) {
+		b.finish(event, result.err)
+	})
+}
+
+func (b *TracingBridge) ConnectPacket(endpoint string) chan ConnectResult {
+	// Dispatch the request
+	event := b.start(&TraceEvent{
+		Operation: OperationConnectPacket,
+		Endpoint: endpoint,
+		ConnectionId: -1,
+	})
+	innerChannel := b.bridge.ConnectPacket(endpoint)
+
+	// Report the result when it arrives and forward it
+	return forwardConnect(innerChannel, func(result ConnectResult) {
+		event.ConnectionId = result.connectionId
+		b.finish(event, result.err)
+	})
+}
+
+func (b *TracingBridge) ConnectionReadMessage(
+	connectionId int,
+) chan ConnectionReadResult {
+	// Dispatch the request
+	event := b.start(&TraceEvent{
+		Operation: OperationConnectionReadMessage,
+		ConnectionId: connectionId,
+	})
+	innerChannel := b.bridge.ConnectionReadMessage(connectionId)
+
+	// Report the result when it arrives and forward it
+	return forwardConnectionRead(
+		innerChannel,
+		func(result ConnectionReadResult) {
+			event.Count = len(result.data)
+			b.finish(event, result.err)
+		},
+	)
+}
+
+func (b *TracingBridge) ConnectionWriteMessage(
+	connectionId int,
+	data []byte,
+) chan ConnectionWriteResult {
+	// Dispatch the request
+	event := b.start(&TraceEvent{
+		Operation: OperationConnectionWriteMessage,
+		ConnectionId: connectionId,
+		Length: len(data),
+	})
+	innerChannel := b.bridge.ConnectionWriteMessage(connectionId, data)
+
+	// Report the result when it arrives and forward it
+	return forwardConnectionWrite(
+		innerChannel,
+		func(result ConnectionWriteResult) {
+			event.Count = result.count
+			b.finish(event, result.err)
+		},
+	)
+}
+
+func (b *TracingBridge) ListenPacket(endpoint string) chan ListenResult {
+	// Dispatch the request
+	event := b.start(&TraceEvent{
+		Operation: OperationListenPacket,
+		Endpoint: endpoint,
+		ListenerId: -1,
+	})
+	innerChannel := b.bridge.ListenPacket(endpoint)
+
+	// Report the result when it arrives and forward it
+	return forwardListen(innerChannel, func(result ListenResult) {
+		event.ListenerId = result.listenerId
+		b.finish(event, result.err)
+	})
+}
@@ -0,0 +1,253 @@
+// +build js
+
+package ipc
+
+// System imports
+import (
+	"sync"
+	"time"
+)
+
+// WatchdogBridge wraps another Bridge implementation and watches for requests
+// that the host doesn't respond to within a time limit.  It doesn't fail such
+// requests (see ClientOptions.RequestTimeout for that) - results are forwarded
+// whenever they arrive - but the first time a request exceeds the limit, the
+// bridge is marked unhealthy and the unhealthy callback (if any) is invoked,
+// providing a health signal for hosts that have stopped responding.
+// ConnectionRead, ConnectionReadMessage, and ListenerAccept requests aren't
+// watched, since they legitimately wait on the remote peer for arbitrarily long
+// periods.  It is installed automatically by HostInitialize when a watchdog
+// limit is provided in ClientOptions, but it can also be used directly.
+type WatchdogBridge struct {
+	// The underlying bridge
+	bridge Bridge
+
+	// The time limit for requests
+	limit time.Duration
+
+	// The callback to invoke when the bridge becomes unhealthy, if any
+	onUnhealthy func(operation string)
+
+	// Lock for the health state
+	lock sync.Mutex
+
+	// Whether or not a request has exceeded the limit
+	unhealthy bool
+}
+
+// NewWatchdogBridge creates a new WatchdogBridge that watches for requests to
+// the specified bridge that take longer than the specified limit.  The
+// onUnhealthy callback, if non-nil, is invoked with the operation name of the
+// first request to exceed the limit.
+func NewWatchdogBridge(
+	bridge Bridge,
+	limit time.Duration,
+	onUnhealthy func(operation string),
+) *WatchdogBridge {
+	return &WatchdogBridge{
+		bridge: bridge,
+		limit: limit,
+		onUnhealthy: onUnhealthy,
+	}
+}
+
+// Healthy returns whether or not all requests to the bridge have received
+// responses within the time limit.  Once a request has exceeded the limit, the
+// bridge remains unhealthy.
+func (b *WatchdogBridge) Healthy() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return !b.unhealthy
+}
+
+// watch starts watching a request for the specified operation, returning a
+// timer that must be stopped when the request's result arrives.
+func (b *WatchdogBridge) watch(operation string) *time.Timer {
+	return time.AfterFunc(b.limit, func() {
+		b.expired(operation)
+	})
+}
+
+// expired marks the bridge as unhealthy after a request for the specified
+// operation has exceeded the limit.
+func (b *WatchdogBridge) expired(operation string) {
+	// Update the health state
+	b.lock.Lock()
+	report := !b.unhealthy
+	b.unhealthy = true
+	b.lock.Unlock()
+
+	// Report the transition if necessary
+	if report && b.onUnhealthy != nil {
+		b.onUnhealthy(operation)
+	}
+}
+
+func (b *WatchdogBridge) Connect(endpoint string) chan ConnectResult {
+	// Dispatch the request and start watching it
+	timer := b.watch(OperationConnect)
+	innerChannel := b.bridge.Connect(endpoint)
+
+	// Stop watching when the result arrives and forward it
+	return forwardConnect(innerChannel, func(ConnectResult) {
+		timer.Stop()
+	})
+}
+
+func (b *WatchdogBridge) ConnectionRead(
+	connectionId,
+	length int,
+) chan ConnectionReadResult {
+	return b.bridge.ConnectionRead(connectionId, length)
+}
+
+func (b *WatchdogBridge) ConnectionWrite(
+	connectionId int,
+	data []byte,
+) chan ConnectionWriteResult {
+	// Dispatch the request and start watching it
+	timer := b.watch(OperationConnectionWrite)
+	innerChannel := b.bridge.ConnectionWrite(connectionId, data)
+
+	// Stop watching when the result arrives and forward it
+	return forwardConnectionWrite(
+		innerChannel,
+		func(ConnectionWriteResult) {
+			timer.Stop()
+		},
+	)
+}
+
+func (b *WatchdogBridge) ConnectionClose(
+	connectionId int,
+) chan ConnectionCloseResult {
+	// Dispatch the request and start watching it
+	timer := b.watch(OperationConnectionClose)
+	innerChannel := b.bridge.ConnectionClose(connectionId)
+
+	// Stop watching when the result arrives and forward it
+	return forwardConnectionClose(
+		innerChannel,
+		func(ConnectionCloseResult) {
+			timer.Stop()
+		},
+	)
+}
+
+func (b *WatchdogBridge) ConnectionCloseRead(
+	connectionId int,
+) chan ConnectionCloseResult {
+	// Dispatch the request and start watching it
+	timer := b.watch(OperationConnectionCloseRead)
+	innerChannel := b.bridge.ConnectionCloseRead(connectionId)
+
+	// Stop watching when the result arrives and forward it
+	return forwardConnectionClose(
+		innerChannel,
+		func(ConnectionCloseResult) {
+			timer.Stop()
+		},
+	)
+}
+
+func (b *WatchdogBridge) ConnectionCloseWrite(
+	connectionId int,
+) chan ConnectionCloseResult {
+	// Dispatch the request and start watching it
+	timer := b.watch(OperationConnectionCloseWrite)
+	innerChannel := b.bridge.ConnectionCloseWrite(connectionId)
+
+	// Stop watching when the result arrives and forward it
+	return forwardConnectionClose(
+		innerChannel,
+		func(ConnectionCloseResult) {
+			timer.Stop()
+		},
+	)
+}
+
+func (b *WatchdogBridge) Listen(endpoint string) chan ListenResult {
+	// Dispatch the request and start watching it
+	timer := b.watch(OperationListen)
+	innerChannel := b.bridge.Listen(endpoint)
+
+	// Stop watching when the result arrives and forward it
+	return forwardListen(innerChannel, func(ListenResult) {
+		timer.Stop()
+	})
+}
+
+func (b *WatchdogBridge) ListenerAccept(
+	listenerId int,
+) chan ListenerAcceptResult {
+	return b.bridge.ListenerAccept(listenerId)
+}
+
+func (b *WatchdogBridge) ListenerClose(
+	listenerId int,
+) chan ListenerCloseResult {
+	// Dispatch the request and start watching it
+	timer := b.watch(OperationListenerClose)
+	innerChannel := b.bridge.ListenerClose(listenerId)
+
+	// Stop watching when the result arrives and forward it
+	return forwardListenerClose(innerChannel, func(ListenerCloseResult) {
+		timer.Stop()
+	})
+}
+
+func (b *WatchdogBridge) Ping() chan PingResult {
+	// Dispatch the request and start watching it
+	timer := b.watch(OperationPing)
+	innerChannel := b.bridge.Ping()
+
+	// Stop watching when the result arrives and forward it
+	return forwardPing(innerChannel, func(PingResult) {
+		timer.Stop()
+	})
+}
+
+func (b *WatchdogBridge) ConnectPacket(endpoint string) chan ConnectResult {
+	// Dispatch the request and start watching it
+	timer := b.watch(OperationConnectPacket)
+	innerChannel := b.bridge.ConnectPacket(endpoint)
+
+	// Stop watching when the result arrives and forward it
+	return forwardConnect(innerChannel, func(ConnectResult) {
+		timer.Stop()
+	})
+}
+
+func (b *WatchdogBridge) ConnectionReadMessage(
+	connectionId int,
+) chan ConnectionReadResult {
+	return b.bridge.ConnectionReadMessage(connectionId)
+}
+
+func (b *WatchdogBridge) ConnectionWriteMessage(
+	connectionId int,
+	data []byte,
+) chan ConnectionWriteResult {
+	// Dispatch the request and start watching it
+	timer := b.watch(OperationConnectionWriteMessage)
+	innerChannel := b.bridge.ConnectionWriteMessage(connectionId, data)
+
+	// Stop watching when the result arrives and forward it
+	return forwardConnectionWrite(
+		innerChannel,
+		func(ConnectionWriteResult) {
+			timer.Stop()
+		},
+	)
+}
+
+func (b *WatchdogBridge) ListenPacket(endpoint string) chan ListenResult {
+	// Dispatch the request and start watching it
+	timer := b.watch(OperationListenPacket)
+	innerChannel := b.bridge.ListenPacket(endpoint)
+
+	// Stop watching when the result arrives and forward it
+	return forwardListen(innerChannel, func(ListenResult) {
+		timer.Stop()
+	})
+}
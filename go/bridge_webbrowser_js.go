@@ -3,7 +3,10 @@
 package ipc
 
 // System imports
-import "encoding/base64"
+import (
+	"encoding/base64"
+	"time"
+)
 
 // GopherJS imports
 import "github.com/gopherjs/gopherjs/js"
@@ -65,6 +68,18 @@ func init() {
 					bridge.RespondConnectionClose(sequence, errorMessage)
 				},
 			)
+			js.Global.Set(
+				"_GIBWebBrowserBridgeRespondConnectionCloseRead",
+				func(sequence int, errorMessage string) {
+					bridge.RespondConnectionCloseRead(sequence, errorMessage)
+				},
+			)
+			js.Global.Set(
+				"_GIBWebBrowserBridgeRespondConnectionCloseWrite",
+				func(sequence int, errorMessage string) {
+					bridge.RespondConnectionCloseWrite(sequence, errorMessage)
+				},
+			)
 			js.Global.Set(
 				"_GIBWebBrowserBridgeRespondListen",
 				func(sequence, listenerId int, errorMessage string) {
@@ -87,6 +102,52 @@ func init() {
 					bridge.RespondListenerClose(sequence, errorMessage)
 				},
 			)
+			js.Global.Set(
+				"_GIBWebBrowserBridgeRespondPing",
+				func(sequence int, errorMessage string) {
+					bridge.RespondPing(sequence, errorMessage)
+				},
+			)
+			js.Global.Set(
+				"_GIBWebBrowserBridgeRespondConnectPacket",
+				func(sequence, connectionId int, errorMessage string) {
+					bridge.RespondConnectPacket(
+						sequence,
+						connectionId,
+						errorMessage,
+					)
+				},
+			)
+			js.Global.Set(
+				"_GIBWebBrowserBridgeRespondConnectionReadMessage",
+				func(sequence int, data64, errorMessage string) {
+					bridge.RespondConnectionReadMessage(
+						sequence,
+						data64,
+						errorMessage,
+					)
+				},
+			)
+			js.Global.Set(
+				"_GIBWebBrowserBridgeRespondConnectionWriteMessage",
+				func(sequence, count int, errorMessage string) {
+					bridge.RespondConnectionWriteMessage(
+						sequence,
+						count,
+						errorMessage,
+					)
+				},
+			)
+			js.Global.Set(
+				"_GIBWebBrowserBridgeRespondListenPacket",
+				func(sequence, listenerId int, errorMessage string) {
+					bridge.RespondListenPacket(
+						sequence,
+						listenerId,
+						errorMessage,
+					)
+				},
+			)
 
 			// Call HostInitialize
 			HostInitialize(bridge, message.String())
@@ -94,12 +155,47 @@ func init() {
 	)
 }
 
+// Outstanding returns the number of requests that have been forwarded to the
+// host and are still awaiting responses.
+func (b *WebBrowserBridge) Outstanding() int {
+	return b.sequences.outstanding()
+}
+
+// Pending returns a description of each request that has been forwarded to the
+// host and is still awaiting a response, oldest first.
+func (b *WebBrowserBridge) Pending() []PendingRequest {
+	return b.sequences.pending()
+}
+
+// SetRequestTimeout sets the time that subsequent requests will wait for a
+// response from the host before failing with ErrRequestTimeout.  Responses
+// that arrive after a request has timed out are reported to the error handler
+// as responses for unknown sequences.  A timeout of 0, the default, disables
+// timeouts.
+func (b *WebBrowserBridge) SetRequestTimeout(timeout time.Duration) {
+	b.sequences.setTimeout(timeout)
+}
+
+// SetErrorHandler sets the handler for errors that can't be returned to a
+// caller, such as responses from the host for unknown sequences or for the
+// wrong type of request.  Where the affected request can be identified, the
+// error is also delivered to its caller.  A nil handler restores the default,
+// LogErrorHandler.
+func (b *WebBrowserBridge) SetErrorHandler(handler ErrorHandler) {
+	b.sequences.setErrorHandler(handler)
+}
+
 func (b *WebBrowserBridge) Connect(endpoint string) chan ConnectResult {
 	// Create a buffered (non-blocking) result channel
 	resultChannel := make(chan ConnectResult, 1)
 
-	// Record the result channel and generate a sequence
-	sequence := b.sequences.push(resultChannel)
+	// Record the pending request and generate a sequence
+	request := connectRequest(resultChannel)
+	sequence, err := b.sequences.push(request)
+	if err != nil {
+		b.sequences.fail(request, sequence, err)
+		return resultChannel
+	}
 
 	// Forward the request to the host with a sequence it can use to respond
 	b.hostProxy.Call("Connect", endpoint, sequence)
@@ -113,16 +209,15 @@ func (b *WebBrowserBridge) RespondConnect(
 	connectionId int,
 	errorMessage string,
 ) {
-	// Get the response channel
-	resultChannel, ok := b.sequences.pop(sequence).(chan ConnectResult)
-	if !ok {
-		panic("invalid response channel type")
-	}
-
-	// Respond
-	resultChannel <- ConnectResult{
-		connectionId: connectionId,
-		err: ErrorFromErrorMessage(errorMessage),
+	// Deliver the response
+	err := respondConnect(
+		b.sequences,
+		sequence,
+		connectionId,
+		ErrorFromErrorMessage(errorMessage),
+	)
+	if err != nil {
+		b.sequences.report(OperationConnect, sequence, err)
 	}
 }
 
@@ -133,8 +228,13 @@ func (b *WebBrowserBridge) ConnectionRead(
 	// Create a buffered (non-blocking) result channel
 	resultChannel := make(chan ConnectionReadResult, 1)
 
-	// Record the result channel and generate a sequence
-	sequence := b.sequences.push(resultChannel)
+	// Record the pending request and generate a sequence
+	request := connectionReadRequest(resultChannel)
+	sequence, err := b.sequences.push(request)
+	if err != nil {
+		b.sequences.fail(request, sequence, err)
+		return resultChannel
+	}
 
 	// Forward the request to the host with a sequence it can use to respond
 	b.hostProxy.Call("ConnectionRead", connectionId, length, sequence)
@@ -148,22 +248,15 @@ func (b *WebBrowserBridge) RespondConnectionRead(
 	data64 string,
 	errorMessage string,
 ) {
-	// Get the response channel
-	resultChannel, ok := b.sequences.pop(sequence).(chan ConnectionReadResult)
-	if !ok {
-		panic("invalid response channel type")
-	}
-
-	// Decode the data
-	data, err := base64.StdEncoding.DecodeString(data64)
+	// Deliver the response
+	err := respondConnectionRead(
+		b.sequences,
+		sequence,
+		data64,
+		ErrorFromErrorMessage(errorMessage),
+	)
 	if err != nil {
-		panic("host sent gibberish data")
-	}
-
-	// Respond
-	resultChannel <- ConnectionReadResult{
-		data: data,
-		err: ErrorFromErrorMessage(errorMessage),
+		b.sequences.report(OperationConnectionRead, sequence, err)
 	}
 }
 
@@ -174,8 +267,13 @@ func (b *WebBrowserBridge) ConnectionWrite(
 	// Create a buffered (non-blocking) result channel
 	resultChannel := make(chan ConnectionWriteResult, 1)
 
-	// Record the result channel and generate a sequence
-	sequence := b.sequences.push(resultChannel)
+	// Record the pending request and generate a sequence
+	request := connectionWriteRequest(resultChannel)
+	sequence, err := b.sequences.push(request)
+	if err != nil {
+		b.sequences.fail(request, sequence, err)
+		return resultChannel
+	}
 
 	// Encode the data
 	data64 := base64.StdEncoding.EncodeToString(data)
@@ -192,16 +290,15 @@ func (b *WebBrowserBridge) RespondConnectionWrite(
 	count int,
 	errorMessage string,
 ) {
-	// Get the response channel
-	resultChannel, ok := b.sequences.pop(sequence).(chan ConnectionWriteResult)
-	if !ok {
-		panic("invalid response channel type")
-	}
-
-	// Respond
-	resultChannel <- ConnectionWriteResult{
-		count: count,
-		err: ErrorFromErrorMessage(errorMessage),
+	// Deliver the response
+	err := respondConnectionWrite(
+		b.sequences,
+		sequence,
+		count,
+		ErrorFromErrorMessage(errorMessage),
+	)
+	if err != nil {
+		b.sequences.report(OperationConnectionWrite, sequence, err)
 	}
 }
 
@@ -211,8 +308,13 @@ func (b *WebBrowserBridge) ConnectionClose(
 	// Create a buffered (non-blocking) result channel
 	resultChannel := make(chan ConnectionCloseResult, 1)
 
-	// Record the result channel and generate a sequence
-	sequence := b.sequences.push(resultChannel)
+	// Record the pending request and generate a sequence
+	request := connectionCloseRequest(resultChannel)
+	sequence, err := b.sequences.push(request)
+	if err != nil {
+		b.sequences.fail(request, sequence, err)
+		return resultChannel
+	}
 
 	// Forward the request to the host with a sequence it can use to respond
 	b.hostProxy.Call("ConnectionClose", connectionId, sequence)
@@ -221,19 +323,90 @@ func (b *WebBrowserBridge) ConnectionClose(
 	return resultChannel
 }
 
+func (b *WebBrowserBridge) ConnectionCloseRead(
+	connectionId int,
+) chan ConnectionCloseResult {
+	// Create a buffered (non-blocking) result channel
+	resultChannel := make(chan ConnectionCloseResult, 1)
+
+	// Record the pending request and generate a sequence
+	request := connectionCloseReadRequest(resultChannel)
+	sequence, err := b.sequences.push(request)
+	if err != nil {
+		b.sequences.fail(request, sequence, err)
+		return resultChannel
+	}
+
+	// Forward the request to the host with a sequence it can use to respond
+	b.hostProxy.Call("ConnectionCloseRead", connectionId, sequence)
+
+	// Return the result channel for the caller to wait on
+	return resultChannel
+}
+
+func (b *WebBrowserBridge) ConnectionCloseWrite(
+	connectionId int,
+) chan ConnectionCloseResult {
+	// Create a buffered (non-blocking) result channel
+	resultChannel := make(chan ConnectionCloseResult, 1)
+
+	// Record the pending request and generate a sequence
+	request := connectionCloseWriteRequest(resultChannel)
+	sequence, err := b.sequences.push(request)
+	if err != nil {
+		b.sequences.fail(request, sequence, err)
+		return resultChannel
+	}
+
+	// Forward the request to the host with a sequence it can use to respond
+	b.hostProxy.Call("ConnectionCloseWrite", connectionId, sequence)
+
+	// Return the result channel for the caller to wait on
+	return resultChannel
+}
+
 func (b *WebBrowserBridge) RespondConnectionClose(
 	sequence int,
 	errorMessage string,
 ) {
-	// Get the response channel
-	resultChannel, ok := b.sequences.pop(sequence).(chan ConnectionCloseResult)
-	if !ok {
-		panic("invalid response channel type")
+	// Deliver the response
+	err := respondConnectionClose(
+		b.sequences,
+		sequence,
+		ErrorFromErrorMessage(errorMessage),
+	)
+	if err != nil {
+		b.sequences.report(OperationConnectionClose, sequence, err)
 	}
+}
 
-	// Respond
-	resultChannel <- ConnectionCloseResult{
-		err: ErrorFromErrorMessage(errorMessage),
+func (b *WebBrowserBridge) RespondConnectionCloseRead(
+	sequence int,
+	errorMessage string,
+) {
+	// Deliver the response
+	err := respondConnectionCloseRead(
+		b.sequences,
+		sequence,
+		ErrorFromErrorMessage(errorMessage),
+	)
+	if err != nil {
+		b.sequences.report(OperationConnectionCloseRead, sequence, err)
+	}
+}
+
+func (b *WebBrowserBridge) RespondConnectionCloseWrite(
+	sequence int,
+	errorMessage string,
+) {
+	// Deliver the response
+	err := respondConnectionCloseWrite(
+		b.sequences,
+		sequence,
+		ErrorFromErrorMessage(errorMessage),
+	)
+	if err != nil {
+		b.sequences.report(OperationConnectionCloseWrite, sequence, err)
 	}
 }
 
@@ -241,8 +414,13 @@ func (b *WebBrowserBridge) Listen(endpoint string) chan ListenResult {
 	// Create a buffered (non-blocking) result channel
 	resultChannel := make(chan ListenResult, 1)
 
-	// Record the result channel and generate a sequence
-	sequence := b.sequences.push(resultChannel)
+	// Record the pending request and generate a sequence
+	request := listenRequest(resultChannel)
+	sequence, err := b.sequences.push(request)
+	if err != nil {
+		b.sequences.fail(request, sequence, err)
+		return resultChannel
+	}
 
 	// Forward the request to the host with a sequence it can use to respond
 	b.hostProxy.Call("Listen", endpoint, sequence)
@@ -256,16 +434,15 @@ func (b *WebBrowserBridge) RespondListen(
 	listenerId int,
 	errorMessage string,
 ) {
-	// Get the response channel
-	resultChannel, ok := b.sequences.pop(sequence).(chan ListenResult)
-	if !ok {
-		panic("invalid response channel type")
-	}
-
-	// Respond
-	resultChannel <- ListenResult{
-		listenerId: listenerId,
-		err: ErrorFromErrorMessage(errorMessage),
+	// Deliver the response
+	err := respondListen(
+		b.sequences,
+		sequence,
+		listenerId,
+		ErrorFromErrorMessage(errorMessage),
+	)
+	if err != nil {
+		b.sequences.report(OperationListen, sequence, err)
 	}
 }
 
@@ -275,8 +452,13 @@ func (b *WebBrowserBridge) ListenerAccept(
 	// Create a buffered (non-blocking) result channel
 	resultChannel := make(chan ListenerAcceptResult, 1)
 
-	// Record the result channel and generate a sequence
-	sequence := b.sequences.push(resultChannel)
+	// Record the pending request and generate a sequence
+	request := listenerAcceptRequest(resultChannel)
+	sequence, err := b.sequences.push(request)
+	if err != nil {
+		b.sequences.fail(request, sequence, err)
+		return resultChannel
+	}
 
 	// Forward the request to the host with a sequence it can use to respond
 	b.hostProxy.Call("ListenerAccept", listenerId, sequence)
@@ -290,16 +472,15 @@ func (b *WebBrowserBridge) RespondListenerAccept(
 	connectionId int,
 	errorMessage string,
 ) {
-	// Get the response channel
-	resultChannel, ok := b.sequences.pop(sequence).(chan ListenerAcceptResult)
-	if !ok {
-		panic("invalid response channel type")
-	}
-
-	// Respond
-	resultChannel <- ListenerAcceptResult{
-		connectionId: connectionId,
-		err: ErrorFromErrorMessage(errorMessage),
+	// Deliver the response
+	err := respondListenerAccept(
+		b.sequences,
+		sequence,
+		connectionId,
+		ErrorFromErrorMessage(errorMessage),
+	)
+	if err != nil {
+		b.sequences.report(OperationListenerAccept, sequence, err)
 	}
 }
 
@@ -309,8 +490,13 @@ func (b *WebBrowserBridge) ListenerClose(
 	// Create a buffered (non-blocking) result channel
 	resultChannel := make(chan ListenerCloseResult, 1)
 
-	// Record the result channel and generate a sequence
-	sequence := b.sequences.push(resultChannel)
+	// Record the pending request and generate a sequence
+	request := listenerCloseRequest(resultChannel)
+	sequence, err := b.sequences.push(request)
+	if err != nil {
+		b.sequences.fail(request, sequence, err)
+		return resultChannel
+	}
 
 	// Forward the request to the host with a sequence it can use to respond
 	b.hostProxy.Call("ListenerClose", listenerId, sequence)
@@ -323,14 +509,196 @@ func (b *WebBrowserBridge) RespondListenerClose(
 	sequence int,
 	errorMessage string,
 ) {
-	// Get the response channel
-	resultChannel, ok := b.sequences.pop(sequence).(chan ListenerCloseResult)
-	if !ok {
-		panic("invalid response channel type")
+	// Deliver the response
+	err := respondListenerClose(
+		b.sequences,
+		sequence,
+		ErrorFromErrorMessage(errorMessage),
+	)
+	if err != nil {
+		b.sequences.report(OperationListenerClose, sequence, err)
+	}
+}
+
+func (b *WebBrowserBridge) Ping() chan PingResult {
+	// Create a buffered (non-blocking) result channel
+	resultChannel := make(chan PingResult, 1)
+
+	// Record the pending request and generate a sequence
+	request := newPingRequest(resultChannel)
+	sequence, err := b.sequences.push(request)
+	if err != nil {
+		b.sequences.fail(request, sequence, err)
+		return resultChannel
+	}
+
+	// Forward the request to the host with a sequence it can use to respond
+	b.hostProxy.Call("Ping", sequence)
+
+	// Return the result channel for the caller to wait on
+	return resultChannel
+}
+
+func (b *WebBrowserBridge) RespondPing(sequence int, errorMessage string) {
+	// Deliver the response
+	err := respondPing(
+		b.sequences,
+		sequence,
+		ErrorFromErrorMessage(errorMessage),
+	)
+	if err != nil {
+		b.sequences.report(OperationPing, sequence, err)
+	}
+}
+
+func (b *WebBrowserBridge) ConnectPacket(endpoint string) chan ConnectResult {
+	// Create a buffered (non-blocking) result channel
+	resultChannel := make(chan ConnectResult, 1)
+
+	// Record the pending request and generate a sequence
+	request := connectPacketRequest(resultChannel)
+	sequence, err := b.sequences.push(request)
+	if err != nil {
+		b.sequences.fail(request, sequence, err)
+		return resultChannel
+	}
+
+	// Forward the request to the host with a sequence it can use to respond
+	b.hostProxy.Call("ConnectPacket", endpoint, sequence)
+
+	// Return the result channel for the caller to wait on
+	return resultChannel
+}
+
+func (b *WebBrowserBridge) RespondConnectPacket(
+	sequence,
+	connectionId int,
+	errorMessage string,
+) {
+	// Deliver the response
+	err := respondConnectPacket(
+		b.sequences,
+		sequence,
+		connectionId,
+		ErrorFromErrorMessage(errorMessage),
+	)
+	if err != nil {
+		b.sequences.report(OperationConnectPacket, sequence, err)
+	}
+}
+
+func (b *WebBrowserBridge) ConnectionReadMessage(
+	connectionId int,
+) chan ConnectionReadResult {
+	// Create a buffered (non-blocking) result channel
+	resultChannel := make(chan ConnectionReadResult, 1)
+
+	// Record the pending request and generate a sequence
+	request := connectionReadMessageRequest(resultChannel)
+	sequence, err := b.sequences.push(request)
+	if err != nil {
+		b.sequences.fail(request, sequence, err)
+		return resultChannel
+	}
+
+	// Forward the request to the host with a sequence it can use to respond
+	b.hostProxy.Call("ConnectionReadMessage", connectionId, sequence)
+
+	// Return the result channel for the caller to wait on
+	return resultChannel
+}
+
+func (b *WebBrowserBridge) RespondConnectionReadMessage(
+	sequence int,
+	data64 string,
+	errorMessage string,
+) {
+	// Deliver the response
+	err := respondConnectionReadMessage(
+		b.sequences,
+		sequence,
+		data64,
+		ErrorFromErrorMessage(errorMessage),
+	)
+	if err != nil {
+		b.sequences.report(OperationConnectionReadMessage, sequence, err)
+	}
+}
+
+func (b *WebBrowserBridge) ConnectionWriteMessage(
+	connectionId int,
+	data []byte,
+) chan ConnectionWriteResult {
+	// Create a buffered (non-blocking) result channel
+	resultChannel := make(chan ConnectionWriteResult, 1)
+
+	// Record the pending request and generate a sequence
+	request := connectionWriteMessageRequest(resultChannel)
+	sequence, err := b.sequences.push(request)
+	if err != nil {
+		b.sequences.fail(request, sequence, err)
+		return resultChannel
 	}
 
-	// Respond
-	resultChannel <- ListenerCloseResult{
-		err: ErrorFromErrorMessage(errorMessage),
+	// Encode the data
+	data64 := base64.StdEncoding.EncodeToString(data)
+
+	// Forward the request to the host with a sequence it can use to respond
+	b.hostProxy.Call("ConnectionWriteMessage", connectionId, data64, sequence)
+
+	// Return the result channel for the caller to wait on
+	return resultChannel
+}
+
+func (b *WebBrowserBridge) RespondConnectionWriteMessage(
+	sequence,
+	count int,
+	errorMessage string,
+) {
+	// Deliver the response
+	err := respondConnectionWriteMessage(
+		b.sequences,
+		sequence,
+		count,
+		ErrorFromErrorMessage(errorMessage),
+	)
+	if err != nil {
+		b.sequences.report(OperationConnectionWriteMessage, sequence, err)
+	}
+}
+
+func (b *WebBrowserBridge) ListenPacket(endpoint string) chan ListenResult {
+	// Create a buffered (non-blocking) result channel
+	resultChannel := make(chan ListenResult, 1)
+
+	// Record the pending request and generate a sequence
+	request := listenPacketRequest(resultChannel)
+	sequence, err := b.sequences.push(request)
+	if err != nil {
+		b.sequences.fail(request, sequence, err)
+		return resultChannel
+	}
+
+	// Forward the request to the host with a sequence it can use to respond
+	b.hostProxy.Call("ListenPacket", endpoint, sequence)
+
+	// Return the result channel for the caller to wait on
+	return resultChannel
+}
+
+func (b *WebBrowserBridge) RespondListenPacket(
+	sequence,
+	listenerId int,
+	errorMessage string,
+) {
+	// Deliver the response
+	err := respondListenPacket(
+		b.sequences,
+		sequence,
+		listenerId,
+		ErrorFromErrorMessage(errorMessage),
+	)
+	if err != nil {
+		b.sequences.report(OperationListenPacket, sequence, err)
 	}
 }
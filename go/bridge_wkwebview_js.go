@@ -3,7 +3,11 @@
 package ipc
 
 // System imports
-import "encoding/base64"
+import (
+	"encoding/base64"
+	"errors"
+	"time"
+)
 
 // GopherJS imports
 import "github.com/gopherjs/gopherjs/js"
@@ -16,6 +20,20 @@ const (
 	WKWebViewBridgeActionListen
 	WKWebViewBridgeActionListenerAccept
 	WKWebViewBridgeActionListenerClose
+	WKWebViewBridgeActionPing
+	WKWebViewBridgeActionConnectionCloseRead
+	WKWebViewBridgeActionConnectionCloseWrite
+	WKWebViewBridgeActionConnectPacket
+	WKWebViewBridgeActionConnectionReadMessage
+	WKWebViewBridgeActionConnectionWriteMessage
+	WKWebViewBridgeActionListenPacket
+)
+
+// ErrInvalidInitializationMessage is reported to LogErrorHandler when the host
+// passes an initialization message that isn't valid Base64, in which case the
+// bridge isn't initialized.
+var ErrInvalidInitializationMessage = errors.New(
+	"unable to decode initialization message",
 )
 
 // WKWebViewBridge implements the Bridge interface for Cocoa WKWebView
@@ -63,7 +81,8 @@ func init() {
 				message64.String(),
 			)
 			if err != nil {
-				panic("unable to decode initialization message")
+				LogErrorHandler(ErrInvalidInitializationMessage)
+				return
 			}
 
 			// Call HostInitialize
@@ -72,12 +91,47 @@ func init() {
 	)
 }
 
+// Outstanding returns the number of requests that have been forwarded to the
+// host and are still awaiting responses.
+func (b *WKWebViewBridge) Outstanding() int {
+	return b.sequences.outstanding()
+}
+
+// Pending returns a description of each request that has been forwarded to the
+// host and is still awaiting a response, oldest first.
+func (b *WKWebViewBridge) Pending() []PendingRequest {
+	return b.sequences.pending()
+}
+
+// SetRequestTimeout sets the time that subsequent requests will wait for a
+// response from the host before failing with ErrRequestTimeout.  Responses
+// that arrive after a request has timed out are reported to the error handler
+// as responses for unknown sequences.  A timeout of 0, the default, disables
+// timeouts.
+func (b *WKWebViewBridge) SetRequestTimeout(timeout time.Duration) {
+	b.sequences.setTimeout(timeout)
+}
+
+// SetErrorHandler sets the handler for errors that can't be returned to a
+// caller, such as responses from the host for unknown sequences or for the
+// wrong type of request.  Where the affected request can be identified, the
+// error is also delivered to its caller.  A nil handler restores the default,
+// LogErrorHandler.
+func (b *WKWebViewBridge) SetErrorHandler(handler ErrorHandler) {
+	b.sequences.setErrorHandler(handler)
+}
+
 func (b *WKWebViewBridge) Connect(endpoint string) chan ConnectResult {
 	// Create a buffered (non-blocking) result channel
 	resultChannel := make(chan ConnectResult, 1)
 
-	// Record the result channel and generate a sequence
-	sequence := b.sequences.push(resultChannel)
+	// Record the pending request and generate a sequence
+	request := connectRequest(resultChannel)
+	sequence, err := b.sequences.push(request)
+	if err != nil {
+		b.sequences.fail(request, sequence, err)
+		return resultChannel
+	}
 
 	// Forward the request to the host with a sequence it can use to respond
 	b.hostMessenger.Call("postMessage", map[string]interface{}{
@@ -95,16 +149,15 @@ func (b *WKWebViewBridge) RespondConnect(
 	connectionId int,
 	errorMessage64 string,
 ) {
-	// Get the response channel
-	resultChannel, ok := b.sequences.pop(sequence).(chan ConnectResult)
-	if !ok {
-		panic("invalid response channel type")
-	}
-
-	// Respond
-	resultChannel <- ConnectResult{
-		connectionId: connectionId,
-		err: ErrorFromBase64EncodedErrorMessage(errorMessage64),
+	// Deliver the response
+	err := respondConnect(
+		b.sequences,
+		sequence,
+		connectionId,
+		ErrorFromBase64EncodedErrorMessage(errorMessage64),
+	)
+	if err != nil {
+		b.sequences.report(OperationConnect, sequence, err)
 	}
 }
 
@@ -115,8 +168,13 @@ func (b *WKWebViewBridge) ConnectionRead(
 	// Create a buffered (non-blocking) result channel
 	resultChannel := make(chan ConnectionReadResult, 1)
 
-	// Record the result channel and generate a sequence
-	sequence := b.sequences.push(resultChannel)
+	// Record the pending request and generate a sequence
+	request := connectionReadRequest(resultChannel)
+	sequence, err := b.sequences.push(request)
+	if err != nil {
+		b.sequences.fail(request, sequence, err)
+		return resultChannel
+	}
 
 	// Forward the request to the host with a sequence it can use to respond
 	b.hostMessenger.Call("postMessage", map[string]interface{}{
@@ -135,22 +193,15 @@ func (b *WKWebViewBridge) RespondConnectionRead(
 	data64 string,
 	errorMessage64 string,
 ) {
-	// Get the response channel
-	resultChannel, ok := b.sequences.pop(sequence).(chan ConnectionReadResult)
-	if !ok {
-		panic("invalid response channel type")
-	}
-
-	// Decode the data
-	data, err := base64.StdEncoding.DecodeString(data64)
+	// Deliver the response
+	err := respondConnectionRead(
+		b.sequences,
+		sequence,
+		data64,
+		ErrorFromBase64EncodedErrorMessage(errorMessage64),
+	)
 	if err != nil {
-		panic("host sent gibberish data")
-	}
-
-	// Respond
-	resultChannel <- ConnectionReadResult{
-		data: data,
-		err: ErrorFromBase64EncodedErrorMessage(errorMessage64),
+		b.sequences.report(OperationConnectionRead, sequence, err)
 	}
 }
 
@@ -161,8 +212,13 @@ func (b *WKWebViewBridge) ConnectionWrite(
 	// Create a buffered (non-blocking) result channel
 	resultChannel := make(chan ConnectionWriteResult, 1)
 
-	// Record the result channel and generate a sequence
-	sequence := b.sequences.push(resultChannel)
+	// Record the pending request and generate a sequence
+	request := connectionWriteRequest(resultChannel)
+	sequence, err := b.sequences.push(request)
+	if err != nil {
+		b.sequences.fail(request, sequence, err)
+		return resultChannel
+	}
 
 	// Encode the data
 	data64 := base64.StdEncoding.EncodeToString(data)
@@ -184,16 +240,15 @@ func (b *WKWebViewBridge) RespondConnectionWrite(
 	count int,
 	errorMessage64 string,
 ) {
-	// Get the response channel
-	resultChannel, ok := b.sequences.pop(sequence).(chan ConnectionWriteResult)
-	if !ok {
-		panic("invalid response channel type")
-	}
-
-	// Respond
-	resultChannel <- ConnectionWriteResult{
-		count: count,
-		err: ErrorFromBase64EncodedErrorMessage(errorMessage64),
+	// Deliver the response
+	err := respondConnectionWrite(
+		b.sequences,
+		sequence,
+		count,
+		ErrorFromBase64EncodedErrorMessage(errorMessage64),
+	)
+	if err != nil {
+		b.sequences.report(OperationConnectionWrite, sequence, err)
 	}
 }
 
@@ -203,8 +258,13 @@ func (b *WKWebViewBridge) ConnectionClose(
 	// Create a buffered (non-blocking) result channel
 	resultChannel := make(chan ConnectionCloseResult, 1)
 
-	// Record the result channel and generate a sequence
-	sequence := b.sequences.push(resultChannel)
+	// Record the pending request and generate a sequence
+	request := connectionCloseRequest(resultChannel)
+	sequence, err := b.sequences.push(request)
+	if err != nil {
+		b.sequences.fail(request, sequence, err)
+		return resultChannel
+	}
 
 	// Forward the request to the host with a sequence it can use to respond
 	b.hostMessenger.Call("postMessage", map[string]interface{}{
@@ -217,19 +277,98 @@ func (b *WKWebViewBridge) ConnectionClose(
 	return resultChannel
 }
 
+func (b *WKWebViewBridge) ConnectionCloseRead(
+	connectionId int,
+) chan ConnectionCloseResult {
+	// Create a buffered (non-blocking) result channel
+	resultChannel := make(chan ConnectionCloseResult, 1)
+
+	// Record the pending request and generate a sequence
+	request := connectionCloseReadRequest(resultChannel)
+	sequence, err := b.sequences.push(request)
+	if err != nil {
+		b.sequences.fail(request, sequence, err)
+		return resultChannel
+	}
+
+	// Forward the request to the host with a sequence it can use to respond
+	b.hostMessenger.Call("postMessage", map[string]interface{}{
+		"sequence": sequence,
+		"action": WKWebViewBridgeActionConnectionCloseRead,
+		"connectionId": connectionId,
+	})
+
+	// Return the result channel for the caller to wait on
+	return resultChannel
+}
+
+func (b *WKWebViewBridge) ConnectionCloseWrite(
+	connectionId int,
+) chan ConnectionCloseResult {
+	// Create a buffered (non-blocking) result channel
+	resultChannel := make(chan ConnectionCloseResult, 1)
+
+	// Record the pending request and generate a sequence
+	request := connectionCloseWriteRequest(resultChannel)
+	sequence, err := b.sequences.push(request)
+	if err != nil {
+		b.sequences.fail(request, sequence, err)
+		return resultChannel
+	}
+
+	// Forward the request to the host with a sequence it can use to respond
+	b.hostMessenger.Call("postMessage", map[string]interface{}{
+		"sequence": sequence,
+		"action": WKWebViewBridgeActionConnectionCloseWrite,
+		"connectionId": connectionId,
+	})
+
+	// Return the result channel for the caller to wait on
+	return resultChannel
+}
+
 func (b *WKWebViewBridge) RespondConnectionClose(
 	sequence int,
 	errorMessage64 string,
 ) {
-	// Get the response channel
-	resultChannel, ok := b.sequences.pop(sequence).(chan ConnectionCloseResult)
-	if !ok {
-		panic("invalid response channel type")
+	// Deliver the response
+	err := respondConnectionClose(
+		b.sequences,
+		sequence,
+		ErrorFromBase64EncodedErrorMessage(errorMessage64),
+	)
+	if err != nil {
+		b.sequences.report(OperationConnectionClose, sequence, err)
+	}
+}
+
+func (b *WKWebViewBridge) RespondConnectionCloseRead(
+	sequence int,
+	errorMessage64 string,
+) {
+	// Deliver the response
+	err := respondConnectionCloseRead(
+		b.sequences,
+		sequence,
+		ErrorFromBase64EncodedErrorMessage(errorMessage64),
+	)
+	if err != nil {
+		b.sequences.report(OperationConnectionCloseRead, sequence, err)
 	}
+}
 
-	// Respond
-	resultChannel <- ConnectionCloseResult{
-		err: ErrorFromBase64EncodedErrorMessage(errorMessage64),
+func (b *WKWebViewBridge) RespondConnectionCloseWrite(
+	sequence int,
+	errorMessage64 string,
+) {
+	// Deliver the response
+	err := respondConnectionCloseWrite(
+		b.sequences,
+		sequence,
+		ErrorFromBase64EncodedErrorMessage(errorMessage64),
+	)
+	if err != nil {
+		b.sequences.report(OperationConnectionCloseWrite, sequence, err)
 	}
 }
 
@@ -237,8 +376,13 @@ func (b *WKWebViewBridge) Listen(endpoint string) chan ListenResult {
 	// Create a buffered (non-blocking) result channel
 	resultChannel := make(chan ListenResult, 1)
 
-	// Record the result channel and generate a sequence
-	sequence := b.sequences.push(resultChannel)
+	// Record the pending request and generate a sequence
+	request := listenRequest(resultChannel)
+	sequence, err := b.sequences.push(request)
+	if err != nil {
+		b.sequences.fail(request, sequence, err)
+		return resultChannel
+	}
 
 	// Forward the request to the host with a sequence it can use to respond
 	b.hostMessenger.Call("postMessage", map[string]interface{}{
@@ -256,16 +400,15 @@ func (b *WKWebViewBridge) RespondListen(
 	listenerId int,
 	errorMessage64 string,
 ) {
-	// Get the response channel
-	resultChannel, ok := b.sequences.pop(sequence).(chan ListenResult)
-	if !ok {
-		panic("invalid response channel type")
-	}
-
-	// Respond
-	resultChannel <- ListenResult{
-		listenerId: listenerId,
-		err: ErrorFromBase64EncodedErrorMessage(errorMessage64),
+	// Deliver the response
+	err := respondListen(
+		b.sequences,
+		sequence,
+		listenerId,
+		ErrorFromBase64EncodedErrorMessage(errorMessage64),
+	)
+	if err != nil {
+		b.sequences.report(OperationListen, sequence, err)
 	}
 }
 
@@ -275,8 +418,13 @@ func (b *WKWebViewBridge) ListenerAccept(
 	// Create a buffered (non-blocking) result channel
 	resultChannel := make(chan ListenerAcceptResult, 1)
 
-	// Record the result channel and generate a sequence
-	sequence := b.sequences.push(resultChannel)
+	// Record the pending request and generate a sequence
+	request := listenerAcceptRequest(resultChannel)
+	sequence, err := b.sequences.push(request)
+	if err != nil {
+		b.sequences.fail(request, sequence, err)
+		return resultChannel
+	}
 
 	// Forward the request to the host with a sequence it can use to respond
 	b.hostMessenger.Call("postMessage", map[string]interface{}{
@@ -294,16 +442,15 @@ func (b *WKWebViewBridge) RespondListenerAccept(
 	connectionId int,
 	errorMessage64 string,
 ) {
-	// Get the response channel
-	resultChannel, ok := b.sequences.pop(sequence).(chan ListenerAcceptResult)
-	if !ok {
-		panic("invalid response channel type")
-	}
-
-	// Respond
-	resultChannel <- ListenerAcceptResult{
-		connectionId: connectionId,
-		err: ErrorFromBase64EncodedErrorMessage(errorMessage64),
+	// Deliver the response
+	err := respondListenerAccept(
+		b.sequences,
+		sequence,
+		connectionId,
+		ErrorFromBase64EncodedErrorMessage(errorMessage64),
+	)
+	if err != nil {
+		b.sequences.report(OperationListenerAccept, sequence, err)
 	}
 }
 
@@ -313,8 +460,13 @@ func (b *WKWebViewBridge) ListenerClose(
 	// Create a buffered (non-blocking) result channel
 	resultChannel := make(chan ListenerCloseResult, 1)
 
-	// Record the result channel and generate a sequence
-	sequence := b.sequences.push(resultChannel)
+	// Record the pending request and generate a sequence
+	request := listenerCloseRequest(resultChannel)
+	sequence, err := b.sequences.push(request)
+	if err != nil {
+		b.sequences.fail(request, sequence, err)
+		return resultChannel
+	}
 
 	// Forward the request to the host with a sequence it can use to respond
 	b.hostMessenger.Call("postMessage", map[string]interface{}{
@@ -331,14 +483,216 @@ func (b *WKWebViewBridge) RespondListenerClose(
 	sequence int,
 	errorMessage64 string,
 ) {
-	// Get the response channel
-	resultChannel, ok := b.sequences.pop(sequence).(chan ListenerCloseResult)
-	if !ok {
-		panic("invalid response channel type")
+	// Deliver the response
+	err := respondListenerClose(
+		b.sequences,
+		sequence,
+		ErrorFromBase64EncodedErrorMessage(errorMessage64),
+	)
+	if err != nil {
+		b.sequences.report(OperationListenerClose, sequence, err)
+	}
+}
+
+func (b *WKWebViewBridge) Ping() chan PingResult {
+	// Create a buffered (non-blocking) result channel
+	resultChannel := make(chan PingResult, 1)
+
+	// Record the pending request and generate a sequence
+	request := newPingRequest(resultChannel)
+	sequence, err := b.sequences.push(request)
+	if err != nil {
+		b.sequences.fail(request, sequence, err)
+		return resultChannel
+	}
+
+	// Forward the request to the host with a sequence it can use to respond
+	b.hostMessenger.Call("postMessage", map[string]interface{}{
+		"sequence": sequence,
+		"action": WKWebViewBridgeActionPing,
+	})
+
+	// Return the result channel for the caller to wait on
+	return resultChannel
+}
+
+func (b *WKWebViewBridge) RespondPing(sequence int, errorMessage64 string) {
+	// Deliver the response
+	err := respondPing(
+		b.sequences,
+		sequence,
+		ErrorFromBase64EncodedErrorMessage(errorMessage64),
+	)
+	if err != nil {
+		b.sequences.report(OperationPing, sequence, err)
+	}
+}
+
+func (b *WKWebViewBridge) ConnectPacket(endpoint string) chan ConnectResult {
+	// Create a buffered (non-blocking) result channel
+	resultChannel := make(chan ConnectResult, 1)
+
+	// Record the pending request and generate a sequence
+	request := connectPacketRequest(resultChannel)
+	sequence, err := b.sequences.push(request)
+	if err != nil {
+		b.sequences.fail(request, sequence, err)
+		return resultChannel
+	}
+
+	// Forward the request to the host with a sequence it can use to respond
+	b.hostMessenger.Call("postMessage", map[string]interface{}{
+		"sequence": sequence,
+		"action": WKWebViewBridgeActionConnectPacket,
+		"endpoint": endpoint,
+	})
+
+	// Return the result channel for the caller to wait on
+	return resultChannel
+}
+
+func (b *WKWebViewBridge) RespondConnectPacket(
+	sequence,
+	connectionId int,
+	errorMessage64 string,
+) {
+	// Deliver the response
+	err := respondConnectPacket(
+		b.sequences,
+		sequence,
+		connectionId,
+		ErrorFromBase64EncodedErrorMessage(errorMessage64),
+	)
+	if err != nil {
+		b.sequences.report(OperationConnectPacket, sequence, err)
+	}
+}
+
+func (b *WKWebViewBridge) ConnectionReadMessage(
+	connectionId int,
+) chan ConnectionReadResult {
+	// Create a buffered (non-blocking) result channel
+	resultChannel := make(chan ConnectionReadResult, 1)
+
+	// Record the pending request and generate a sequence
+	request := connectionReadMessageRequest(resultChannel)
+	sequence, err := b.sequences.push(request)
+	if err != nil {
+		b.sequences.fail(request, sequence, err)
+		return resultChannel
+	}
+
+	// Forward the request to the host with a sequence it can use to respond
+	b.hostMessenger.Call("postMessage", map[string]interface{}{
+		"sequence": sequence,
+		"action": WKWebViewBridgeActionConnectionReadMessage,
+		"connectionId": connectionId,
+	})
+
+	// Return the result channel for the caller to wait on
+	return resultChannel
+}
+
+func (b *WKWebViewBridge) RespondConnectionReadMessage(
+	sequence int,
+	data64 string,
+	errorMessage64 string,
+) {
+	// Deliver the response
+	err := respondConnectionReadMessage(
+		b.sequences,
+		sequence,
+		data64,
+		ErrorFromBase64EncodedErrorMessage(errorMessage64),
+	)
+	if err != nil {
+		b.sequences.report(OperationConnectionReadMessage, sequence, err)
+	}
+}
+
+func (b *WKWebViewBridge) ConnectionWriteMessage(
+	connectionId int,
+	data []byte,
+) chan ConnectionWriteResult {
+	// Create a buffered (non-blocking) result channel
+	resultChannel := make(chan ConnectionWriteResult, 1)
+
+	// Record the pending request and generate a sequence
+	request := connectionWriteMessageRequest(resultChannel)
+	sequence, err := b.sequences.push(request)
+	if err != nil {
+		b.sequences.fail(request, sequence, err)
+		return resultChannel
+	}
+
+	// Encode the data
+	data64 := base64.StdEncoding.EncodeToString(data)
+
+	// Forward the request to the host with a sequence it can use to respond
+	b.hostMessenger.Call("postMessage", map[string]interface{}{
+		"sequence": sequence,
+		"action": WKWebViewBridgeActionConnectionWriteMessage,
+		"connectionId": connectionId,
+		"data64": data64,
+	})
+
+	// Return the result channel for the caller to wait on
+	return resultChannel
+}
+
+func (b *WKWebViewBridge) RespondConnectionWriteMessage(
+	sequence,
+	count int,
+	errorMessage64 string,
+) {
+	// Deliver the response
+	err := respondConnectionWriteMessage(
+		b.sequences,
+		sequence,
+		count,
+		ErrorFromBase64EncodedErrorMessage(errorMessage64),
+	)
+	if err != nil {
+		b.sequences.report(OperationConnectionWriteMessage, sequence, err)
+	}
+}
+
+func (b *WKWebViewBridge) ListenPacket(endpoint string) chan ListenResult {
+	// Create a buffered (non-blocking) result channel
+	resultChannel := make(chan ListenResult, 1)
+
+	// Record the pending request and generate a sequence
+	request := listenPacketRequest(resultChannel)
+	sequence, err := b.sequences.push(request)
+	if err != nil {
+		b.sequences.fail(request, sequence, err)
+		return resultChannel
 	}
 
-	// Respond
-	resultChannel <- ListenerCloseResult{
-		err: ErrorFromBase64EncodedErrorMessage(errorMessage64),
+	// Forward the request to the host with a sequence it can use to respond
+	b.hostMessenger.Call("postMessage", map[string]interface{}{
+		"sequence": sequence,
+		"action": WKWebViewBridgeActionListenPacket,
+		"endpoint": endpoint,
+	})
+
+	// Return the result channel for the caller to wait on
+	return resultChannel
+}
+
+func (b *WKWebViewBridge) RespondListenPacket(
+	sequence,
+	listenerId int,
+	errorMessage64 string,
+) {
+	// Deliver the response
+	err := respondListenPacket(
+		b.sequences,
+		sequence,
+		listenerId,
+		ErrorFromBase64EncodedErrorMessage(errorMessage64),
+	)
+	if err != nil {
+		b.sequences.report(OperationListenPacket, sequence, err)
 	}
 }
@@ -0,0 +1,106 @@
+// +build !js
+
+package ipc
+
+// System imports
+import (
+	"errors"
+	"net"
+	"os"
+)
+
+// ErrPeerNotAuthorized is returned by the built-in accept policies when a peer
+// fails verification.
+var ErrPeerNotAuthorized = errors.New("peer not authorized")
+
+// PeerCredentials represents the identity of the process on the other end of
+// a native IPC connection, as reported by the operating system.  Fields that
+// aren't available on the current platform are set to -1 (for numeric fields)
+// or the empty string (for Executable).
+type PeerCredentials struct {
+	// UID is the effective user id of the peer process.  It is not available
+	// on Windows.
+	UID int
+
+	// GID is the effective group id of the peer process.  It is not available
+	// on Windows.
+	GID int
+
+	// PID is the process id of the peer process.
+	PID int
+
+	// Executable is the path of the peer process' executable image.  It is
+	// available on Linux and Windows, but only if the peer is still running and
+	// the current process has permission to inspect it.
+	Executable string
+}
+
+// AcceptPolicy is a function that decides whether or not to accept a
+// connection based on its peer's credentials.  It should return nil to accept
+// the connection or an error describing why the peer is not authorized.
+type AcceptPolicy func(credentials *PeerCredentials) error
+
+// policyListener wraps a native listener to enforce an AcceptPolicy.
+type policyListener struct {
+	net.Listener
+
+	// The policy to enforce
+	policy AcceptPolicy
+}
+
+func (l *policyListener) Accept() (net.Conn, error) {
+	for {
+		// Accept a connection
+		connection, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		// Check its credentials.  If we can't determine them, reject the
+		// connection.
+		credentials, err := PeerCredentialsOf(connection)
+		if err == nil {
+			err = l.policy(credentials)
+		}
+
+		// If the peer is authorized, we're done, otherwise close the
+		// connection and keep waiting.  We don't return an error for rejected
+		// connections because most accept loops treat errors as fatal, and an
+		// unauthorized peer shouldn't be able to shut down the listener.
+		if err == nil {
+			return connection, nil
+		}
+		connection.Close()
+	}
+}
+
+// SameUserPolicy is an AcceptPolicy that only accepts connections from
+// processes running as the same user as the current process.  On Windows,
+// where peer user ids aren't available, it rejects all connections.
+func SameUserPolicy(credentials *PeerCredentials) error {
+	if credentials.UID == -1 || credentials.UID != os.Getuid() {
+		return ErrPeerNotAuthorized
+	}
+	return nil
+}
+
+// ExecutablePolicy creates an AcceptPolicy that accepts connections from
+// processes running as the same user as the current process (where that can
+// be determined) and whose executable is one of the specified paths.
+func ExecutablePolicy(executables ...string) AcceptPolicy {
+	return func(credentials *PeerCredentials) error {
+		// Check the user if possible
+		if credentials.UID != -1 && credentials.UID != os.Getuid() {
+			return ErrPeerNotAuthorized
+		}
+
+		// Check the executable
+		for _, executable := range executables {
+			if credentials.Executable != "" &&
+				credentials.Executable == executable {
+				return nil
+			}
+		}
+		return ErrPeerNotAuthorized
+	}
+}
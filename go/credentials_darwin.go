@@ -0,0 +1,65 @@
+// +build darwin,!js
+
+package ipc
+
+// System imports
+import (
+	"errors"
+	"net"
+)
+
+// Extended system imports
+import "golang.org/x/sys/unix"
+
+// PeerCredentialsOf returns the credentials of the process on the other end of
+// a native IPC connection.  On OS X, these are obtained using LOCAL_PEERCRED
+// and LOCAL_PEERPID and reflect the peer at the time the connection was
+// established.  The peer's executable is not available.
+func PeerCredentialsOf(connection net.Conn) (*PeerCredentials, error) {
+	// Extract the underlying socket
+	unixConnection, ok := connection.(*net.UnixConn)
+	if !ok {
+		return nil, errors.New("connection is not a Unix domain socket")
+	}
+	rawConnection, err := unixConnection.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	// Query credentials
+	var credentials *unix.Xucred
+	var pid int
+	var credentialsErr error
+	err = rawConnection.Control(func(fd uintptr) {
+		credentials, credentialsErr = unix.GetsockoptXucred(
+			int(fd),
+			unix.SOL_LOCAL,
+			unix.LOCAL_PEERCRED,
+		)
+		if credentialsErr == nil {
+			pid, credentialsErr = unix.GetsockoptInt(
+				int(fd),
+				unix.SOL_LOCAL,
+				unix.LOCAL_PEERPID,
+			)
+		}
+	})
+	if err != nil {
+		return nil, err
+	} else if credentialsErr != nil {
+		return nil, credentialsErr
+	}
+
+	// Extract the primary group, if any
+	gid := -1
+	if credentials.Ngroups > 0 {
+		gid = int(credentials.Groups[0])
+	}
+
+	// All done
+	return &PeerCredentials{
+		UID: int(credentials.Uid),
+		GID: gid,
+		PID: pid,
+	}, nil
+}
@@ -0,0 +1,55 @@
+// +build linux,!js
+
+package ipc
+
+// System imports
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+// PeerCredentialsOf returns the credentials of the process on the other end of
+// a native IPC connection.  On Linux, these are obtained using SO_PEERCRED and
+// reflect the peer at the time the connection was established.
+func PeerCredentialsOf(connection net.Conn) (*PeerCredentials, error) {
+	// Extract the underlying socket
+	unixConnection, ok := connection.(*net.UnixConn)
+	if !ok {
+		return nil, errors.New("connection is not a Unix domain socket")
+	}
+	rawConnection, err := unixConnection.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	// Query credentials
+	var credentials *syscall.Ucred
+	var credentialsErr error
+	err = rawConnection.Control(func(fd uintptr) {
+		credentials, credentialsErr = syscall.GetsockoptUcred(
+			int(fd),
+			syscall.SOL_SOCKET,
+			syscall.SO_PEERCRED,
+		)
+	})
+	if err != nil {
+		return nil, err
+	} else if credentialsErr != nil {
+		return nil, credentialsErr
+	}
+
+	// Look up the executable.  This may fail if the peer has exited or we
+	// aren't allowed to inspect it, in which case we leave it empty.
+	executable, _ := os.Readlink(fmt.Sprintf("/proc/%d/exe", credentials.Pid))
+
+	// All done
+	return &PeerCredentials{
+		UID:        int(credentials.Uid),
+		GID:        int(credentials.Gid),
+		PID:        int(credentials.Pid),
+		Executable: executable,
+	}, nil
+}
@@ -0,0 +1,15 @@
+// +build !linux,!darwin,!windows,!js
+
+package ipc
+
+// System imports
+import (
+	"errors"
+	"net"
+)
+
+// PeerCredentialsOf returns the credentials of the process on the other end of
+// a native IPC connection.  It is not currently supported on this platform.
+func PeerCredentialsOf(connection net.Conn) (*PeerCredentials, error) {
+	return nil, errors.New("peer credentials not supported on this platform")
+}
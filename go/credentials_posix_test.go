@@ -0,0 +1,99 @@
+// +build !windows,!js
+
+package ipc
+
+// System imports
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestPeerCredentialsOf(t *testing.T) {
+	// Create a connected socket pair, whose peer is this process
+	first, second := socketPair(t)
+	defer first.Close()
+	defer second.Close()
+
+	// Verify the credentials
+	credentials, err := PeerCredentialsOf(first)
+	if err != nil {
+		t.Fatal("unable to query credentials:", err)
+	}
+	if credentials.UID != os.Geteuid() {
+		t.Error("unexpected uid:", credentials.UID)
+	}
+	if credentials.GID != os.Getegid() {
+		t.Error("unexpected gid:", credentials.GID)
+	}
+	if credentials.PID != os.Getpid() {
+		t.Error("unexpected pid:", credentials.PID)
+	}
+	if runtime.GOOS == "linux" {
+		executable, err := os.Executable()
+		if err != nil {
+			t.Fatal("unable to determine executable:", err)
+		}
+		executable, _ = filepath.EvalSymlinks(executable)
+		if credentials.Executable != executable {
+			t.Error("unexpected executable:", credentials.Executable)
+		}
+	}
+
+	// Connections other than Unix domain sockets should be rejected
+	pipeFirst, pipeSecond := net.Pipe()
+	defer pipeFirst.Close()
+	defer pipeSecond.Close()
+	if _, err := PeerCredentialsOf(pipeFirst); err == nil {
+		t.Error("credentials returned for in-memory pipe")
+	}
+}
+
+func TestAcceptPolicy(t *testing.T) {
+	// Listen with a policy that rejects this process
+	endpoint := testEndpoint(t)
+	listener, err := ListenIPCWithOptions(endpoint, &ListenOptions{
+		AcceptPolicy: ExecutablePolicy(filepath.Join(os.TempDir(), "gib-other")),
+	})
+	if err != nil {
+		t.Fatal("unable to listen:", err)
+	}
+	accepted := acceptOne(listener)
+
+	// Rejected connections should be closed without being returned
+	connection, err := DialIPC(endpoint)
+	if err != nil {
+		t.Fatal("unable to connect:", err)
+	}
+	connection.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := connection.Read(make([]byte, 1)); err == nil || isTimeoutError(err) {
+		t.Fatal("rejected connection not closed:", err)
+	}
+	connection.Close()
+	select {
+	case <-accepted:
+		t.Fatal("rejected connection accepted")
+	default:
+	}
+	listener.Close()
+
+	// A policy that permits this process should accept the connection
+	endpoint = testEndpoint(t)
+	listener, err = ListenIPCWithOptions(endpoint, &ListenOptions{
+		AcceptPolicy: SameUserPolicy,
+	})
+	if err != nil {
+		t.Fatal("unable to listen:", err)
+	}
+	defer listener.Close()
+	accepted = acceptOne(listener)
+	connection, err = DialIPC(endpoint)
+	if err != nil {
+		t.Fatal("unable to connect:", err)
+	}
+	defer connection.Close()
+	receiveConn(t, accepted).Close()
+}
@@ -0,0 +1,56 @@
+// +build !js
+
+package ipc
+
+// System imports
+import (
+	"os"
+	"testing"
+)
+
+func TestSameUserPolicy(t *testing.T) {
+	cases := []struct {
+		uid        int
+		authorized bool
+	}{
+		{os.Getuid(), true},
+		{os.Getuid() + 1, false},
+		{-1, false},
+	}
+	for _, c := range cases {
+		err := SameUserPolicy(&PeerCredentials{UID: c.uid, GID: -1, PID: 1})
+		if authorized := err == nil; authorized != c.authorized {
+			t.Errorf("uid %d: authorized = %t, expected %t", c.uid, authorized, c.authorized)
+		} else if err != nil && err != ErrPeerNotAuthorized {
+			t.Errorf("uid %d: unexpected error: %v", c.uid, err)
+		}
+	}
+}
+
+func TestExecutablePolicy(t *testing.T) {
+	policy := ExecutablePolicy("/usr/bin/backend", "/opt/app/backend")
+	cases := []struct {
+		uid        int
+		executable string
+		authorized bool
+	}{
+		{os.Getuid(), "/usr/bin/backend", true},
+		{os.Getuid(), "/opt/app/backend", true},
+		{-1, "/opt/app/backend", true},
+		{os.Getuid(), "/usr/bin/other", false},
+		{os.Getuid(), "", false},
+		{os.Getuid() + 1, "/usr/bin/backend", false},
+	}
+	for _, c := range cases {
+		err := policy(&PeerCredentials{UID: c.uid, GID: -1, PID: 1, Executable: c.executable})
+		if authorized := err == nil; authorized != c.authorized {
+			t.Errorf("uid %d, executable %q: authorized = %t, expected %t",
+				c.uid, c.executable, authorized, c.authorized)
+		}
+	}
+
+	// A policy without executables rejects everything
+	if ExecutablePolicy()(&PeerCredentials{UID: os.Getuid()}) == nil {
+		t.Error("empty executable policy authorized peer")
+	}
+}
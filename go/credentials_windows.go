@@ -0,0 +1,106 @@
+// +build windows,!js
+
+package ipc
+
+// System imports
+import (
+	"errors"
+	"net"
+	"syscall"
+	"unsafe"
+)
+
+// Windows API functions not provided by the syscall package
+var (
+	modkernel32 = syscall.NewLazyDLL("kernel32.dll")
+
+	procGetNamedPipeClientProcessId = modkernel32.NewProc(
+		"GetNamedPipeClientProcessId",
+	)
+	procGetNamedPipeServerProcessId = modkernel32.NewProc(
+		"GetNamedPipeServerProcessId",
+	)
+	procQueryFullProcessImageNameW = modkernel32.NewProc(
+		"QueryFullProcessImageNameW",
+	)
+)
+
+// processQueryLimitedInformation is the PROCESS_QUERY_LIMITED_INFORMATION
+// access right.
+const processQueryLimitedInformation = 0x1000
+
+// processExecutable looks up the executable path for a process.
+func processExecutable(pid uint32) (string, error) {
+	// Open the process
+	process, err := syscall.OpenProcess(
+		processQueryLimitedInformation,
+		false,
+		pid,
+	)
+	if err != nil {
+		return "", err
+	}
+	defer syscall.CloseHandle(process)
+
+	// Query the image name
+	buffer := make([]uint16, syscall.MAX_LONG_PATH)
+	size := uint32(len(buffer))
+	result, _, err := procQueryFullProcessImageNameW.Call(
+		uintptr(process),
+		0,
+		uintptr(unsafe.Pointer(&buffer[0])),
+		uintptr(unsafe.Pointer(&size)),
+	)
+	if result == 0 {
+		return "", err
+	}
+
+	// All done
+	return syscall.UTF16ToString(buffer[:size]), nil
+}
+
+// PeerCredentialsOf returns the credentials of the process on the other end of
+// a native IPC connection.  On Windows, only the peer's process id (obtained
+// using GetNamedPipeClientProcessId or GetNamedPipeServerProcessId) and
+// executable are available.  Since the same API can't tell us which end of the
+// pipe we're on, the client process id is tried first, and if that refers to
+// the current process, the server process id is used instead.
+func PeerCredentialsOf(connection net.Conn) (*PeerCredentials, error) {
+	// Extract the underlying pipe handle
+	pipeConnection, ok := connection.(*windowsPipeConn)
+	if !ok {
+		return nil, errors.New("connection is not a named pipe")
+	}
+	handle := syscall.Handle(pipeConnection.handle)
+
+	// Query the peer process id
+	var pid uint32
+	result, _, err := procGetNamedPipeClientProcessId.Call(
+		uintptr(handle),
+		uintptr(unsafe.Pointer(&pid)),
+	)
+	if result == 0 {
+		return nil, err
+	}
+	if pid == uint32(syscall.Getpid()) {
+		result, _, err = procGetNamedPipeServerProcessId.Call(
+			uintptr(handle),
+			uintptr(unsafe.Pointer(&pid)),
+		)
+		if result == 0 {
+			return nil, err
+		}
+	}
+
+	// Look up the executable.  This may fail if the peer has exited or we
+	// aren't allowed to inspect it, in which case we leave it empty.
+	executable, _ := processExecutable(pid)
+
+	// All done
+	return &PeerCredentials{
+		UID:        -1,
+		GID:        -1,
+		PID:        int(pid),
+		Executable: executable,
+	}, nil
+}
@@ -0,0 +1,49 @@
+package ipc
+
+// System imports
+import (
+	"errors"
+	"strings"
+)
+
+// logicalEndpointPrefix is the prefix used to distinguish logical endpoint
+// names from platform-specific socket paths and pipe names.
+const logicalEndpointPrefix = "ipc:"
+
+// Endpoint returns a logical endpoint for the specified name, e.g.
+// Endpoint("myapp.backend").  Logical endpoints can be passed to DialIPC and
+// ListenIPC on any platform, where they are resolved to a socket in a per-user
+// runtime directory on POSIX systems and a per-user named pipe on Windows.  The
+// GopherJS build passes logical endpoints through to the host unchanged, and
+// hosts apply the same resolution rules.  Names may contain only ASCII letters,
+// digits, '.', '-', and '_'.
+func Endpoint(name string) string {
+	return logicalEndpointPrefix + name
+}
+
+// logicalEndpointName checks whether or not an endpoint is a logical endpoint,
+// and, if so, extracts and validates its name.
+func logicalEndpointName(endpoint string) (string, bool, error) {
+	// Check for the logical endpoint prefix
+	if !strings.HasPrefix(endpoint, logicalEndpointPrefix) {
+		return "", false, nil
+	}
+
+	// Extract and validate the name
+	name := endpoint[len(logicalEndpointPrefix):]
+	if name == "" {
+		return "", true, errors.New("empty logical endpoint name")
+	}
+	for _, r := range name {
+		valid := (r >= 'a' && r <= 'z') ||
+			(r >= 'A' && r <= 'Z') ||
+			(r >= '0' && r <= '9') ||
+			r == '.' || r == '-' || r == '_'
+		if !valid {
+			return "", true, errors.New("invalid logical endpoint name")
+		}
+	}
+
+	// All done
+	return name, true, nil
+}
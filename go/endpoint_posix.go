@@ -0,0 +1,68 @@
+// +build !windows,!js
+
+package ipc
+
+// System imports
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// runtimeDirectory computes the per-user directory in which logical endpoint
+// sockets are created.  It uses $XDG_RUNTIME_DIR if set.  Otherwise, on OS X
+// it uses the temporary directory (which is already per-user), and on other
+// systems it uses a uid-specific subdirectory of the temporary directory.  The
+// boolean return value indicates whether or not the directory is one that we
+// are responsible for creating.
+func runtimeDirectory() (string, bool) {
+	if directory := os.Getenv("XDG_RUNTIME_DIR"); directory != "" {
+		return filepath.Clean(directory), false
+	} else if runtime.GOOS == "darwin" {
+		return filepath.Clean(os.TempDir()), false
+	}
+	return filepath.Join(
+		os.TempDir(),
+		fmt.Sprintf("gib-%d", os.Getuid()),
+	), true
+}
+
+// ResolveEndpoint converts a logical endpoint (see Endpoint) to the socket path
+// that it represents on this system.  Other endpoints are returned unchanged.
+func ResolveEndpoint(endpoint string) (string, error) {
+	// If this isn't a logical endpoint, then there's nothing to resolve
+	name, logical, err := logicalEndpointName(endpoint)
+	if !logical {
+		return endpoint, nil
+	} else if err != nil {
+		return "", err
+	}
+
+	// Compute the path
+	directory, _ := runtimeDirectory()
+	return filepath.Join(directory, name+".sock"), nil
+}
+
+// prepareLogicalEndpoint verifies that the runtime directory for a logical
+// endpoint is private to the current user (if we're responsible for it), since
+// it lives in a shared temporary directory where another user could create it
+// first and then plant or intercept sockets.  If create is true, the directory
+// is created if it doesn't exist (as is necessary before listening).
+func prepareLogicalEndpoint(endpoint string, create bool) error {
+	// Only logical endpoints need preparation
+	if _, logical, _ := logicalEndpointName(endpoint); !logical {
+		return nil
+	}
+
+	// Create and/or verify the runtime directory if we're responsible for it
+	if directory, responsible := runtimeDirectory(); responsible {
+		if create {
+			return preparePrivateDirectory(directory)
+		}
+		return verifyPrivateDirectory(directory)
+	}
+
+	// All done
+	return nil
+}
@@ -0,0 +1,73 @@
+// +build !windows,!js,!darwin
+
+package ipc
+
+// System imports
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLogicalEndpointRuntimeDirectory(t *testing.T) {
+	// Use a temporary directory as the system temporary directory
+	temporary, err := ioutil.TempDir("", "gib")
+	if err != nil {
+		t.Fatal("unable to create temporary directory:", err)
+	}
+	defer os.RemoveAll(temporary)
+	t.Setenv("TMPDIR", temporary)
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	runtime := filepath.Join(temporary, fmt.Sprintf("gib-%d", os.Getuid()))
+	endpoint := Endpoint("test")
+
+	// A runtime directory that others can access should be rejected
+	if err := os.Mkdir(runtime, 0755); err != nil {
+		t.Fatal("unable to create runtime directory:", err)
+	} else if err := os.Chmod(runtime, 0755); err != nil {
+		t.Fatal("unable to set runtime directory permissions:", err)
+	}
+	if listener, err := ListenIPC(endpoint); err == nil {
+		listener.Close()
+		t.Fatal("listener created in shared runtime directory")
+	}
+	if _, err := DialIPC(endpoint); err == nil {
+		t.Fatal("connection made in shared runtime directory")
+	}
+
+	// So should a symbolic link to a private directory
+	target := filepath.Join(temporary, "target")
+	if err := os.Mkdir(target, 0700); err != nil {
+		t.Fatal("unable to create link target:", err)
+	} else if err := os.Remove(runtime); err != nil {
+		t.Fatal("unable to remove runtime directory:", err)
+	} else if err := os.Symlink(target, runtime); err != nil {
+		t.Fatal("unable to create symbolic link:", err)
+	}
+	if listener, err := ListenIPC(endpoint); err == nil {
+		listener.Close()
+		t.Fatal("listener created in linked runtime directory")
+	}
+
+	// A missing runtime directory should be created privately
+	if err := os.Remove(runtime); err != nil {
+		t.Fatal("unable to remove symbolic link:", err)
+	}
+	listener, err := ListenIPC(endpoint)
+	if err != nil {
+		t.Fatal("unable to listen:", err)
+	}
+	defer listener.Close()
+	if info, err := os.Lstat(runtime); err != nil {
+		t.Fatal("runtime directory not created:", err)
+	} else if info.Mode().Perm() != 0700 {
+		t.Fatal("runtime directory has incorrect permissions:", info.Mode())
+	}
+	connection, err := DialIPC(endpoint)
+	if err != nil {
+		t.Fatal("unable to connect:", err)
+	}
+	connection.Close()
+}
@@ -0,0 +1,36 @@
+// +build windows,!js
+
+package ipc
+
+// System imports
+import (
+	"os/user"
+	"strings"
+)
+
+// ResolveEndpoint converts a logical endpoint (see Endpoint) to the named pipe
+// name that it represents on this system.  Logical endpoints are mapped to
+// \\.\pipe\USER.NAME, where USER is the current user's name (without domain).
+// Other endpoints are returned unchanged.
+func ResolveEndpoint(endpoint string) (string, error) {
+	// If this isn't a logical endpoint, then there's nothing to resolve
+	name, logical, err := logicalEndpointName(endpoint)
+	if !logical {
+		return endpoint, nil
+	} else if err != nil {
+		return "", err
+	}
+
+	// Grab the user name, stripping any domain component
+	current, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+	username := current.Username
+	if index := strings.LastIndex(username, `\`); index != -1 {
+		username = username[index+1:]
+	}
+
+	// Compute the pipe name
+	return `\\.\pipe\` + username + "." + name, nil
+}
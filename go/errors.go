@@ -1,5 +1,3 @@
-// +build js
-
 package ipc
 
 // This package provides some common error handling helper routines to be shared
@@ -9,10 +7,28 @@ package ipc
 
 // System imports
 import (
-	"errors"
 	"encoding/base64"
+	"errors"
 )
 
+// errorFromMessage creates an error with the specified message, mapping
+// messages for errors that callers may want to test for (e.g. the permission
+// error reported by hosts enforcing an endpoint allowlist) back to their
+// package-level values.
+func errorFromMessage(errorMessage string) error {
+	// Check for known errors
+	if errorMessage == ErrEndpointNotPermitted.Error() {
+		return ErrEndpointNotPermitted
+	} else if errorMessage == ErrMessageTooLarge.Error() {
+		return ErrMessageTooLarge
+	} else if errorMessage == ErrPacketModeUnsupported.Error() {
+		return ErrPacketModeUnsupported
+	}
+
+	// Otherwise create a new error
+	return errors.New(errorMessage)
+}
+
 func ErrorFromErrorMessage(errorMessage string) error {
 	// If there is no error, we're done
 	if errorMessage == "" {
@@ -20,7 +36,7 @@ func ErrorFromErrorMessage(errorMessage string) error {
 	}
 
 	// Otherwise create a new error
-	return errors.New(errorMessage)
+	return errorFromMessage(errorMessage)
 }
 
 // ErrorFromBase64EncodedErrorMessage decodes a 64-bit encoded string an returns
@@ -42,5 +58,5 @@ func ErrorFromBase64EncodedErrorMessage(errorMessage64 string) error {
 	}
 
 	// Otherwise create a new error
-	return errors.New(string(errorMessageBytes))
+	return errorFromMessage(string(errorMessageBytes))
 }
@@ -0,0 +1,22 @@
+package ipc
+
+// System imports
+import "errors"
+
+// ErrFilePassingUnsupported is returned by WriteWithFiles and ReadWithFiles
+// when file descriptor passing isn't available, either because the platform
+// doesn't support it (Windows and GopherJS) or because the connection isn't a
+// Unix domain socket connection.
+var ErrFilePassingUnsupported = errors.New(
+	"file descriptor passing not supported",
+)
+
+// ErrFilesTruncated is returned by ReadWithFiles when the peer sent more files
+// than the caller was prepared to receive.  Any files that were received are
+// closed, since the message can't be processed reliably.
+var ErrFilesTruncated = errors.New("received files truncated")
+
+// ErrFilesWithoutData is returned by WriteWithFiles when files are sent without
+// any accompanying data.  At least one byte must be sent with the files so that
+// the peer's read picks them up.
+var ErrFilesWithoutData = errors.New("files must be sent with data")
@@ -0,0 +1,148 @@
+// +build !windows,!js
+
+package ipc
+
+// System imports
+import (
+	"net"
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// WriteWithFiles writes data to a native IPC connection and passes the
+// specified files (e.g. opened files or shared memory objects) to the peer
+// alongside it using SCM_RIGHTS.  The connection must be a Unix domain socket
+// connection, such as those returned by DialIPC and ListenIPC (wrapped
+// connections, e.g. those secured with TLS, don't support file passing).  The
+// peer receives duplicates of the file descriptors, so the caller remains
+// responsible for closing its files.  If files are specified, data must be
+// non-empty.
+func WriteWithFiles(
+	connection net.Conn,
+	data []byte,
+	files []*os.File,
+) (int, error) {
+	// Extract the underlying socket
+	unixConnection, ok := connection.(*net.UnixConn)
+	if !ok {
+		return 0, ErrFilePassingUnsupported
+	}
+
+	// If there aren't any files, this is a normal write
+	if len(files) == 0 {
+		return unixConnection.Write(data)
+	} else if len(data) == 0 {
+		return 0, ErrFilesWithoutData
+	}
+
+	// Create the control message
+	descriptors := make([]int, len(files))
+	for i, file := range files {
+		descriptors[i] = int(file.Fd())
+	}
+	rights := syscall.UnixRights(descriptors...)
+
+	// Write the data and files.  The files must remain open until the message
+	// has been sent.
+	count, _, err := unixConnection.WriteMsgUnix(data, rights, nil)
+	runtime.KeepAlive(files)
+	if err != nil {
+		return count, err
+	}
+
+	// If the write was partial, the files have already been sent with the
+	// first portion, so write the remainder normally
+	if count < len(data) {
+		remaining, err := unixConnection.Write(data[count:])
+		return count + remaining, err
+	}
+
+	// All done
+	return count, nil
+}
+
+// ReadWithFiles reads data from a native IPC connection along with any files
+// the peer passed alongside it using SCM_RIGHTS, accepting at most maxFiles
+// files.  The connection requirements are the same as for WriteWithFiles.  The
+// caller is responsible for closing the returned files.  If the peer sent more
+// than maxFiles files, all received files are closed and ErrFilesTruncated is
+// returned.
+func ReadWithFiles(
+	connection net.Conn,
+	data []byte,
+	maxFiles int,
+) (int, []*os.File, error) {
+	// Extract the underlying socket
+	unixConnection, ok := connection.(*net.UnixConn)
+	if !ok {
+		return 0, nil, ErrFilePassingUnsupported
+	}
+
+	// Create a control message buffer large enough for the permitted files
+	var control []byte
+	if maxFiles > 0 {
+		control = make([]byte, syscall.CmsgSpace(maxFiles*4))
+	}
+
+	// Read the data and control messages
+	count, controlCount, flags, _, err := unixConnection.ReadMsgUnix(
+		data,
+		control,
+	)
+
+	// Extract any files, even if the read failed, so they aren't leaked.  The
+	// control buffer may have room for more than maxFiles descriptors due to
+	// alignment, so enforce the limit explicitly.
+	files, filesErr := parseRights(control[:controlCount])
+	if filesErr == nil &&
+		(flags&syscall.MSG_CTRUNC != 0 || len(files) > maxFiles) {
+		filesErr = ErrFilesTruncated
+	}
+	if filesErr != nil {
+		for _, file := range files {
+			file.Close()
+		}
+		files = nil
+		if err == nil {
+			err = filesErr
+		}
+	}
+
+	// All done
+	return count, files, err
+}
+
+// parseRights extracts files from SCM_RIGHTS control messages.
+func parseRights(control []byte) ([]*os.File, error) {
+	// Check if there's anything to parse
+	if len(control) == 0 {
+		return nil, nil
+	}
+
+	// Parse the control messages
+	messages, err := syscall.ParseSocketControlMessage(control)
+	if err != nil {
+		return nil, err
+	}
+
+	// Extract file descriptors and wrap them
+	var files []*os.File
+	for _, message := range messages {
+		if message.Header.Level != syscall.SOL_SOCKET ||
+			message.Header.Type != syscall.SCM_RIGHTS {
+			continue
+		}
+		descriptors, err := syscall.ParseUnixRights(&message)
+		if err != nil {
+			return files, err
+		}
+		for _, descriptor := range descriptors {
+			syscall.CloseOnExec(descriptor)
+			files = append(files, os.NewFile(uintptr(descriptor), "ipc-file"))
+		}
+	}
+
+	// All done
+	return files, nil
+}
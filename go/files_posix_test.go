@@ -0,0 +1,132 @@
+// +build !windows,!js
+
+package ipc
+
+// System imports
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+)
+
+// tempFiles creates the specified number of temporary files, each containing
+// its index as a single byte, that are closed when the test completes.
+func tempFiles(t *testing.T, count int) []*os.File {
+	files := make([]*os.File, count)
+	for i := range files {
+		file, err := ioutil.TempFile(t.TempDir(), "files")
+		if err != nil {
+			t.Fatal("unable to create file:", err)
+		}
+		t.Cleanup(func() { file.Close() })
+		if _, err := file.Write([]byte{byte(i)}); err != nil {
+			t.Fatal("unable to write file:", err)
+		}
+		files[i] = file
+	}
+	return files
+}
+
+// openDescriptors returns the number of file descriptors open in the process.
+func openDescriptors(t *testing.T) int {
+	entries, err := ioutil.ReadDir("/dev/fd")
+	if err != nil {
+		t.Skip("unable to list file descriptors:", err)
+	}
+	return len(entries)
+}
+
+func TestFilePassing(t *testing.T) {
+	client, server := socketPair(t)
+	defer client.Close()
+	defer server.Close()
+
+	// Send files with data
+	files := tempFiles(t, 2)
+	if count, err := WriteWithFiles(client, []byte("files"), files); err != nil {
+		t.Fatal("unable to write files:", err)
+	} else if count != 5 {
+		t.Fatal("unexpected write count:", count)
+	}
+	buffer := make([]byte, 16)
+	count, received, err := ReadWithFiles(server, buffer, 2)
+	if err != nil {
+		t.Fatal("unable to read files:", err)
+	} else if string(buffer[:count]) != "files" {
+		t.Error("unexpected data:", string(buffer[:count]))
+	}
+	if len(received) != 2 {
+		t.Fatal("unexpected file count:", len(received))
+	}
+
+	// The received files should be duplicates of those sent
+	for i, file := range received {
+		contents := make([]byte, 1)
+		if _, err := file.ReadAt(contents, 0); err != nil {
+			t.Error("unable to read received file:", err)
+		} else if contents[0] != byte(i) {
+			t.Error("received file mismatch:", i, contents[0])
+		}
+		file.Close()
+	}
+
+	// Writes without files should be delivered without files
+	if _, err := WriteWithFiles(client, []byte("plain"), nil); err != nil {
+		t.Fatal("unable to write data:", err)
+	}
+	count, received, err = ReadWithFiles(server, buffer, 2)
+	if err != nil || string(buffer[:count]) != "plain" || len(received) != 0 {
+		t.Error("unexpected plain read:", count, len(received), err)
+	}
+
+	// Files can't be sent without data
+	if _, err := WriteWithFiles(client, nil, files); err != ErrFilesWithoutData {
+		t.Error("unexpected error sending files without data:", err)
+	}
+}
+
+func TestFilePassingTruncated(t *testing.T) {
+	for _, maxFiles := range []int{0, 1, 2} {
+		client, server := socketPair(t)
+		files := tempFiles(t, 3)
+		before := openDescriptors(t)
+
+		// Send more files than the reader permits
+		if _, err := WriteWithFiles(client, []byte("files"), files); err != nil {
+			t.Fatal("unable to write files:", err)
+		}
+		buffer := make([]byte, 16)
+		count, received, err := ReadWithFiles(server, buffer, maxFiles)
+		if err != ErrFilesTruncated {
+			t.Error("unexpected error with limit", maxFiles, err)
+		} else if received != nil {
+			t.Error("files returned with limit", maxFiles)
+		} else if string(buffer[:count]) != "files" {
+			t.Error("unexpected data with limit", maxFiles, buffer[:count])
+		}
+
+		// All of the received files should have been closed
+		if after := openDescriptors(t); after != before {
+			t.Errorf("leaked %d descriptors with limit %d",
+				after-before, maxFiles)
+		}
+		client.Close()
+		server.Close()
+	}
+}
+
+func TestFilePassingUnsupported(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	files := tempFiles(t, 1)
+	_, err := WriteWithFiles(client, []byte("x"), files)
+	if err != ErrFilePassingUnsupported {
+		t.Error("unexpected write error:", err)
+	}
+	_, _, err = ReadWithFiles(server, make([]byte, 1), 1)
+	if err != ErrFilePassingUnsupported {
+		t.Error("unexpected read error:", err)
+	}
+}
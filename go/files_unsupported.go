@@ -0,0 +1,31 @@
+// +build windows js
+
+package ipc
+
+// System imports
+import (
+	"net"
+	"os"
+)
+
+// WriteWithFiles writes data to an IPC connection and passes the specified
+// files to the peer alongside it.  File descriptor passing is only supported
+// on POSIX systems, so it always fails with ErrFilePassingUnsupported here.
+func WriteWithFiles(
+	connection net.Conn,
+	data []byte,
+	files []*os.File,
+) (int, error) {
+	return 0, ErrFilePassingUnsupported
+}
+
+// ReadWithFiles reads data and any passed files from an IPC connection.  File
+// descriptor passing is only supported on POSIX systems, so it always fails
+// with ErrFilePassingUnsupported here.
+func ReadWithFiles(
+	connection net.Conn,
+	data []byte,
+	maxFiles int,
+) (int, []*os.File, error) {
+	return 0, nil, ErrFilePassingUnsupported
+}
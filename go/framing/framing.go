@@ -0,0 +1,320 @@
+// Package framing provides length-prefixed message framing over stream
+// connections.  A MessageConn wraps any net.Conn (e.g. one returned by
+// ipc.DialIPC or ipc.ListenIPC, in either the GopherJS or native builds) and
+// exchanges discrete messages over it, enforcing a maximum message size,
+// reusing its buffers between messages, and optionally compressing individual
+// messages.  Both ends of a connection must use framing, but they needn't
+// agree on compression settings, since each frame records whether or not it is
+// compressed.
+//
+// Each frame consists of a 4-byte big-endian header followed by the message
+// payload.  The low 31 bits of the header hold the payload length, and the
+// high bit is set if the payload is compressed with DEFLATE.
+package framing
+
+// System imports
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+)
+
+const (
+	// DefaultMaximumMessageSize is the maximum message size used if none is
+	// specified in Options.
+	DefaultMaximumMessageSize = 4 * 1024 * 1024
+
+	// DefaultCompressionThreshold is the size below which messages aren't
+	// compressed if no threshold is specified in Options.  Compressing small
+	// messages rarely makes them smaller.
+	DefaultCompressionThreshold = 512
+
+	// headerSize is the size of a frame header.
+	headerSize = 4
+
+	// compressedFlag is the header bit indicating a compressed payload.
+	compressedFlag = 1 << 31
+
+	// lengthMask is the header mask for the payload length.
+	lengthMask = compressedFlag - 1
+)
+
+// ErrMessageTooLarge is returned by WriteMessage when a message exceeds the
+// maximum message size and by ReadMessage when the peer sends such a message
+// (in which case the message is discarded).
+var ErrMessageTooLarge = errors.New("message too large")
+
+// ErrInvalidFrame is returned by ReadMessage when a compressed frame can't be
+// decompressed.
+var ErrInvalidFrame = errors.New("invalid frame")
+
+// Options specifies optional behavior for a MessageConn.  The zero value
+// selects the defaults.
+type Options struct {
+	// MaximumMessageSize is the largest message (before compression) that
+	// can be sent or received.  If zero, DefaultMaximumMessageSize is used.
+	// Values that don't fit in a frame header are capped.
+	MaximumMessageSize int
+
+	// Compression enables DEFLATE compression of outgoing messages.  Incoming
+	// compressed messages are always accepted.
+	Compression bool
+
+	// CompressionLevel is the flate compression level used for outgoing
+	// messages.  If zero, flate.DefaultCompression is used.
+	CompressionLevel int
+
+	// CompressionThreshold is the size below which outgoing messages aren't
+	// compressed.  If zero, DefaultCompressionThreshold is used.
+	CompressionThreshold int
+}
+
+// MessageConn exchanges length-prefixed messages over a stream connection.
+// WriteMessage may be called concurrently from multiple goroutines.
+// ReadMessage may be called concurrently with WriteMessage, but since its
+// result is only valid until the next read, reads should be performed from a
+// single goroutine.
+type MessageConn struct {
+	// The underlying connection
+	connection net.Conn
+
+	// The maximum message size
+	maximumMessageSize int
+
+	// Whether or not to compress outgoing messages, and the level and
+	// threshold to use
+	compression          bool
+	compressionLevel     int
+	compressionThreshold int
+
+	// Lock serializing reads (and protecting the fields below)
+	readLock sync.Mutex
+
+	// The read header buffer
+	readHeader [headerSize]byte
+
+	// The read payload buffer
+	readBuffer []byte
+
+	// The decompression buffer and the reusable decompressor, its input, and
+	// the size limit wrapped around it
+	decompressed      bytes.Buffer
+	decompressor      io.ReadCloser
+	decompressorInput bytes.Reader
+	decompressorLimit io.LimitedReader
+
+	// Lock serializing writes (and protecting the fields below)
+	writeLock sync.Mutex
+
+	// The write buffer, which holds a complete frame so that each message is
+	// sent with a single write
+	writeBuffer bytes.Buffer
+
+	// The reusable compressor
+	compressor *flate.Writer
+}
+
+// NewMessageConn creates a new MessageConn wrapping the specified connection.
+// The options may be nil, in which case defaults are used.  The MessageConn
+// takes ownership of the connection, which shouldn't be used directly
+// afterward.
+func NewMessageConn(connection net.Conn, options *Options) *MessageConn {
+	// Use defaults if no options have been specified
+	if options == nil {
+		options = &Options{}
+	}
+
+	// Create the connection
+	result := &MessageConn{
+		connection:           connection,
+		maximumMessageSize:   options.MaximumMessageSize,
+		compression:          options.Compression,
+		compressionLevel:     options.CompressionLevel,
+		compressionThreshold: options.CompressionThreshold,
+	}
+
+	// Apply defaults and limits
+	if result.maximumMessageSize <= 0 {
+		result.maximumMessageSize = DefaultMaximumMessageSize
+	} else if int64(result.maximumMessageSize) > lengthMask {
+		result.maximumMessageSize = lengthMask
+	}
+	if result.compressionLevel == 0 {
+		result.compressionLevel = flate.DefaultCompression
+	}
+	if result.compressionThreshold <= 0 {
+		result.compressionThreshold = DefaultCompressionThreshold
+	}
+
+	// All done
+	return result
+}
+
+// ReadMessage reads the next message from the connection.  The returned slice
+// refers to an internal buffer and is only valid until the next call to
+// ReadMessage - callers that need to retain the message should copy it.  It
+// returns io.EOF if the connection is closed cleanly between messages and
+// io.ErrUnexpectedEOF if it is closed partway through a message.
+func (c *MessageConn) ReadMessage() ([]byte, error) {
+	// Lock reads
+	c.readLock.Lock()
+	defer c.readLock.Unlock()
+
+	// Read the header
+	if _, err := io.ReadFull(c.connection, c.readHeader[:]); err != nil {
+		return nil, err
+	}
+	header := binary.BigEndian.Uint32(c.readHeader[:])
+	length := int(header & lengthMask)
+	compressed := header&compressedFlag != 0
+
+	// Enforce the size limit, discarding the payload so that the next read
+	// starts on a frame boundary
+	if length > c.maximumMessageSize {
+		_, err := io.CopyN(ioutil.Discard, c.connection, int64(length))
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		} else if err != nil {
+			return nil, err
+		}
+		return nil, ErrMessageTooLarge
+	}
+
+	// Ensure that the payload buffer has sufficient capacity
+	if cap(c.readBuffer) < length {
+		c.readBuffer = make([]byte, length)
+	}
+	payload := c.readBuffer[:length]
+
+	// Read the payload
+	if _, err := io.ReadFull(c.connection, payload); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	// If the payload isn't compressed, we're done
+	if !compressed {
+		return payload, nil
+	}
+
+	// Otherwise decompress it
+	return c.decompress(payload)
+}
+
+// decompress decompresses a payload into the decompression buffer, enforcing
+// the size limit.  It must be called with the read lock held.
+func (c *MessageConn) decompress(payload []byte) ([]byte, error) {
+	// Reset the decompressor
+	c.decompressorInput.Reset(payload)
+	if c.decompressor == nil {
+		c.decompressor = flate.NewReader(&c.decompressorInput)
+	} else if err := c.decompressor.(flate.Resetter).Reset(
+		&c.decompressorInput, nil,
+	); err != nil {
+		return nil, err
+	}
+
+	// Decompress, reading one byte beyond the limit so that we can detect
+	// oversized messages
+	c.decompressed.Reset()
+	c.decompressorLimit.R = c.decompressor
+	c.decompressorLimit.N = int64(c.maximumMessageSize) + 1
+	if _, err := c.decompressed.ReadFrom(&c.decompressorLimit); err != nil {
+		return nil, ErrInvalidFrame
+	} else if c.decompressed.Len() > c.maximumMessageSize {
+		return nil, ErrMessageTooLarge
+	}
+
+	// All done
+	return c.decompressed.Bytes(), nil
+}
+
+// WriteMessage sends a message over the connection.  The message is sent with
+// a single write to the underlying connection.
+func (c *MessageConn) WriteMessage(message []byte) error {
+	// Enforce the size limit
+	if len(message) > c.maximumMessageSize {
+		return ErrMessageTooLarge
+	}
+
+	// Lock writes
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	// Reset the write buffer and reserve space for the header
+	c.writeBuffer.Reset()
+	var header [headerSize]byte
+	c.writeBuffer.Write(header[:])
+
+	// Write the payload, compressing it if enabled and worthwhile
+	compressed := false
+	if c.compression && len(message) >= c.compressionThreshold {
+		if err := c.compress(message); err != nil {
+			return err
+		}
+		if c.writeBuffer.Len()-headerSize < len(message) {
+			compressed = true
+		} else {
+			c.writeBuffer.Truncate(headerSize)
+		}
+	}
+	if !compressed {
+		c.writeBuffer.Write(message)
+	}
+
+	// Fill in the header
+	frame := c.writeBuffer.Bytes()
+	length := uint32(len(frame) - headerSize)
+	if compressed {
+		length |= compressedFlag
+	}
+	binary.BigEndian.PutUint32(frame, length)
+
+	// Send the frame
+	_, err := c.connection.Write(frame)
+	return err
+}
+
+// compress compresses a message into the write buffer.  It must be called with
+// the write lock held.
+func (c *MessageConn) compress(message []byte) error {
+	// Create or reset the compressor
+	if c.compressor == nil {
+		compressor, err := flate.NewWriter(&c.writeBuffer, c.compressionLevel)
+		if err != nil {
+			return err
+		}
+		c.compressor = compressor
+	} else {
+		c.compressor.Reset(&c.writeBuffer)
+	}
+
+	// Compress the message
+	if _, err := c.compressor.Write(message); err != nil {
+		return err
+	}
+	return c.compressor.Close()
+}
+
+// Close closes the underlying connection.  Any blocked ReadMessage or
+// WriteMessage calls are unblocked and return errors.
+func (c *MessageConn) Close() error {
+	return c.connection.Close()
+}
+
+// LocalAddr returns the local address of the underlying connection.
+func (c *MessageConn) LocalAddr() net.Addr {
+	return c.connection.LocalAddr()
+}
+
+// RemoteAddr returns the remote address of the underlying connection.
+func (c *MessageConn) RemoteAddr() net.Addr {
+	return c.connection.RemoteAddr()
+}
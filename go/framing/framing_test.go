@@ -0,0 +1,120 @@
+package framing
+
+// System imports
+import (
+	"bytes"
+	"math/rand"
+	"net"
+	"testing"
+)
+
+// messagePipe creates a pair of MessageConns over an in-memory pipe that are
+// closed when the test completes.
+func messagePipe(
+	t *testing.T,
+	writerOptions, readerOptions *Options,
+) (*MessageConn, *MessageConn) {
+	writerConnection, readerConnection := net.Pipe()
+	writer := NewMessageConn(writerConnection, writerOptions)
+	reader := NewMessageConn(readerConnection, readerOptions)
+	t.Cleanup(func() {
+		writer.Close()
+		reader.Close()
+	})
+	return writer, reader
+}
+
+// writeMessages writes messages in the background, since writes over an
+// in-memory pipe block until they're read.
+func writeMessages(writer *MessageConn, messages ...[]byte) chan error {
+	result := make(chan error, 1)
+	go func() {
+		for _, message := range messages {
+			if err := writer.WriteMessage(message); err != nil {
+				result <- err
+				return
+			}
+		}
+		result <- nil
+	}()
+	return result
+}
+
+// expectMessage reads a message and verifies its contents.
+func expectMessage(t *testing.T, reader *MessageConn, expected []byte) {
+	message, err := reader.ReadMessage()
+	if err != nil {
+		t.Fatal("unable to read message:", err)
+	} else if !bytes.Equal(message, expected) {
+		t.Fatalf("message mismatch: %d bytes received, %d expected",
+			len(message), len(expected))
+	}
+}
+
+func TestMessageSizeLimit(t *testing.T) {
+	writer, reader := messagePipe(
+		t,
+		&Options{MaximumMessageSize: 1024},
+		&Options{MaximumMessageSize: 16},
+	)
+
+	// Writes beyond the writer's limit should be refused outright
+	if err := writer.WriteMessage(make([]byte, 1025)); err != ErrMessageTooLarge {
+		t.Fatal("unexpected error for oversized write:", err)
+	}
+
+	// Messages beyond the reader's limit should be discarded, after which the
+	// reader should resynchronize on the next frame
+	small := []byte("small")
+	errs := writeMessages(writer, bytes.Repeat([]byte{'x'}, 17), small)
+	if _, err := reader.ReadMessage(); err != ErrMessageTooLarge {
+		t.Fatal("unexpected error for oversized read:", err)
+	}
+	expectMessage(t, reader, small)
+	if err := <-errs; err != nil {
+		t.Fatal("unable to write messages:", err)
+	}
+}
+
+func TestCompressionInterop(t *testing.T) {
+	// Create compressible and incompressible messages above the threshold
+	compressible := bytes.Repeat([]byte("compressible "), 1024)
+	incompressible := make([]byte, 8*1024)
+	rand.Read(incompressible)
+	small := []byte("below threshold")
+
+	// Verify each combination of compression settings
+	for _, settings := range [][2]bool{{true, false}, {false, true}, {true, true}} {
+		writer, reader := messagePipe(
+			t,
+			&Options{Compression: settings[0]},
+			&Options{Compression: settings[1]},
+		)
+		errs := writeMessages(writer, compressible, incompressible, small)
+		expectMessage(t, reader, compressible)
+		expectMessage(t, reader, incompressible)
+		expectMessage(t, reader, small)
+		if err := <-errs; err != nil {
+			t.Fatal("unable to write messages:", err)
+		}
+	}
+}
+
+func TestDecompressionBomb(t *testing.T) {
+	// A small frame that decompresses beyond the reader's limit should be
+	// rejected without affecting subsequent frames
+	writer, reader := messagePipe(
+		t,
+		&Options{Compression: true, MaximumMessageSize: 4 * 1024 * 1024},
+		&Options{MaximumMessageSize: 64 * 1024},
+	)
+	small := []byte("small")
+	errs := writeMessages(writer, make([]byte, 4*1024*1024), small)
+	if _, err := reader.ReadMessage(); err != ErrMessageTooLarge {
+		t.Fatal("unexpected error for decompression bomb:", err)
+	}
+	expectMessage(t, reader, small)
+	if err := <-errs; err != nil {
+		t.Fatal("unable to write messages:", err)
+	}
+}
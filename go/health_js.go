@@ -0,0 +1,193 @@
+// +build js
+
+package ipc
+
+// System imports
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrBridgeNotInitialized is recorded by HealthMonitor checks when it has no
+// bridge to ping because HostInitialize hasn't been invoked yet.
+var ErrBridgeNotInitialized = errors.New("bridge not initialized")
+
+// HealthStatus describes the outcome of a HealthMonitor's most recent check.
+type HealthStatus struct {
+	// Healthy indicates whether or not the most recent ping succeeded within
+	// the timeout.
+	Healthy bool
+
+	// RoundTrip is the round-trip time of the most recent successful ping.
+	RoundTrip time.Duration
+
+	// Checked is the time at which the most recent check completed, or the
+	// zero time if no check has completed.
+	Checked time.Time
+
+	// Failures is the number of consecutive failed checks.
+	Failures int
+
+	// Err is the error from the most recent failed check, e.g.
+	// ErrRequestTimeout if the host didn't respond in time.
+	Err error
+}
+
+// HealthMonitor periodically pings the host through a bridge and tracks
+// whether or not it is responsive, allowing user interfaces to report an
+// unreachable backend rather than hanging.  While a ping is outstanding past
+// its timeout, no further pings are issued, so an unresponsive host doesn't
+// accumulate requests.
+type HealthMonitor struct {
+	// The bridge to ping, or nil to use the global bridge
+	bridge Bridge
+
+	// The interval between checks
+	interval time.Duration
+
+	// The time limit for each ping
+	timeout time.Duration
+
+	// The callback to invoke when health changes, if any
+	onChange func(HealthStatus)
+
+	// Lock for the status
+	lock sync.Mutex
+
+	// The current status
+	status HealthStatus
+
+	// Channel closed to stop the monitor
+	done chan struct{}
+
+	// Ensures the monitor is only stopped once
+	stopOnce sync.Once
+}
+
+// NewHealthMonitor creates and starts a HealthMonitor that pings the specified
+// bridge (or the global bridge installed by HostInitialize if nil, in which
+// case checks fail until it is installed) at the specified interval, treating
+// pings that take longer than the specified timeout as failures.  The onChange callback, if non-nil, is invoked from the
+// monitor's goroutine whenever the bridge transitions between healthy and
+// unhealthy.  The monitor reports unhealthy until its first check completes.
+func NewHealthMonitor(
+	bridge Bridge,
+	interval,
+	timeout time.Duration,
+	onChange func(HealthStatus),
+) *HealthMonitor {
+	// Create the monitor
+	monitor := &HealthMonitor{
+		bridge: bridge,
+		interval: interval,
+		timeout: timeout,
+		onChange: onChange,
+		done: make(chan struct{}),
+	}
+
+	// Start monitoring
+	go monitor.run()
+
+	// All done
+	return monitor
+}
+
+// Status returns the current health status.
+func (m *HealthMonitor) Status() HealthStatus {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.status
+}
+
+// Stop stops the monitor.  It is safe to call more than once.
+func (m *HealthMonitor) Stop() {
+	m.stopOnce.Do(func() {
+		close(m.done)
+	})
+}
+
+// record updates the status with the outcome of a check.
+func (m *HealthMonitor) record(roundTrip time.Duration, err error) {
+	// Update the status
+	m.lock.Lock()
+	wasHealthy := m.status.Healthy
+	first := m.status.Checked.IsZero()
+	m.status.Healthy = err == nil
+	m.status.Checked = time.Now()
+	if err == nil {
+		m.status.RoundTrip = roundTrip
+		m.status.Failures = 0
+		m.status.Err = nil
+	} else {
+		m.status.Failures++
+		m.status.Err = err
+	}
+	status := m.status
+	m.lock.Unlock()
+
+	// Report changes
+	if m.onChange != nil && (first || status.Healthy != wasHealthy) {
+		m.onChange(status)
+	}
+}
+
+// check pings the bridge and records the outcome, returning false if the
+// monitor was stopped before the check completed.  If there's no bridge to
+// ping, the check fails with ErrBridgeNotInitialized.
+func (m *HealthMonitor) check() bool {
+	// Grab the bridge to ping
+	bridge := m.bridge
+	if bridge == nil {
+		bridge = global.bridge
+	}
+	if bridge == nil {
+		m.record(0, ErrBridgeNotInitialized)
+		return true
+	}
+
+	// Dispatch a ping
+	resultChannel := bridge.Ping()
+
+	// Wait for the result, a timeout, or cancellation
+	timer := time.NewTimer(m.timeout)
+	select {
+	case result := <-resultChannel:
+		timer.Stop()
+		m.record(result.roundTrip, result.err)
+	case <-timer.C:
+		m.record(0, ErrRequestTimeout)
+
+		// Wait for the outstanding ping before issuing another
+		select {
+		case <-resultChannel:
+		case <-m.done:
+			return false
+		}
+	case <-m.done:
+		timer.Stop()
+		return false
+	}
+
+	// All done
+	return true
+}
+
+// run is the monitoring loop.
+func (m *HealthMonitor) run() {
+	for {
+		// Perform a check
+		if !m.check() {
+			return
+		}
+
+		// Wait for the next check
+		timer := time.NewTimer(m.interval)
+		select {
+		case <-timer.C:
+		case <-m.done:
+			timer.Stop()
+			return
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package ipc
+
+// System imports
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// InstrumentedConn wraps a connection to track its I/O counters and record
+// operation metrics in a Registry.
+type InstrumentedConn struct {
+	// The connection's own counters.  This must remain the first field to
+	// guarantee 64-bit alignment of its contents on 32-bit platforms.
+	counters connectionCounters
+
+	// The underlying connection
+	net.Conn
+
+	// The registry receiving metrics
+	registry *Registry
+
+	// Ensures that closure is only recorded once
+	closeOnce sync.Once
+}
+
+// InstrumentConn wraps a connection so that its reads, writes, and closure are
+// recorded in the specified registry (or DefaultRegistry if nil) and so that
+// its own I/O counters are available via StatisticsOf.  The connection is
+// counted as opened immediately.
+func InstrumentConn(connection net.Conn, registry *Registry) *InstrumentedConn {
+	// Use the default registry if none was specified
+	if registry == nil {
+		registry = DefaultRegistry
+	}
+
+	// Record the connection
+	registry.connectionOpened()
+
+	// Wrap the connection
+	return &InstrumentedConn{
+		Conn:     connection,
+		registry: registry,
+	}
+}
+
+func (c *InstrumentedConn) Read(b []byte) (int, error) {
+	// Perform the read
+	start := time.Now()
+	count, err := c.Conn.Read(b)
+
+	// Record metrics
+	c.counters.recordRead(count, err)
+	c.registry.connections.recordRead(count, err)
+	c.registry.observeOperation(
+		OperationConnectionRead,
+		time.Since(start),
+		err,
+	)
+
+	// All done
+	return count, err
+}
+
+func (c *InstrumentedConn) Write(b []byte) (int, error) {
+	// Perform the write
+	start := time.Now()
+	count, err := c.Conn.Write(b)
+
+	// Record metrics
+	c.counters.recordWrite(count, err)
+	c.registry.connections.recordWrite(count, err)
+	c.registry.observeOperation(
+		OperationConnectionWrite,
+		time.Since(start),
+		err,
+	)
+
+	// All done
+	return count, err
+}
+
+func (c *InstrumentedConn) Close() error {
+	// Perform the close
+	start := time.Now()
+	err := c.Conn.Close()
+
+	// Record metrics, counting the connection as closed only once
+	c.registry.observeOperation(
+		OperationConnectionClose,
+		time.Since(start),
+		err,
+	)
+	c.closeOnce.Do(c.registry.connectionClosed)
+
+	// All done
+	return err
+}
+
+// Statistics returns the connection's I/O counters.
+func (c *InstrumentedConn) Statistics() ConnectionStatistics {
+	return c.counters.snapshot()
+}
+
+// instrumentedListener wraps a listener to instrument accepted connections.
+type instrumentedListener struct {
+	// The underlying listener
+	net.Listener
+
+	// The registry receiving metrics
+	registry *Registry
+}
+
+// InstrumentListener wraps a listener so that accept operations are recorded
+// in the specified registry (or DefaultRegistry if nil) and accepted
+// connections are wrapped with InstrumentConn.
+func InstrumentListener(listener net.Listener, registry *Registry) net.Listener {
+	// Use the default registry if none was specified
+	if registry == nil {
+		registry = DefaultRegistry
+	}
+
+	// Wrap the listener
+	return &instrumentedListener{
+		Listener: listener,
+		registry: registry,
+	}
+}
+
+func (l *instrumentedListener) Accept() (net.Conn, error) {
+	// Perform the accept
+	start := time.Now()
+	connection, err := l.Listener.Accept()
+
+	// Record metrics
+	l.registry.observeOperation(
+		OperationListenerAccept,
+		time.Since(start),
+		err,
+	)
+
+	// Handle errors
+	if err != nil {
+		return nil, err
+	}
+
+	// Wrap the connection
+	return InstrumentConn(connection, l.registry), nil
+}
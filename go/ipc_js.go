@@ -24,6 +24,7 @@ func (a *ipcAddr) String() string {
 
 // ipcConn implements the net.Conn interface for GopherJS IPC connections.
 type ipcConn struct {
+	counters connectionCounters
 	address *ipcAddr
 	connectionId int
 }
@@ -38,6 +39,9 @@ func (c *ipcConn) Read(b []byte) (int, error) {
 	// We always copy the resultant bytes, regardless of errors
 	copy(b, result.data)
 
+	// Update counters
+	c.counters.recordRead(len(result.data), result.err)
+
 	// All done
 	return len(result.data), result.err
 }
@@ -49,6 +53,9 @@ func (c *ipcConn) Write(b []byte) (int, error) {
 	// Wait for the result
 	result := <-resultChannel
 
+	// Update counters
+	c.counters.recordWrite(result.count, result.err)
+
 	// All done
 	return result.count, result.err
 }
@@ -74,6 +81,38 @@ func (c *ipcConn) Close() error {
 	return result.err
 }
 
+// CloseRead shuts down the read side of the connection.  Most callers should
+// just use Close.
+func (c *ipcConn) CloseRead() error {
+	// Dispatch the request through the bridge
+	resultChannel := global.bridge.ConnectionCloseRead(c.connectionId)
+
+	// Wait for the result
+	result := <-resultChannel
+
+	// All done
+	return result.err
+}
+
+// CloseWrite shuts down the write side of the connection, signaling
+// end-of-stream to the peer while still allowing reads.  Most callers should
+// just use Close.
+func (c *ipcConn) CloseWrite() error {
+	// Dispatch the request through the bridge
+	resultChannel := global.bridge.ConnectionCloseWrite(c.connectionId)
+
+	// Wait for the result
+	result := <-resultChannel
+
+	// All done
+	return result.err
+}
+
+// Statistics returns the connection's I/O counters.
+func (c *ipcConn) Statistics() ConnectionStatistics {
+	return c.counters.snapshot()
+}
+
 func (c *ipcConn) LocalAddr() net.Addr {
 	return c.address
 }
@@ -113,7 +152,9 @@ func (c *ipcConn) SetWriteDeadline(t time.Time) error {
 // done using Unix domain sockets, and the endpoint argument should be the path
 // of an existing Unix domain socket endpoint to connect to.  On Windows
 // systems, this is done using named pipes, and the endpoint argument should be
-// the name of an existing named pipe endpoint to connect to.
+// the name of an existing named pipe endpoint to connect to.  Logical endpoints
+// created with Endpoint are passed to the host unchanged, and the host resolves
+// them according to its platform's rules.
 func DialIPC(endpoint string) (net.Conn, error) {
 	// Dispatch the request through the bridge
 	resultChannel := global.bridge.Connect(endpoint)
@@ -188,7 +229,8 @@ func (l *ipcListener) Addr() net.Addr {
 // path at which to create the endpoint.  The path should not be bound to an
 // existing listener.  On Windows systems, this is done using named pipes, and
 // the endpoint argument should be the name of a named pipe at which to create
-// the endpoint.  The name should not be bound to an existing listener.
+// the endpoint.  The name should not be bound to an existing listener.  As with
+// DialIPC, logical endpoints are resolved by the host.
 func ListenIPC(endpoint string) (net.Listener, error) {
 	// Dispatch the request through the bridge
 	resultChannel := global.bridge.Listen(endpoint)
@@ -207,3 +249,17 @@ func ListenIPC(endpoint string) (net.Listener, error) {
 		listenerId: result.listenerId,
 	}, nil
 }
+
+// Ping sends a ping to the host through the bridge and returns the round-trip
+// time.  The host responds without performing any I/O, so this measures the
+// latency of the bridge itself and verifies that the host is responsive.
+func Ping() (time.Duration, error) {
+	// Dispatch the request through the bridge
+	resultChannel := global.bridge.Ping()
+
+	// Wait for the result
+	result := <-resultChannel
+
+	// All done
+	return result.roundTrip, result.err
+}
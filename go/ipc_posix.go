@@ -3,19 +3,200 @@
 package ipc
 
 // System imports
-import "net"
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrAbstractNamespaceUnsupported is returned when an abstract namespace
+// endpoint is used on a platform other than Linux.
+var ErrAbstractNamespaceUnsupported = errors.New(
+	"abstract namespace sockets not supported on this platform",
+)
+
+// isAbstractEndpoint returns whether or not an endpoint refers to the Linux
+// abstract socket namespace, which is indicated by a leading '@'.  Sockets in
+// the abstract namespace have no filesystem presence, so there is no socket
+// file to clean up or protect with filesystem permissions.
+func isAbstractEndpoint(endpoint string) bool {
+	return strings.HasPrefix(endpoint, "@")
+}
+
+// resolvePath converts an endpoint to the socket address used to dial or
+// listen.  Logical endpoints are resolved to socket paths and abstract
+// namespace endpoints are validated (Go's net package handles the translation
+// of the leading '@' itself).
+func resolvePath(endpoint string) (string, error) {
+	if isAbstractEndpoint(endpoint) {
+		if !abstractNamespaceSupported {
+			return "", ErrAbstractNamespaceUnsupported
+		}
+		return endpoint, nil
+	}
+	return ResolveEndpoint(endpoint)
+}
 
 // DialIPC establishes a new IPC connection.  On POSIX systems, this is done
 // using Unix domain sockets, and the endpoint argument should be the path of an
-// existing Unix domain socket endpoint to connect to.
+// existing Unix domain socket endpoint to connect to or a logical endpoint
+// created with Endpoint.  On Linux, endpoints with a leading '@' refer to the
+// abstract socket namespace.
 func DialIPC(endpoint string) (net.Conn, error) {
-	return net.Dial("unix", endpoint)
+	// Resolve the endpoint and verify its directory
+	path, err := resolvePath(endpoint)
+	if err != nil {
+		return nil, err
+	} else if err = prepareLogicalEndpoint(endpoint, false); err != nil {
+		return nil, err
+	}
+
+	// Connect
+	return net.Dial("unix", path)
+}
+
+// posixListener wraps a Unix domain socket listener to release any endpoint
+// lock held on its behalf when it is closed, and to manage its socket file if
+// it was bound securely (see listenSecured).
+type posixListener struct {
+	*net.UnixListener
+
+	// The address of the listener
+	address *net.UnixAddr
+
+	// Whether or not we're responsible for removing the socket file (rather
+	// than the underlying listener)
+	unlink bool
+
+	// The endpoint lock, if any
+	lock *endpointLock
+}
+
+func (l *posixListener) Addr() net.Addr {
+	return l.address
+}
+
+func (l *posixListener) Close() error {
+	// Close the listener (which will remove its socket file unless we're
+	// responsible for that)
+	err := l.UnixListener.Close()
+
+	// Remove the socket file if necessary.  This has to be done before
+	// releasing the lock so that the next owner doesn't see it as stale.
+	if l.unlink {
+		if removeErr := os.Remove(l.address.Name); err == nil {
+			err = removeErr
+		}
+		l.unlink = false
+	}
+
+	// Release the lock
+	if l.lock != nil {
+		if lockErr := l.lock.release(); err == nil {
+			err = lockErr
+		}
+		l.lock = nil
+	}
+
+	// All done
+	return err
 }
 
 // ListenIPC establishes a new IPC connection listener.  On POSIX systems, this
 // is done using Unix domain sockets, and the endpoint argument should be the
-// path at which to create the endpoint.  The path should not be bound to an
-// existing listener.
+// path at which to create the endpoint or a logical endpoint created with
+// Endpoint.  The path should not be bound to an existing listener.  On Linux,
+// endpoints with a leading '@' create listeners in the abstract socket
+// namespace, which leave no socket file on disk.
 func ListenIPC(endpoint string) (net.Listener, error) {
-	return net.Listen("unix", endpoint)
+	return ListenIPCWithOptions(endpoint, nil)
+}
+
+// ListenIPCWithOptions is the same as ListenIPC, but allows the listener's
+// behavior to be customized.  See ListenOptions for details.
+func ListenIPCWithOptions(
+	endpoint string,
+	options *ListenOptions,
+) (net.Listener, error) {
+	// Use defaults if no options were provided
+	if options == nil {
+		options = &ListenOptions{}
+	}
+
+	// Resolve the endpoint
+	path, err := resolvePath(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	// Make sure the runtime directory exists for logical endpoints
+	if err := prepareLogicalEndpoint(endpoint, true); err != nil {
+		return nil, err
+	}
+
+	// Abstract namespace sockets have no filesystem presence, so none of the
+	// filesystem-related options apply
+	abstract := isAbstractEndpoint(path)
+
+	// If requested, verify that the parent directory is private, creating it
+	// if necessary
+	if options.PrivateDirectory && !abstract {
+		if err := preparePrivateDirectory(filepath.Dir(path)); err != nil {
+			return nil, err
+		}
+	}
+
+	// Resolve the owning group, if any
+	gid := -1
+	if options.Group != "" && !abstract {
+		if gid, err = lookupGroup(options.Group); err != nil {
+			return nil, err
+		}
+	}
+
+	// If requested, lock the endpoint and reclaim any stale socket
+	var lock *endpointLock
+	if options.ReclaimStale && !abstract {
+		if lock, err = lockEndpoint(path); err != nil {
+			return nil, err
+		}
+		if err = reclaimStale(path); err != nil {
+			lock.release()
+			return nil, err
+		}
+	}
+
+	// Listen, securing the socket file if necessary
+	address := &net.UnixAddr{Name: path, Net: "unix"}
+	secure := !abstract && (options.Mode != 0 || gid != -1)
+	var listener *net.UnixListener
+	if secure {
+		listener, err = listenSecured(path, options.Mode, gid)
+	} else {
+		listener, err = net.ListenUnix("unix", address)
+	}
+	if err != nil {
+		if lock != nil {
+			lock.release()
+		}
+		return nil, err
+	}
+
+	// Wrap the listener
+	var result net.Listener = &posixListener{
+		UnixListener: listener,
+		address:      address,
+		unlink:       secure,
+		lock:         lock,
+	}
+
+	// Enforce the accept policy, if any
+	if options.AcceptPolicy != nil {
+		result = &policyListener{result, options.AcceptPolicy}
+	}
+
+	// All done
+	return result, nil
 }
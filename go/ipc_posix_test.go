@@ -0,0 +1,93 @@
+// +build !windows,!js
+
+package ipc
+
+// System imports
+import (
+	"fmt"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+// testAbstractEndpoint creates a unique abstract namespace endpoint.
+func testAbstractEndpoint() string {
+	return fmt.Sprintf("@gib-test-%d-%d", os.Getpid(), time.Now().UnixNano())
+}
+
+func TestAbstractEndpoint(t *testing.T) {
+	if !abstractNamespaceSupported {
+		t.Skip("abstract namespace not supported")
+	}
+	endpoint := testAbstractEndpoint()
+
+	// Listen with options that would otherwise create or remove files.  These
+	// should all be skipped for abstract endpoints.
+	options := &ListenOptions{
+		Mode:             0600,
+		PrivateDirectory: true,
+		ReclaimStale:     true,
+	}
+	listener, err := ListenIPCWithOptions(endpoint, options)
+	if err != nil {
+		t.Fatal("unable to listen:", err)
+	}
+	if address := listener.Addr().String(); address != endpoint {
+		t.Error("unexpected listener address:", address)
+	}
+	for _, path := range []string{endpoint, endpoint + ".lock"} {
+		if _, err := os.Lstat(path); !os.IsNotExist(err) {
+			t.Error("file created for abstract endpoint:", path)
+		}
+	}
+
+	// Exchange data over a connection
+	accepted := acceptOne(listener)
+	connection, err := DialIPC(endpoint)
+	if err != nil {
+		t.Fatal("unable to connect:", err)
+	}
+	server := receiveConn(t, accepted)
+	if _, err := connection.Write([]byte("abstract")); err != nil {
+		t.Fatal("unable to write:", err)
+	}
+	received := make([]byte, 8)
+	if _, err := io.ReadFull(server, received); err != nil {
+		t.Fatal("unable to read:", err)
+	} else if string(received) != "abstract" {
+		t.Fatal("unexpected data:", string(received))
+	}
+	connection.Close()
+	server.Close()
+
+	// Closing the listener should release the name without any removal, so
+	// that it can be reused immediately
+	if err := listener.Close(); err != nil {
+		t.Fatal("unable to close listener:", err)
+	}
+	listener, err = ListenIPCWithOptions(endpoint, options)
+	if err != nil {
+		t.Fatal("unable to reuse endpoint:", err)
+	}
+	listener.Close()
+	if _, err := DialIPC(endpoint); err == nil {
+		t.Fatal("connected to closed abstract endpoint")
+	}
+}
+
+func TestAbstractEndpointUnsupported(t *testing.T) {
+	if abstractNamespaceSupported {
+		t.Skip("abstract namespace supported")
+	}
+	endpoint := testAbstractEndpoint()
+	if listener, err := ListenIPC(endpoint); err == nil {
+		listener.Close()
+		t.Fatal("abstract listener created")
+	} else if err != ErrAbstractNamespaceUnsupported {
+		t.Fatal("unexpected listen error:", err)
+	}
+	if _, err := DialIPC(endpoint); err != ErrAbstractNamespaceUnsupported {
+		t.Fatal("unexpected dial error:", err)
+	}
+}
@@ -5,20 +5,58 @@ package ipc
 // System imports
 import "net"
 
-// npipe imports
-import "gopkg.in/natefinch/npipe.v2"
-
 // DialIPC establishes a new IPC connection.  On Windows systems, this is done
 // using named pipes, and the endpoint argument should be the name of an
-// existing named pipe endpoint to connect to.
+// existing named pipe endpoint to connect to or a logical endpoint created with
+// Endpoint.
 func DialIPC(endpoint string) (net.Conn, error) {
-	return npipe.Dial(endpoint)
+	// Resolve the endpoint
+	name, err := ResolveEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	// Connect
+	return dialPipe(name)
 }
 
 // ListenIPC establishes a new IPC connection listener.  On Windows systems,
 // this is done using named pipes, and the endpoint argument should be the name
-// of a named pipe at which to create the endpoint.  The name should not be
-// bound to an existing listener.
+// of a named pipe at which to create the endpoint or a logical endpoint created
+// with Endpoint.  The name should not be bound to an existing listener.
 func ListenIPC(endpoint string) (net.Listener, error) {
-	return npipe.Listen(endpoint)
+	return ListenIPCWithOptions(endpoint, nil)
+}
+
+// ListenIPCWithOptions is the same as ListenIPC, but allows the listener's
+// behavior to be customized.  See ListenOptions for details.
+func ListenIPCWithOptions(
+	endpoint string,
+	options *ListenOptions,
+) (net.Listener, error) {
+	// Use defaults if no options were provided
+	if options == nil {
+		options = &ListenOptions{}
+	}
+
+	// Resolve the endpoint
+	name, err := ResolveEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	// Listen.  Named pipes disappear when their last handle is closed, so
+	// there is never a stale endpoint to reclaim.
+	listener, err := listenPipe(name, options.SecurityDescriptor)
+	if err != nil {
+		return nil, err
+	}
+
+	// Enforce the accept policy, if any
+	if options.AcceptPolicy != nil {
+		return &policyListener{listener, options.AcceptPolicy}, nil
+	}
+
+	// All done
+	return listener, nil
 }
@@ -0,0 +1,270 @@
+// +build js
+
+package ipc
+
+// System imports
+import (
+	"errors"
+	"io"
+)
+
+// GopherJS imports
+import "github.com/gopherjs/gopherjs/js"
+
+// javaScriptReadLength is the read length used by the JavaScript API when none
+// is specified (and by the ReadableStream it provides).
+const javaScriptReadLength = 64 * 1024
+
+// maximumJavaScriptReadLength is the largest read length accepted by the
+// JavaScript API.
+const maximumJavaScriptReadLength = 16 * 1024 * 1024
+
+// errInvalidJavaScriptData is returned when data passed to the JavaScript API
+// for writing isn't a string, ArrayBuffer, or ArrayBuffer view.
+var errInvalidJavaScriptData = errors.New(
+	"data must be a string, ArrayBuffer, or ArrayBuffer view",
+)
+
+// errInvalidJavaScriptReadLength is returned when the length passed to the
+// JavaScript API for reading isn't a positive integer within the maximum.
+var errInvalidJavaScriptReadLength = errors.New(
+	"read length must be a positive integer no larger than 16 MiB",
+)
+
+// init exports the connection API to plain JavaScript as the GIB global, so
+// that code not written in Go can use the bridge.  All operations return
+// Promises, so the API isn't exported on engines without them.  The API is:
+//
+//	GIB.initialize()
+//		Starts bridge initialization (see ClientInitialize) and returns a
+//		Promise for the host's initialization message.  It must be called
+//		before the host initializes the bridge, and shouldn't be used if Go
+//		code calls ClientInitialize itself.
+//	GIB.connect(endpoint)
+//		Connects to an endpoint (see DialIPC) and returns a Promise for a
+//		connection object.
+//
+// Connection objects have the following members:
+//
+//	read([length])
+//		Returns a Promise for a Uint8Array of up to length bytes (64 KiB by
+//		default), or null at end-of-stream.  The Promise is rejected if length
+//		isn't a positive integer (up to 16 MiB).  Reads shouldn't overlap.
+//	write(data)
+//		Writes a string (as UTF-8), ArrayBuffer, or ArrayBuffer view and
+//		returns a Promise for the number of bytes written.  The data is
+//		copied before write returns, so its buffer may be reused immediately.
+//	closeRead()
+//		Shuts down the read side of the connection and returns a Promise.
+//	closeWrite()
+//		Shuts down the write side of the connection and returns a Promise.
+//	close()
+//		Closes the connection and returns a Promise.
+//	readable, writable
+//		A WHATWG ReadableStream and WritableStream over the connection (only
+//		present on engines that support streams).  Cancelling the readable
+//		stream shuts down the read side of the connection and closing the
+//		writable stream shuts down its write side, but close must still be
+//		called to release the connection.
+func init() {
+	// Don't export the API if Promises aren't available (e.g. in the Windows
+	// WebBrowser control)
+	if js.Global.Get("Promise") == js.Undefined {
+		return
+	}
+
+	// Create the API object
+	api := js.Global.Get("Object").New()
+	api.Set("initialize", func() *js.Object {
+		// Start initialization synchronously so that it precedes host
+		// initialization
+		controlChannel := ClientInitialize()
+
+		// Wait for the initialization message
+		return newJavaScriptPromise(func() (interface{}, error) {
+			message, ok := <-controlChannel
+			if !ok {
+				return nil, errors.New("bridge shut down")
+			}
+			return message, nil
+		})
+	})
+	api.Set("connect", func(endpoint string) *js.Object {
+		return newJavaScriptPromise(func() (interface{}, error) {
+			connection, err := DialIPC(endpoint)
+			if err != nil {
+				return nil, err
+			}
+			return newJavaScriptConnection(connection.(*ipcConn)), nil
+		})
+	})
+
+	// Export it
+	js.Global.Set("GIB", api)
+}
+
+// newJavaScriptPromise creates a JavaScript Promise settled by the result of
+// the specified operation.  The operation is run on a separate goroutine,
+// since it will generally block on the bridge.  Errors are converted to
+// JavaScript Error objects.
+func newJavaScriptPromise(operation func() (interface{}, error)) *js.Object {
+	return js.Global.Get("Promise").New(func(resolve, reject *js.Object) {
+		go func() {
+			if result, err := operation(); err != nil {
+				reject.Invoke(js.Global.Get("Error").New(err.Error()))
+			} else {
+				resolve.Invoke(result)
+			}
+		}()
+	})
+}
+
+// bytesFromJavaScript converts data passed to the JavaScript API into bytes.
+// The bytes are always copied, since GopherJS byte slices for ArrayBuffers and
+// their views share the underlying JavaScript memory, which the caller is free
+// to modify as soon as the API call returns.  It must therefore be invoked
+// synchronously, before the API call returns.
+func bytesFromJavaScript(data *js.Object) ([]byte, error) {
+	// Convert ArrayBuffers and their views to Uint8Arrays, which GopherJS
+	// converts to byte slices.  Views are checked first, since both have a
+	// byteLength property.
+	if data == js.Undefined || data == nil {
+		return nil, errInvalidJavaScriptData
+	} else if js.Global.Get("ArrayBuffer").Call("isView", data).Bool() {
+		data = js.Global.Get("Uint8Array").New(
+			data.Get("buffer"),
+			data.Get("byteOffset"),
+			data.Get("byteLength"),
+		)
+	} else if data.Get("byteLength") != js.Undefined {
+		data = js.Global.Get("Uint8Array").New(data)
+	}
+
+	// Extract the bytes
+	switch value := data.Interface().(type) {
+	case []byte:
+		result := make([]byte, len(value))
+		copy(result, value)
+		return result, nil
+	case string:
+		return []byte(value), nil
+	default:
+		return nil, errInvalidJavaScriptData
+	}
+}
+
+// readLengthFromJavaScript converts the optional read length passed to the
+// JavaScript API, which must be a positive integral number no larger than
+// maximumJavaScriptReadLength.
+func readLengthFromJavaScript(length *js.Object) (int, error) {
+	// Use the default if no length is specified
+	if length == js.Undefined {
+		return javaScriptReadLength, nil
+	}
+
+	// Verify that the length is a number.  Other types (including strings)
+	// would otherwise be coerced.
+	if length == nil || length.Get("constructor") != js.Global.Get("Number") {
+		return 0, errInvalidJavaScriptReadLength
+	}
+
+	// Verify that it's an integer within range.  NaN fails the comparisons.
+	value := length.Float()
+	if !(value > 0 && value <= maximumJavaScriptReadLength) ||
+		value != float64(int(value)) {
+		return 0, errInvalidJavaScriptReadLength
+	}
+
+	// All done
+	return int(value), nil
+}
+
+// newJavaScriptConnection creates the JavaScript object representing a
+// connection in the JavaScript API.
+func newJavaScriptConnection(connection *ipcConn) *js.Object {
+	// Create the basic operations
+	read := func(length int) (interface{}, error) {
+		buffer := make([]byte, length)
+		count, err := connection.Read(buffer)
+		if count > 0 {
+			return buffer[:count], nil
+		} else if err == io.EOF {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		return buffer[:0], nil
+	}
+	write := func(data *js.Object) *js.Object {
+		buffer, dataErr := bytesFromJavaScript(data)
+		return newJavaScriptPromise(func() (interface{}, error) {
+			if dataErr != nil {
+				return nil, dataErr
+			}
+			return connection.Write(buffer)
+		})
+	}
+	closeRead := func() *js.Object {
+		return newJavaScriptPromise(func() (interface{}, error) {
+			return nil, connection.CloseRead()
+		})
+	}
+	closeWrite := func() *js.Object {
+		return newJavaScriptPromise(func() (interface{}, error) {
+			return nil, connection.CloseWrite()
+		})
+	}
+	closeConnection := func() *js.Object {
+		return newJavaScriptPromise(func() (interface{}, error) {
+			return nil, connection.Close()
+		})
+	}
+
+	// Create the connection object
+	object := js.Global.Get("Object").New()
+	object.Set("read", func(length *js.Object) *js.Object {
+		readLength, lengthErr := readLengthFromJavaScript(length)
+		return newJavaScriptPromise(func() (interface{}, error) {
+			if lengthErr != nil {
+				return nil, lengthErr
+			}
+			return read(readLength)
+		})
+	})
+	object.Set("write", write)
+	object.Set("closeRead", closeRead)
+	object.Set("closeWrite", closeWrite)
+	object.Set("close", closeConnection)
+
+	// Create the readable stream if supported
+	if readableStream := js.Global.Get("ReadableStream"); readableStream != js.Undefined {
+		source := js.Global.Get("Object").New()
+		source.Set("pull", func(controller *js.Object) *js.Object {
+			return newJavaScriptPromise(func() (interface{}, error) {
+				data, err := read(javaScriptReadLength)
+				if err != nil {
+					return nil, err
+				} else if data == nil {
+					controller.Call("close")
+				} else if len(data.([]byte)) > 0 {
+					controller.Call("enqueue", data)
+				}
+				return nil, nil
+			})
+		})
+		source.Set("cancel", closeRead)
+		object.Set("readable", readableStream.New(source))
+	}
+
+	// Create the writable stream if supported
+	if writableStream := js.Global.Get("WritableStream"); writableStream != js.Undefined {
+		sink := js.Global.Get("Object").New()
+		sink.Set("write", write)
+		sink.Set("close", closeWrite)
+		sink.Set("abort", closeConnection)
+		object.Set("writable", writableStream.New(sink))
+	}
+
+	// All done
+	return object
+}
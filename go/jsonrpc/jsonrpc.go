@@ -0,0 +1,696 @@
+// Package jsonrpc implements JSON-RPC 2.0 over IPC connections.  Unlike
+// net/rpc with gob, the wire format can be produced and consumed by
+// hand-written JavaScript, so both GopherJS and plain JavaScript UI code can
+// talk to a Go backend.  A Conn wraps any net.Conn (e.g. one returned by
+// ipc.DialIPC or ipc.ListenIPC, in either the GopherJS or native builds) and
+// acts as both client and server, so either end can invoke methods on the
+// other (e.g. the backend can call methods implemented by the UI).  Batches,
+// notifications, and cancellation are supported.
+//
+// Messages are JSON values (request or response objects, or arrays of them
+// for batches) separated by newlines.  Requests are cancelled by sending a
+// "$/cancelRequest" notification whose params are an object containing the
+// request's id, in which case the handler's context is cancelled.
+package jsonrpc
+
+// System imports
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"sync"
+)
+
+// Version is the JSON-RPC protocol version.
+const Version = "2.0"
+
+// CancelMethod is the notification method used to cancel requests.
+const CancelMethod = "$/cancelRequest"
+
+// Standard error codes.
+const (
+	// CodeParseError indicates that invalid JSON was received.
+	CodeParseError = -32700
+	// CodeInvalidRequest indicates that a message isn't a valid request.
+	CodeInvalidRequest = -32600
+	// CodeMethodNotFound indicates that the requested method doesn't exist.
+	CodeMethodNotFound = -32601
+	// CodeInvalidParams indicates that a method's parameters are invalid.
+	CodeInvalidParams = -32602
+	// CodeInternalError indicates an internal error in the handler.
+	CodeInternalError = -32603
+	// CodeRequestCancelled indicates that a request was cancelled.
+	CodeRequestCancelled = -32800
+)
+
+// ErrClosed is returned by Call, CallBatch, and Notify once the connection has
+// closed.
+var ErrClosed = errors.New("JSON-RPC connection closed")
+
+// ErrInvalidResponse is returned by Call and CallBatch if the peer sends a
+// response containing neither a result nor an error.
+var ErrInvalidResponse = errors.New("invalid JSON-RPC response")
+
+// Error is a JSON-RPC error object.  Handlers can return an *Error to control
+// the error sent to the caller - other errors are reported with
+// CodeInternalError.  Errors returned by the peer are reported to callers as
+// *Error values.
+type Error struct {
+	// Code is the error code.
+	Code int `json:"code"`
+
+	// Message is a short description of the error.
+	Message string `json:"message"`
+
+	// Data is optional additional information about the error.
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Handler handles a request or notification.  The params argument holds the
+// raw request parameters, which is nil if none were sent.  The returned result
+// is encoded as JSON and sent to the caller (results of notifications are
+// discarded).  The context is cancelled if the caller cancels the request or
+// the connection closes.  Handlers are invoked on their own goroutines, so
+// they may block (including on calls back to the peer).
+type Handler func(
+	ctx context.Context,
+	conn *Conn,
+	params json.RawMessage,
+) (interface{}, error)
+
+// BatchCall represents a single call within a batch sent with CallBatch.
+type BatchCall struct {
+	// Method is the method to invoke.
+	Method string
+
+	// Params are the call parameters, which are encoded as JSON.  If nil, no
+	// parameters are sent.
+	Params interface{}
+
+	// Result, if non-nil, receives the decoded result of the call.
+	Result interface{}
+
+	// Notification indicates that the call should be sent as a notification,
+	// in which case no response is expected.
+	Notification bool
+
+	// Error is set by CallBatch to the call's error, if any.
+	Error error
+}
+
+// message is the union of the request and response objects, used for
+// decoding incoming messages.
+type message struct {
+	// The protocol version
+	Version string `json:"jsonrpc"`
+
+	// The raw request id.  This is nil if the id is absent (as it is for
+	// notifications), but holds "null" if the id is present and null, since
+	// requests with null ids still require responses.
+	ID json.RawMessage `json:"id"`
+
+	// The method (requests only)
+	Method string `json:"method"`
+
+	// The parameters (requests only)
+	Params json.RawMessage `json:"params"`
+
+	// The result (successful responses only)
+	Result json.RawMessage `json:"result"`
+
+	// The error (failed responses only)
+	Error *Error `json:"error"`
+}
+
+// hasID returns whether or not a message carries an id (which may be null).
+// Requests without ids are notifications.
+func (m *message) hasID() bool {
+	return m.ID != nil
+}
+
+// request is an outgoing request or notification object.
+type request struct {
+	// The protocol version
+	Version string `json:"jsonrpc"`
+
+	// The request id (nil for notifications)
+	ID *int64 `json:"id,omitempty"`
+
+	// The method
+	Method string `json:"method"`
+
+	// The parameters
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// response is an outgoing response object.
+type response struct {
+	// The protocol version
+	Version string `json:"jsonrpc"`
+
+	// The request id (null if it couldn't be determined)
+	ID json.RawMessage `json:"id"`
+
+	// The result (successful responses only)
+	Result json.RawMessage `json:"result,omitempty"`
+
+	// The error (failed responses only)
+	Error *Error `json:"error,omitempty"`
+}
+
+// cancelParams are the parameters of a cancellation notification.
+type cancelParams struct {
+	// The id of the request to cancel
+	ID json.RawMessage `json:"id"`
+}
+
+// nullID is the id used in responses when the request id is unknown.
+var nullID = json.RawMessage("null")
+
+// Conn is a JSON-RPC connection.  It acts as both client (via Call, CallBatch,
+// and Notify) and server (via the handlers it was created with).  All methods
+// are safe for concurrent use.
+type Conn struct {
+	// The underlying connection
+	connection net.Conn
+
+	// The handlers for incoming requests, keyed by method
+	handlers map[string]Handler
+
+	// Context cancelled when the connection closes, used as the parent of
+	// handler contexts
+	context context.Context
+	cancel  context.CancelFunc
+
+	// Lock serializing writes (and protecting the encoder)
+	writeLock sync.Mutex
+
+	// The encoder for outgoing messages
+	encoder *json.Encoder
+
+	// Lock for the fields below
+	lock sync.Mutex
+
+	// The next outgoing request id
+	nextID int64
+
+	// Result channels for outgoing requests awaiting responses, keyed by id
+	pending map[int64]chan *message
+
+	// Cancellation functions for incoming requests being handled, keyed by
+	// encoded id
+	incoming map[string]context.CancelFunc
+
+	// Whether or not the connection has closed
+	closed bool
+
+	// Channel closed when the connection has closed
+	done chan struct{}
+}
+
+// NewConn creates a new JSON-RPC connection over the specified connection and
+// starts serving it.  Incoming requests are dispatched to the specified
+// handlers by method name, and requests for other methods fail with
+// CodeMethodNotFound.  The handlers may be nil for connections that only make
+// calls.  The Conn takes ownership of the connection, which shouldn't be used
+// directly afterward.
+func NewConn(connection net.Conn, handlers map[string]Handler) *Conn {
+	// Copy the handlers so that the caller can't modify them
+	methods := make(map[string]Handler, len(handlers))
+	for method, handler := range handlers {
+		methods[method] = handler
+	}
+
+	// Create the connection
+	ctx, cancel := context.WithCancel(context.Background())
+	result := &Conn{
+		connection: connection,
+		handlers:   methods,
+		context:    ctx,
+		cancel:     cancel,
+		encoder:    json.NewEncoder(connection),
+		pending:    make(map[int64]chan *message),
+		incoming:   make(map[string]context.CancelFunc),
+		done:       make(chan struct{}),
+	}
+
+	// Start serving
+	go result.run()
+
+	// All done
+	return result
+}
+
+// Close closes the connection.  Outstanding calls fail with ErrClosed and the
+// contexts of running handlers are cancelled.
+func (c *Conn) Close() error {
+	err := c.connection.Close()
+	<-c.done
+	return err
+}
+
+// Done returns a channel that is closed when the connection has closed, either
+// because Close was called or because the peer disconnected.
+func (c *Conn) Done() <-chan struct{} {
+	return c.done
+}
+
+// Call invokes a method on the peer and waits for the result, which is decoded
+// into result (unless it is nil).  If the peer returns an error, it is
+// returned as an *Error.  If the context is cancelled before the response
+// arrives, a cancellation notification is sent to the peer and the context's
+// error is returned.
+func (c *Conn) Call(
+	ctx context.Context,
+	method string,
+	params interface{},
+	result interface{},
+) error {
+	call := &BatchCall{Method: method, Params: params, Result: result}
+	if err := c.CallBatch(ctx, []*BatchCall{call}); err != nil {
+		return err
+	}
+	return call.Error
+}
+
+// Notify sends a notification to the peer.  No response is expected.
+func (c *Conn) Notify(method string, params interface{}) error {
+	call := &BatchCall{Method: method, Params: params, Notification: true}
+	return c.CallBatch(context.Background(), []*BatchCall{call})
+}
+
+// CallBatch sends multiple calls to the peer in a single batch and waits for
+// all of their responses.  Each call's result and error are stored in the
+// call.  The returned error indicates a failure to send the batch or a
+// cancellation of the context, in which case calls that haven't completed have
+// their errors set to the same error.  A batch containing a single call is
+// sent as a plain request.
+func (c *Conn) CallBatch(ctx context.Context, calls []*BatchCall) error {
+	// Check for empty batches, which the protocol doesn't allow
+	if len(calls) == 0 {
+		return errors.New("empty batch")
+	}
+
+	// Create the requests, registering result channels for non-notifications
+	requests := make([]*request, len(calls))
+	channels := make([]chan *message, len(calls))
+	ids := make([]int64, len(calls))
+	for i, call := range calls {
+		var params json.RawMessage
+		if call.Params != nil {
+			encoded, err := json.Marshal(call.Params)
+			if err != nil {
+				c.abandon(ids, channels)
+				return err
+			}
+			params = encoded
+		}
+		requests[i] = &request{
+			Version: Version,
+			Method:  call.Method,
+			Params:  params,
+		}
+		if !call.Notification {
+			id, channel, err := c.register()
+			if err != nil {
+				c.abandon(ids, channels)
+				return err
+			}
+			requests[i].ID = &id
+			ids[i] = id
+			channels[i] = channel
+		}
+	}
+
+	// Send the requests
+	var err error
+	if len(requests) == 1 {
+		err = c.send(requests[0])
+	} else {
+		err = c.send(requests)
+	}
+	if err != nil {
+		c.abandon(ids, channels)
+		return err
+	}
+
+	// Wait for responses
+	for i, call := range calls {
+		if channels[i] == nil {
+			continue
+		}
+		select {
+		case response, ok := <-channels[i]:
+			if !ok {
+				call.Error = ErrClosed
+			} else {
+				call.Error = decodeResult(response, call.Result)
+			}
+		case <-ctx.Done():
+			c.abandon(ids[i:], channels[i:])
+			for j := i; j < len(calls); j++ {
+				if channels[j] == nil {
+					continue
+				}
+				select {
+				case response, ok := <-channels[j]:
+					if !ok {
+						calls[j].Error = ErrClosed
+					} else {
+						calls[j].Error = decodeResult(response, calls[j].Result)
+					}
+					channels[j] = nil
+				default:
+					calls[j].Error = ctx.Err()
+				}
+			}
+			c.cancelRemote(ids[i:], channels[i:])
+			return ctx.Err()
+		}
+	}
+
+	// All done
+	return nil
+}
+
+// decodeResult converts a response into a result or error.
+func decodeResult(response *message, result interface{}) error {
+	if response.Error != nil {
+		return response.Error
+	} else if response.Result == nil {
+		return ErrInvalidResponse
+	} else if result != nil {
+		return json.Unmarshal(response.Result, result)
+	}
+	return nil
+}
+
+// register allocates a request id and registers a result channel for it.
+func (c *Conn) register() (int64, chan *message, error) {
+	// Lock the connection
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	// Don't register new requests once closed
+	if c.closed {
+		return 0, nil, ErrClosed
+	}
+
+	// Register the request
+	id := c.nextID
+	c.nextID++
+	channel := make(chan *message, 1)
+	c.pending[id] = channel
+
+	// All done
+	return id, channel, nil
+}
+
+// abandon unregisters result channels for requests whose responses are no
+// longer wanted.  Entries without channels (notifications) are ignored.
+func (c *Conn) abandon(ids []int64, channels []chan *message) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for i, channel := range channels {
+		if channel != nil && c.pending[ids[i]] == channel {
+			delete(c.pending, ids[i])
+		}
+	}
+}
+
+// cancelRemote sends cancellation notifications for abandoned requests.
+// Failures are ignored, since the requests have already been abandoned.
+func (c *Conn) cancelRemote(ids []int64, channels []chan *message) {
+	for i, channel := range channels {
+		if channel == nil {
+			continue
+		}
+		id, _ := json.Marshal(ids[i])
+		c.Notify(CancelMethod, &cancelParams{ID: id})
+	}
+}
+
+// send encodes and writes a message.
+func (c *Conn) send(value interface{}) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	return c.encoder.Encode(value)
+}
+
+// run reads and dispatches incoming messages until the connection fails, then
+// shuts down the connection.
+func (c *Conn) run() {
+	// Decode messages until there's an error
+	decoder := json.NewDecoder(c.connection)
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); err != nil {
+			// Report syntax errors to the peer.  We can't resynchronize after
+			// them, so we close the connection regardless.
+			if _, ok := err.(*json.SyntaxError); ok {
+				c.send(&response{
+					Version: Version,
+					ID:      nullID,
+					Error:   &Error{Code: CodeParseError, Message: err.Error()},
+				})
+			}
+			break
+		}
+		c.dispatch(raw)
+	}
+
+	// Shut down
+	c.connection.Close()
+	c.cancel()
+	c.lock.Lock()
+	c.closed = true
+	for id, channel := range c.pending {
+		close(channel)
+		delete(c.pending, id)
+	}
+	c.lock.Unlock()
+	close(c.done)
+}
+
+// dispatch handles a single incoming message or batch.  Responses are
+// delivered to waiting callers immediately, and requests are handled on
+// separate goroutines.
+func (c *Conn) dispatch(raw json.RawMessage) {
+	// Handle single messages
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) == 0 || trimmed[0] != '[' {
+		if request := c.decode(raw); request != nil {
+			handle := c.prepare(request)
+			go func() {
+				if response := handle(); response != nil {
+					c.send(response)
+				}
+			}()
+		}
+		return
+	}
+
+	// Decode the batch.  Empty batches are invalid.
+	var batch []json.RawMessage
+	if err := json.Unmarshal(raw, &batch); err != nil || len(batch) == 0 {
+		c.send(invalidRequest(nullID))
+		return
+	}
+
+	// Deliver responses and prepare requests for handling
+	var handlers []func() *response
+	for _, element := range batch {
+		if request := c.decode(element); request != nil {
+			handlers = append(handlers, c.prepare(request))
+		}
+	}
+	if len(handlers) == 0 {
+		return
+	}
+
+	// Handle the requests concurrently and send any responses as a batch
+	go func() {
+		responses := make([]*response, len(handlers))
+		var group sync.WaitGroup
+		group.Add(len(handlers))
+		for i, handle := range handlers {
+			go func(i int, handle func() *response) {
+				responses[i] = handle()
+				group.Done()
+			}(i, handle)
+		}
+		group.Wait()
+		var batch []*response
+		for _, response := range responses {
+			if response != nil {
+				batch = append(batch, response)
+			}
+		}
+		if len(batch) > 0 {
+			c.send(batch)
+		}
+	}()
+}
+
+// decode decodes a single message.  Responses are delivered to waiting
+// callers and nil is returned.  Valid requests are returned for handling.
+// Invalid messages are returned as a message without a method, which prepare
+// converts to an invalid request error.
+func (c *Conn) decode(raw json.RawMessage) *message {
+	// Decode the message
+	result := &message{}
+	if err := json.Unmarshal(raw, result); err != nil || result.Version != Version {
+		return &message{}
+	}
+
+	// If this is a request, return it for handling
+	if result.Method != "" {
+		return result
+	}
+
+	// Otherwise it should be a response to one of our requests.  Responses
+	// that don't match an outstanding request (including error responses with
+	// null ids) are dropped rather than answered, since answering them could
+	// start an endless exchange of errors.
+	if result.Result == nil && result.Error == nil {
+		return &message{}
+	} else if !result.hasID() || bytes.Equal(result.ID, nullID) {
+		return nil
+	}
+	var id int64
+	if json.Unmarshal(result.ID, &id) != nil {
+		return nil
+	}
+	c.lock.Lock()
+	channel, ok := c.pending[id]
+	delete(c.pending, id)
+	c.lock.Unlock()
+	if ok {
+		channel <- result
+	}
+	return nil
+}
+
+// prepare prepares a request for handling, returning a function that invokes
+// the handler and returns the response to send (if any).  Cancellation
+// notifications are processed and requests are registered for cancellation
+// before prepare returns, so that a cancellation can't overtake the request it
+// cancels.
+func (c *Conn) prepare(request *message) func() *response {
+	// Reject invalid requests
+	if request.Method == "" {
+		return func() *response {
+			return invalidRequest(nullID)
+		}
+	}
+
+	// Handle cancellation notifications
+	if request.Method == CancelMethod && !request.hasID() {
+		var params cancelParams
+		if json.Unmarshal(request.Params, &params) == nil {
+			c.lock.Lock()
+			if cancel, ok := c.incoming[string(params.ID)]; ok {
+				cancel()
+			}
+			c.lock.Unlock()
+		}
+		return func() *response { return nil }
+	}
+
+	// Look up the handler
+	handler, ok := c.handlers[request.Method]
+	if !ok {
+		return func() *response {
+			if !request.hasID() {
+				return nil
+			}
+			return &response{
+				Version: Version,
+				ID:      request.ID,
+				Error: &Error{
+					Code:    CodeMethodNotFound,
+					Message: "method not found",
+				},
+			}
+		}
+	}
+
+	// Create a cancellable context for the handler and register it for
+	// cancellation if this is a request.  Requests that reuse the id of a
+	// request that's still being handled are rejected, since responses and
+	// cancellations for them would be ambiguous.
+	ctx, cancel := context.WithCancel(c.context)
+	var key string
+	if request.hasID() {
+		key = string(request.ID)
+		c.lock.Lock()
+		_, duplicate := c.incoming[key]
+		if !duplicate {
+			c.incoming[key] = cancel
+		}
+		c.lock.Unlock()
+		if duplicate {
+			cancel()
+			return func() *response {
+				return &response{
+					Version: Version,
+					ID:      request.ID,
+					Error: &Error{
+						Code:    CodeInvalidRequest,
+						Message: "duplicate request id",
+					},
+				}
+			}
+		}
+	}
+
+	// Create the handling function
+	return func() *response {
+		// Invoke the handler and unregister the request
+		result, err := handler(ctx, c, request.Params)
+		cancel()
+		if !request.hasID() {
+			return nil
+		}
+		c.lock.Lock()
+		delete(c.incoming, key)
+		c.lock.Unlock()
+
+		// Encode the result or error
+		response := &response{Version: Version, ID: request.ID}
+		if err == nil {
+			response.Result, err = json.Marshal(result)
+		}
+		if err != nil {
+			response.Result = nil
+			response.Error = toError(err)
+		}
+
+		// All done
+		return response
+	}
+}
+
+// invalidRequest creates an invalid request error response.
+func invalidRequest(id json.RawMessage) *response {
+	return &response{
+		Version: Version,
+		ID:      id,
+		Error:   &Error{Code: CodeInvalidRequest, Message: "invalid request"},
+	}
+}
+
+// toError converts a handler error to an error object.
+func toError(err error) *Error {
+	if e, ok := err.(*Error); ok {
+		return e
+	} else if err == context.Canceled {
+		return &Error{Code: CodeRequestCancelled, Message: "request cancelled"}
+	}
+	return &Error{Code: CodeInternalError, Message: err.Error()}
+}
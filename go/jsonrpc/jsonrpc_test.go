@@ -0,0 +1,343 @@
+package jsonrpc
+
+// System imports
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// connPair creates a pair of connected Conns over an in-memory pipe that are
+// closed when the test completes.
+func connPair(
+	t *testing.T,
+	clientHandlers, serverHandlers map[string]Handler,
+) (*Conn, *Conn) {
+	clientConnection, serverConnection := net.Pipe()
+	client := NewConn(clientConnection, clientHandlers)
+	server := NewConn(serverConnection, serverHandlers)
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return client, server
+}
+
+// addHandler adds a pair of integers.
+func addHandler(_ context.Context, _ *Conn, params json.RawMessage) (interface{}, error) {
+	var operands [2]int
+	if err := json.Unmarshal(params, &operands); err != nil {
+		return nil, &Error{Code: CodeInvalidParams, Message: err.Error()}
+	}
+	return operands[0] + operands[1], nil
+}
+
+func TestBatchWithNotifications(t *testing.T) {
+	// Create a server that records notifications
+	notifications := make(chan string, 4)
+	client, _ := connPair(t, nil, map[string]Handler{
+		"add": addHandler,
+		"note": func(_ context.Context, _ *Conn, params json.RawMessage) (interface{}, error) {
+			var note string
+			json.Unmarshal(params, &note)
+			notifications <- note
+			return nil, nil
+		},
+	})
+
+	// Send a batch mixing calls and notifications
+	var first, second int
+	calls := []*BatchCall{
+		{Method: "add", Params: []int{1, 2}, Result: &first},
+		{Method: "note", Params: "hello", Notification: true},
+		{Method: "missing", Params: []int{}},
+		{Method: "add", Params: []int{3, 4}, Result: &second},
+	}
+	if err := client.CallBatch(context.Background(), calls); err != nil {
+		t.Fatal("unable to send batch:", err)
+	}
+
+	// Verify the results
+	if calls[0].Error != nil || first != 3 {
+		t.Error("unexpected first result:", first, calls[0].Error)
+	}
+	if calls[1].Error != nil {
+		t.Error("unexpected notification error:", calls[1].Error)
+	}
+	if e, ok := calls[2].Error.(*Error); !ok || e.Code != CodeMethodNotFound {
+		t.Error("unexpected error for missing method:", calls[2].Error)
+	}
+	if calls[3].Error != nil || second != 7 {
+		t.Error("unexpected second result:", second, calls[3].Error)
+	}
+	select {
+	case note := <-notifications:
+		if note != "hello" {
+			t.Error("unexpected notification:", note)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("notification not delivered")
+	}
+}
+
+func TestNotificationOnlyBatch(t *testing.T) {
+	// Create a server and talk to it directly over the wire
+	clientConnection, serverConnection := net.Pipe()
+	server := NewConn(serverConnection, map[string]Handler{"add": addHandler})
+	defer server.Close()
+	defer clientConnection.Close()
+	reader := bufio.NewReader(clientConnection)
+
+	// A batch of notifications shouldn't produce any response, so the next
+	// response should be for the following request
+	batch := `[{"jsonrpc":"2.0","method":"add","params":[1,2]},` +
+		`{"jsonrpc":"2.0","method":"missing"}]` + "\n" +
+		`{"jsonrpc":"2.0","id":7,"method":"add","params":[2,3]}` + "\n"
+	go io.WriteString(clientConnection, batch)
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		t.Fatal("unable to read response:", err)
+	}
+	var response struct {
+		ID     int `json:"id"`
+		Result int `json:"result"`
+	}
+	if err := json.Unmarshal(line, &response); err != nil {
+		t.Fatal("unable to decode response:", err, string(line))
+	} else if response.ID != 7 || response.Result != 5 {
+		t.Fatal("unexpected response:", string(line))
+	}
+}
+
+func TestCancellation(t *testing.T) {
+	// Create a server with a handler that blocks until cancelled
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	client, _ := connPair(t, nil, map[string]Handler{
+		"block": func(ctx context.Context, _ *Conn, _ json.RawMessage) (interface{}, error) {
+			close(started)
+			<-ctx.Done()
+			close(cancelled)
+			return nil, ctx.Err()
+		},
+	})
+
+	// Start a call and cancel it once the handler is running
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- client.Call(ctx, "block", nil, nil)
+	}()
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler not invoked")
+	}
+	cancel()
+
+	// The call should fail with the context's error and the handler's context
+	// should be cancelled by the $/cancelRequest notification
+	if err := <-errs; err != context.Canceled {
+		t.Fatal("unexpected call error:", err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler not cancelled")
+	}
+}
+
+func TestCallFromHandler(t *testing.T) {
+	// Create a server whose handler calls back to the client while handling a
+	// request
+	client, _ := connPair(
+		t,
+		map[string]Handler{"add": addHandler},
+		map[string]Handler{
+			"addViaClient": func(ctx context.Context, conn *Conn, params json.RawMessage) (interface{}, error) {
+				var sum int
+				if err := conn.Call(ctx, "add", params, &sum); err != nil {
+					return nil, err
+				}
+				return sum, nil
+			},
+		},
+	)
+
+	// Invoke it
+	var sum int
+	if err := client.Call(context.Background(), "addViaClient", []int{5, 6}, &sum); err != nil {
+		t.Fatal("call failed:", err)
+	} else if sum != 11 {
+		t.Fatal("unexpected result:", sum)
+	}
+}
+
+func TestParseErrorClosesConnection(t *testing.T) {
+	// Create a server and send it invalid JSON
+	clientConnection, serverConnection := net.Pipe()
+	server := NewConn(serverConnection, nil)
+	defer server.Close()
+	defer clientConnection.Close()
+	go io.WriteString(clientConnection, "{invalid\n")
+
+	// It should respond with a parse error
+	line, err := bufio.NewReader(clientConnection).ReadBytes('\n')
+	if err != nil {
+		t.Fatal("unable to read response:", err)
+	}
+	var response struct {
+		ID    json.RawMessage `json:"id"`
+		Error *Error          `json:"error"`
+	}
+	if err := json.Unmarshal(line, &response); err != nil {
+		t.Fatal("unable to decode response:", err)
+	} else if response.Error == nil || response.Error.Code != CodeParseError {
+		t.Fatal("unexpected response:", response.Error)
+	} else if string(response.ID) != "null" {
+		t.Fatal("unexpected response id:", string(response.ID))
+	}
+
+	// And then close the connection
+	select {
+	case <-server.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection not closed")
+	}
+	if _, err := clientConnection.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatal("unexpected read error:", err)
+	}
+	if err := server.Notify("note", nil); err == nil {
+		t.Fatal("notification sent on closed connection")
+	}
+}
+
+// rawPeer creates a Conn with the specified handlers over an in-memory pipe,
+// returning the other end of the pipe for the test to drive by hand.
+func rawPeer(
+	t *testing.T,
+	handlers map[string]Handler,
+) (*Conn, net.Conn, *bufio.Reader) {
+	connection, peer := net.Pipe()
+	conn := NewConn(connection, handlers)
+	t.Cleanup(func() {
+		peer.Close()
+		conn.Close()
+	})
+	return conn, peer, bufio.NewReader(peer)
+}
+
+// rawResponse is a decoded response read by a raw peer.
+type rawResponse struct {
+	ID     json.RawMessage `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *Error          `json:"error"`
+}
+
+// readResponse reads and decodes a single response.
+func readResponse(t *testing.T, reader *bufio.Reader) *rawResponse {
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		t.Fatal("unable to read response:", err)
+	}
+	response := &rawResponse{}
+	if err := json.Unmarshal(line, response); err != nil {
+		t.Fatal("unable to decode response:", err)
+	}
+	return response
+}
+
+func TestNullIDRequest(t *testing.T) {
+	_, peer, reader := rawPeer(t, map[string]Handler{"add": addHandler})
+
+	// A request with a null id isn't a notification, so it should receive a
+	// response with a null id
+	go io.WriteString(peer,
+		`{"jsonrpc":"2.0","id":null,"method":"add","params":[1,2]}`+"\n")
+	response := readResponse(t, reader)
+	if string(response.ID) != "null" {
+		t.Error("unexpected response id:", string(response.ID))
+	}
+	if response.Error != nil || string(response.Result) != "3" {
+		t.Error("unexpected response:", string(response.Result), response.Error)
+	}
+}
+
+func TestNullIDResponse(t *testing.T) {
+	client, peer, reader := rawPeer(t, nil)
+
+	// Start a call, which will have id 0
+	results := make(chan int, 1)
+	failures := make(chan error, 1)
+	go func() {
+		var result int
+		failures <- client.Call(context.Background(), "get", nil, &result)
+		results <- result
+	}()
+	if _, err := reader.ReadBytes('\n'); err != nil {
+		t.Fatal("unable to read request:", err)
+	}
+
+	// A response with a null id shouldn't be delivered to it
+	go io.WriteString(peer,
+		`{"jsonrpc":"2.0","id":null,"result":1}`+"\n"+
+			`{"jsonrpc":"2.0","id":0,"result":2}`+"\n")
+	if err := <-failures; err != nil {
+		t.Fatal("call failed:", err)
+	} else if result := <-results; result != 2 {
+		t.Error("unexpected result:", result)
+	}
+}
+
+func TestDuplicateRequestID(t *testing.T) {
+	// Create a server whose handler blocks until released
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	_, peer, reader := rawPeer(t, map[string]Handler{
+		"wait": func(
+			_ context.Context,
+			_ *Conn,
+			_ json.RawMessage,
+		) (interface{}, error) {
+			started <- struct{}{}
+			<-release
+			return "done", nil
+		},
+	})
+	request := `{"jsonrpc":"2.0","id":7,"method":"wait"}` + "\n"
+
+	// Send a request, wait for it to start, and then send another with the
+	// same id, which should be rejected without being handled
+	go io.WriteString(peer, request)
+	<-started
+	go io.WriteString(peer, request)
+	response := readResponse(t, reader)
+	if string(response.ID) != "7" || response.Error == nil ||
+		response.Error.Code != CodeInvalidRequest {
+		t.Fatal("duplicate request not rejected:", response.Error)
+	}
+
+	// The original request should complete normally
+	close(release)
+	response = readResponse(t, reader)
+	if string(response.ID) != "7" || response.Error != nil ||
+		string(response.Result) != `"done"` {
+		t.Fatal("unexpected original response:", response.Error)
+	}
+	select {
+	case <-started:
+		t.Fatal("duplicate request was handled")
+	default:
+	}
+
+	// Once the original request has completed, its id can be reused
+	go io.WriteString(peer, request)
+	response = readResponse(t, reader)
+	if response.Error != nil || string(response.Result) != `"done"` {
+		t.Error("unable to reuse request id:", response.Error)
+	}
+}
@@ -0,0 +1,62 @@
+// +build !js
+
+package ipc
+
+// System imports
+import "os"
+
+// ListenOptions provides optional behavior for native IPC listeners created
+// with ListenIPCWithOptions.  The zero value (or a nil pointer) provides the
+// same behavior as ListenIPC.  Options that don't apply to the current
+// platform are ignored unless otherwise noted.
+type ListenOptions struct {
+	// ReclaimStale enables safe takeover of socket files left behind by a
+	// previous listener that exited without cleaning up (e.g. because it
+	// crashed).  A lock file (the socket path with a ".lock" suffix) is held
+	// for the lifetime of the listener to prevent multiple processes from
+	// racing for the same endpoint, and an existing socket file is only
+	// removed if no process is accepting connections on it.  This option only
+	// applies to filesystem-based Unix domain sockets - named pipes and
+	// abstract namespace sockets are removed automatically by the system.
+	ReclaimStale bool
+
+	// Mode, if non-zero, sets the permission bits of the socket file.  By
+	// default, the socket file's permissions are determined by the process
+	// umask, which may allow other local users to connect.  On most systems,
+	// connecting to a Unix domain socket requires write permission, so a mode
+	// of 0600 restricts connections to the owning user.  The mode is applied
+	// before the socket becomes visible at its path, so there is no window in
+	// which connections are possible with the default permissions.  This
+	// option only applies to filesystem-based Unix domain sockets.
+	Mode os.FileMode
+
+	// Group, if non-empty, sets the owning group of the socket file.  It may
+	// be either a group name or a numeric group id.  Like Mode, it is applied
+	// before the socket becomes visible at its path.  This option only applies
+	// to filesystem-based Unix domain sockets.
+	Group string
+
+	// PrivateDirectory requires that the socket's parent directory be private
+	// to the current user.  If the directory doesn't exist, it is created with
+	// permissions 0700.  If it does exist, it must be owned by the current user
+	// and must not be accessible to any other user.  Note that this prevents
+	// other users from connecting regardless of Mode and Group.  This option
+	// only applies to filesystem-based Unix domain sockets.
+	PrivateDirectory bool
+
+	// SecurityDescriptor, if non-empty, is an SDDL security descriptor (e.g.
+	// "D:P(A;;GA;;;OW)") to apply to named pipes on Windows.  By default,
+	// named pipes are created with the process' default security descriptor,
+	// which generally allows other local users to connect.  Remote clients are
+	// rejected regardless.  The descriptor must grant the current user
+	// full access, since the listener needs to create new pipe instances.  It
+	// is ignored on other platforms.
+	SecurityDescriptor string
+
+	// AcceptPolicy, if non-nil, is invoked with the peer credentials of each
+	// accepted connection (see PeerCredentialsOf).  Connections for which the
+	// policy returns an error (or whose credentials can't be determined) are
+	// closed immediately and are never returned from Accept.  SameUserPolicy
+	// and ExecutablePolicy provide common policies.
+	AcceptPolicy AcceptPolicy
+}
@@ -0,0 +1,373 @@
+package ipc
+
+// System imports
+import (
+	"io"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Operation names used when recording metrics.  These correspond to the
+// methods of the GopherJS Bridge interface, and native instrumented
+// connections use the same names for their reads, writes, and closes.
+const (
+	OperationConnect                = "connect"
+	OperationConnectionRead         = "connection_read"
+	OperationConnectionWrite        = "connection_write"
+	OperationConnectionClose        = "connection_close"
+	OperationListen                 = "listen"
+	OperationListenerAccept         = "listener_accept"
+	OperationListenerClose          = "listener_close"
+	OperationPing                   = "ping"
+	OperationConnectionCloseRead    = "connection_close_read"
+	OperationConnectionCloseWrite   = "connection_close_write"
+	OperationConnectPacket          = "connect_packet"
+	OperationConnectionReadMessage  = "connection_read_message"
+	OperationConnectionWriteMessage = "connection_write_message"
+	OperationListenPacket           = "listen_packet"
+)
+
+// Error kinds used when recording failed operations.
+const (
+	ErrorKindEOF        = "eof"
+	ErrorKindTimeout    = "timeout"
+	ErrorKindPermission = "permission"
+	ErrorKindOther      = "other"
+)
+
+// latencyBuckets are the upper bounds (in seconds) of the latency histogram
+// buckets.  They span the range from a fast native call to a badly stalled
+// bridge round trip.
+var latencyBuckets = []float64{
+	0.0001, 0.00025, 0.0005,
+	0.001, 0.0025, 0.005,
+	0.01, 0.025, 0.05,
+	0.1, 0.25, 0.5,
+	1, 2.5, 5, 10,
+}
+
+// errorKind classifies an error for metrics purposes.
+func errorKind(err error) string {
+	if err == io.EOF {
+		return ErrorKindEOF
+	} else if err == ErrEndpointNotPermitted || os.IsPermission(err) {
+		return ErrorKindPermission
+	} else if e, ok := err.(net.Error); ok && e.Timeout() {
+		return ErrorKindTimeout
+	}
+	return ErrorKindOther
+}
+
+// ConnectionStatistics is a snapshot of I/O counters for a connection (or, in
+// a MetricsSnapshot, for all connections observed by a registry).  End-of-file
+// conditions are not counted as errors.
+type ConnectionStatistics struct {
+	// BytesRead is the number of bytes read.
+	BytesRead uint64
+
+	// BytesWritten is the number of bytes written.
+	BytesWritten uint64
+
+	// Reads is the number of read operations.
+	Reads uint64
+
+	// Writes is the number of write operations.
+	Writes uint64
+
+	// ReadErrors is the number of read operations that failed.
+	ReadErrors uint64
+
+	// WriteErrors is the number of write operations that failed.
+	WriteErrors uint64
+}
+
+// connectionCounters tracks I/O counters for a connection.  It is safe for
+// concurrent use.
+type connectionCounters struct {
+	// The counters.  These are accessed atomically and must remain at the
+	// start of the structure to guarantee 64-bit alignment on 32-bit
+	// platforms.
+	bytesRead    uint64
+	bytesWritten uint64
+	reads        uint64
+	writes       uint64
+	readErrors   uint64
+	writeErrors  uint64
+}
+
+// recordRead records the result of a read operation.
+func (c *connectionCounters) recordRead(count int, err error) {
+	atomic.AddUint64(&c.reads, 1)
+	if count > 0 {
+		atomic.AddUint64(&c.bytesRead, uint64(count))
+	}
+	if err != nil && err != io.EOF {
+		atomic.AddUint64(&c.readErrors, 1)
+	}
+}
+
+// recordWrite records the result of a write operation.
+func (c *connectionCounters) recordWrite(count int, err error) {
+	atomic.AddUint64(&c.writes, 1)
+	if count > 0 {
+		atomic.AddUint64(&c.bytesWritten, uint64(count))
+	}
+	if err != nil {
+		atomic.AddUint64(&c.writeErrors, 1)
+	}
+}
+
+// snapshot returns the current counter values.
+func (c *connectionCounters) snapshot() ConnectionStatistics {
+	return ConnectionStatistics{
+		BytesRead:    atomic.LoadUint64(&c.bytesRead),
+		BytesWritten: atomic.LoadUint64(&c.bytesWritten),
+		Reads:        atomic.LoadUint64(&c.reads),
+		Writes:       atomic.LoadUint64(&c.writes),
+		ReadErrors:   atomic.LoadUint64(&c.readErrors),
+		WriteErrors:  atomic.LoadUint64(&c.writeErrors),
+	}
+}
+
+// statisticsProvider is implemented by connections that track their own I/O
+// counters.
+type statisticsProvider interface {
+	Statistics() ConnectionStatistics
+}
+
+// StatisticsOf returns the I/O counters for a connection, if it tracks them.
+// GopherJS IPC connections and connections wrapped with InstrumentConn track
+// counters.
+func StatisticsOf(connection net.Conn) (ConnectionStatistics, bool) {
+	if provider, ok := connection.(statisticsProvider); ok {
+		return provider.Statistics(), true
+	}
+	return ConnectionStatistics{}, false
+}
+
+// HistogramSnapshot is a snapshot of a latency histogram.
+type HistogramSnapshot struct {
+	// Bounds are the upper bounds (in seconds) of each bucket.
+	Bounds []float64
+
+	// Counts are the number of observations falling into each bucket
+	// (non-cumulative).  There is one more count than there are bounds, with
+	// the final count tracking observations above the largest bound.
+	Counts []uint64
+
+	// Count is the total number of observations.
+	Count uint64
+
+	// Sum is the sum of all observations, in seconds.
+	Sum float64
+}
+
+// histogram is a fixed-bucket latency histogram.  It is not safe for
+// concurrent use - callers must provide synchronization.
+type histogram struct {
+	// The bucket counts
+	counts []uint64
+
+	// The total observation count
+	count uint64
+
+	// The observation sum (in seconds)
+	sum float64
+}
+
+func newHistogram() *histogram {
+	return &histogram{
+		counts: make([]uint64, len(latencyBuckets)+1),
+	}
+}
+
+// observe records an observation.
+func (h *histogram) observe(duration time.Duration) {
+	seconds := duration.Seconds()
+	h.counts[sort.SearchFloat64s(latencyBuckets, seconds)]++
+	h.count++
+	h.sum += seconds
+}
+
+// snapshot returns a copy of the histogram state.
+func (h *histogram) snapshot() HistogramSnapshot {
+	return HistogramSnapshot{
+		Bounds: append([]float64(nil), latencyBuckets...),
+		Counts: append([]uint64(nil), h.counts...),
+		Count:  h.count,
+		Sum:    h.sum,
+	}
+}
+
+// OperationStatistics is a snapshot of the metrics for a single operation
+// type.
+type OperationStatistics struct {
+	// Count is the number of completed operations.
+	Count uint64
+
+	// Errors is the number of failed operations, keyed by error kind.
+	Errors map[string]uint64
+
+	// Latency is the distribution of operation latencies.
+	Latency HistogramSnapshot
+}
+
+// operationMetrics tracks metrics for a single operation type.
+type operationMetrics struct {
+	// The completed operation count
+	count uint64
+
+	// The error counts by kind
+	errors map[string]uint64
+
+	// The latency histogram
+	latency *histogram
+}
+
+// MetricsSnapshot is a point-in-time copy of the metrics tracked by a
+// Registry.
+type MetricsSnapshot struct {
+	// Connections aggregates the I/O counters for all connections observed by
+	// the registry.
+	Connections ConnectionStatistics
+
+	// ConnectionsOpened is the number of connections established (by either
+	// dialing or accepting).
+	ConnectionsOpened uint64
+
+	// ConnectionsClosed is the number of connections closed.
+	ConnectionsClosed uint64
+
+	// InFlight is the number of requests currently awaiting results.
+	InFlight int64
+
+	// Operations contains per-operation statistics, keyed by operation name.
+	Operations map[string]OperationStatistics
+}
+
+// Registry collects metrics from instrumented bridges and connections.  It is
+// safe for concurrent use.
+type Registry struct {
+	// Aggregate connection counters
+	connections connectionCounters
+
+	// Lock for the remaining fields
+	lock sync.Mutex
+
+	// Connection lifecycle counts
+	connectionsOpened uint64
+	connectionsClosed uint64
+
+	// The number of in-flight requests
+	inFlight int64
+
+	// Per-operation metrics
+	operations map[string]*operationMetrics
+}
+
+// NewRegistry creates a new, empty metrics registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		operations: make(map[string]*operationMetrics),
+	}
+}
+
+// DefaultRegistry is the registry used by instrumentation when none is
+// specified.
+var DefaultRegistry = NewRegistry()
+
+// startOperation records the start of a request.
+func (r *Registry) startOperation() {
+	r.lock.Lock()
+	r.inFlight++
+	r.lock.Unlock()
+}
+
+// finishOperation records the completion of a request started with
+// startOperation.
+func (r *Registry) finishOperation(
+	operation string,
+	start time.Time,
+	err error,
+) {
+	r.lock.Lock()
+	r.inFlight--
+	r.lock.Unlock()
+	r.observeOperation(operation, time.Since(start), err)
+}
+
+// observeOperation records a completed operation.
+func (r *Registry) observeOperation(
+	operation string,
+	duration time.Duration,
+	err error,
+) {
+	// Lock the registry
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	// Grab or create the operation metrics
+	metrics, ok := r.operations[operation]
+	if !ok {
+		metrics = &operationMetrics{
+			errors:  make(map[string]uint64),
+			latency: newHistogram(),
+		}
+		r.operations[operation] = metrics
+	}
+
+	// Record the operation
+	metrics.count++
+	metrics.latency.observe(duration)
+	if err != nil {
+		metrics.errors[errorKind(err)]++
+	}
+}
+
+// connectionOpened records the establishment of a connection.
+func (r *Registry) connectionOpened() {
+	r.lock.Lock()
+	r.connectionsOpened++
+	r.lock.Unlock()
+}
+
+// connectionClosed records the closure of a connection.
+func (r *Registry) connectionClosed() {
+	r.lock.Lock()
+	r.connectionsClosed++
+	r.lock.Unlock()
+}
+
+// Snapshot returns a copy of the registry's current metrics.
+func (r *Registry) Snapshot() *MetricsSnapshot {
+	// Lock the registry
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	// Copy metrics
+	snapshot := &MetricsSnapshot{
+		Connections:       r.connections.snapshot(),
+		ConnectionsOpened: r.connectionsOpened,
+		ConnectionsClosed: r.connectionsClosed,
+		InFlight:          r.inFlight,
+		Operations:        make(map[string]OperationStatistics, len(r.operations)),
+	}
+	for operation, metrics := range r.operations {
+		errors := make(map[string]uint64, len(metrics.errors))
+		for kind, count := range metrics.errors {
+			errors[kind] = count
+		}
+		snapshot.Operations[operation] = OperationStatistics{
+			Count:   metrics.count,
+			Errors:  errors,
+			Latency: metrics.latency.snapshot(),
+		}
+	}
+
+	// All done
+	return snapshot
+}
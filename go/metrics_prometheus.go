@@ -0,0 +1,148 @@
+// +build !js
+
+package ipc
+
+// System imports
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// prometheusFloat formats a floating point value for the Prometheus text
+// exposition format.
+func prometheusFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// WritePrometheus writes the registry's current metrics to the specified writer
+// in the Prometheus text exposition format.  All metric names are prefixed
+// with "gib_".
+func (r *Registry) WritePrometheus(writer io.Writer) error {
+	// Grab a snapshot so that we don't hold the registry lock while writing
+	snapshot := r.Snapshot()
+
+	// Create a buffered writer so that we can defer error checking until the
+	// end
+	output := bufio.NewWriter(writer)
+
+	// Write a simple metric with its metadata
+	simple := func(name, kind, help string, value string) {
+		fmt.Fprintf(output, "# HELP %s %s\n", name, help)
+		fmt.Fprintf(output, "# TYPE %s %s\n", name, kind)
+		fmt.Fprintf(output, "%s %s\n", name, value)
+	}
+
+	// Write connection metrics
+	connections := snapshot.Connections
+	simple(
+		"gib_connection_bytes_read_total", "counter",
+		"Bytes read from IPC connections.",
+		strconv.FormatUint(connections.BytesRead, 10),
+	)
+	simple(
+		"gib_connection_bytes_written_total", "counter",
+		"Bytes written to IPC connections.",
+		strconv.FormatUint(connections.BytesWritten, 10),
+	)
+	simple(
+		"gib_connection_read_errors_total", "counter",
+		"Failed IPC connection reads (excluding end-of-file).",
+		strconv.FormatUint(connections.ReadErrors, 10),
+	)
+	simple(
+		"gib_connection_write_errors_total", "counter",
+		"Failed IPC connection writes.",
+		strconv.FormatUint(connections.WriteErrors, 10),
+	)
+	simple(
+		"gib_connections_opened_total", "counter",
+		"IPC connections established.",
+		strconv.FormatUint(snapshot.ConnectionsOpened, 10),
+	)
+	simple(
+		"gib_connections_closed_total", "counter",
+		"IPC connections closed.",
+		strconv.FormatUint(snapshot.ConnectionsClosed, 10),
+	)
+	simple(
+		"gib_requests_in_flight", "gauge",
+		"Bridge requests awaiting results.",
+		strconv.FormatInt(snapshot.InFlight, 10),
+	)
+
+	// Sort operation names so that output is deterministic
+	operations := make([]string, 0, len(snapshot.Operations))
+	for operation := range snapshot.Operations {
+		operations = append(operations, operation)
+	}
+	sort.Strings(operations)
+
+	// Write operation counts
+	fmt.Fprintln(output, "# HELP gib_operations_total IPC operations completed.")
+	fmt.Fprintln(output, "# TYPE gib_operations_total counter")
+	for _, operation := range operations {
+		fmt.Fprintf(output, "gib_operations_total{operation=%q} %d\n",
+			operation, snapshot.Operations[operation].Count,
+		)
+	}
+
+	// Write operation errors
+	fmt.Fprintln(output, "# HELP gib_operation_errors_total IPC operations failed, by error kind.")
+	fmt.Fprintln(output, "# TYPE gib_operation_errors_total counter")
+	for _, operation := range operations {
+		errors := snapshot.Operations[operation].Errors
+		kinds := make([]string, 0, len(errors))
+		for kind := range errors {
+			kinds = append(kinds, kind)
+		}
+		sort.Strings(kinds)
+		for _, kind := range kinds {
+			fmt.Fprintf(output,
+				"gib_operation_errors_total{operation=%q,kind=%q} %d\n",
+				operation, kind, errors[kind],
+			)
+		}
+	}
+
+	// Write operation latencies.  Prometheus histogram buckets are
+	// cumulative.
+	fmt.Fprintln(output, "# HELP gib_operation_duration_seconds IPC operation latency.")
+	fmt.Fprintln(output, "# TYPE gib_operation_duration_seconds histogram")
+	for _, operation := range operations {
+		latency := snapshot.Operations[operation].Latency
+		var cumulative uint64
+		for i, bound := range latency.Bounds {
+			cumulative += latency.Counts[i]
+			fmt.Fprintf(output,
+				"gib_operation_duration_seconds_bucket{operation=%q,le=%q} %d\n",
+				operation, prometheusFloat(bound), cumulative,
+			)
+		}
+		fmt.Fprintf(output,
+			"gib_operation_duration_seconds_bucket{operation=%q,le=\"+Inf\"} %d\n",
+			operation, latency.Count,
+		)
+		fmt.Fprintf(output,
+			"gib_operation_duration_seconds_sum{operation=%q} %s\n",
+			operation, prometheusFloat(latency.Sum),
+		)
+		fmt.Fprintf(output,
+			"gib_operation_duration_seconds_count{operation=%q} %d\n",
+			operation, latency.Count,
+		)
+	}
+
+	// Flush output
+	return output.Flush()
+}
+
+// ServeHTTP implements http.Handler, serving the registry's metrics in the
+// Prometheus text exposition format.
+func (r *Registry) ServeHTTP(writer http.ResponseWriter, _ *http.Request) {
+	writer.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	r.WritePrometheus(writer)
+}
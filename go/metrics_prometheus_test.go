@@ -0,0 +1,48 @@
+// +build !js
+
+package ipc
+
+// System imports
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWritePrometheus(t *testing.T) {
+	// Populate a registry with deterministic values
+	registry := NewRegistry()
+	registry.connections.recordRead(10, nil)
+	registry.connections.recordRead(0, io.EOF)
+	registry.connections.recordWrite(4, nil)
+	registry.connections.recordWrite(0, errors.New("write failed"))
+	registry.connectionOpened()
+	registry.connectionOpened()
+	registry.connectionClosed()
+	registry.startOperation()
+	registry.startOperation()
+	registry.observeOperation(OperationConnect, 2*time.Millisecond, nil)
+	registry.observeOperation(
+		OperationConnect,
+		30*time.Millisecond,
+		ErrEndpointNotPermitted,
+	)
+	registry.observeOperation(OperationPing, 20*time.Second, io.EOF)
+
+	// Render the metrics and compare them with the expected output
+	var output bytes.Buffer
+	if err := registry.WritePrometheus(&output); err != nil {
+		t.Fatal("unable to write metrics:", err)
+	}
+	expected, err := ioutil.ReadFile(filepath.Join("testdata", "metrics.prom"))
+	if err != nil {
+		t.Fatal("unable to read expected output:", err)
+	}
+	if !bytes.Equal(output.Bytes(), expected) {
+		t.Errorf("output mismatch:\n%s", output.String())
+	}
+}
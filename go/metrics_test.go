@@ -0,0 +1,154 @@
+package ipc
+
+// System imports
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// testTimeoutError is a net.Error timeout used to test error classification.
+type testTimeoutError struct{}
+
+func (testTimeoutError) Error() string   { return "timeout" }
+func (testTimeoutError) Timeout() bool   { return true }
+func (testTimeoutError) Temporary() bool { return true }
+
+func TestRegistryOperations(t *testing.T) {
+	registry := NewRegistry()
+
+	// Record operations with each error kind
+	registry.observeOperation(OperationConnect, 300*time.Microsecond, nil)
+	registry.observeOperation(OperationConnect, time.Millisecond, io.EOF)
+	registry.observeOperation(OperationConnect, time.Millisecond, ErrEndpointNotPermitted)
+	registry.observeOperation(OperationConnect, time.Millisecond, testTimeoutError{})
+	registry.observeOperation(OperationConnect, time.Minute, errors.New("other"))
+
+	// Track in-flight requests
+	registry.startOperation()
+	registry.startOperation()
+	registry.finishOperation(OperationPing, time.Now(), nil)
+
+	// Verify the snapshot
+	snapshot := registry.Snapshot()
+	if snapshot.InFlight != 1 {
+		t.Error("unexpected in-flight count:", snapshot.InFlight)
+	}
+	if count := snapshot.Operations[OperationPing].Count; count != 1 {
+		t.Error("unexpected ping count:", count)
+	}
+	connect := snapshot.Operations[OperationConnect]
+	if connect.Count != 5 {
+		t.Error("unexpected connect count:", connect.Count)
+	}
+	for _, kind := range []string{
+		ErrorKindEOF,
+		ErrorKindPermission,
+		ErrorKindTimeout,
+		ErrorKindOther,
+	} {
+		if connect.Errors[kind] != 1 {
+			t.Errorf("unexpected %s error count: %d", kind, connect.Errors[kind])
+		}
+	}
+
+	// Verify the histogram.  Bounds are inclusive, and observations beyond the
+	// largest bound land in the final bucket.
+	latency := connect.Latency
+	if len(latency.Counts) != len(latency.Bounds)+1 {
+		t.Fatal("unexpected bucket count:", len(latency.Counts))
+	}
+	expected := make([]uint64, len(latency.Counts))
+	expected[2] = 1
+	expected[3] = 3
+	expected[len(expected)-1] = 1
+	for i, count := range latency.Counts {
+		if count != expected[i] {
+			t.Errorf("unexpected count in bucket %d: %d", i, count)
+		}
+	}
+	if latency.Count != 5 {
+		t.Error("unexpected observation count:", latency.Count)
+	}
+
+	// Snapshots should be independent of the registry
+	connect.Errors[ErrorKindEOF] = 100
+	connect.Latency.Counts[0] = 100
+	connect = registry.Snapshot().Operations[OperationConnect]
+	if connect.Errors[ErrorKindEOF] != 1 || connect.Latency.Counts[0] != 0 {
+		t.Error("snapshot shares state with registry")
+	}
+}
+
+func TestInstrumentConn(t *testing.T) {
+	registry := NewRegistry()
+	first, second := net.Pipe()
+	client := InstrumentConn(first, registry)
+	server := InstrumentConn(second, registry)
+
+	// Transfer data
+	written := make(chan struct{})
+	go func() {
+		client.Write([]byte("hello"))
+		close(written)
+	}()
+	buffer := make([]byte, 16)
+	count, err := server.Read(buffer)
+	if err != nil || count != 5 {
+		t.Fatal("unable to read:", count, err)
+	}
+	<-written
+
+	// Close the client, after which the server should see end-of-file (which
+	// isn't counted as an error) and writes should fail
+	client.Close()
+	client.Close()
+	if _, err := server.Read(buffer); err != io.EOF {
+		t.Fatal("unexpected read error:", err)
+	}
+	if _, err := client.Write([]byte("x")); err == nil {
+		t.Fatal("write to closed connection succeeded")
+	}
+	server.Close()
+
+	// Verify the per-connection counters
+	if statistics, ok := StatisticsOf(client); !ok {
+		t.Fatal("instrumented connection has no statistics")
+	} else if statistics != (ConnectionStatistics{
+		BytesWritten: 5,
+		Writes:       2,
+		WriteErrors:  1,
+	}) {
+		t.Errorf("unexpected client statistics: %+v", statistics)
+	}
+	if statistics := server.Statistics(); statistics != (ConnectionStatistics{
+		BytesRead: 5,
+		Reads:     2,
+	}) {
+		t.Errorf("unexpected server statistics: %+v", statistics)
+	}
+	if _, ok := StatisticsOf(first); ok {
+		t.Error("uninstrumented connection has statistics")
+	}
+
+	// Verify the aggregate counters, which should count each closure once
+	snapshot := registry.Snapshot()
+	if snapshot.Connections != (ConnectionStatistics{
+		BytesRead:    5,
+		BytesWritten: 5,
+		Reads:        2,
+		Writes:       2,
+		WriteErrors:  1,
+	}) {
+		t.Errorf("unexpected aggregate statistics: %+v", snapshot.Connections)
+	}
+	if snapshot.ConnectionsOpened != 2 || snapshot.ConnectionsClosed != 2 {
+		t.Error("unexpected connection counts:",
+			snapshot.ConnectionsOpened, snapshot.ConnectionsClosed)
+	}
+	if count := snapshot.Operations[OperationConnectionClose].Count; count != 3 {
+		t.Error("unexpected close count:", count)
+	}
+}
@@ -0,0 +1,78 @@
+package ipc
+
+// System imports
+import (
+	"errors"
+	"net"
+)
+
+// MaximumMessageSize is the largest message that can be sent or received over
+// a packet IPC connection.  It is chosen to fit within the default socket
+// buffer sizes on Linux and the pipe buffer sizes used on Windows.
+const MaximumMessageSize = 64 * 1024
+
+// ErrMessageTooLarge is returned by WriteMessage when a message exceeds
+// MaximumMessageSize, and by ReadMessage when the peer sent such a message (in
+// which case the message is discarded).
+var ErrMessageTooLarge = errors.New("message too large")
+
+// ErrEmptyMessage is returned by WriteMessage for empty messages.  Empty
+// messages can't be distinguished from end-of-stream on all platforms, so
+// they aren't supported.
+var ErrEmptyMessage = errors.New("empty messages not supported")
+
+// ErrPacketModeUnsupported is returned by DialIPCPacket and ListenIPCPacket on
+// platforms without a message-oriented IPC transport.
+var ErrPacketModeUnsupported = errors.New(
+	"packet mode IPC not supported on this platform",
+)
+
+// PacketConn is a message-oriented IPC connection.  Unlike a stream
+// connection, message boundaries are preserved, so each call to ReadMessage
+// returns exactly one message sent with WriteMessage.  Messages are delivered
+// reliably and in order.  ReadMessage and WriteMessage may each be called
+// concurrently with the other, but not with themselves.
+type PacketConn interface {
+	// ReadMessage reads the next message from the connection.  It returns
+	// io.EOF once the peer has closed the connection and all of its messages
+	// have been read.
+	ReadMessage() ([]byte, error)
+
+	// WriteMessage sends a message over the connection.  The message must be
+	// non-empty and no larger than MaximumMessageSize.
+	WriteMessage(message []byte) error
+
+	// Close closes the connection.  Any blocked ReadMessage or WriteMessage
+	// calls are unblocked and return errors.
+	Close() error
+
+	// LocalAddr returns the local address of the connection.
+	LocalAddr() net.Addr
+
+	// RemoteAddr returns the remote address of the connection.
+	RemoteAddr() net.Addr
+}
+
+// PacketListener is a listener for message-oriented IPC connections.
+type PacketListener interface {
+	// Accept waits for and returns the next connection to the listener.
+	Accept() (PacketConn, error)
+
+	// Close closes the listener.  Any blocked Accept calls are unblocked and
+	// return errors.
+	Close() error
+
+	// Addr returns the listener's address.
+	Addr() net.Addr
+}
+
+// validateMessage verifies that a message can be sent over a packet IPC
+// connection.
+func validateMessage(message []byte) error {
+	if len(message) == 0 {
+		return ErrEmptyMessage
+	} else if len(message) > MaximumMessageSize {
+		return ErrMessageTooLarge
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+// +build js
+
+package ipc
+
+// System imports
+import (
+	"io"
+	"net"
+)
+
+// ipcPacketConn implements the PacketConn interface for GopherJS IPC
+// connections.
+type ipcPacketConn struct {
+	address *ipcAddr
+	connectionId int
+}
+
+func (c *ipcPacketConn) ReadMessage() ([]byte, error) {
+	// Dispatch the request through the bridge
+	resultChannel := global.bridge.ConnectionReadMessage(c.connectionId)
+
+	// Wait for the result
+	result := <-resultChannel
+
+	// Watch for errors
+	if result.err != nil {
+		return nil, result.err
+	}
+
+	// Empty messages aren't supported, so the host reports end-of-stream with
+	// an empty result
+	if len(result.data) == 0 {
+		return nil, io.EOF
+	}
+
+	// All done
+	return result.data, nil
+}
+
+func (c *ipcPacketConn) WriteMessage(message []byte) error {
+	// Validate the message before sending it across the bridge
+	if err := validateMessage(message); err != nil {
+		return err
+	}
+
+	// Dispatch the request through the bridge
+	resultChannel := global.bridge.ConnectionWriteMessage(c.connectionId, message)
+
+	// Wait for the result
+	result := <-resultChannel
+
+	// All done
+	return result.err
+}
+
+func (c *ipcPacketConn) Close() error {
+	// If the connection is already closed, do nothing
+	if c.connectionId == -1 {
+		return nil
+	}
+
+	// Dispatch the request through the bridge
+	resultChannel := global.bridge.ConnectionClose(c.connectionId)
+
+	// Wait for the result
+	result := <-resultChannel
+
+	// If we were successful, mark the connection as closed
+	if result.err == nil {
+		c.connectionId = -1
+	}
+
+	// All done
+	return result.err
+}
+
+func (c *ipcPacketConn) LocalAddr() net.Addr {
+	return c.address
+}
+
+func (c *ipcPacketConn) RemoteAddr() net.Addr {
+	return c.address
+}
+
+// DialIPCPacket establishes a new message-oriented GopherJS IPC connection.
+// On Linux hosts, this is done using SOCK_SEQPACKET Unix domain sockets, and on
+// Windows hosts, this is done using message-mode named pipes.  Other hosts
+// return ErrPacketModeUnsupported.  Endpoints are interpreted as with DialIPC.
+func DialIPCPacket(endpoint string) (PacketConn, error) {
+	// Dispatch the request through the bridge
+	resultChannel := global.bridge.ConnectPacket(endpoint)
+
+	// Wait for the result
+	result := <-resultChannel
+
+	// Watch for errors
+	if result.err != nil {
+		return nil, result.err
+	}
+
+	// All done
+	return &ipcPacketConn{
+		address: &ipcAddr{endpoint: endpoint},
+		connectionId: result.connectionId,
+	}, nil
+}
+
+// ipcPacketListener implements the PacketListener interface for GopherJS IPC
+// connections.
+type ipcPacketListener struct {
+	address *ipcAddr
+	listenerId int
+}
+
+func (l *ipcPacketListener) Accept() (PacketConn, error) {
+	// Dispatch the request through the bridge
+	resultChannel := global.bridge.ListenerAccept(l.listenerId)
+
+	// Wait for the result
+	result := <-resultChannel
+
+	// Watch for errors
+	if result.err != nil {
+		return nil, result.err
+	}
+
+	// All done
+	return &ipcPacketConn{
+		address: l.address,
+		connectionId: result.connectionId,
+	}, nil
+}
+
+func (l *ipcPacketListener) Close() error {
+	// If the listener is already closed, do nothing
+	if l.listenerId == -1 {
+		return nil
+	}
+
+	// Dispatch the request through the bridge
+	resultChannel := global.bridge.ListenerClose(l.listenerId)
+
+	// Wait for the result
+	result := <-resultChannel
+
+	// If we were successful, mark the listener as closed
+	if result.err == nil {
+		l.listenerId = -1
+	}
+
+	// All done
+	return result.err
+}
+
+func (l *ipcPacketListener) Addr() net.Addr {
+	return l.address
+}
+
+// ListenIPCPacket establishes a new message-oriented GopherJS IPC connection
+// listener.  Connections accepted by the listener preserve message boundaries.
+// As with DialIPCPacket, only Linux and Windows hosts support packet mode.
+func ListenIPCPacket(endpoint string) (PacketListener, error) {
+	// Dispatch the request through the bridge
+	resultChannel := global.bridge.ListenPacket(endpoint)
+
+	// Wait for the result
+	result := <-resultChannel
+
+	// Watch for errors
+	if result.err != nil {
+		return nil, result.err
+	}
+
+	// All done
+	return &ipcPacketListener{
+		address: &ipcAddr{endpoint: endpoint},
+		listenerId: result.listenerId,
+	}, nil
+}
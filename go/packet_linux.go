@@ -0,0 +1,7 @@
+// +build linux,!js
+
+package ipc
+
+// packetModeSupported indicates whether or not the platform supports
+// SOCK_SEQPACKET Unix domain sockets.
+const packetModeSupported = true
@@ -0,0 +1,117 @@
+// +build linux,!js
+
+package ipc
+
+// System imports
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+// packetPair creates a connected pair of packet connections that are closed
+// when the test completes.
+func packetPair(t *testing.T) (PacketConn, PacketConn) {
+	// Create a listener
+	listener, err := ListenIPCPacket(testEndpoint(t))
+	if err != nil {
+		t.Fatal("unable to listen:", err)
+	}
+	defer listener.Close()
+
+	// Accept a connection in the background
+	accepted := make(chan PacketConn, 1)
+	go func() {
+		connection, err := listener.Accept()
+		if err != nil {
+			close(accepted)
+			return
+		}
+		accepted <- connection
+	}()
+
+	// Dial
+	client, err := DialIPCPacket(listener.Addr().String())
+	if err != nil {
+		t.Fatal("unable to dial:", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	server, ok := <-accepted
+	if !ok {
+		t.Fatal("unable to accept connection")
+	}
+	t.Cleanup(func() { server.Close() })
+
+	// All done
+	return client, server
+}
+
+func TestPacketMessageBoundaries(t *testing.T) {
+	client, server := packetPair(t)
+
+	// Send several messages, including one of the maximum size, without
+	// reading them, so that they're queued together
+	maximum := bytes.Repeat([]byte{0xa5}, MaximumMessageSize)
+	messages := [][]byte{[]byte("a"), []byte("bc"), maximum, []byte("def")}
+	written := make(chan error, 1)
+	go func() {
+		for _, message := range messages {
+			if err := client.WriteMessage(message); err != nil {
+				written <- err
+				return
+			}
+		}
+		written <- nil
+	}()
+
+	// Each read should return exactly one message
+	for i, expected := range messages {
+		message, err := server.ReadMessage()
+		if err != nil {
+			t.Fatal("unable to read message:", err)
+		} else if !bytes.Equal(message, expected) {
+			t.Fatalf("message %d mismatch: %d bytes != %d bytes",
+				i, len(message), len(expected))
+		}
+	}
+	if err := <-written; err != nil {
+		t.Fatal("unable to write messages:", err)
+	}
+
+	// Once the peer closes, reads should return end-of-file
+	client.Close()
+	if _, err := server.ReadMessage(); err != io.EOF {
+		t.Error("unexpected error after peer close:", err)
+	}
+}
+
+func TestPacketMessageValidation(t *testing.T) {
+	client, server := packetPair(t)
+
+	// Invalid messages should be rejected before sending
+	oversized := make([]byte, MaximumMessageSize+1)
+	if err := client.WriteMessage(oversized); err != ErrMessageTooLarge {
+		t.Error("unexpected oversized write error:", err)
+	}
+	if err := client.WriteMessage(nil); err != ErrEmptyMessage {
+		t.Error("unexpected empty write error:", err)
+	}
+
+	// An oversized message sent by a peer that bypasses validation should be
+	// rejected and discarded without affecting subsequent messages
+	raw := client.(*posixPacketConn).connection
+	if _, err := raw.Write(oversized); err != nil {
+		t.Fatal("unable to write oversized message:", err)
+	}
+	if err := client.WriteMessage([]byte("next")); err != nil {
+		t.Fatal("unable to write message:", err)
+	}
+	if _, err := server.ReadMessage(); err != ErrMessageTooLarge {
+		t.Error("unexpected oversized read error:", err)
+	}
+	if message, err := server.ReadMessage(); err != nil {
+		t.Fatal("unable to read message:", err)
+	} else if string(message) != "next" {
+		t.Error("unexpected message after oversized message:", message)
+	}
+}
@@ -0,0 +1,8 @@
+// +build !linux,!windows,!js
+
+package ipc
+
+// packetModeSupported indicates whether or not the platform supports
+// SOCK_SEQPACKET Unix domain sockets.  Some BSDs do, but we only rely on them
+// where they're well supported.
+const packetModeSupported = false
@@ -0,0 +1,174 @@
+// +build !windows,!js
+
+package ipc
+
+// System imports
+import (
+	"io"
+	"net"
+	"sync"
+	"syscall"
+)
+
+// posixPacketConn implements PacketConn using a SOCK_SEQPACKET Unix domain
+// socket.
+type posixPacketConn struct {
+	// The underlying socket
+	connection *net.UnixConn
+
+	// Lock serializing reads (and protecting the read buffer)
+	readLock sync.Mutex
+
+	// The read buffer, which is large enough to detect oversized messages
+	buffer []byte
+}
+
+func newPosixPacketConn(connection *net.UnixConn) *posixPacketConn {
+	return &posixPacketConn{
+		connection: connection,
+		buffer:     make([]byte, MaximumMessageSize+1),
+	}
+}
+
+func (c *posixPacketConn) ReadMessage() ([]byte, error) {
+	// Serialize reads
+	c.readLock.Lock()
+	defer c.readLock.Unlock()
+
+	// Read the next message.  We have to use ReadMsgUnix rather than Read to
+	// find out whether the message was truncated.
+	count, _, flags, _, err := c.connection.ReadMsgUnix(c.buffer, nil)
+	if err != nil {
+		return nil, err
+	} else if flags&syscall.MSG_TRUNC != 0 || count > MaximumMessageSize {
+		return nil, ErrMessageTooLarge
+	} else if count == 0 {
+		// Since empty messages aren't allowed, this indicates the orderly
+		// shutdown of the connection
+		return nil, io.EOF
+	}
+
+	// Copy out the message
+	message := make([]byte, count)
+	copy(message, c.buffer[:count])
+
+	// All done
+	return message, nil
+}
+
+func (c *posixPacketConn) WriteMessage(message []byte) error {
+	// Validate the message
+	if err := validateMessage(message); err != nil {
+		return err
+	}
+
+	// Send it.  Each write on a SOCK_SEQPACKET socket is sent atomically as a
+	// single message.
+	_, err := c.connection.Write(message)
+	return err
+}
+
+func (c *posixPacketConn) Close() error {
+	return c.connection.Close()
+}
+
+func (c *posixPacketConn) LocalAddr() net.Addr {
+	return c.connection.LocalAddr()
+}
+
+func (c *posixPacketConn) RemoteAddr() net.Addr {
+	return c.connection.RemoteAddr()
+}
+
+// DialIPCPacket establishes a new message-oriented IPC connection.  On Linux,
+// this is done using SOCK_SEQPACKET Unix domain sockets, and the endpoint
+// argument has the same form as for DialIPC.  The endpoint must have been
+// created with ListenIPCPacket, since stream and packet endpoints are
+// incompatible.  On other POSIX systems, it fails with
+// ErrPacketModeUnsupported.
+func DialIPCPacket(endpoint string) (PacketConn, error) {
+	// Check for support
+	if !packetModeSupported {
+		return nil, ErrPacketModeUnsupported
+	}
+
+	// Resolve the endpoint and verify its directory
+	path, err := resolvePath(endpoint)
+	if err != nil {
+		return nil, err
+	} else if err = prepareLogicalEndpoint(endpoint, false); err != nil {
+		return nil, err
+	}
+
+	// Connect
+	connection, err := net.DialUnix(
+		"unixpacket",
+		nil,
+		&net.UnixAddr{Name: path, Net: "unixpacket"},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// All done
+	return newPosixPacketConn(connection), nil
+}
+
+// posixPacketListener implements PacketListener using a SOCK_SEQPACKET Unix
+// domain socket listener.
+type posixPacketListener struct {
+	listener *net.UnixListener
+}
+
+func (l *posixPacketListener) Accept() (PacketConn, error) {
+	// Accept the connection
+	connection, err := l.listener.AcceptUnix()
+	if err != nil {
+		return nil, err
+	}
+
+	// All done
+	return newPosixPacketConn(connection), nil
+}
+
+func (l *posixPacketListener) Close() error {
+	return l.listener.Close()
+}
+
+func (l *posixPacketListener) Addr() net.Addr {
+	return l.listener.Addr()
+}
+
+// ListenIPCPacket establishes a new message-oriented IPC connection listener.
+// On Linux, this is done using SOCK_SEQPACKET Unix domain sockets, and the
+// endpoint argument has the same form as for ListenIPC.  On other POSIX
+// systems, it fails with ErrPacketModeUnsupported.
+func ListenIPCPacket(endpoint string) (PacketListener, error) {
+	// Check for support
+	if !packetModeSupported {
+		return nil, ErrPacketModeUnsupported
+	}
+
+	// Resolve the endpoint
+	path, err := resolvePath(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	// Make sure the runtime directory exists for logical endpoints
+	if err := prepareLogicalEndpoint(endpoint, true); err != nil {
+		return nil, err
+	}
+
+	// Listen
+	listener, err := net.ListenUnix(
+		"unixpacket",
+		&net.UnixAddr{Name: path, Net: "unixpacket"},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// All done
+	return &posixPacketListener{listener}, nil
+}
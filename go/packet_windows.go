@@ -0,0 +1,272 @@
+// +build windows,!js
+
+package ipc
+
+// System imports
+import (
+	"io"
+	"net"
+	"sync"
+)
+
+// Extended system imports
+import "golang.org/x/sys/windows"
+
+// packetPipeBufferSize is the size of the input and output buffers for
+// message-mode named pipes.
+const packetPipeBufferSize = MaximumMessageSize
+
+// windowsPacketConn implements PacketConn using a message-mode named pipe.
+type windowsPacketConn struct {
+	// The pipe handle
+	handle windows.Handle
+
+	// The pipe address
+	address pipeAddr
+
+	// Locks serializing reads and writes
+	readLock  sync.Mutex
+	writeLock sync.Mutex
+
+	// The read buffer
+	buffer []byte
+
+	// Lock for the closure state
+	stateLock sync.Mutex
+
+	// Whether or not the connection is closed
+	closed bool
+
+	// Tracks in-flight operations, so that the handle isn't closed underneath
+	// them
+	operations sync.WaitGroup
+}
+
+func newWindowsPacketConn(
+	handle windows.Handle,
+	address pipeAddr,
+) *windowsPacketConn {
+	return &windowsPacketConn{
+		handle:  handle,
+		address: address,
+		buffer:  make([]byte, MaximumMessageSize),
+	}
+}
+
+// begin registers an in-flight operation, failing if the connection is closed.
+func (c *windowsPacketConn) begin() error {
+	c.stateLock.Lock()
+	defer c.stateLock.Unlock()
+	if c.closed {
+		return errPipeClosed
+	}
+	c.operations.Add(1)
+	return nil
+}
+
+// translate converts pipe errors to their Go equivalents.
+func (c *windowsPacketConn) translate(err error) error {
+	switch err {
+	case windows.ERROR_BROKEN_PIPE, windows.ERROR_PIPE_NOT_CONNECTED:
+		return io.EOF
+	case windows.ERROR_OPERATION_ABORTED:
+		c.stateLock.Lock()
+		defer c.stateLock.Unlock()
+		if c.closed {
+			return errPipeClosed
+		}
+	}
+	return err
+}
+
+func (c *windowsPacketConn) ReadMessage() ([]byte, error) {
+	// Serialize reads
+	c.readLock.Lock()
+	defer c.readLock.Unlock()
+
+	// Register the operation
+	if err := c.begin(); err != nil {
+		return nil, err
+	}
+	defer c.operations.Done()
+
+	// Read the next message
+	read := func(overlapped *windows.Overlapped) error {
+		return windows.ReadFile(c.handle, c.buffer, nil, overlapped)
+	}
+	count, err := overlappedIO(c.handle, read, nil)
+
+	// If the message didn't fit in the buffer, discard the remainder
+	if err == windows.ERROR_MORE_DATA {
+		for err == windows.ERROR_MORE_DATA {
+			_, err = overlappedIO(c.handle, read, nil)
+		}
+		if err != nil {
+			return nil, c.translate(err)
+		}
+		return nil, ErrMessageTooLarge
+	} else if err != nil {
+		return nil, c.translate(err)
+	} else if count == 0 {
+		// Since empty messages aren't allowed, this can only happen if the
+		// peer is misbehaving, but treat it as end-of-stream for consistency
+		// with SOCK_SEQPACKET sockets
+		return nil, io.EOF
+	}
+
+	// Copy out the message
+	message := make([]byte, count)
+	copy(message, c.buffer[:count])
+
+	// All done
+	return message, nil
+}
+
+func (c *windowsPacketConn) WriteMessage(message []byte) error {
+	// Validate the message
+	if err := validateMessage(message); err != nil {
+		return err
+	}
+
+	// Serialize writes
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	// Register the operation
+	if err := c.begin(); err != nil {
+		return err
+	}
+	defer c.operations.Done()
+
+	// Send the message
+	count, err := overlappedIO(c.handle, func(o *windows.Overlapped) error {
+		return windows.WriteFile(c.handle, message, nil, o)
+	}, nil)
+	if err != nil {
+		return c.translate(err)
+	} else if int(count) != len(message) {
+		return io.ErrShortWrite
+	}
+
+	// All done
+	return nil
+}
+
+func (c *windowsPacketConn) Close() error {
+	// Mark the connection as closed
+	c.stateLock.Lock()
+	if c.closed {
+		c.stateLock.Unlock()
+		return errPipeClosed
+	}
+	c.closed = true
+	c.stateLock.Unlock()
+
+	// Cancel in-flight operations and wait for them to finish
+	done := make(chan struct{})
+	go func() {
+		c.operations.Wait()
+		close(done)
+	}()
+	cancelUntil(c.handle, done)
+
+	// Close the handle
+	return windows.CloseHandle(c.handle)
+}
+
+func (c *windowsPacketConn) LocalAddr() net.Addr {
+	return c.address
+}
+
+func (c *windowsPacketConn) RemoteAddr() net.Addr {
+	return c.address
+}
+
+// DialIPCPacket establishes a new message-oriented IPC connection.  On Windows
+// systems, this is done using message-mode named pipes, and the endpoint
+// argument has the same form as for DialIPC.  The endpoint must have been
+// created with ListenIPCPacket, since byte-mode pipes don't preserve message
+// boundaries.
+func DialIPCPacket(endpoint string) (PacketConn, error) {
+	// Resolve the endpoint
+	name, err := ResolveEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	name16, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Open the pipe
+	handle, err := openPipe(name16)
+	if err != nil {
+		return nil, err
+	}
+
+	// Switch the client end to message read mode (it defaults to byte mode)
+	mode := uint32(windows.PIPE_READMODE_MESSAGE)
+	if err := windows.SetNamedPipeHandleState(handle, &mode, nil, nil); err != nil {
+		windows.CloseHandle(handle)
+		return nil, err
+	}
+
+	// All done
+	return newWindowsPacketConn(handle, pipeAddr(name)), nil
+}
+
+// windowsPacketListener implements PacketListener using message-mode named
+// pipes.
+type windowsPacketListener struct {
+	*pipeListener
+}
+
+// createPacketPipe creates a new message-mode named pipe instance.
+func createPacketPipe(name *uint16, first bool) (windows.Handle, error) {
+	flags := uint32(windows.PIPE_ACCESS_DUPLEX | windows.FILE_FLAG_OVERLAPPED)
+	if first {
+		flags |= windows.FILE_FLAG_FIRST_PIPE_INSTANCE
+	}
+	return windows.CreateNamedPipe(
+		name,
+		flags,
+		windows.PIPE_TYPE_MESSAGE|
+			windows.PIPE_READMODE_MESSAGE|
+			windows.PIPE_WAIT|
+			windows.PIPE_REJECT_REMOTE_CLIENTS,
+		windows.PIPE_UNLIMITED_INSTANCES,
+		packetPipeBufferSize,
+		packetPipeBufferSize,
+		0,
+		nil,
+	)
+}
+
+func (l *windowsPacketListener) Accept() (PacketConn, error) {
+	handle, err := l.accept()
+	if err != nil {
+		return nil, err
+	}
+	return newWindowsPacketConn(handle, l.address), nil
+}
+
+// ListenIPCPacket establishes a new message-oriented IPC connection listener.
+// On Windows systems, this is done using message-mode named pipes, and the
+// endpoint argument has the same form as for ListenIPC.  Remote clients are
+// rejected.
+func ListenIPCPacket(endpoint string) (PacketListener, error) {
+	// Resolve the endpoint
+	name, err := ResolveEndpoint(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the listener
+	listener, err := newPipeListener(name, createPacketPipe)
+	if err != nil {
+		return nil, err
+	}
+
+	// All done
+	return &windowsPacketListener{listener}, nil
+}
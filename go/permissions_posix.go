@@ -0,0 +1,124 @@
+// +build !windows,!js
+
+package ipc
+
+// System imports
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+// preparePrivateDirectory ensures that the specified directory exists and is
+// private to the current user, creating it with permissions 0700 if necessary.
+func preparePrivateDirectory(directory string) error {
+	// Create the directory if it doesn't exist.  Mkdir's permissions are
+	// subject to the umask, but the umask can only remove permissions, so 0700
+	// is safe.
+	if err := os.Mkdir(directory, 0700); err != nil && !os.IsExist(err) {
+		return err
+	}
+
+	// Verify it
+	return verifyPrivateDirectory(directory)
+}
+
+// verifyPrivateDirectory verifies that the specified directory is a real
+// directory, owned by the current user, and inaccessible to other users.
+func verifyPrivateDirectory(directory string) error {
+	// Verify that the directory is a directory (and not e.g. a symbolic link
+	// to one that somebody else controls)
+	info, err := os.Lstat(directory)
+	if err != nil {
+		return err
+	} else if !info.IsDir() {
+		return errors.New("socket parent is not a directory")
+	}
+
+	// Verify ownership and permissions
+	if stat, ok := info.Sys().(*syscall.Stat_t); !ok {
+		return errors.New("unable to determine socket directory ownership")
+	} else if int(stat.Uid) != os.Getuid() {
+		return errors.New("socket directory not owned by current user")
+	} else if info.Mode().Perm()&0077 != 0 {
+		return errors.New("socket directory accessible by other users")
+	}
+
+	// All done
+	return nil
+}
+
+// lookupGroup converts a group name or numeric group id to a group id.
+func lookupGroup(group string) (int, error) {
+	// Check for a numeric group id
+	if gid, err := strconv.Atoi(group); err == nil {
+		return gid, nil
+	}
+
+	// Otherwise look up the group by name
+	entry, err := user.LookupGroup(group)
+	if err != nil {
+		return -1, err
+	}
+	return strconv.Atoi(entry.Gid)
+}
+
+// listenSecured creates a Unix domain socket listener at the specified path
+// with the specified permissions and group ownership (a mode of 0 or a gid of
+// -1 leaves the corresponding attribute unchanged).  To ensure that the socket
+// is never visible at its final path with default permissions, the socket is
+// bound in a private temporary directory next to the final path, its
+// attributes are set there, and it is then linked into place.  Linking (rather
+// than renaming) ensures that we never replace an existing file.  The returned
+// listener will not remove the socket file when closed, since Go only tracks
+// the temporary path.
+func listenSecured(
+	path string,
+	mode os.FileMode,
+	gid int,
+) (*net.UnixListener, error) {
+	// Create a private temporary directory alongside the final path
+	temporary, err := ioutil.TempDir(filepath.Dir(path), ".gib-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(temporary)
+
+	// Bind the socket in the temporary directory
+	temporaryPath := filepath.Join(temporary, "socket")
+	listener, err := net.ListenUnix(
+		"unix",
+		&net.UnixAddr{Name: temporaryPath, Net: "unix"},
+	)
+	if err != nil {
+		return nil, err
+	}
+	listener.SetUnlinkOnClose(false)
+
+	// Set attributes
+	if mode != 0 {
+		err = os.Chmod(temporaryPath, mode)
+	}
+	if err == nil && gid != -1 {
+		err = os.Chown(temporaryPath, -1, gid)
+	}
+
+	// Link the socket into place
+	if err == nil {
+		err = os.Link(temporaryPath, path)
+	}
+
+	// Handle errors
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	// All done
+	return listener, nil
+}
@@ -0,0 +1,121 @@
+// +build !windows,!js
+
+package ipc
+
+// System imports
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+func TestListenSocketModeAndGroup(t *testing.T) {
+	endpoint := testEndpoint(t)
+
+	// Pick a group that we're allowed to assign: our primary group, by name if
+	// it has one
+	gid := os.Getgid()
+	group := strconv.Itoa(gid)
+	if entry, err := user.LookupGroupId(group); err == nil {
+		group = entry.Name
+	}
+
+	// Listen with a restricted mode and explicit group
+	listener, err := ListenIPCWithOptions(endpoint, &ListenOptions{
+		Mode:  0600,
+		Group: group,
+	})
+	if err != nil {
+		t.Fatal("unable to listen:", err)
+	}
+
+	// Verify the socket file's attributes
+	info, err := os.Lstat(endpoint)
+	if err != nil {
+		t.Fatal("unable to inspect socket:", err)
+	} else if info.Mode()&os.ModeSocket == 0 {
+		t.Fatal("endpoint is not a socket:", info.Mode())
+	} else if info.Mode().Perm() != 0600 {
+		t.Fatal("socket has incorrect permissions:", info.Mode().Perm())
+	} else if stat, ok := info.Sys().(*syscall.Stat_t); !ok {
+		t.Fatal("unable to determine socket ownership")
+	} else if int(stat.Gid) != gid {
+		t.Fatal("socket has incorrect group:", stat.Gid)
+	}
+
+	// Verify that no temporary binding directories were left behind
+	entries, err := filepath.Glob(filepath.Join(filepath.Dir(endpoint), ".gib-*"))
+	if err != nil {
+		t.Fatal("unable to list socket directory:", err)
+	} else if len(entries) > 0 {
+		t.Fatal("temporary binding directory left behind:", entries)
+	}
+
+	// Verify that the socket is usable and is removed on close
+	connection, err := DialIPC(endpoint)
+	if err != nil {
+		t.Fatal("unable to connect:", err)
+	}
+	connection.Close()
+	if err := listener.Close(); err != nil {
+		t.Fatal("unable to close listener:", err)
+	}
+	if _, err := os.Lstat(endpoint); !os.IsNotExist(err) {
+		t.Fatal("socket not removed on close:", err)
+	}
+}
+
+func TestListenUnknownGroup(t *testing.T) {
+	endpoint := testEndpoint(t)
+	listener, err := ListenIPCWithOptions(endpoint, &ListenOptions{
+		Group: "gib-nonexistent-group",
+	})
+	if err == nil {
+		listener.Close()
+		t.Fatal("listener created with unknown group")
+	}
+	if _, err := os.Lstat(endpoint); !os.IsNotExist(err) {
+		t.Fatal("socket created despite failure:", err)
+	}
+}
+
+func TestListenPrivateDirectory(t *testing.T) {
+	endpoint := testEndpoint(t)
+	directory := filepath.Dir(endpoint)
+
+	// A directory that others can access should be rejected
+	if err := os.Chmod(directory, 0755); err != nil {
+		t.Fatal("unable to set directory permissions:", err)
+	}
+	options := &ListenOptions{PrivateDirectory: true}
+	if listener, err := ListenIPCWithOptions(endpoint, options); err == nil {
+		listener.Close()
+		t.Fatal("listener created in shared directory")
+	}
+
+	// A private directory should be accepted
+	if err := os.Chmod(directory, 0700); err != nil {
+		t.Fatal("unable to set directory permissions:", err)
+	}
+	listener, err := ListenIPCWithOptions(endpoint, options)
+	if err != nil {
+		t.Fatal("unable to listen in private directory:", err)
+	}
+	listener.Close()
+
+	// A missing directory should be created privately
+	nested := filepath.Join(directory, "nested", "test.sock")
+	listener, err = ListenIPCWithOptions(nested, options)
+	if err != nil {
+		t.Fatal("unable to listen in new directory:", err)
+	}
+	listener.Close()
+	if info, err := os.Lstat(filepath.Dir(nested)); err != nil {
+		t.Fatal("directory not created:", err)
+	} else if info.Mode().Perm() != 0700 {
+		t.Fatal("directory has incorrect permissions:", info.Mode().Perm())
+	}
+}
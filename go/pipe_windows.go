@@ -0,0 +1,644 @@
+// +build windows,!js
+
+package ipc
+
+// System imports
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// Extended system imports
+import "golang.org/x/sys/windows"
+
+// Windows API functions not provided by the windows package
+var (
+	procWaitNamedPipeW = modkernel32.NewProc("WaitNamedPipeW")
+)
+
+const (
+	// pipeBufferSize is the size of the input and output buffers for
+	// byte-mode named pipes.
+	pipeBufferSize = 64 * 1024
+
+	// pipeWaitTimeout is the time (in milliseconds) to wait for a busy named
+	// pipe to become available.
+	pipeWaitTimeout = 5000
+
+	// pipeCancelInterval is the interval at which pending I/O is re-cancelled
+	// while closing a named pipe, in case an operation was issued just after
+	// the previous cancellation.
+	pipeCancelInterval = 10 * time.Millisecond
+)
+
+// errPipeClosed is returned by operations on closed named pipe connections and
+// listeners.
+var errPipeClosed = errors.New("use of closed pipe")
+
+// pipeTimeoutError is returned when a named pipe connection's deadline
+// expires.
+type pipeTimeoutError struct{}
+
+func (pipeTimeoutError) Error() string {
+	return "pipe i/o timeout"
+}
+
+func (pipeTimeoutError) Timeout() bool {
+	return true
+}
+
+func (pipeTimeoutError) Temporary() bool {
+	return true
+}
+
+// pipeAddr implements net.Addr for named pipes that we manage ourselves.
+type pipeAddr string
+
+func (pipeAddr) Network() string {
+	return "pipe"
+}
+
+func (a pipeAddr) String() string {
+	return string(a)
+}
+
+// overlappedIO performs an overlapped I/O operation on a handle and waits for
+// it to complete.  The operation must be issued using the provided overlapped
+// structure.  Errors returned by the operation other than ERROR_IO_PENDING and
+// ERROR_MORE_DATA are returned immediately.  If issued is non-nil, it is
+// invoked with the overlapped structure once the operation is pending (and
+// before waiting for it), so that it can be cancelled.
+func overlappedIO(
+	handle windows.Handle,
+	operation func(*windows.Overlapped) error,
+	issued func(*windows.Overlapped),
+) (uint32, error) {
+	// Create an event for the operation.  We can't wait on the handle itself
+	// since reads and writes may be pending simultaneously.
+	event, err := windows.CreateEvent(nil, 1, 0, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer windows.CloseHandle(event)
+
+	// Issue the operation
+	overlapped := &windows.Overlapped{HEvent: event}
+	err = operation(overlapped)
+	if err != nil &&
+		err != windows.ERROR_IO_PENDING &&
+		err != windows.ERROR_MORE_DATA {
+		return 0, err
+	}
+	if issued != nil {
+		issued(overlapped)
+	}
+
+	// Wait for completion
+	var count uint32
+	err = windows.GetOverlappedResult(handle, overlapped, &count, true)
+	return count, err
+}
+
+// openPipe opens the client end of a named pipe for overlapped I/O, waiting for
+// an instance to become available if necessary.
+func openPipe(name *uint16) (windows.Handle, error) {
+	for {
+		// Attempt to open the pipe
+		handle, err := windows.CreateFile(
+			name,
+			windows.GENERIC_READ|windows.GENERIC_WRITE,
+			0,
+			nil,
+			windows.OPEN_EXISTING,
+			windows.FILE_FLAG_OVERLAPPED,
+			0,
+		)
+		if err == nil {
+			return handle, nil
+		} else if err != windows.ERROR_PIPE_BUSY {
+			return windows.InvalidHandle, err
+		}
+
+		// Wait for an instance to become available
+		result, _, err := procWaitNamedPipeW.Call(
+			uintptr(unsafe.Pointer(name)),
+			pipeWaitTimeout,
+		)
+		if result == 0 {
+			return windows.InvalidHandle, err
+		}
+	}
+}
+
+// cancelUntil cancels pending I/O on a handle until the done channel is
+// closed.
+func cancelUntil(handle windows.Handle, done <-chan struct{}) {
+	for {
+		windows.CancelIoEx(handle, nil)
+		select {
+		case <-done:
+			return
+		case <-time.After(pipeCancelInterval):
+		}
+	}
+}
+
+// pipeListener manages the instances of a named pipe on behalf of a listener,
+// always keeping an instance available for clients to connect to.
+type pipeListener struct {
+	// The pipe name
+	name *uint16
+
+	// The pipe address
+	address pipeAddr
+
+	// The function used to create pipe instances
+	create func(name *uint16, first bool) (windows.Handle, error)
+
+	// Lock serializing accepts
+	acceptLock sync.Mutex
+
+	// Lock for the listener state
+	stateLock sync.Mutex
+
+	// Whether or not the listener is closed
+	closed bool
+
+	// The pipe instance awaiting the next connection, or
+	// windows.InvalidHandle if it needs to be created
+	next windows.Handle
+}
+
+// newPipeListener creates a new pipe listener, creating the first pipe
+// instance (which ensures that the name isn't already in use).
+func newPipeListener(
+	name string,
+	create func(name *uint16, first bool) (windows.Handle, error),
+) (*pipeListener, error) {
+	// Convert the name
+	name16, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the first pipe instance
+	handle, err := create(name16, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// All done
+	return &pipeListener{
+		name:    name16,
+		address: pipeAddr(name),
+		create:  create,
+		next:    handle,
+	}, nil
+}
+
+// accept waits for a client to connect and returns the connected pipe
+// instance.
+func (l *pipeListener) accept() (windows.Handle, error) {
+	// Serialize accepts
+	l.acceptLock.Lock()
+	defer l.acceptLock.Unlock()
+
+	// Grab the pending instance, creating it if necessary
+	l.stateLock.Lock()
+	if l.closed {
+		l.stateLock.Unlock()
+		return windows.InvalidHandle, errPipeClosed
+	}
+	if l.next == windows.InvalidHandle {
+		next, err := l.create(l.name, false)
+		if err != nil {
+			l.stateLock.Unlock()
+			return windows.InvalidHandle, err
+		}
+		l.next = next
+	}
+	handle := l.next
+	l.stateLock.Unlock()
+
+	// Wait for a client to connect
+	_, err := overlappedIO(handle, func(o *windows.Overlapped) error {
+		return windows.ConnectNamedPipe(handle, o)
+	}, nil)
+	if err != nil && err != windows.ERROR_PIPE_CONNECTED {
+		l.stateLock.Lock()
+		defer l.stateLock.Unlock()
+		if l.closed {
+			return windows.InvalidHandle, errPipeClosed
+		}
+		return windows.InvalidHandle, err
+	}
+
+	// Hand off the instance and create the next one so that clients can
+	// connect before the next call to Accept.  If creation fails, we'll try
+	// again in the next call.
+	l.stateLock.Lock()
+	l.next = windows.InvalidHandle
+	if next, err := l.create(l.name, false); err == nil {
+		l.next = next
+	}
+	l.stateLock.Unlock()
+
+	// All done
+	return handle, nil
+}
+
+func (l *pipeListener) Close() error {
+	// Mark the listener as closed
+	l.stateLock.Lock()
+	if l.closed {
+		l.stateLock.Unlock()
+		return errPipeClosed
+	}
+	l.closed = true
+	handle := l.next
+	l.stateLock.Unlock()
+
+	// Cancel any pending accept and wait for it to finish.  Accept won't
+	// replace the pending instance once the listener is closed.
+	if handle != windows.InvalidHandle {
+		done := make(chan struct{})
+		go func() {
+			l.acceptLock.Lock()
+			close(done)
+		}()
+		cancelUntil(handle, done)
+		defer l.acceptLock.Unlock()
+	}
+
+	// Close the pending instance
+	l.stateLock.Lock()
+	defer l.stateLock.Unlock()
+	if l.next != windows.InvalidHandle {
+		return windows.CloseHandle(l.next)
+	}
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr {
+	return l.address
+}
+
+// pipeDeadline manages the deadline for one direction of a byte-mode pipe
+// connection.  It cancels the pending operation when the deadline expires,
+// including when the deadline is changed while the operation is blocked.
+type pipeDeadline struct {
+	// The pipe handle
+	handle windows.Handle
+
+	// Lock for the fields below
+	lock sync.Mutex
+
+	// The deadline
+	deadline time.Time
+
+	// The pending operation, if any
+	pending *windows.Overlapped
+
+	// The timer that cancels the pending operation, if any
+	timer *time.Timer
+
+	// Whether or not the pending operation was cancelled by the timer
+	expired bool
+}
+
+// set updates the deadline, re-arming the timer for any pending operation.
+func (d *pipeDeadline) set(deadline time.Time) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.deadline = deadline
+	if d.pending != nil {
+		d.arm()
+	}
+}
+
+// arm (re-)starts the timer for the pending operation.  It must be called with
+// the lock held.
+func (d *pipeDeadline) arm() {
+	// Stop any existing timer
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+
+	// If there's no deadline, we're done
+	if d.deadline.IsZero() {
+		return
+	}
+
+	// Start the timer.  It fires immediately if the deadline has passed.
+	pending := d.pending
+	d.timer = time.AfterFunc(time.Until(d.deadline), func() {
+		d.lock.Lock()
+		defer d.lock.Unlock()
+		if d.pending == pending {
+			d.expired = true
+			windows.CancelIoEx(d.handle, pending)
+		}
+	})
+}
+
+// check returns a timeout error if the deadline has already passed.
+func (d *pipeDeadline) check() error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if !d.deadline.IsZero() && !time.Now().Before(d.deadline) {
+		return pipeTimeoutError{}
+	}
+	return nil
+}
+
+// watch registers a pending operation.
+func (d *pipeDeadline) watch(overlapped *windows.Overlapped) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.pending = overlapped
+	d.expired = false
+	d.arm()
+}
+
+// finish unregisters the pending operation and returns whether or not it was
+// cancelled by the timer.
+func (d *pipeDeadline) finish() bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	d.pending = nil
+	return d.expired
+}
+
+// windowsPipeConn implements net.Conn using a byte-mode named pipe handle.
+type windowsPipeConn struct {
+	// The pipe handle
+	handle windows.Handle
+
+	// The pipe address
+	address pipeAddr
+
+	// Locks serializing reads and writes
+	readLock  sync.Mutex
+	writeLock sync.Mutex
+
+	// The read and write deadlines
+	readDeadline  pipeDeadline
+	writeDeadline pipeDeadline
+
+	// Lock for the closure state
+	stateLock sync.Mutex
+
+	// Whether or not the connection is closed
+	closed bool
+
+	// Tracks in-flight operations, so that the handle isn't closed underneath
+	// them
+	operations sync.WaitGroup
+}
+
+func newWindowsPipeConn(
+	handle windows.Handle,
+	address pipeAddr,
+) *windowsPipeConn {
+	return &windowsPipeConn{
+		handle:        handle,
+		address:       address,
+		readDeadline:  pipeDeadline{handle: handle},
+		writeDeadline: pipeDeadline{handle: handle},
+	}
+}
+
+// begin registers an in-flight operation, failing if the connection is closed.
+func (c *windowsPipeConn) begin() error {
+	c.stateLock.Lock()
+	defer c.stateLock.Unlock()
+	if c.closed {
+		return errPipeClosed
+	}
+	c.operations.Add(1)
+	return nil
+}
+
+// perform performs an overlapped operation, subject to the specified
+// deadline, and translates pipe errors to their Go equivalents.
+func (c *windowsPipeConn) perform(
+	deadline *pipeDeadline,
+	operation func(*windows.Overlapped) error,
+) (uint32, error) {
+	// Register the operation
+	if err := c.begin(); err != nil {
+		return 0, err
+	}
+	defer c.operations.Done()
+
+	// Check the deadline
+	if err := deadline.check(); err != nil {
+		return 0, err
+	}
+
+	// Perform the operation
+	count, err := overlappedIO(c.handle, operation, deadline.watch)
+	expired := deadline.finish()
+
+	// Translate errors
+	switch err {
+	case windows.ERROR_BROKEN_PIPE, windows.ERROR_PIPE_NOT_CONNECTED:
+		err = io.EOF
+	case windows.ERROR_OPERATION_ABORTED:
+		c.stateLock.Lock()
+		if c.closed {
+			err = errPipeClosed
+		} else if expired {
+			err = pipeTimeoutError{}
+		}
+		c.stateLock.Unlock()
+	}
+
+	// All done
+	return count, err
+}
+
+func (c *windowsPipeConn) Read(buffer []byte) (int, error) {
+	// Serialize reads
+	c.readLock.Lock()
+	defer c.readLock.Unlock()
+
+	// Handle empty reads
+	if len(buffer) == 0 {
+		return 0, nil
+	}
+
+	// Perform the read
+	count, err := c.perform(&c.readDeadline, func(o *windows.Overlapped) error {
+		return windows.ReadFile(c.handle, buffer, nil, o)
+	})
+	if err == nil && count == 0 {
+		err = io.EOF
+	}
+	return int(count), err
+}
+
+func (c *windowsPipeConn) Write(buffer []byte) (int, error) {
+	// Serialize writes
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	// Write until all data is sent
+	written := 0
+	for written < len(buffer) {
+		remaining := buffer[written:]
+		count, err := c.perform(
+			&c.writeDeadline,
+			func(o *windows.Overlapped) error {
+				return windows.WriteFile(c.handle, remaining, nil, o)
+			},
+		)
+		written += int(count)
+		if err != nil {
+			return written, err
+		}
+	}
+
+	// All done
+	return written, nil
+}
+
+func (c *windowsPipeConn) Close() error {
+	// Mark the connection as closed
+	c.stateLock.Lock()
+	if c.closed {
+		c.stateLock.Unlock()
+		return errPipeClosed
+	}
+	c.closed = true
+	c.stateLock.Unlock()
+
+	// Cancel in-flight operations and wait for them to finish
+	done := make(chan struct{})
+	go func() {
+		c.operations.Wait()
+		close(done)
+	}()
+	cancelUntil(c.handle, done)
+
+	// Close the handle
+	return windows.CloseHandle(c.handle)
+}
+
+func (c *windowsPipeConn) LocalAddr() net.Addr {
+	return c.address
+}
+
+func (c *windowsPipeConn) RemoteAddr() net.Addr {
+	return c.address
+}
+
+func (c *windowsPipeConn) SetDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	c.writeDeadline.set(t)
+	return nil
+}
+
+func (c *windowsPipeConn) SetReadDeadline(t time.Time) error {
+	c.readDeadline.set(t)
+	return nil
+}
+
+func (c *windowsPipeConn) SetWriteDeadline(t time.Time) error {
+	c.writeDeadline.set(t)
+	return nil
+}
+
+// windowsPipeListener implements net.Listener using byte-mode named pipes.
+type windowsPipeListener struct {
+	*pipeListener
+
+	// The security attributes used to create pipe instances, if any.  We hold
+	// on to them since the listener creates instances for its entire lifetime.
+	attributes *windows.SecurityAttributes
+}
+
+func (l *windowsPipeListener) Accept() (net.Conn, error) {
+	handle, err := l.accept()
+	if err != nil {
+		return nil, err
+	}
+	return newWindowsPipeConn(handle, l.address), nil
+}
+
+// dialPipe connects to a byte-mode named pipe.
+func dialPipe(name string) (*windowsPipeConn, error) {
+	// Convert the name
+	name16, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Open the pipe
+	handle, err := openPipe(name16)
+	if err != nil {
+		return nil, err
+	}
+
+	// All done
+	return newWindowsPipeConn(handle, pipeAddr(name)), nil
+}
+
+// listenPipe creates a byte-mode named pipe listener.  If an SDDL security
+// descriptor is specified, pipe instances are created with it, otherwise they
+// receive the default security descriptor.  Remote clients are rejected.
+func listenPipe(name, sddl string) (*windowsPipeListener, error) {
+	// Parse the security descriptor, if any
+	var attributes *windows.SecurityAttributes
+	if sddl != "" {
+		descriptor, err := windows.SecurityDescriptorFromString(sddl)
+		if err != nil {
+			return nil, err
+		}
+		attributes = &windows.SecurityAttributes{
+			SecurityDescriptor: descriptor,
+		}
+		attributes.Length = uint32(unsafe.Sizeof(*attributes))
+	}
+
+	// Create the listener
+	listener, err := newPipeListener(
+		name,
+		func(name *uint16, first bool) (windows.Handle, error) {
+			flags := uint32(
+				windows.PIPE_ACCESS_DUPLEX | windows.FILE_FLAG_OVERLAPPED,
+			)
+			if first {
+				flags |= windows.FILE_FLAG_FIRST_PIPE_INSTANCE
+			}
+			return windows.CreateNamedPipe(
+				name,
+				flags,
+				windows.PIPE_TYPE_BYTE|
+					windows.PIPE_READMODE_BYTE|
+					windows.PIPE_WAIT|
+					windows.PIPE_REJECT_REMOTE_CLIENTS,
+				windows.PIPE_UNLIMITED_INSTANCES,
+				pipeBufferSize,
+				pipeBufferSize,
+				0,
+				attributes,
+			)
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// All done
+	return &windowsPipeListener{listener, attributes}, nil
+}
@@ -0,0 +1,109 @@
+package ipc
+
+// System imports
+import (
+	"errors"
+)
+
+// ErrEndpointNotPermitted is returned when a connect or listen request targets
+// an endpoint outside of the configured endpoint policy.  Hosts enforcing their
+// own allowlists report this same message, which the GopherJS bridges map back
+// to this value, so callers can compare against it directly.
+var ErrEndpointNotPermitted = errors.New("endpoint not permitted")
+
+// EndpointPolicy is an allowlist of endpoint patterns.  Patterns are matched
+// against the endpoint exactly as it is passed to Connect or Listen (i.e.
+// before logical endpoint resolution), with '*' matching any sequence of
+// characters (including path separators) and '?' matching any single byte.
+// There are no escape sequences, so Windows pipe names can be written
+// naturally, e.g. `\\.\pipe\myapp.*`.  Since '*' can match separators,
+// endpoints containing ".." path segments (delimited by '/' or '\') are never
+// permitted, which prevents patterns like `/tmp/myapp/*` from being escaped.
+// Hosts implement the same matching rules.  A nil policy permits all
+// endpoints.
+type EndpointPolicy struct {
+	// The approved patterns
+	patterns []string
+}
+
+// NewEndpointPolicy creates a new endpoint policy that permits endpoints
+// matching any of the specified patterns.  A policy with no patterns permits
+// nothing.
+func NewEndpointPolicy(patterns ...string) *EndpointPolicy {
+	return &EndpointPolicy{
+		patterns: append([]string(nil), patterns...),
+	}
+}
+
+// Permits returns whether or not the policy permits the specified endpoint.
+func (p *EndpointPolicy) Permits(endpoint string) bool {
+	// A nil policy permits everything
+	if p == nil {
+		return true
+	}
+
+	// Reject path traversal outright, since stars would otherwise match it
+	if containsTraversal(endpoint) {
+		return false
+	}
+
+	// Check each pattern
+	for _, pattern := range p.patterns {
+		if matchEndpointPattern(pattern, endpoint) {
+			return true
+		}
+	}
+
+	// No match
+	return false
+}
+
+// containsTraversal returns whether or not an endpoint contains a ".." path
+// segment, treating both '/' and '\' as separators.
+func containsTraversal(endpoint string) bool {
+	start := 0
+	for i := 0; i <= len(endpoint); i++ {
+		if i == len(endpoint) || endpoint[i] == '/' || endpoint[i] == '\\' {
+			if endpoint[start:i] == ".." {
+				return true
+			}
+			start = i + 1
+		}
+	}
+	return false
+}
+
+// matchEndpointPattern matches an endpoint against a single pattern.  It uses
+// the standard iterative wildcard algorithm, backtracking only to the most
+// recent '*', so it runs in time proportional to the product of the lengths in
+// the worst case.
+func matchEndpointPattern(pattern, endpoint string) bool {
+	// Track our positions in each string, as well as the most recent star (and
+	// the endpoint position it was tried against) for backtracking
+	p, e := 0, 0
+	star, starEndpoint := -1, 0
+
+	// Walk the endpoint
+	for e < len(endpoint) {
+		if p < len(pattern) && (pattern[p] == '?' || pattern[p] == endpoint[e]) {
+			p++
+			e++
+		} else if p < len(pattern) && pattern[p] == '*' {
+			star = p
+			starEndpoint = e
+			p++
+		} else if star != -1 {
+			p = star + 1
+			starEndpoint++
+			e = starEndpoint
+		} else {
+			return false
+		}
+	}
+
+	// Any remaining pattern characters must all be stars
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
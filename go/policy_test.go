@@ -0,0 +1,50 @@
+package ipc
+
+// System imports
+import (
+	"testing"
+)
+
+func TestEndpointPolicyPermits(t *testing.T) {
+	policy := NewEndpointPolicy(
+		"/tmp/myapp/*",
+		`\\.\pipe\myapp.*`,
+		"ipc:worker-?",
+	)
+	cases := []struct {
+		endpoint  string
+		permitted bool
+	}{
+		{"/tmp/myapp/backend.sock", true},
+		{"/tmp/myapp/nested/backend.sock", true},
+		{"/tmp/myapp/..sock", true},
+		{"/tmp/myapp/a..b/backend.sock", true},
+		{"/tmp/other/backend.sock", false},
+		{"/tmp/myapp/../../home/victim/agent.sock", false},
+		{"/tmp/myapp/nested/../../other.sock", false},
+		{"/tmp/myapp/..", false},
+		{"/tmp/myapp/..\\..\\victim.sock", false},
+		{`\\.\pipe\myapp.backend`, true},
+		{`\\.\pipe\myapp.x\..\victim`, false},
+		{`\\.\pipe\myapp.x/../victim`, false},
+		{"ipc:worker-1", true},
+		{"ipc:worker-12", false},
+	}
+	for _, c := range cases {
+		if permitted := policy.Permits(c.endpoint); permitted != c.permitted {
+			t.Errorf("Permits(%q) = %t, expected %t",
+				c.endpoint, permitted, c.permitted,
+			)
+		}
+	}
+}
+
+func TestEndpointPolicyNil(t *testing.T) {
+	var policy *EndpointPolicy
+	if !policy.Permits("/tmp/anything/../at/all") {
+		t.Error("nil policy rejected endpoint")
+	}
+	if NewEndpointPolicy().Permits("/tmp/myapp/backend.sock") {
+		t.Error("empty policy permitted endpoint")
+	}
+}
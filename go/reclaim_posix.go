@@ -0,0 +1,128 @@
+// +build !windows,!js
+
+package ipc
+
+// System imports
+import (
+	"errors"
+	"net"
+	"os"
+	"syscall"
+)
+
+// ErrEndpointInUse is returned by ListenIPCWithOptions when stale socket
+// reclamation is enabled and another process is already listening on (or is in
+// the process of claiming) the requested endpoint.
+var ErrEndpointInUse = errors.New("endpoint in use by another listener")
+
+// endpointLock represents an exclusive lock on a socket endpoint, held via
+// flock on a lock file adjacent to the socket.
+type endpointLock struct {
+	// The lock file
+	file *os.File
+
+	// The lock file path
+	path string
+}
+
+// lockEndpoint acquires the lock for the specified socket path without
+// blocking.  It returns ErrEndpointInUse if another process holds the lock.
+func lockEndpoint(socketPath string) (*endpointLock, error) {
+	// Compute the lock file path
+	path := socketPath + ".lock"
+
+	// Loop until we hold a lock on the file currently at the lock path.  Since
+	// lock files are removed when their listener closes, we may lock a file
+	// that has been unlinked by its previous owner, in which case we need to
+	// try again with the new file.
+	for {
+		// Open or create the lock file
+		file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+		if err != nil {
+			return nil, err
+		}
+
+		// Attempt to lock it
+		err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == syscall.EWOULDBLOCK {
+			file.Close()
+			return nil, ErrEndpointInUse
+		} else if err != nil {
+			file.Close()
+			return nil, err
+		}
+
+		// Verify that the file we locked is still the one at the lock path
+		locked, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		current, err := os.Stat(path)
+		if err == nil && os.SameFile(locked, current) {
+			return &endpointLock{file: file, path: path}, nil
+		} else if err != nil && !os.IsNotExist(err) {
+			file.Close()
+			return nil, err
+		}
+
+		// If not, try again
+		file.Close()
+	}
+}
+
+// release removes the lock file and releases the lock.  The file is removed
+// while the lock is still held so that no other process can lock it after we
+// release it but before we remove it.
+func (l *endpointLock) release() error {
+	removeErr := os.Remove(l.path)
+	closeErr := l.file.Close()
+	if removeErr != nil {
+		return removeErr
+	}
+	return closeErr
+}
+
+// isConnectionRefused determines whether or not a dial error indicates that
+// nobody is listening on a socket.
+func isConnectionRefused(err error) bool {
+	if opErr, ok := err.(*net.OpError); ok {
+		if sysErr, ok := opErr.Err.(*os.SyscallError); ok {
+			return sysErr.Err == syscall.ECONNREFUSED
+		}
+	}
+	return false
+}
+
+// reclaimStale removes the socket file at the specified path if it exists and
+// no process is listening on it.  It must only be called with the endpoint's
+// lock held.  It returns ErrEndpointInUse if a process is listening.
+func reclaimStale(path string) error {
+	// Check whether or not there's anything at the path.  We only ever remove
+	// sockets - anything else is left for the bind to fail on.
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	} else if info.Mode()&os.ModeSocket == 0 {
+		return nil
+	}
+
+	// Probe the socket
+	connection, err := net.Dial("unix", path)
+	if err == nil {
+		connection.Close()
+		return ErrEndpointInUse
+	} else if !isConnectionRefused(err) {
+		return err
+	}
+
+	// Nobody is listening, so remove the stale socket
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	// All done
+	return nil
+}
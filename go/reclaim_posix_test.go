@@ -0,0 +1,134 @@
+// +build !windows,!js
+
+package ipc
+
+// System imports
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+)
+
+// createStaleSocket creates a socket file at the specified path with nobody
+// listening on it, as left behind by a crashed listener.
+func createStaleSocket(t *testing.T, path string) {
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		t.Fatal("unable to create socket:", err)
+	}
+	listener.SetUnlinkOnClose(false)
+	listener.Close()
+}
+
+func TestReclaimStaleSocket(t *testing.T) {
+	endpoint := testEndpoint(t)
+	createStaleSocket(t, endpoint)
+
+	// Without reclamation, the stale socket blocks the endpoint
+	if listener, err := ListenIPC(endpoint); err == nil {
+		listener.Close()
+		t.Fatal("listener created over stale socket without reclamation")
+	}
+
+	// With reclamation, it is replaced
+	options := &ListenOptions{ReclaimStale: true}
+	listener, err := ListenIPCWithOptions(endpoint, options)
+	if err != nil {
+		t.Fatal("unable to reclaim stale socket:", err)
+	}
+	connection, err := DialIPC(endpoint)
+	if err != nil {
+		t.Fatal("unable to connect to reclaimed endpoint:", err)
+	}
+	connection.Close()
+
+	// The lock file should be held for the lifetime of the listener and then
+	// removed along with the socket
+	if _, err := os.Lstat(endpoint + ".lock"); err != nil {
+		t.Fatal("lock file missing:", err)
+	}
+	if err := listener.Close(); err != nil {
+		t.Fatal("unable to close listener:", err)
+	}
+	if _, err := os.Lstat(endpoint + ".lock"); !os.IsNotExist(err) {
+		t.Fatal("lock file not removed:", err)
+	}
+	if _, err := os.Lstat(endpoint); !os.IsNotExist(err) {
+		t.Fatal("socket not removed:", err)
+	}
+}
+
+func TestReclaimLiveSocket(t *testing.T) {
+	endpoint := testEndpoint(t)
+
+	// Start a listener without reclamation (and thus without a lock)
+	live, err := ListenIPC(endpoint)
+	if err != nil {
+		t.Fatal("unable to listen:", err)
+	}
+	defer live.Close()
+	accepted := acceptOne(live)
+
+	// Reclamation must not take over a live endpoint
+	options := &ListenOptions{ReclaimStale: true}
+	if listener, err := ListenIPCWithOptions(endpoint, options); err == nil {
+		listener.Close()
+		t.Fatal("live endpoint reclaimed")
+	} else if err != ErrEndpointInUse {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// The live listener should still be reachable.  The probe made by the
+	// reclamation attempt may have been accepted first, so drain it.
+	receiveConn(t, accepted).Close()
+	accepted = acceptOne(live)
+	connection, err := DialIPC(endpoint)
+	if err != nil {
+		t.Fatal("live endpoint unreachable:", err)
+	}
+	defer connection.Close()
+	receiveConn(t, accepted).Close()
+	if _, err := os.Lstat(endpoint + ".lock"); !os.IsNotExist(err) {
+		t.Fatal("lock file left behind by failed reclamation:", err)
+	}
+}
+
+func TestReclaimLockedEndpoint(t *testing.T) {
+	endpoint := testEndpoint(t)
+	options := &ListenOptions{ReclaimStale: true}
+
+	// Start a listener with reclamation, which holds the endpoint lock
+	first, err := ListenIPCWithOptions(endpoint, options)
+	if err != nil {
+		t.Fatal("unable to listen:", err)
+	}
+	defer first.Close()
+
+	// A second listener should be refused by the lock
+	if second, err := ListenIPCWithOptions(endpoint, options); err == nil {
+		second.Close()
+		t.Fatal("second listener created")
+	} else if err != ErrEndpointInUse {
+		t.Fatal("unexpected error:", err)
+	}
+}
+
+func TestReclaimIgnoresNonSockets(t *testing.T) {
+	endpoint := testEndpoint(t)
+
+	// Create a regular file at the endpoint path
+	if err := ioutil.WriteFile(endpoint, []byte("data"), 0600); err != nil {
+		t.Fatal("unable to create file:", err)
+	}
+
+	// Reclamation should fail rather than removing it
+	options := &ListenOptions{ReclaimStale: true}
+	if listener, err := ListenIPCWithOptions(endpoint, options); err == nil {
+		listener.Close()
+		t.Fatal("listener created over regular file")
+	}
+	if data, err := ioutil.ReadFile(endpoint); err != nil || string(data) != "data" {
+		t.Fatal("regular file was modified:", err)
+	}
+}
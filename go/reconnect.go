@@ -0,0 +1,454 @@
+package ipc
+
+// System imports
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrReconnected is returned by ReconnectingConn operations that failed
+// because the underlying connection was lost but where a replacement
+// connection was successfully established before the operation returned.  Any
+// data being transferred by the failed operation may or may not have been
+// delivered, but the caller may retry the operation (or re-synchronize its
+// protocol state) on the same ReconnectingConn.
+var ErrReconnected = errors.New("connection lost and re-established")
+
+// ErrReconnectingConnClosed is returned by ReconnectingConn operations after
+// the connection has been closed, either by the caller or because the dialer
+// gave up trying to reconnect.
+var ErrReconnectingConnClosed = errors.New("reconnecting connection closed")
+
+const (
+	// defaultInitialBackoff is the default delay before the first reconnection
+	// attempt.
+	defaultInitialBackoff = 100 * time.Millisecond
+
+	// defaultMaximumBackoff is the default cap on the delay between
+	// reconnection attempts.
+	defaultMaximumBackoff = 10 * time.Second
+)
+
+// ConnectionState represents the state of a ReconnectingConn.
+type ConnectionState int
+
+const (
+	// ConnectionStateConnecting indicates that a connection (or reconnection)
+	// is being attempted.
+	ConnectionStateConnecting ConnectionState = iota
+	// ConnectionStateConnected indicates that a connection is established and
+	// its handshake (if any) has completed.
+	ConnectionStateConnected
+	// ConnectionStateDisconnected indicates that the connection was lost and
+	// that the dialer is waiting before its next attempt.
+	ConnectionStateDisconnected
+	// ConnectionStateClosed indicates that the connection has been closed and
+	// that no further reconnection will be attempted.
+	ConnectionStateClosed
+)
+
+func (s ConnectionState) String() string {
+	switch s {
+	case ConnectionStateConnecting:
+		return "connecting"
+	case ConnectionStateConnected:
+		return "connected"
+	case ConnectionStateDisconnected:
+		return "disconnected"
+	case ConnectionStateClosed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+// ReconnectingDialer creates connections that transparently re-dial their
+// endpoint (using DialIPC) when the underlying connection fails, e.g. because
+// the backend process restarted.  It works identically in GopherJS and native
+// builds.  The zero value of each field selects a sensible default.
+type ReconnectingDialer struct {
+	// Endpoint is the endpoint to dial, in the format expected by DialIPC.
+	Endpoint string
+
+	// InitialBackoff is the delay before the first reconnection attempt.  Each
+	// subsequent failed attempt doubles the delay, up to MaximumBackoff.
+	InitialBackoff time.Duration
+
+	// MaximumBackoff is the cap on the delay between reconnection attempts.
+	MaximumBackoff time.Duration
+
+	// MaximumAttempts is the number of consecutive failed reconnection
+	// attempts after which the connection is closed.  A value of 0 retries
+	// forever.
+	MaximumAttempts int
+
+	// Handshake, if non-nil, is invoked on every newly dialed connection before
+	// it is made available to callers.  The resumed argument is false for the
+	// initial connection and true for reconnections, allowing the handshake to
+	// replay any session-resume exchange that the backend requires.  If the
+	// handshake fails, the connection is discarded and treated as a failed
+	// attempt.
+	Handshake func(conn net.Conn, resumed bool) error
+
+	// StateChanged, if non-nil, is invoked each time the connection state
+	// changes.  It is invoked synchronously and must not call back into the
+	// ReconnectingConn.
+	StateChanged func(state ConnectionState)
+}
+
+// Dial establishes the initial connection, retrying with backoff until it
+// succeeds or MaximumAttempts is exhausted, and returns a ReconnectingConn
+// that will continue to re-dial on failure.
+func (d *ReconnectingDialer) Dial() (*ReconnectingConn, error) {
+	// Create the connection wrapper
+	c := &ReconnectingConn{
+		dialer:  d,
+		address: &reconnectingAddr{endpoint: d.Endpoint},
+		state:   ConnectionStateConnecting,
+		closed:  make(chan struct{}),
+	}
+
+	// Perform the initial connection
+	d.notify(ConnectionStateConnecting)
+	conn, err := d.connect(false, nil)
+	if err != nil {
+		d.notify(ConnectionStateClosed)
+		return nil, err
+	}
+	c.conn = conn
+	c.state = ConnectionStateConnected
+	d.notify(ConnectionStateConnected)
+
+	// All done
+	return c, nil
+}
+
+// notify invokes the state change callback, if any.
+func (d *ReconnectingDialer) notify(state ConnectionState) {
+	if d.StateChanged != nil {
+		d.StateChanged(state)
+	}
+}
+
+// connect dials and performs the handshake with exponential backoff between
+// failed attempts.  It returns the last error encountered if MaximumAttempts
+// is exhausted, or ErrReconnectingConnClosed if the cancellation channel (which
+// may be nil) is closed.
+func (d *ReconnectingDialer) connect(
+	resumed bool,
+	cancel <-chan struct{},
+) (net.Conn, error) {
+	// Compute backoff parameters
+	backoff := d.InitialBackoff
+	if backoff <= 0 {
+		backoff = defaultInitialBackoff
+	}
+	maximumBackoff := d.MaximumBackoff
+	if maximumBackoff <= 0 {
+		maximumBackoff = defaultMaximumBackoff
+	}
+
+	// Loop until we connect or run out of attempts.  The initial connection
+	// is attempted immediately, reconnections wait first to give the backend
+	// a chance to come back up.
+	for attempt := 1; ; attempt++ {
+		// Wait if necessary
+		if resumed || attempt > 1 {
+			timer := time.NewTimer(backoff)
+			select {
+			case <-timer.C:
+			case <-cancel:
+				timer.Stop()
+				return nil, ErrReconnectingConnClosed
+			}
+			backoff *= 2
+			if backoff > maximumBackoff {
+				backoff = maximumBackoff
+			}
+		}
+
+		// Attempt to connect and perform the handshake
+		conn, err := DialIPC(d.Endpoint)
+		if err == nil && d.Handshake != nil {
+			if err = d.Handshake(conn, resumed); err != nil {
+				conn.Close()
+			}
+		}
+		if err == nil {
+			select {
+			case <-cancel:
+				conn.Close()
+				return nil, ErrReconnectingConnClosed
+			default:
+				return conn, nil
+			}
+		}
+
+		// Check whether or not we should give up
+		if d.MaximumAttempts > 0 && attempt >= d.MaximumAttempts {
+			return nil, err
+		}
+	}
+}
+
+// reconnectingAddr implements the net.Addr interface for reconnecting
+// connections, whose underlying local and remote addresses may change.
+type reconnectingAddr struct {
+	endpoint string
+}
+
+func (*reconnectingAddr) Network() string {
+	return "ipc"
+}
+
+func (a *reconnectingAddr) String() string {
+	return a.endpoint
+}
+
+// ReconnectingConn implements the net.Conn interface on top of a sequence of
+// underlying IPC connections, re-dialing whenever the current connection
+// fails.  Operations that observe a failure return ErrReconnected if a
+// replacement connection was established, or the original error if the
+// dialer gave up (after which the connection is closed).  Deadline expirations
+// aren't failures - they are returned unchanged and the connection is kept.
+type ReconnectingConn struct {
+	// The dialer used for reconnection
+	dialer *ReconnectingDialer
+
+	// The address reported by LocalAddr and RemoteAddr
+	address *reconnectingAddr
+
+	// Lock for the fields below.  It is never held during I/O.
+	lock sync.Mutex
+
+	// The current underlying connection, or nil if closed
+	conn net.Conn
+
+	// The current connection state
+	state ConnectionState
+
+	// Signal channel that is non-nil while a reconnection is in progress and
+	// is closed when it completes
+	reconnecting chan struct{}
+
+	// Signal channel that is closed by Close, aborting any reconnection
+	closed chan struct{}
+
+	// Ensures that the closed channel is only closed once
+	closeOnce sync.Once
+}
+
+// State returns the current connection state.
+func (c *ReconnectingConn) State() ConnectionState {
+	// Lock the connection
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	// All done
+	return c.state
+}
+
+// current returns the current underlying connection, waiting for any
+// in-progress reconnection to complete.  It returns nil if the connection is
+// closed, including while a reconnection is being abandoned.
+func (c *ReconnectingConn) current() net.Conn {
+	for {
+		// Check the current state
+		c.lock.Lock()
+		conn, reconnecting := c.conn, c.reconnecting
+		c.lock.Unlock()
+
+		// If no reconnection is underway, we're done
+		if reconnecting == nil {
+			return conn
+		}
+
+		// Otherwise wait for it to complete, unless we're closed first
+		select {
+		case <-reconnecting:
+		case <-c.closed:
+			return nil
+		}
+	}
+}
+
+// isTimeout returns whether or not an error is a deadline expiration, which
+// doesn't indicate a connection failure.
+func isTimeout(err error) bool {
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
+
+// reconnectAfter handles a failure of the specified underlying connection.  If
+// no other operation has already replaced it, it performs a reconnection.  It
+// returns ErrReconnected if the failed connection has been replaced, or the
+// original error if reconnection failed.
+func (c *ReconnectingConn) reconnectAfter(failed net.Conn, err error) error {
+	// Lock the connection
+	c.lock.Lock()
+
+	// If the connection has been closed, report the failure as-is
+	if c.conn == nil {
+		c.lock.Unlock()
+		return err
+	}
+
+	// If the failed connection has already been replaced (or is being replaced)
+	// by another operation, just wait for that to finish
+	if c.conn != failed || c.reconnecting != nil {
+		c.lock.Unlock()
+		if c.current() == nil {
+			return err
+		}
+		return ErrReconnected
+	}
+
+	// Otherwise we're responsible for reconnecting
+	reconnecting := make(chan struct{})
+	c.reconnecting = reconnecting
+	c.state = ConnectionStateDisconnected
+	c.lock.Unlock()
+	failed.Close()
+	c.dialer.notify(ConnectionStateDisconnected)
+
+	// Perform the reconnection
+	c.dialer.notify(ConnectionStateConnecting)
+	conn, dialErr := c.dialer.connect(true, c.closed)
+
+	// Record the result.  If the connection was closed while we were
+	// reconnecting, discard the new connection.
+	c.lock.Lock()
+	closed := c.conn == nil
+	if dialErr != nil || closed {
+		if conn != nil {
+			conn.Close()
+		}
+		c.conn = nil
+		c.state = ConnectionStateClosed
+	} else {
+		c.conn = conn
+		c.state = ConnectionStateConnected
+	}
+	c.reconnecting = nil
+	c.lock.Unlock()
+	close(reconnecting)
+
+	// Notify of the final state and report the result.  If the connection was
+	// closed by the caller, Close will already have reported that.
+	if closed {
+		return err
+	} else if dialErr != nil {
+		c.dialer.notify(ConnectionStateClosed)
+		return err
+	}
+	c.dialer.notify(ConnectionStateConnected)
+	return ErrReconnected
+}
+
+func (c *ReconnectingConn) Read(b []byte) (int, error) {
+	// Grab the current connection
+	conn := c.current()
+	if conn == nil {
+		return 0, ErrReconnectingConnClosed
+	}
+
+	// Perform the read, recovering from failures (but not deadline
+	// expirations)
+	n, err := conn.Read(b)
+	if err != nil && !isTimeout(err) {
+		err = c.reconnectAfter(conn, err)
+	}
+
+	// All done
+	return n, err
+}
+
+func (c *ReconnectingConn) Write(b []byte) (int, error) {
+	// Grab the current connection
+	conn := c.current()
+	if conn == nil {
+		return 0, ErrReconnectingConnClosed
+	}
+
+	// Perform the write, recovering from failures (but not deadline
+	// expirations)
+	n, err := conn.Write(b)
+	if err != nil && !isTimeout(err) {
+		err = c.reconnectAfter(conn, err)
+	}
+
+	// All done
+	return n, err
+}
+
+// Close closes the current underlying connection and stops any further
+// reconnection.  An in-progress reconnection is aborted, and operations
+// waiting on it return immediately.
+func (c *ReconnectingConn) Close() error {
+	// Abort any reconnection
+	c.closeOnce.Do(func() {
+		close(c.closed)
+	})
+
+	// Lock the connection and detach the underlying connection
+	c.lock.Lock()
+	conn := c.conn
+	c.conn = nil
+	c.state = ConnectionStateClosed
+	reconnecting := c.reconnecting != nil
+	c.lock.Unlock()
+
+	// If we were already closed, do nothing
+	if conn == nil {
+		return nil
+	}
+
+	// Notify of the state change
+	c.dialer.notify(ConnectionStateClosed)
+
+	// If a reconnection is underway, then the connection we detached has
+	// already been closed
+	if reconnecting {
+		return nil
+	}
+
+	// Otherwise close it
+	return conn.Close()
+}
+
+func (c *ReconnectingConn) LocalAddr() net.Addr {
+	return c.address
+}
+
+func (c *ReconnectingConn) RemoteAddr() net.Addr {
+	return c.address
+}
+
+// SetDeadline sets the deadline on the current underlying connection.
+// Deadlines are not carried over to replacement connections.
+func (c *ReconnectingConn) SetDeadline(t time.Time) error {
+	if conn := c.current(); conn != nil {
+		return conn.SetDeadline(t)
+	}
+	return ErrReconnectingConnClosed
+}
+
+// SetReadDeadline sets the read deadline on the current underlying connection.
+// Deadlines are not carried over to replacement connections.
+func (c *ReconnectingConn) SetReadDeadline(t time.Time) error {
+	if conn := c.current(); conn != nil {
+		return conn.SetReadDeadline(t)
+	}
+	return ErrReconnectingConnClosed
+}
+
+// SetWriteDeadline sets the write deadline on the current underlying
+// connection.  Deadlines are not carried over to replacement connections.
+func (c *ReconnectingConn) SetWriteDeadline(t time.Time) error {
+	if conn := c.current(); conn != nil {
+		return conn.SetWriteDeadline(t)
+	}
+	return ErrReconnectingConnClosed
+}
@@ -0,0 +1,224 @@
+// +build !windows,!js
+
+package ipc
+
+// System imports
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testEndpoint creates a socket path in a temporary directory that is removed
+// when the test completes.
+func testEndpoint(t *testing.T) string {
+	directory, err := ioutil.TempDir("", "gib")
+	if err != nil {
+		t.Fatal("unable to create temporary directory:", err)
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(directory)
+	})
+	return filepath.Join(directory, "test.sock")
+}
+
+// acceptOne accepts a single connection from a listener in the background.
+func acceptOne(listener net.Listener) chan net.Conn {
+	result := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			close(result)
+			return
+		}
+		result <- conn
+	}()
+	return result
+}
+
+// receiveConn waits for an accepted connection.
+func receiveConn(t *testing.T, accepted chan net.Conn) net.Conn {
+	select {
+	case conn, ok := <-accepted:
+		if !ok {
+			t.Fatal("accept failed")
+		}
+		return conn
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for connection")
+	}
+	return nil
+}
+
+func TestReconnectAfterListenerRestart(t *testing.T) {
+	endpoint := testEndpoint(t)
+
+	// Start the initial listener
+	listener, err := ListenIPC(endpoint)
+	if err != nil {
+		t.Fatal("unable to listen:", err)
+	}
+	accepted := acceptOne(listener)
+
+	// Dial, recording state changes
+	states := make(chan ConnectionState, 16)
+	dialer := &ReconnectingDialer{
+		Endpoint:       endpoint,
+		InitialBackoff: 10 * time.Millisecond,
+		StateChanged: func(state ConnectionState) {
+			states <- state
+		},
+	}
+	conn, err := dialer.Dial()
+	if err != nil {
+		t.Fatal("unable to dial:", err)
+	}
+	defer conn.Close()
+	server := receiveConn(t, accepted)
+
+	// Simulate a backend restart
+	server.Close()
+	listener.Close()
+	listener, err = ListenIPC(endpoint)
+	if err != nil {
+		t.Fatal("unable to restart listener:", err)
+	}
+	defer listener.Close()
+	accepted = acceptOne(listener)
+
+	// The next read should observe the failure and reconnect
+	if _, err := conn.Read(make([]byte, 1)); err != ErrReconnected {
+		t.Fatal("expected ErrReconnected, got:", err)
+	}
+	if state := conn.State(); state != ConnectionStateConnected {
+		t.Fatal("unexpected state after reconnection:", state)
+	}
+	server = receiveConn(t, accepted)
+	defer server.Close()
+
+	// Verify that the replacement connection works
+	if _, err := conn.Write([]byte("x")); err != nil {
+		t.Fatal("write after reconnection failed:", err)
+	}
+	buffer := make([]byte, 1)
+	if _, err := server.Read(buffer); err != nil || buffer[0] != 'x' {
+		t.Fatal("replacement connection didn't deliver data:", err)
+	}
+
+	// Verify the state sequence
+	expected := []ConnectionState{
+		ConnectionStateConnecting,
+		ConnectionStateConnected,
+		ConnectionStateDisconnected,
+		ConnectionStateConnecting,
+		ConnectionStateConnected,
+	}
+	for _, e := range expected {
+		if state := <-states; state != e {
+			t.Fatalf("unexpected state: %s != %s", state, e)
+		}
+	}
+}
+
+func TestReconnectDeadlineDoesNotReconnect(t *testing.T) {
+	endpoint := testEndpoint(t)
+
+	// Start a listener and connect
+	listener, err := ListenIPC(endpoint)
+	if err != nil {
+		t.Fatal("unable to listen:", err)
+	}
+	defer listener.Close()
+	accepted := acceptOne(listener)
+	dialer := &ReconnectingDialer{Endpoint: endpoint}
+	conn, err := dialer.Dial()
+	if err != nil {
+		t.Fatal("unable to dial:", err)
+	}
+	defer conn.Close()
+	server := receiveConn(t, accepted)
+	defer server.Close()
+
+	// Let a read deadline expire
+	conn.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	_, err = conn.Read(make([]byte, 1))
+	if !isTimeout(err) {
+		t.Fatal("expected timeout error, got:", err)
+	}
+	if state := conn.State(); state != ConnectionStateConnected {
+		t.Fatal("unexpected state after timeout:", state)
+	}
+
+	// Verify that the original connection is still in use
+	conn.SetReadDeadline(time.Time{})
+	if _, err := server.Write([]byte("y")); err != nil {
+		t.Fatal("server write failed:", err)
+	}
+	buffer := make([]byte, 1)
+	if _, err := conn.Read(buffer); err != nil || buffer[0] != 'y' {
+		t.Fatal("original connection didn't deliver data:", err)
+	}
+}
+
+func TestReconnectCloseAbortsReconnection(t *testing.T) {
+	endpoint := testEndpoint(t)
+
+	// Start a listener and connect, retrying forever
+	listener, err := ListenIPC(endpoint)
+	if err != nil {
+		t.Fatal("unable to listen:", err)
+	}
+	accepted := acceptOne(listener)
+	dialer := &ReconnectingDialer{
+		Endpoint:       endpoint,
+		InitialBackoff: 10 * time.Millisecond,
+		MaximumBackoff: 20 * time.Millisecond,
+	}
+	conn, err := dialer.Dial()
+	if err != nil {
+		t.Fatal("unable to dial:", err)
+	}
+	server := receiveConn(t, accepted)
+
+	// Take the backend down permanently
+	server.Close()
+	listener.Close()
+
+	// Start a read, which will begin reconnecting, and then a write, which
+	// will wait on the reconnection
+	readErrors := make(chan error, 1)
+	go func() {
+		_, err := conn.Read(make([]byte, 1))
+		readErrors <- err
+	}()
+	for conn.State() == ConnectionStateConnected {
+		time.Sleep(time.Millisecond)
+	}
+	writeErrors := make(chan error, 1)
+	go func() {
+		_, err := conn.Write([]byte("z"))
+		writeErrors <- err
+	}()
+
+	// Close the connection and ensure that both operations return
+	time.Sleep(50 * time.Millisecond)
+	if err := conn.Close(); err != nil {
+		t.Fatal("close failed:", err)
+	}
+	for _, errors := range []chan error{readErrors, writeErrors} {
+		select {
+		case err := <-errors:
+			if err == nil || err == ErrReconnected {
+				t.Fatal("unexpected operation result:", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("operation still blocked after close")
+		}
+	}
+	if state := conn.State(); state != ConnectionStateClosed {
+		t.Fatal("unexpected state after close:", state)
+	}
+}
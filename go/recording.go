@@ -0,0 +1,287 @@
+package ipc
+
+// System imports
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// BridgeRecord is a single entry in a bridge traffic recording, representing
+// either a request or its response.  Recordings are streams of gob-encoded
+// records, which omit zero-valued fields and are thus fairly compact.  The
+// GopherJS RecordingBridge produces recordings and ReplayBridge consumes them,
+// but they can be inspected from native code using ReadBridgeRecords.
+type BridgeRecord struct {
+	// Sequence identifies the request, and is shared by its response.
+	Sequence uint64
+
+	// Response indicates whether this is a response (rather than a request).
+	Response bool
+
+	// Offset is the time elapsed since the recording started.
+	Offset time.Duration
+
+	// Operation is the operation name (see the Operation constants).
+	Operation string
+
+	// Endpoint is the endpoint for connect and listen requests.
+	Endpoint string
+
+	// ConnectionId is the connection identifier for connection requests, or
+	// the resulting connection identifier for connect and accept responses.
+	ConnectionId int
+
+	// ListenerId is the listener identifier for listener requests, or the
+	// resulting listener identifier for listen responses.
+	ListenerId int
+
+	// Length is the requested length for read requests.
+	Length int
+
+	// Data is the payload for write requests and read responses.
+	Data []byte
+
+	// Count is the number of bytes written for write responses.
+	Count int
+
+	// RoundTrip is the measured round-trip time for ping responses.
+	RoundTrip time.Duration
+
+	// Error is the error message for failed responses.
+	Error string
+}
+
+// ReadBridgeRecords reads all records from a recording.
+func ReadBridgeRecords(reader io.Reader) ([]*BridgeRecord, error) {
+	// Create a decoder
+	decoder := gob.NewDecoder(reader)
+
+	// Read records until the end of the stream
+	var records []*BridgeRecord
+	for {
+		record := &BridgeRecord{}
+		if err := decoder.Decode(record); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	// All done
+	return records, nil
+}
+
+// bridgeRecordWriter writes records to a recording.  It is safe for concurrent
+// use.
+type bridgeRecordWriter struct {
+	// Lock serializing access
+	lock sync.Mutex
+
+	// The record encoder
+	encoder *gob.Encoder
+
+	// The start time of the recording
+	start time.Time
+
+	// The next request sequence
+	nextSequence uint64
+
+	// The first error encountered while writing, after which no further
+	// records are written
+	err error
+}
+
+func newBridgeRecordWriter(writer io.Writer) *bridgeRecordWriter {
+	return &bridgeRecordWriter{
+		encoder: gob.NewEncoder(writer),
+		start:   time.Now(),
+	}
+}
+
+// request writes a request record, assigning it a sequence.
+func (w *bridgeRecordWriter) request(record *BridgeRecord) uint64 {
+	// Lock the writer
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	// Assign a sequence
+	record.Sequence = w.nextSequence
+	w.nextSequence++
+
+	// Write the record
+	w.write(record)
+
+	// All done
+	return record.Sequence
+}
+
+// response writes a response record for the specified sequence.
+func (w *bridgeRecordWriter) response(sequence uint64, record *BridgeRecord) {
+	// Lock the writer
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	// Set up the record
+	record.Sequence = sequence
+	record.Response = true
+
+	// Write the record
+	w.write(record)
+}
+
+// write writes a record.  The lock must be held by the caller.
+func (w *bridgeRecordWriter) write(record *BridgeRecord) {
+	if w.err == nil {
+		record.Offset = time.Since(w.start)
+		w.err = w.encoder.Encode(record)
+	}
+}
+
+// failure returns the first error encountered while writing.
+func (w *bridgeRecordWriter) failure() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.err
+}
+
+// errorMessage converts an error to a record error message.
+func errorMessage(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// ErrReplayMismatch is returned by ReplayBridge when a request doesn't match
+// the next request recorded for its connection, listener, or endpoint.
+var ErrReplayMismatch = errors.New("request does not match recording")
+
+// ErrReplayExhausted is returned by ReplayBridge when there are no further
+// recorded requests for a connection, listener, or endpoint, or when the
+// matching recorded request never received a response.
+var ErrReplayExhausted = errors.New("recording exhausted")
+
+// replayStream returns the name of the request stream to which a request
+// belongs.  Requests within a stream are replayed in their recorded order,
+// while separate streams are replayed independently.
+func replayStream(request *BridgeRecord) string {
+	switch request.Operation {
+	case OperationConnectionRead, OperationConnectionWrite,
+		OperationConnectionClose, OperationConnectionCloseRead,
+		OperationConnectionCloseWrite, OperationConnectionReadMessage,
+		OperationConnectionWriteMessage:
+		return fmt.Sprintf("connection %d", request.ConnectionId)
+	case OperationListenerAccept, OperationListenerClose:
+		return fmt.Sprintf("listener %d", request.ListenerId)
+	default:
+		return fmt.Sprintf("%s %q", request.Operation, request.Endpoint)
+	}
+}
+
+// bridgeReplayer matches requests against a recording and provides the
+// recorded responses.  It is safe for concurrent use.
+type bridgeReplayer struct {
+	// Lock serializing access
+	lock sync.Mutex
+
+	// Recorded requests that haven't been replayed, in order, keyed by stream
+	streams map[string][]*BridgeRecord
+
+	// Recorded responses, keyed by sequence
+	responses map[uint64]*BridgeRecord
+
+	// The number of recorded requests that haven't been replayed
+	unreplayed int
+
+	// Whether or not the session has diverged from the recording
+	diverged bool
+
+	// Whether or not write payloads must match
+	strict bool
+}
+
+func newBridgeReplayer(records []*BridgeRecord, strict bool) *bridgeReplayer {
+	// Create the replayer
+	replayer := &bridgeReplayer{
+		streams:   make(map[string][]*BridgeRecord),
+		responses: make(map[uint64]*BridgeRecord),
+		strict:    strict,
+	}
+
+	// Split requests and responses
+	for _, record := range records {
+		if record.Response {
+			replayer.responses[record.Sequence] = record
+		} else {
+			stream := replayStream(record)
+			replayer.streams[stream] = append(replayer.streams[stream], record)
+			replayer.unreplayed++
+		}
+	}
+
+	// All done
+	return replayer
+}
+
+// remaining returns the number of recorded requests that have not yet been
+// replayed.
+func (r *bridgeReplayer) remaining() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.unreplayed
+}
+
+// replay matches a request against the next recorded request in its stream
+// and returns the corresponding recorded response.  The stream is only
+// advanced when the request matches - the first mismatch fails the replay.
+func (r *bridgeReplayer) replay(request *BridgeRecord) (*BridgeRecord, error) {
+	// Lock the replayer
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	// If we've already diverged from the recording, there's nothing to match
+	if r.diverged {
+		return nil, ErrReplayMismatch
+	}
+
+	// Grab the next recorded request in the stream
+	stream := replayStream(request)
+	requests := r.streams[stream]
+	if len(requests) == 0 {
+		return nil, ErrReplayExhausted
+	}
+	recorded := requests[0]
+
+	// Verify that it matches
+	if request.Operation != recorded.Operation ||
+		request.Endpoint != recorded.Endpoint ||
+		request.ConnectionId != recorded.ConnectionId ||
+		request.ListenerId != recorded.ListenerId ||
+		request.Length != recorded.Length ||
+		len(request.Data) != len(recorded.Data) ||
+		(r.strict && !bytes.Equal(request.Data, recorded.Data)) {
+		r.diverged = true
+		return nil, ErrReplayMismatch
+	}
+	if len(requests) == 1 {
+		delete(r.streams, stream)
+	} else {
+		r.streams[stream] = requests[1:]
+	}
+	r.unreplayed--
+
+	// Grab the response
+	response, ok := r.responses[recorded.Sequence]
+	if !ok {
+		return nil, ErrReplayExhausted
+	}
+
+	// All done
+	return response, nil
+}
@@ -0,0 +1,302 @@
+package ipc
+
+// System imports
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// failingWriter is an io.Writer that fails every write.
+type failingWriter struct {
+	// The number of attempted writes
+	writes int
+}
+
+func (w *failingWriter) Write(data []byte) (int, error) {
+	w.writes++
+	return 0, errors.New("write failed")
+}
+
+func TestBridgeRecordRoundTrip(t *testing.T) {
+	// Record two overlapping requests
+	var recording bytes.Buffer
+	writer := newBridgeRecordWriter(&recording)
+	connect := writer.request(&BridgeRecord{
+		Operation: OperationConnect,
+		Endpoint:  "endpoint",
+	})
+	write := writer.request(&BridgeRecord{
+		Operation:    OperationConnectionWrite,
+		ConnectionId: 4,
+		Data:         []byte("data"),
+	})
+	writer.response(write, &BridgeRecord{
+		Operation: OperationConnectionWrite,
+		Error:     errorMessage(errors.New("broken pipe")),
+	})
+	writer.response(connect, &BridgeRecord{
+		Operation:    OperationConnect,
+		ConnectionId: 5,
+	})
+	if err := writer.failure(); err != nil {
+		t.Fatal("unable to write recording:", err)
+	}
+
+	// Read the recording back
+	records, err := ReadBridgeRecords(&recording)
+	if err != nil {
+		t.Fatal("unable to read recording:", err)
+	}
+	expected := []*BridgeRecord{
+		{
+			Sequence:  0,
+			Operation: OperationConnect,
+			Endpoint:  "endpoint",
+		},
+		{
+			Sequence:     1,
+			Operation:    OperationConnectionWrite,
+			ConnectionId: 4,
+			Data:         []byte("data"),
+		},
+		{
+			Sequence:  1,
+			Response:  true,
+			Operation: OperationConnectionWrite,
+			Error:     "broken pipe",
+		},
+		{
+			Sequence:     0,
+			Response:     true,
+			Operation:    OperationConnect,
+			ConnectionId: 5,
+		},
+	}
+	if len(records) != len(expected) {
+		t.Fatal("unexpected record count:", len(records))
+	}
+	for i, record := range records {
+		if i > 0 && record.Offset < records[i-1].Offset {
+			t.Error("record offsets are not monotonic")
+		}
+		record.Offset = 0
+		if !reflect.DeepEqual(record, expected[i]) {
+			t.Errorf("record %d mismatch: %+v != %+v", i, record, expected[i])
+		}
+	}
+
+	// Empty and corrupt recordings
+	if records, err := ReadBridgeRecords(&bytes.Buffer{}); err != nil {
+		t.Error("unable to read empty recording:", err)
+	} else if len(records) != 0 {
+		t.Error("empty recording produced records")
+	}
+	corrupt := bytes.NewBufferString("not a recording")
+	if _, err := ReadBridgeRecords(corrupt); err == nil {
+		t.Error("corrupt recording read successfully")
+	}
+}
+
+func TestBridgeRecordWriterFailure(t *testing.T) {
+	// Record to a failing writer
+	output := &failingWriter{}
+	writer := newBridgeRecordWriter(output)
+	sequence := writer.request(&BridgeRecord{Operation: OperationPing})
+	writer.response(sequence, &BridgeRecord{Operation: OperationPing})
+	writer.request(&BridgeRecord{Operation: OperationPing})
+
+	// The first error should be retained, sequences should continue to be
+	// assigned, and recording should stop after the failure
+	if writer.failure() == nil {
+		t.Error("write failure not reported")
+	}
+	if writer.nextSequence != 2 {
+		t.Error("unexpected next sequence:", writer.nextSequence)
+	}
+	if output.writes != 1 {
+		t.Error("writes continued after failure:", output.writes)
+	}
+}
+
+// replayRecording is a recording of two connections whose requests
+// interleave.  The second read has no recorded response.
+var replayRecording = []*BridgeRecord{
+	{Sequence: 0, Operation: OperationConnect, Endpoint: "a"},
+	{Sequence: 0, Response: true, Operation: OperationConnect, ConnectionId: 1},
+	{Sequence: 1, Operation: OperationConnect, Endpoint: "b"},
+	{Sequence: 1, Response: true, Operation: OperationConnect, ConnectionId: 2},
+	{
+		Sequence:     2,
+		Operation:    OperationConnectionWrite,
+		ConnectionId: 1,
+		Data:         []byte("one"),
+	},
+	{
+		Sequence:     3,
+		Operation:    OperationConnectionWrite,
+		ConnectionId: 2,
+		Data:         []byte("two"),
+	},
+	{Sequence: 3, Response: true, Operation: OperationConnectionWrite, Count: 3},
+	{Sequence: 2, Response: true, Operation: OperationConnectionWrite, Count: 3},
+	{
+		Sequence:     4,
+		Operation:    OperationConnectionRead,
+		ConnectionId: 2,
+		Length:       8,
+	},
+}
+
+func TestBridgeReplayer(t *testing.T) {
+	replayer := newBridgeReplayer(replayRecording, true)
+	if replayer.remaining() != 5 {
+		t.Fatal("unexpected request count:", replayer.remaining())
+	}
+
+	// Replay connection 2 entirely before connection 1, which differs from the
+	// recorded order but preserves the order within each connection
+	steps := []struct {
+		request  BridgeRecord
+		response *BridgeRecord
+		err      error
+	}{
+		{
+			BridgeRecord{Operation: OperationConnect, Endpoint: "b"},
+			replayRecording[3],
+			nil,
+		},
+		{
+			BridgeRecord{
+				Operation:    OperationConnectionWrite,
+				ConnectionId: 2,
+				Data:         []byte("two"),
+			},
+			replayRecording[6],
+			nil,
+		},
+		{
+			BridgeRecord{
+				Operation:    OperationConnectionRead,
+				ConnectionId: 2,
+				Length:       8,
+			},
+			nil,
+			ErrReplayExhausted,
+		},
+		{
+			BridgeRecord{
+				Operation:    OperationConnectionRead,
+				ConnectionId: 2,
+				Length:       8,
+			},
+			nil,
+			ErrReplayExhausted,
+		},
+		{
+			BridgeRecord{Operation: OperationConnect, Endpoint: "a"},
+			replayRecording[1],
+			nil,
+		},
+		{
+			BridgeRecord{
+				Operation:    OperationConnectionWrite,
+				ConnectionId: 1,
+				Data:         []byte("one"),
+			},
+			replayRecording[7],
+			nil,
+		},
+	}
+	for i, step := range steps {
+		response, err := replayer.replay(&step.request)
+		if err != step.err {
+			t.Fatalf("step %d: unexpected error: %v", i, err)
+		} else if response != step.response {
+			t.Fatalf("step %d: unexpected response: %+v", i, response)
+		}
+	}
+	if replayer.remaining() != 0 {
+		t.Error("unexpected remaining count:", replayer.remaining())
+	}
+}
+
+func TestBridgeReplayerMismatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		strict  bool
+		request BridgeRecord
+		err     error
+	}{
+		{
+			"loose payload",
+			false,
+			BridgeRecord{
+				Operation:    OperationConnectionWrite,
+				ConnectionId: 1,
+				Data:         []byte("eno"),
+			},
+			nil,
+		},
+		{
+			"strict payload",
+			true,
+			BridgeRecord{
+				Operation:    OperationConnectionWrite,
+				ConnectionId: 1,
+				Data:         []byte("eno"),
+			},
+			ErrReplayMismatch,
+		},
+		{
+			"payload length",
+			false,
+			BridgeRecord{
+				Operation:    OperationConnectionWrite,
+				ConnectionId: 1,
+				Data:         []byte("one!"),
+			},
+			ErrReplayMismatch,
+		},
+		{
+			"operation",
+			false,
+			BridgeRecord{
+				Operation:    OperationConnectionClose,
+				ConnectionId: 1,
+			},
+			ErrReplayMismatch,
+		},
+		{
+			"unrecorded stream",
+			false,
+			BridgeRecord{
+				Operation:    OperationConnectionWrite,
+				ConnectionId: 3,
+				Data:         []byte("one"),
+			},
+			ErrReplayExhausted,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			replayer := newBridgeReplayer(replayRecording, test.strict)
+			if _, err := replayer.replay(&test.request); err != test.err {
+				t.Fatal("unexpected error:", err)
+			}
+
+			// After a mismatch, the replay should fail permanently, even for
+			// requests that would otherwise match
+			_, err := replayer.replay(&BridgeRecord{
+				Operation: OperationConnect,
+				Endpoint:  "a",
+			})
+			if test.err == ErrReplayMismatch && err != ErrReplayMismatch {
+				t.Error("replay continued after mismatch:", err)
+			} else if test.err != ErrReplayMismatch && err != nil {
+				t.Error("unable to continue replay:", err)
+			}
+		})
+	}
+}
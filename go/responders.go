@@ -0,0 +1,564 @@
+package ipc
+
+// System imports
+import (
+	"encoding/base64"
+	"errors"
+	"time"
+)
+
+// This file provides the response decoding and delivery logic shared by the
+// sequence-based bridge implementations.  Host responses are untrusted input
+// (any script in the web view can invoke the response functions), so none of
+// these functions panic.  Problems with a response (unknown sequences,
+// responses for the wrong type of request, and undecodable data) are returned
+// as errors for the bridge to report to its error handler.  Whenever the
+// request can be identified, the problem is also delivered to the waiting
+// caller as the result error, with identifiers set to -1 for results that carry
+// one.
+
+// ErrInvalidResultChannel is reported when the host sends a response of one
+// type for a request of another type (e.g. a read response for a connect
+// request).
+var ErrInvalidResultChannel = errors.New("invalid response channel type")
+
+// ErrInvalidResponseData is delivered to the caller when the host sends a read
+// response whose data can't be decoded.
+var ErrInvalidResponseData = errors.New("host sent gibberish data")
+
+// decodeResponseData decodes base64-encoded response data.
+func decodeResponseData(data64 string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(data64)
+	if err != nil {
+		return nil, ErrInvalidResponseData
+	}
+	return data, nil
+}
+
+// connectRequest is the pending request type for ConnectResult results.
+type connectRequest chan ConnectResult
+
+func (r connectRequest) operation() string {
+	return OperationConnect
+}
+
+func (r connectRequest) fail(err error) {
+	r <- ConnectResult{connectionId: -1, err: err}
+}
+
+// connectionReadRequest is the pending request type for ConnectionReadResult
+// results.
+type connectionReadRequest chan ConnectionReadResult
+
+func (r connectionReadRequest) operation() string {
+	return OperationConnectionRead
+}
+
+func (r connectionReadRequest) fail(err error) {
+	r <- ConnectionReadResult{err: err}
+}
+
+// connectionWriteRequest is the pending request type for ConnectionWriteResult
+// results.
+type connectionWriteRequest chan ConnectionWriteResult
+
+func (r connectionWriteRequest) operation() string {
+	return OperationConnectionWrite
+}
+
+func (r connectionWriteRequest) fail(err error) {
+	r <- ConnectionWriteResult{err: err}
+}
+
+// connectionCloseRequest is the pending request type for ConnectionCloseResult
+// results.
+type connectionCloseRequest chan ConnectionCloseResult
+
+func (r connectionCloseRequest) operation() string {
+	return OperationConnectionClose
+}
+
+func (r connectionCloseRequest) fail(err error) {
+	r <- ConnectionCloseResult{err: err}
+}
+
+// connectionCloseReadRequest is the pending request type for
+// ConnectionCloseResult results.
+type connectionCloseReadRequest chan ConnectionCloseResult
+
+func (r connectionCloseReadRequest) operation() string {
+	return OperationConnectionCloseRead
+}
+
+func (r connectionCloseReadRequest) fail(err error) {
+	r <- ConnectionCloseResult{err: err}
+}
+
+// connectionCloseWriteRequest is the pending request type for
+// ConnectionCloseResult results.
+type connectionCloseWriteRequest chan ConnectionCloseResult
+
+func (r connectionCloseWriteRequest) operation() string {
+	return OperationConnectionCloseWrite
+}
+
+func (r connectionCloseWriteRequest) fail(err error) {
+	r <- ConnectionCloseResult{err: err}
+}
+
+// listenRequest is the pending request type for ListenResult results.
+type listenRequest chan ListenResult
+
+func (r listenRequest) operation() string {
+	return OperationListen
+}
+
+func (r listenRequest) fail(err error) {
+	r <- ListenResult{listenerId: -1, err: err}
+}
+
+// listenerAcceptRequest is the pending request type for ListenerAcceptResult
+// results.
+type listenerAcceptRequest chan ListenerAcceptResult
+
+func (r listenerAcceptRequest) operation() string {
+	return OperationListenerAccept
+}
+
+func (r listenerAcceptRequest) fail(err error) {
+	r <- ListenerAcceptResult{connectionId: -1, err: err}
+}
+
+// listenerCloseRequest is the pending request type for ListenerCloseResult
+// results.
+type listenerCloseRequest chan ListenerCloseResult
+
+func (r listenerCloseRequest) operation() string {
+	return OperationListenerClose
+}
+
+func (r listenerCloseRequest) fail(err error) {
+	r <- ListenerCloseResult{err: err}
+}
+
+// connectPacketRequest is the pending request type for packet ConnectResult
+// results.
+type connectPacketRequest chan ConnectResult
+
+func (r connectPacketRequest) operation() string {
+	return OperationConnectPacket
+}
+
+func (r connectPacketRequest) fail(err error) {
+	r <- ConnectResult{connectionId: -1, err: err}
+}
+
+// connectionReadMessageRequest is the pending request type for message
+// ConnectionReadResult results.
+type connectionReadMessageRequest chan ConnectionReadResult
+
+func (r connectionReadMessageRequest) operation() string {
+	return OperationConnectionReadMessage
+}
+
+func (r connectionReadMessageRequest) fail(err error) {
+	r <- ConnectionReadResult{err: err}
+}
+
+// connectionWriteMessageRequest is the pending request type for message
+// ConnectionWriteResult results.
+type connectionWriteMessageRequest chan ConnectionWriteResult
+
+func (r connectionWriteMessageRequest) operation() string {
+	return OperationConnectionWriteMessage
+}
+
+func (r connectionWriteMessageRequest) fail(err error) {
+	r <- ConnectionWriteResult{err: err}
+}
+
+// listenPacketRequest is the pending request type for packet ListenResult
+// results.
+type listenPacketRequest chan ListenResult
+
+func (r listenPacketRequest) operation() string {
+	return OperationListenPacket
+}
+
+func (r listenPacketRequest) fail(err error) {
+	r <- ListenResult{listenerId: -1, err: err}
+}
+
+// pingRequest is the pending request type for PingResult results.  It also
+// records the time at which the ping was issued, from which the round-trip time
+// is computed.
+type pingRequest struct {
+	results chan PingResult
+	start   time.Time
+}
+
+func newPingRequest(results chan PingResult) pingRequest {
+	return pingRequest{
+		results: results,
+		start:   time.Now(),
+	}
+}
+
+func (r pingRequest) operation() string {
+	return OperationPing
+}
+
+func (r pingRequest) fail(err error) {
+	r.results <- PingResult{err: err}
+}
+
+func respondConnect(
+	sequences *sequencer,
+	sequence,
+	connectionId int,
+	err error,
+) error {
+	// Get the pending request
+	request, popErr := sequences.pop(sequence)
+	if popErr != nil {
+		return popErr
+	}
+	resultChannel, ok := request.(connectRequest)
+	if !ok {
+		request.fail(ErrInvalidResultChannel)
+		return ErrInvalidResultChannel
+	}
+
+	// Respond
+	resultChannel <- ConnectResult{
+		connectionId: connectionId,
+		err:          err,
+	}
+	return nil
+}
+
+func respondConnectionRead(
+	sequences *sequencer,
+	sequence int,
+	data64 string,
+	err error,
+) error {
+	// Get the pending request
+	request, popErr := sequences.pop(sequence)
+	if popErr != nil {
+		return popErr
+	}
+	resultChannel, ok := request.(connectionReadRequest)
+	if !ok {
+		request.fail(ErrInvalidResultChannel)
+		return ErrInvalidResultChannel
+	}
+
+	// Decode the data.  If it's invalid, the connection's stream is corrupt,
+	// so report that to the caller in preference to any host error.
+	data, decodeErr := decodeResponseData(data64)
+	if decodeErr != nil {
+		err = decodeErr
+	}
+
+	// Respond
+	resultChannel <- ConnectionReadResult{
+		data: data,
+		err:  err,
+	}
+	return decodeErr
+}
+
+func respondConnectionWrite(
+	sequences *sequencer,
+	sequence,
+	count int,
+	err error,
+) error {
+	// Get the pending request
+	request, popErr := sequences.pop(sequence)
+	if popErr != nil {
+		return popErr
+	}
+	resultChannel, ok := request.(connectionWriteRequest)
+	if !ok {
+		request.fail(ErrInvalidResultChannel)
+		return ErrInvalidResultChannel
+	}
+
+	// Respond
+	resultChannel <- ConnectionWriteResult{
+		count: count,
+		err:   err,
+	}
+	return nil
+}
+
+func respondConnectionClose(
+	sequences *sequencer,
+	sequence int,
+	err error,
+) error {
+	// Get the pending request
+	request, popErr := sequences.pop(sequence)
+	if popErr != nil {
+		return popErr
+	}
+	resultChannel, ok := request.(connectionCloseRequest)
+	if !ok {
+		request.fail(ErrInvalidResultChannel)
+		return ErrInvalidResultChannel
+	}
+
+	// Respond
+	resultChannel <- ConnectionCloseResult{
+		err: err,
+	}
+	return nil
+}
+
+func respondConnectionCloseRead(
+	sequences *sequencer,
+	sequence int,
+	err error,
+) error {
+	// Get the pending request
+	request, popErr := sequences.pop(sequence)
+	if popErr != nil {
+		return popErr
+	}
+	resultChannel, ok := request.(connectionCloseReadRequest)
+	if !ok {
+		request.fail(ErrInvalidResultChannel)
+		return ErrInvalidResultChannel
+	}
+
+	// Respond
+	resultChannel <- ConnectionCloseResult{
+		err: err,
+	}
+	return nil
+}
+
+func respondConnectionCloseWrite(
+	sequences *sequencer,
+	sequence int,
+	err error,
+) error {
+	// Get the pending request
+	request, popErr := sequences.pop(sequence)
+	if popErr != nil {
+		return popErr
+	}
+	resultChannel, ok := request.(connectionCloseWriteRequest)
+	if !ok {
+		request.fail(ErrInvalidResultChannel)
+		return ErrInvalidResultChannel
+	}
+
+	// Respond
+	resultChannel <- ConnectionCloseResult{
+		err: err,
+	}
+	return nil
+}
+
+func respondListen(
+	sequences *sequencer,
+	sequence,
+	listenerId int,
+	err error,
+) error {
+	// Get the pending request
+	request, popErr := sequences.pop(sequence)
+	if popErr != nil {
+		return popErr
+	}
+	resultChannel, ok := request.(listenRequest)
+	if !ok {
+		request.fail(ErrInvalidResultChannel)
+		return ErrInvalidResultChannel
+	}
+
+	// Respond
+	resultChannel <- ListenResult{
+		listenerId: listenerId,
+		err:        err,
+	}
+	return nil
+}
+
+func respondListenerAccept(
+	sequences *sequencer,
+	sequence,
+	connectionId int,
+	err error,
+) error {
+	// Get the pending request
+	request, popErr := sequences.pop(sequence)
+	if popErr != nil {
+		return popErr
+	}
+	resultChannel, ok := request.(listenerAcceptRequest)
+	if !ok {
+		request.fail(ErrInvalidResultChannel)
+		return ErrInvalidResultChannel
+	}
+
+	// Respond
+	resultChannel <- ListenerAcceptResult{
+		connectionId: connectionId,
+		err:          err,
+	}
+	return nil
+}
+
+func respondListenerClose(
+	sequences *sequencer,
+	sequence int,
+	err error,
+) error {
+	// Get the pending request
+	request, popErr := sequences.pop(sequence)
+	if popErr != nil {
+		return popErr
+	}
+	resultChannel, ok := request.(listenerCloseRequest)
+	if !ok {
+		request.fail(ErrInvalidResultChannel)
+		return ErrInvalidResultChannel
+	}
+
+	// Respond
+	resultChannel <- ListenerCloseResult{
+		err: err,
+	}
+	return nil
+}
+
+func respondPing(sequences *sequencer, sequence int, err error) error {
+	// Get the pending request
+	request, popErr := sequences.pop(sequence)
+	if popErr != nil {
+		return popErr
+	}
+	pending, ok := request.(pingRequest)
+	if !ok {
+		request.fail(ErrInvalidResultChannel)
+		return ErrInvalidResultChannel
+	}
+
+	// Respond
+	pending.results <- PingResult{
+		roundTrip: time.Since(pending.start),
+		err:       err,
+	}
+	return nil
+}
+
+func respondConnectPacket(
+	sequences *sequencer,
+	sequence,
+	connectionId int,
+	err error,
+) error {
+	// Get the pending request
+	request, popErr := sequences.pop(sequence)
+	if popErr != nil {
+		return popErr
+	}
+	resultChannel, ok := request.(connectPacketRequest)
+	if !ok {
+		request.fail(ErrInvalidResultChannel)
+		return ErrInvalidResultChannel
+	}
+
+	// Respond
+	resultChannel <- ConnectResult{
+		connectionId: connectionId,
+		err:          err,
+	}
+	return nil
+}
+
+func respondConnectionReadMessage(
+	sequences *sequencer,
+	sequence int,
+	data64 string,
+	err error,
+) error {
+	// Get the pending request
+	request, popErr := sequences.pop(sequence)
+	if popErr != nil {
+		return popErr
+	}
+	resultChannel, ok := request.(connectionReadMessageRequest)
+	if !ok {
+		request.fail(ErrInvalidResultChannel)
+		return ErrInvalidResultChannel
+	}
+
+	// Decode the message.  If it's invalid, report that to the caller in
+	// preference to any host error.
+	data, decodeErr := decodeResponseData(data64)
+	if decodeErr != nil {
+		err = decodeErr
+	}
+
+	// Respond
+	resultChannel <- ConnectionReadResult{
+		data: data,
+		err:  err,
+	}
+	return decodeErr
+}
+
+func respondConnectionWriteMessage(
+	sequences *sequencer,
+	sequence,
+	count int,
+	err error,
+) error {
+	// Get the pending request
+	request, popErr := sequences.pop(sequence)
+	if popErr != nil {
+		return popErr
+	}
+	resultChannel, ok := request.(connectionWriteMessageRequest)
+	if !ok {
+		request.fail(ErrInvalidResultChannel)
+		return ErrInvalidResultChannel
+	}
+
+	// Respond
+	resultChannel <- ConnectionWriteResult{
+		count: count,
+		err:   err,
+	}
+	return nil
+}
+
+func respondListenPacket(
+	sequences *sequencer,
+	sequence,
+	listenerId int,
+	err error,
+) error {
+	// Get the pending request
+	request, popErr := sequences.pop(sequence)
+	if popErr != nil {
+		return popErr
+	}
+	resultChannel, ok := request.(listenPacketRequest)
+	if !ok {
+		request.fail(ErrInvalidResultChannel)
+		return ErrInvalidResultChannel
+	}
+
+	// Respond
+	resultChannel <- ListenResult{
+		listenerId: listenerId,
+		err:        err,
+	}
+	return nil
+}
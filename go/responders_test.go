@@ -0,0 +1,356 @@
+package ipc
+
+// System imports
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func FuzzErrorFromErrorMessage(f *testing.F) {
+	f.Add("")
+	f.Add("connection refused")
+	f.Add(ErrEndpointNotPermitted.Error())
+	f.Fuzz(func(t *testing.T, message string) {
+		err := ErrorFromErrorMessage(message)
+		if message == "" {
+			if err != nil {
+				t.Fatal("empty message produced error:", err)
+			}
+		} else if err == nil {
+			t.Fatal("non-empty message produced no error")
+		} else if err.Error() != message {
+			t.Fatalf("message mismatch: %q != %q", err.Error(), message)
+		}
+	})
+}
+
+func FuzzErrorFromBase64EncodedErrorMessage(f *testing.F) {
+	f.Add("")
+	f.Add(base64.StdEncoding.EncodeToString([]byte("broken pipe")))
+	f.Add("not base64!")
+	f.Add("QQ")
+	f.Add("QQ==\r\n")
+	f.Fuzz(func(t *testing.T, message64 string) {
+		err := ErrorFromBase64EncodedErrorMessage(message64)
+		if message64 == "" {
+			if err != nil {
+				t.Fatal("empty message produced error:", err)
+			}
+			return
+		} else if err == nil {
+			t.Fatal("non-empty message produced no error")
+		}
+		if message, decodeErr := base64.StdEncoding.DecodeString(message64); decodeErr == nil {
+			if len(message) > 0 && err.Error() != string(message) {
+				t.Fatalf("message mismatch: %q != %q", err.Error(), message)
+			}
+		}
+	})
+}
+
+func FuzzDecodeResponseData(f *testing.F) {
+	f.Add("")
+	f.Add(base64.StdEncoding.EncodeToString([]byte{0, 1, 2, 255}))
+	f.Add("////")
+	f.Add("A===")
+	f.Add("AA\nAA")
+	f.Fuzz(func(t *testing.T, data64 string) {
+		data, err := decodeResponseData(data64)
+		if err != nil {
+			if err != ErrInvalidResponseData {
+				t.Fatal("unexpected decoding error:", err)
+			}
+			return
+		}
+		roundTrip, err := decodeResponseData(
+			base64.StdEncoding.EncodeToString(data),
+		)
+		if err != nil || string(roundTrip) != string(data) {
+			t.Fatal("decoded data does not round trip")
+		}
+	})
+}
+
+// responseKindCount is the number of response types.
+const responseKindCount = 14
+
+// newRequest creates a pending request for the specified response kind.
+func newRequest(kind int) pendingRequest {
+	switch kind {
+	case 0:
+		return make(connectRequest, 1)
+	case 1:
+		return make(connectionReadRequest, 1)
+	case 2:
+		return make(connectionWriteRequest, 1)
+	case 3:
+		return make(connectionCloseRequest, 1)
+	case 4:
+		return make(listenRequest, 1)
+	case 5:
+		return make(listenerAcceptRequest, 1)
+	case 6:
+		return make(listenerCloseRequest, 1)
+	case 7:
+		return newPingRequest(make(chan PingResult, 1))
+	case 8:
+		return make(connectionCloseReadRequest, 1)
+	case 9:
+		return make(connectionCloseWriteRequest, 1)
+	case 10:
+		return make(connectPacketRequest, 1)
+	case 11:
+		return make(connectionReadMessageRequest, 1)
+	case 12:
+		return make(connectionWriteMessageRequest, 1)
+	default:
+		return make(listenPacketRequest, 1)
+	}
+}
+
+// respond delivers a response of the specified kind.
+func respond(
+	kind int,
+	sequences *sequencer,
+	sequence int,
+	data64 string,
+) error {
+	switch kind {
+	case 0:
+		return respondConnect(sequences, sequence, 1, nil)
+	case 1:
+		return respondConnectionRead(sequences, sequence, data64, nil)
+	case 2:
+		return respondConnectionWrite(sequences, sequence, 1, nil)
+	case 3:
+		return respondConnectionClose(sequences, sequence, nil)
+	case 4:
+		return respondListen(sequences, sequence, 1, nil)
+	case 5:
+		return respondListenerAccept(sequences, sequence, 1, nil)
+	case 6:
+		return respondListenerClose(sequences, sequence, nil)
+	case 7:
+		return respondPing(sequences, sequence, nil)
+	case 8:
+		return respondConnectionCloseRead(sequences, sequence, nil)
+	case 9:
+		return respondConnectionCloseWrite(sequences, sequence, nil)
+	case 10:
+		return respondConnectPacket(sequences, sequence, 1, nil)
+	case 11:
+		return respondConnectionReadMessage(sequences, sequence, data64, nil)
+	case 12:
+		return respondConnectionWriteMessage(sequences, sequence, 1, nil)
+	default:
+		return respondListenPacket(sequences, sequence, 1, nil)
+	}
+}
+
+// carriesData returns whether or not responses of the specified kind carry
+// encoded data.
+func carriesData(kind int) bool {
+	return kind == 1 || kind == 11
+}
+
+// received performs a non-blocking receive on a pending request's result
+// channel, returning whether or not a result was available and the result's
+// error.
+func received(request pendingRequest) (bool, error) {
+	var err error
+	var ok bool
+	switch c := request.(type) {
+	case connectRequest:
+		select {
+		case r := <-c:
+			ok, err = true, r.err
+		default:
+		}
+	case connectionReadRequest:
+		select {
+		case r := <-c:
+			ok, err = true, r.err
+		default:
+		}
+	case connectionWriteRequest:
+		select {
+		case r := <-c:
+			ok, err = true, r.err
+		default:
+		}
+	case connectionCloseRequest:
+		select {
+		case r := <-c:
+			ok, err = true, r.err
+		default:
+		}
+	case listenRequest:
+		select {
+		case r := <-c:
+			ok, err = true, r.err
+		default:
+		}
+	case listenerAcceptRequest:
+		select {
+		case r := <-c:
+			ok, err = true, r.err
+		default:
+		}
+	case listenerCloseRequest:
+		select {
+		case r := <-c:
+			ok, err = true, r.err
+		default:
+		}
+	case connectionCloseReadRequest:
+		select {
+		case r := <-c:
+			ok, err = true, r.err
+		default:
+		}
+	case connectionCloseWriteRequest:
+		select {
+		case r := <-c:
+			ok, err = true, r.err
+		default:
+		}
+	case connectPacketRequest:
+		select {
+		case r := <-c:
+			ok, err = true, r.err
+		default:
+		}
+	case connectionReadMessageRequest:
+		select {
+		case r := <-c:
+			ok, err = true, r.err
+		default:
+		}
+	case connectionWriteMessageRequest:
+		select {
+		case r := <-c:
+			ok, err = true, r.err
+		default:
+		}
+	case listenPacketRequest:
+		select {
+		case r := <-c:
+			ok, err = true, r.err
+		default:
+		}
+	case pingRequest:
+		select {
+		case r := <-c.results:
+			ok, err = true, r.err
+		default:
+		}
+	}
+	return ok, err
+}
+
+// FuzzResponders interprets its input as a program of requests and responses,
+// including responses for unknown, duplicate, and mistyped sequences, and
+// verifies the responders' behavior against a simple model.
+func FuzzResponders(f *testing.F) {
+	f.Add([]byte{0, 0, 1, 0, 0}, "")
+	f.Add([]byte{1, 1, 129, 0, 129, 0}, "AAEC")
+	f.Add([]byte{2, 130, 0, 131, 0}, "!!")
+	f.Fuzz(func(t *testing.T, program []byte, data64 string) {
+		// Track outstanding requests
+		sequences := newSequencer()
+		kinds := make(map[int]int)
+		requests := make(map[int]pendingRequest)
+		var issued int
+
+		// Interpret the program.  Bytes with the high bit clear issue a
+		// request, and bytes with the high bit set are followed by a sequence
+		// byte and respond to that sequence.
+		for i := 0; i < len(program); i++ {
+			kind := int(program[i]&0x7f) % responseKindCount
+			if program[i]&0x80 == 0 {
+				request := newRequest(kind)
+				sequence, err := sequences.push(request)
+				if err != nil {
+					t.Fatal("unable to push channel:", err)
+				} else if sequence != issued {
+					t.Fatal("unexpected sequence:", sequence)
+				}
+				issued++
+				kinds[sequence] = kind
+				requests[sequence] = request
+				continue
+			}
+			if i++; i == len(program) {
+				break
+			}
+			sequence := int(program[i]) - 8
+			err := respond(kind, sequences, sequence, data64)
+
+			// Check the result against the model
+			expectedKind, outstanding := kinds[sequence]
+			if !outstanding {
+				if err != ErrUnknownSequence {
+					t.Fatal("expected unknown sequence error, got", err)
+				}
+			} else if expectedKind != kind {
+				if err != ErrInvalidResultChannel {
+					t.Fatal("expected invalid result channel error, got", err)
+				}
+				ok, resultErr := received(requests[sequence])
+				if !ok || resultErr != ErrInvalidResultChannel {
+					t.Fatal("mistyped response not reported to caller")
+				}
+				delete(kinds, sequence)
+				delete(requests, sequence)
+			} else {
+				_, decodeErr := decodeResponseData(data64)
+				if carriesData(kind) && decodeErr != nil {
+					if err != ErrInvalidResponseData {
+						t.Fatal("invalid data not reported:", err)
+					}
+				} else if err != nil {
+					t.Fatal("valid response failed:", err)
+				}
+				ok, resultErr := received(requests[sequence])
+				if !ok {
+					t.Fatal("valid response was not delivered")
+				}
+				if carriesData(kind) && decodeErr != nil && resultErr != ErrInvalidResponseData {
+					t.Fatal("invalid data not delivered:", resultErr)
+				}
+				delete(kinds, sequence)
+				delete(requests, sequence)
+			}
+
+			// Verify outstanding request accounting
+			if sequences.outstanding() != len(kinds) {
+				t.Fatal("outstanding request count mismatch")
+			}
+		}
+	})
+}
+
+func TestRequestFailIdentifiers(t *testing.T) {
+	failure := errors.New("failure")
+	for kind := 0; kind < responseKindCount; kind++ {
+		request := newRequest(kind)
+		request.fail(failure)
+		id := -1
+		switch c := request.(type) {
+		case connectRequest:
+			id = (<-c).connectionId
+		case connectPacketRequest:
+			id = (<-c).connectionId
+		case listenRequest:
+			id = (<-c).listenerId
+		case listenPacketRequest:
+			id = (<-c).listenerId
+		case listenerAcceptRequest:
+			id = (<-c).connectionId
+		}
+		if id != -1 {
+			t.Errorf("%s failure delivered identifier %d", request.operation(), id)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package ipc
+
+// System imports
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// maximumSequence is the largest sequence issued by a sequencer, after which
+// sequences wrap around to 0.  Sequences are limited to the 32-bit signed
+// integer range because that is what the hosts use to represent them.
+const maximumSequence = 1<<31 - 1
+
+// ErrUnknownSequence is reported when the host responds to a request sequence
+// that isn't outstanding, e.g. because the sequence was never issued, has
+// already received a response, or has timed out.
+var ErrUnknownSequence = errors.New("unknown response sequence")
+
+// ErrSequenceOverlap is reported when a request sequence can't be issued
+// because every sequence is awaiting a response.
+var ErrSequenceOverlap = errors.New("sequence overlap")
+
+// ErrRequestTimeout is delivered to the caller when the host doesn't respond to
+// a request within the request timeout (in which case it is also reported to
+// the error handler).
+var ErrRequestTimeout = errors.New("request timed out")
+
+// SequenceError describes a problem routing a request or response through a
+// sequence-based bridge.  Errors of this type are passed to the bridge's error
+// handler.
+type SequenceError struct {
+	// Operation is the operation being requested or responded to (see the
+	// Operation constants).
+	Operation string
+
+	// Sequence is the request/response sequence.
+	Sequence int
+
+	// Err is the underlying error, e.g. ErrUnknownSequence.
+	Err error
+}
+
+func (e *SequenceError) Error() string {
+	return fmt.Sprintf("%s sequence %d: %v", e.Operation, e.Sequence, e.Err)
+}
+
+// ErrorHandler is a function that handles bridge errors which can't be
+// returned to a caller, e.g. malformed or duplicated host responses.  It is
+// invoked on the goroutine (or JavaScript callback) that encountered the error.
+type ErrorHandler func(error)
+
+// LogErrorHandler is the default ErrorHandler, which logs errors using the
+// standard logger.
+func LogErrorHandler(err error) {
+	log.Println("bridge error:", err)
+}
+
+// PendingRequest describes a request that has been forwarded to the host and is
+// awaiting a response.
+type PendingRequest struct {
+	// Sequence is the request sequence.
+	Sequence int
+
+	// Operation is the requested operation (see the Operation constants).
+	Operation string
+
+	// Issued is the time at which the request was issued.
+	Issued time.Time
+}
+
+// pendingRequest is the interface for typed entries in a sequencer's
+// pending-request table.  Each request type wraps the result channel for its
+// operation, so responders can recover the channel with a type assertion on
+// the entry and can fail any entry without knowing its type.
+type pendingRequest interface {
+	// operation returns the requested operation.
+	operation() string
+
+	// fail delivers an error to the caller.
+	fail(err error)
+}
+
+// pendingEntry is an entry in a sequencer's pending-request table.
+type pendingEntry struct {
+	// The request
+	request pendingRequest
+
+	// The time at which the request was issued
+	issued time.Time
+
+	// The timeout timer, if any
+	timer *time.Timer
+}
+
+// sequencer is a small utility class to manage pending requests for
+// request/response sequences where the response can't be routed by a callback
+// (e.g. if there isn't a way to pass callbacks across the JavaScript/host
+// barrier)
+type sequencer struct {
+	// Lock for the sequence state.  Under GopherJS, there is no parallelism,
+	// but responses may still interleave with requests and timeouts.
+	sync.Mutex
+
+	// The next request/response sequence to use
+	nextSequence int
+
+	// Map from sequence to pending request
+	entries map[int]*pendingEntry
+
+	// The request timeout, or 0 if requests don't time out
+	timeout time.Duration
+
+	// The error handler, or nil to use LogErrorHandler
+	errorHandler ErrorHandler
+}
+
+func newSequencer() *sequencer {
+	return &sequencer{
+		entries: make(map[int]*pendingEntry),
+	}
+}
+
+// push records a pending request and returns its sequence.  Sequences wrap
+// around after maximumSequence, skipping any that are still outstanding.  If
+// every sequence is outstanding, it returns ErrSequenceOverlap, in which case
+// the request isn't recorded.
+func (s *sequencer) push(request pendingRequest) (int, error) {
+	// Lock the sequencer
+	s.Lock()
+	defer s.Unlock()
+
+	// Make sure there's a free sequence
+	if len(s.entries) > maximumSequence {
+		return s.nextSequence, ErrSequenceOverlap
+	}
+
+	// Compute sequence
+	sequence := s.nextSequence
+	for {
+		if _, ok := s.entries[sequence]; !ok {
+			break
+		}
+		sequence = nextSequence(sequence)
+	}
+	s.nextSequence = nextSequence(sequence)
+
+	// Store the entry
+	entry := &pendingEntry{
+		request: request,
+		issued:  time.Now(),
+	}
+	s.entries[sequence] = entry
+
+	// Start the timeout timer, if any
+	if s.timeout > 0 {
+		entry.timer = time.AfterFunc(s.timeout, func() {
+			s.expire(sequence, entry)
+		})
+	}
+
+	// All done
+	return sequence, nil
+}
+
+// nextSequence computes the sequence following the specified sequence.
+func nextSequence(sequence int) int {
+	if sequence >= maximumSequence {
+		return 0
+	}
+	return sequence + 1
+}
+
+// pop removes and returns the pending request for a sequence.  It returns
+// ErrUnknownSequence if the sequence isn't outstanding.
+func (s *sequencer) pop(sequence int) (pendingRequest, error) {
+	// Lock the sequencer
+	s.Lock()
+	defer s.Unlock()
+
+	// Get the entry
+	entry, ok := s.entries[sequence]
+	if !ok {
+		return nil, ErrUnknownSequence
+	}
+
+	// Remove the entry and stop its timer
+	delete(s.entries, sequence)
+	if entry.timer != nil {
+		entry.timer.Stop()
+	}
+
+	// All done
+	return entry.request, nil
+}
+
+// expire fails a request that has timed out, unless it has already received a
+// response.
+func (s *sequencer) expire(sequence int, entry *pendingEntry) {
+	// Remove the entry if it's still pending
+	s.Lock()
+	current, ok := s.entries[sequence]
+	expired := ok && current == entry
+	if expired {
+		delete(s.entries, sequence)
+	}
+	s.Unlock()
+
+	// Fail the request
+	if expired {
+		s.fail(entry.request, sequence, ErrRequestTimeout)
+	}
+}
+
+// outstanding returns the number of requests awaiting responses.
+func (s *sequencer) outstanding() int {
+	// Lock the sequencer
+	s.Lock()
+	defer s.Unlock()
+
+	// Count the entries
+	return len(s.entries)
+}
+
+// pending returns a description of each request awaiting a response, ordered
+// by the time at which they were issued.
+func (s *sequencer) pending() []PendingRequest {
+	// Lock the sequencer
+	s.Lock()
+	defer s.Unlock()
+
+	// Describe the entries
+	result := make([]PendingRequest, 0, len(s.entries))
+	for sequence, entry := range s.entries {
+		result = append(result, PendingRequest{
+			Sequence:  sequence,
+			Operation: entry.request.operation(),
+			Issued:    entry.issued,
+		})
+	}
+
+	// Sort them
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Issued.Equal(result[j].Issued) {
+			return result[i].Sequence < result[j].Sequence
+		}
+		return result[i].Issued.Before(result[j].Issued)
+	})
+
+	// All done
+	return result
+}
+
+// setTimeout sets the request timeout for subsequently issued requests.  A
+// timeout of 0 disables timeouts.
+func (s *sequencer) setTimeout(timeout time.Duration) {
+	// Lock the sequencer
+	s.Lock()
+	defer s.Unlock()
+
+	// Set the timeout
+	s.timeout = timeout
+}
+
+// setErrorHandler sets the error handler.  A nil handler restores the default
+// LogErrorHandler.
+func (s *sequencer) setErrorHandler(handler ErrorHandler) {
+	// Lock the sequencer
+	s.Lock()
+	defer s.Unlock()
+
+	// Set the handler
+	s.errorHandler = handler
+}
+
+// report passes an error for the specified operation and sequence to the error
+// handler.
+func (s *sequencer) report(operation string, sequence int, err error) {
+	// Grab the handler
+	s.Lock()
+	handler := s.errorHandler
+	s.Unlock()
+	if handler == nil {
+		handler = LogErrorHandler
+	}
+
+	// Invoke the handler outside of the lock, in case it issues requests
+	handler(&SequenceError{
+		Operation: operation,
+		Sequence:  sequence,
+		Err:       err,
+	})
+}
+
+// fail reports an error for a request and delivers the error to the request's
+// caller.
+func (s *sequencer) fail(request pendingRequest, sequence int, err error) {
+	s.report(request.operation(), sequence, err)
+	request.fail(err)
+}
@@ -0,0 +1,70 @@
+package ipc
+
+// System imports
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSequencerTimeout(t *testing.T) {
+	// Create a sequencer with a short timeout that records reported errors
+	sequences := newSequencer()
+	sequences.setTimeout(10 * time.Millisecond)
+	reported := make(chan error, 2)
+	sequences.setErrorHandler(func(err error) {
+		reported <- err
+	})
+
+	// Issue a request that receives a response in time and one that doesn't
+	answered := make(connectRequest, 1)
+	answeredSequence, err := sequences.push(answered)
+	if err != nil {
+		t.Fatal("unable to push request:", err)
+	}
+	expired := make(connectRequest, 1)
+	expiredSequence, err := sequences.push(expired)
+	if err != nil {
+		t.Fatal("unable to push request:", err)
+	}
+	if request, err := sequences.pop(answeredSequence); err != nil {
+		t.Fatal("unable to pop request:", err)
+	} else if request != pendingRequest(answered) {
+		t.Fatal("popped request mismatch")
+	}
+
+	// The unanswered request should fail and be reported
+	select {
+	case result := <-expired:
+		if result.err != ErrRequestTimeout || result.connectionId != -1 {
+			t.Error("unexpected result:", result.connectionId, result.err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("request did not time out")
+	}
+	var sequenceErr *SequenceError
+	if err := <-reported; !errors.As(err, &sequenceErr) {
+		t.Fatal("unexpected error type:", err)
+	} else if sequenceErr.Sequence != expiredSequence ||
+		sequenceErr.Operation != OperationConnect ||
+		sequenceErr.Err != ErrRequestTimeout {
+		t.Error("unexpected sequence error:", sequenceErr)
+	}
+
+	// The expired request should no longer be pending, so a late response is
+	// for an unknown sequence, and the answered request shouldn't have failed
+	if sequences.outstanding() != 0 {
+		t.Error("expired request still outstanding")
+	}
+	if _, err := sequences.pop(expiredSequence); err != ErrUnknownSequence {
+		t.Error("unexpected late pop error:", err)
+	}
+	if ok, _ := received(answered); ok {
+		t.Error("answered request received a result")
+	}
+	select {
+	case err := <-reported:
+		t.Error("unexpected reported error:", err)
+	default:
+	}
+}
@@ -0,0 +1,690 @@
+// +build !js
+
+package ipc
+
+// System imports
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// This file implements a shared-memory stream for bulk transfers between
+// native processes.  One side offers a shared memory region over an existing
+// IPC connection and the other accepts it, after which the region holds two
+// single-producer/single-consumer ring buffers (one per direction) and the IPC
+// connection is used only to signal when data or space becomes available.  The
+// region layout is:
+//
+//	[0, 64)                           header (magic, capacity)
+//	[64, 192)                         ring 0 control (offerer to accepter)
+//	[192, 320)                        ring 1 control (accepter to offerer)
+//	[320, 320+capacity)               ring 0 data
+//	[320+capacity, 320+2*capacity)    ring 1 data
+//
+// Each ring control block holds the total number of bytes written (head) and
+// a closed flag in its first cache line and the total number of bytes read
+// (tail) in its second, so that producer and consumer don't contend.
+
+const (
+	// sharedMemoryMagic identifies an initialized shared memory region.
+	sharedMemoryMagic = 0x3130534d48534247 // "GBSHMS01", little-endian
+
+	// sharedMemoryHeaderSize is the size of the region header.
+	sharedMemoryHeaderSize = 64
+
+	// sharedMemoryRingControlSize is the size of each ring control block.
+	sharedMemoryRingControlSize = 128
+
+	// sharedMemoryDataOffset is the offset of the first ring's data.
+	sharedMemoryDataOffset = sharedMemoryHeaderSize +
+		2*sharedMemoryRingControlSize
+
+	// DefaultSharedMemoryCapacity is the per-direction ring capacity used by
+	// OfferSharedMemory when no capacity is specified.
+	DefaultSharedMemoryCapacity = 4 * 1024 * 1024
+
+	// sharedMemoryMaximumCapacity is the largest accepted ring capacity.
+	sharedMemoryMaximumCapacity = 1 << 30
+)
+
+// Signals sent over the IPC connection.
+const (
+	// sharedMemorySignalData indicates that data is available in the
+	// receiver's inbound ring.
+	sharedMemorySignalData = 'd'
+
+	// sharedMemorySignalSpace indicates that space is available in the
+	// receiver's outbound ring.
+	sharedMemorySignalSpace = 's'
+
+	// sharedMemorySignalClose indicates that the sender has closed its
+	// outbound ring.
+	sharedMemorySignalClose = 'c'
+
+	// sharedMemoryAcknowledge is sent by the accepter once it has mapped and
+	// validated the region.
+	sharedMemoryAcknowledge = 'k'
+
+	// sharedMemoryReject is sent by the accepter if it couldn't use the region.
+	sharedMemoryReject = 'x'
+)
+
+// ErrSharedMemoryRejected is returned by OfferSharedMemory when the peer fails
+// to map or validate the offered region.
+var ErrSharedMemoryRejected = errors.New("shared memory region rejected")
+
+// ErrInvalidSharedMemory is returned by AcceptSharedMemory when the offered
+// region or offer message is malformed.
+var ErrInvalidSharedMemory = errors.New("invalid shared memory region")
+
+// ErrSharedMemoryClosed is returned by operations on a closed shared memory
+// connection.
+var ErrSharedMemoryClosed = errors.New("shared memory connection closed")
+
+// sharedMemoryTimeoutError is returned when a shared memory connection's
+// deadline expires.
+type sharedMemoryTimeoutError struct{}
+
+func (sharedMemoryTimeoutError) Error() string {
+	return "shared memory i/o timeout"
+}
+
+func (sharedMemoryTimeoutError) Timeout() bool {
+	return true
+}
+
+func (sharedMemoryTimeoutError) Temporary() bool {
+	return true
+}
+
+// sharedMemoryRegion is a mapped shared memory region.
+type sharedMemoryRegion struct {
+	// The mapped memory
+	data []byte
+
+	// The name used to open the region, if it is opened by name (Windows)
+	name string
+
+	// The file backing the region, if it is passed to the peer as a file
+	// descriptor (POSIX)
+	file *os.File
+
+	// Platform-specific release function, which unmaps the region and
+	// releases any associated handles
+	release func() error
+}
+
+// handedOff releases any resources that were only needed to pass the region to
+// the peer.  The mapping itself remains valid.
+func (r *sharedMemoryRegion) handedOff() {
+	if r.file != nil {
+		r.file.Close()
+		r.file = nil
+	}
+}
+
+// sharedMemoryOffer is the message sent to offer a region.  Alongside it, the
+// platform may pass a handle to the region (e.g. a file descriptor on POSIX).
+type sharedMemoryOffer struct {
+	// The size of the region
+	size uint64
+
+	// The name used to open the region, if any
+	name string
+}
+
+// encode encodes an offer message.
+func (o *sharedMemoryOffer) encode() []byte {
+	message := make([]byte, 10+len(o.name))
+	binary.BigEndian.PutUint64(message[0:8], o.size)
+	binary.BigEndian.PutUint16(message[8:10], uint16(len(o.name)))
+	copy(message[10:], o.name)
+	return message
+}
+
+// decode decodes an offer message from the fixed-size prefix (which may have
+// been read along with a passed handle) and the rest of the connection.
+func (o *sharedMemoryOffer) decode(prefix []byte, connection net.Conn) error {
+	// Parse the fixed-size portion
+	if len(prefix) != 10 {
+		return ErrInvalidSharedMemory
+	}
+	o.size = binary.BigEndian.Uint64(prefix[0:8])
+	nameLength := binary.BigEndian.Uint16(prefix[8:10])
+
+	// Read the name
+	name := make([]byte, nameLength)
+	if _, err := io.ReadFull(connection, name); err != nil {
+		return err
+	}
+	o.name = string(name)
+
+	// All done
+	return nil
+}
+
+// sharedMemoryRegionSize computes the region size for a ring capacity.
+func sharedMemoryRegionSize(capacity int) int {
+	return sharedMemoryDataOffset + 2*capacity
+}
+
+// sharedMemoryRing is one direction of a shared memory connection.
+type sharedMemoryRing struct {
+	// The total number of bytes written
+	head *uint64
+
+	// Whether or not the producer has closed the ring (nonzero if closed)
+	closed *uint32
+
+	// The total number of bytes read
+	tail *uint64
+
+	// The ring data
+	data []byte
+}
+
+// newSharedMemoryRing creates a ring over the specified ring index of a region
+// with the specified capacity.
+func newSharedMemoryRing(memory []byte, index, capacity int) *sharedMemoryRing {
+	control := sharedMemoryHeaderSize + index*sharedMemoryRingControlSize
+	data := sharedMemoryDataOffset + index*capacity
+	return &sharedMemoryRing{
+		head:   (*uint64)(unsafe.Pointer(&memory[control])),
+		closed: (*uint32)(unsafe.Pointer(&memory[control+8])),
+		tail:   (*uint64)(unsafe.Pointer(&memory[control+64])),
+		data:   memory[data : data+capacity : data+capacity],
+	}
+}
+
+// write copies as much of b into the ring as fits, returning the number of
+// bytes copied.  It must only be called by the producer.
+func (r *sharedMemoryRing) write(b []byte) int {
+	// Compute available space
+	head := atomic.LoadUint64(r.head)
+	tail := atomic.LoadUint64(r.tail)
+	capacity := uint64(len(r.data))
+	space := capacity - (head - tail)
+	if space == 0 {
+		return 0
+	}
+	if uint64(len(b)) < space {
+		space = uint64(len(b))
+	}
+
+	// Copy the data, wrapping around the end of the ring if necessary
+	start := head % capacity
+	count := copy(r.data[start:], b[:space])
+	if uint64(count) < space {
+		count += copy(r.data, b[count:space])
+	}
+
+	// Publish the data
+	atomic.StoreUint64(r.head, head+uint64(count))
+
+	// All done
+	return count
+}
+
+// read copies as much data from the ring into b as is available, returning
+// the number of bytes copied.  It must only be called by the consumer.
+func (r *sharedMemoryRing) read(b []byte) int {
+	// Compute available data
+	head := atomic.LoadUint64(r.head)
+	tail := atomic.LoadUint64(r.tail)
+	available := head - tail
+	if available == 0 {
+		return 0
+	}
+	if uint64(len(b)) < available {
+		available = uint64(len(b))
+	}
+
+	// Copy the data, wrapping around the end of the ring if necessary
+	capacity := uint64(len(r.data))
+	start := tail % capacity
+	count := copy(b[:available], r.data[start:])
+	if uint64(count) < available {
+		count += copy(b[count:available], r.data)
+	}
+
+	// Release the space
+	atomic.StoreUint64(r.tail, tail+uint64(count))
+
+	// All done
+	return count
+}
+
+// empty returns whether or not the ring has no data available.
+func (r *sharedMemoryRing) empty() bool {
+	return atomic.LoadUint64(r.head) == atomic.LoadUint64(r.tail)
+}
+
+// close marks the ring as closed by the producer.
+func (r *sharedMemoryRing) close() {
+	atomic.StoreUint32(r.closed, 1)
+}
+
+// isClosed returns whether or not the producer has closed the ring.
+func (r *sharedMemoryRing) isClosed() bool {
+	return atomic.LoadUint32(r.closed) != 0
+}
+
+// sharedMemoryConn implements net.Conn over a pair of shared memory rings,
+// using an IPC connection for signalling.
+type sharedMemoryConn struct {
+	// The IPC connection used for signalling
+	signal net.Conn
+
+	// The mapped region
+	region *sharedMemoryRegion
+
+	// The ring carrying data to the peer
+	outbound *sharedMemoryRing
+
+	// The ring carrying data from the peer
+	inbound *sharedMemoryRing
+
+	// Notification channels (with single-item buffers) for inbound data and
+	// outbound space
+	readable chan struct{}
+	writable chan struct{}
+
+	// Channel closed when the peer closes the signalling connection
+	peerGone chan struct{}
+
+	// Channel closed when the connection is closed locally
+	closed chan struct{}
+
+	// Ensures the connection is only closed once
+	closeOnce sync.Once
+
+	// Locks serializing reads, writes, and signal sends
+	readLock   sync.Mutex
+	writeLock  sync.Mutex
+	signalLock sync.Mutex
+
+	// Lock for deadlines
+	deadlineLock sync.Mutex
+
+	// The read and write deadlines
+	readDeadline  time.Time
+	writeDeadline time.Time
+
+	// Channel closed (and replaced) whenever a deadline changes, so that
+	// blocked operations can pick up the new deadline
+	deadlineChanged chan struct{}
+}
+
+// newSharedMemoryConn creates a connection over a mapped region and starts
+// its signal dispatcher.
+func newSharedMemoryConn(
+	signal net.Conn,
+	region *sharedMemoryRegion,
+	capacity int,
+	offerer bool,
+) *sharedMemoryConn {
+	// Determine ring assignments
+	outboundIndex, inboundIndex := 0, 1
+	if !offerer {
+		outboundIndex, inboundIndex = 1, 0
+	}
+
+	// Create the connection
+	connection := &sharedMemoryConn{
+		signal:   signal,
+		region:   region,
+		outbound: newSharedMemoryRing(region.data, outboundIndex, capacity),
+		inbound:  newSharedMemoryRing(region.data, inboundIndex, capacity),
+		readable: make(chan struct{}, 1),
+		writable: make(chan struct{}, 1),
+		peerGone: make(chan struct{}),
+		closed:   make(chan struct{}),
+
+		deadlineChanged: make(chan struct{}),
+	}
+
+	// Start dispatching signals
+	go connection.dispatch()
+
+	// All done
+	return connection
+}
+
+// notify performs a non-blocking send on a notification channel.
+func notify(channel chan struct{}) {
+	select {
+	case channel <- struct{}{}:
+	default:
+	}
+}
+
+// dispatch reads signals from the IPC connection and converts them to
+// notifications until the connection fails or is closed.
+func (c *sharedMemoryConn) dispatch() {
+	buffer := make([]byte, 256)
+	for {
+		count, err := c.signal.Read(buffer)
+		for _, signal := range buffer[:count] {
+			switch signal {
+			case sharedMemorySignalData, sharedMemorySignalClose:
+				notify(c.readable)
+			case sharedMemorySignalSpace:
+				notify(c.writable)
+			}
+		}
+		if err != nil {
+			close(c.peerGone)
+			return
+		}
+	}
+}
+
+// send sends a signal to the peer.
+func (c *sharedMemoryConn) send(signal byte) error {
+	c.signalLock.Lock()
+	defer c.signalLock.Unlock()
+	_, err := c.signal.Write([]byte{signal})
+	return err
+}
+
+// wait waits for a notification, returning an error if the connection is
+// closed or the read or write deadline (as selected by read) expires.  It also
+// returns (without error) if the peer has gone away or the deadline changes,
+// so the caller should re-check the connection state.
+func (c *sharedMemoryConn) wait(notification chan struct{}, read bool) error {
+	// Grab the current deadline
+	c.deadlineLock.Lock()
+	deadline := c.writeDeadline
+	if read {
+		deadline = c.readDeadline
+	}
+	deadlineChanged := c.deadlineChanged
+	c.deadlineLock.Unlock()
+
+	// Set up the deadline timer, if any
+	var expired <-chan time.Time
+	if !deadline.IsZero() {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return sharedMemoryTimeoutError{}
+		}
+		timer := time.NewTimer(remaining)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	// Wait
+	select {
+	case <-notification:
+		return nil
+	case <-c.peerGone:
+		return nil
+	case <-deadlineChanged:
+		return nil
+	case <-c.closed:
+		return ErrSharedMemoryClosed
+	case <-expired:
+		return sharedMemoryTimeoutError{}
+	}
+}
+
+func (c *sharedMemoryConn) Read(b []byte) (int, error) {
+	// Serialize reads
+	c.readLock.Lock()
+	defer c.readLock.Unlock()
+
+	// Handle empty reads
+	if len(b) == 0 {
+		return 0, nil
+	}
+
+	// Wait for data
+	for {
+		// Check for local closure
+		select {
+		case <-c.closed:
+			return 0, ErrSharedMemoryClosed
+		default:
+		}
+
+		// Attempt to read, notifying the peer of the freed space
+		if count := c.inbound.read(b); count > 0 {
+			c.send(sharedMemorySignalSpace)
+			return count, nil
+		}
+
+		// If the ring is drained and the peer has closed it (or vanished),
+		// then we've reached the end of the stream.  We need to re-check that
+		// the ring is empty after observing closure, since the peer may have
+		// written data just before closing.
+		peerGone := false
+		select {
+		case <-c.peerGone:
+			peerGone = true
+		default:
+		}
+		if (peerGone || c.inbound.isClosed()) && c.inbound.empty() {
+			return 0, io.EOF
+		}
+
+		// Wait for a notification.  If the peer has vanished, there won't be
+		// any more, but the loop will terminate on the next pass.
+		if !peerGone {
+			if err := c.wait(c.readable, true); err != nil {
+				return 0, err
+			}
+		}
+	}
+}
+
+func (c *sharedMemoryConn) Write(b []byte) (int, error) {
+	// Serialize writes
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+
+	// Write until all data is in the ring
+	written := 0
+	for written < len(b) {
+		// Check for local closure, then for the peer going away
+		select {
+		case <-c.closed:
+			return written, ErrSharedMemoryClosed
+		default:
+		}
+		select {
+		case <-c.peerGone:
+			return written, io.ErrClosedPipe
+		default:
+		}
+
+		// Attempt to write, notifying the peer of the new data
+		if count := c.outbound.write(b[written:]); count > 0 {
+			written += count
+			if err := c.send(sharedMemorySignalData); err != nil {
+				return written, err
+			}
+			continue
+		}
+
+		// Wait for space
+		if err := c.wait(c.writable, false); err != nil {
+			return written, err
+		}
+	}
+
+	// All done
+	return written, nil
+}
+
+// Close closes the connection.  Data already written remains readable by the
+// peer, which will see the end of the stream once it is drained.  The
+// underlying IPC connection is also closed.
+func (c *sharedMemoryConn) Close() error {
+	err := ErrSharedMemoryClosed
+	c.closeOnce.Do(func() {
+		// Mark our outbound ring as closed and let the peer know
+		c.outbound.close()
+		c.send(sharedMemorySignalClose)
+
+		// Unblock any local operations
+		close(c.closed)
+
+		// Wait for in-flight operations to finish with the region before
+		// releasing it
+		c.readLock.Lock()
+		c.writeLock.Lock()
+		err = c.signal.Close()
+		if releaseErr := c.region.release(); err == nil {
+			err = releaseErr
+		}
+		c.writeLock.Unlock()
+		c.readLock.Unlock()
+	})
+	return err
+}
+
+func (c *sharedMemoryConn) LocalAddr() net.Addr {
+	return c.signal.LocalAddr()
+}
+
+func (c *sharedMemoryConn) RemoteAddr() net.Addr {
+	return c.signal.RemoteAddr()
+}
+
+// setDeadlines updates the read and/or write deadlines and wakes any blocked
+// operations so that they pick up the change.
+func (c *sharedMemoryConn) setDeadlines(t time.Time, read, write bool) {
+	c.deadlineLock.Lock()
+	defer c.deadlineLock.Unlock()
+	if read {
+		c.readDeadline = t
+	}
+	if write {
+		c.writeDeadline = t
+	}
+	close(c.deadlineChanged)
+	c.deadlineChanged = make(chan struct{})
+}
+
+func (c *sharedMemoryConn) SetDeadline(t time.Time) error {
+	c.setDeadlines(t, true, true)
+	return nil
+}
+
+func (c *sharedMemoryConn) SetReadDeadline(t time.Time) error {
+	c.setDeadlines(t, true, false)
+	return nil
+}
+
+func (c *sharedMemoryConn) SetWriteDeadline(t time.Time) error {
+	c.setDeadlines(t, false, true)
+	return nil
+}
+
+// OfferSharedMemory creates a shared memory region with the specified
+// per-direction capacity (or DefaultSharedMemoryCapacity if capacity is 0) and
+// offers it to the peer of an existing IPC connection, which must call
+// AcceptSharedMemory.  On success, it returns a net.Conn that transfers data
+// through the region, using the IPC connection only for signalling, so the IPC
+// connection must not be used directly afterward (it is closed along with the
+// returned connection).  On Linux, the region is created with memfd_create and
+// on other POSIX platforms with an unlinked temporary file, and in both cases
+// it is passed to the peer as a file descriptor, so the IPC connection must be
+// one returned by DialIPC or ListenIPC.  On Linux, the memfd's size is sealed
+// before it is offered and AcceptSharedMemory rejects regions whose size isn't
+// sealed, so neither side can truncate the region out from under the other's
+// mapping.  Temporary files can't be sealed, so on other POSIX platforms (and
+// on Linux kernels without memfd_create), each side trusts the peer not to
+// truncate the file, which would cause SIGBUS on access.  On Windows, the
+// region is a named file mapping in the session namespace, whose size is fixed
+// at creation.
+func OfferSharedMemory(connection net.Conn, capacity int) (net.Conn, error) {
+	// Validate the capacity
+	if capacity == 0 {
+		capacity = DefaultSharedMemoryCapacity
+	} else if capacity < 0 || capacity > sharedMemoryMaximumCapacity {
+		return nil, errors.New("invalid shared memory capacity")
+	}
+
+	// Create the region and initialize its header
+	size := sharedMemoryRegionSize(capacity)
+	region, err := createSharedMemoryRegion(size)
+	if err != nil {
+		return nil, err
+	}
+	binary.LittleEndian.PutUint64(region.data[0:8], sharedMemoryMagic)
+	binary.LittleEndian.PutUint64(region.data[8:16], uint64(capacity))
+
+	// Send the offer
+	offer := &sharedMemoryOffer{size: uint64(size), name: region.name}
+	if err := sendSharedMemoryOffer(connection, offer, region); err != nil {
+		region.release()
+		return nil, err
+	}
+
+	// Wait for the acknowledgement
+	response := make([]byte, 1)
+	if _, err := io.ReadFull(connection, response); err != nil {
+		region.release()
+		return nil, err
+	} else if response[0] != sharedMemoryAcknowledge {
+		region.release()
+		return nil, ErrSharedMemoryRejected
+	}
+
+	// Now that the peer has mapped the region, release any handles that were
+	// only needed for the handoff
+	region.handedOff()
+
+	// Create the connection
+	return newSharedMemoryConn(connection, region, capacity, true), nil
+}
+
+// AcceptSharedMemory accepts a shared memory region offered by the peer of an
+// existing IPC connection using OfferSharedMemory, returning a net.Conn that
+// transfers data through the region.  The same restrictions on the IPC
+// connection apply as for OfferSharedMemory.
+func AcceptSharedMemory(connection net.Conn) (net.Conn, error) {
+	// Receive and map the region
+	region, err := receiveSharedMemoryOffer(connection)
+	if err != nil {
+		connection.Write([]byte{sharedMemoryReject})
+		return nil, err
+	}
+
+	// Validate the region
+	var capacity int
+	if len(region.data) < sharedMemoryDataOffset {
+		err = ErrInvalidSharedMemory
+	} else if binary.LittleEndian.Uint64(region.data[0:8]) != sharedMemoryMagic {
+		err = ErrInvalidSharedMemory
+	} else {
+		encoded := binary.LittleEndian.Uint64(region.data[8:16])
+		if encoded == 0 || encoded > sharedMemoryMaximumCapacity ||
+			sharedMemoryRegionSize(int(encoded)) != len(region.data) {
+			err = ErrInvalidSharedMemory
+		}
+		capacity = int(encoded)
+	}
+	if err != nil {
+		region.release()
+		connection.Write([]byte{sharedMemoryReject})
+		return nil, err
+	}
+
+	// Acknowledge the region
+	if _, err := connection.Write([]byte{sharedMemoryAcknowledge}); err != nil {
+		region.release()
+		return nil, err
+	}
+
+	// Create the connection
+	return newSharedMemoryConn(connection, region, capacity, false), nil
+}
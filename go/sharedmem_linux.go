@@ -0,0 +1,94 @@
+// +build linux,!js
+
+package ipc
+
+// System imports
+import (
+	"os"
+	"sync"
+)
+
+// Extended system imports
+import "golang.org/x/sys/unix"
+
+// sharedMemorySizeSeals are the seals that fix the size of a shared memory
+// file, so that the peer can't shrink it out from under our mapping (which
+// would result in SIGBUS on access).
+const sharedMemorySizeSeals = unix.F_SEAL_SHRINK | unix.F_SEAL_GROW
+
+// memfdSupport records whether or not the kernel supports memfd_create.
+var memfdSupport struct {
+	sync.Once
+	supported bool
+}
+
+// memfdSupported returns whether or not the kernel supports memfd_create.
+func memfdSupported() bool {
+	memfdSupport.Do(func() {
+		descriptor, err := unix.MemfdCreate("gib-probe", unix.MFD_CLOEXEC)
+		if err == nil {
+			unix.Close(descriptor)
+		}
+		memfdSupport.supported = err != unix.ENOSYS
+	})
+	return memfdSupport.supported
+}
+
+// createSharedMemoryFile creates an anonymous file to back a shared memory
+// region using memfd_create, falling back to an unlinked temporary file on
+// kernels that don't support it.
+func createSharedMemoryFile() (*os.File, error) {
+	// Attempt to create a memfd
+	descriptor, err := unix.MemfdCreate(
+		"gib-shm",
+		unix.MFD_CLOEXEC|unix.MFD_ALLOW_SEALING,
+	)
+	if err == nil {
+		return os.NewFile(uintptr(descriptor), "gib-shm"), nil
+	} else if err != unix.ENOSYS {
+		return nil, err
+	}
+
+	// Fall back to a temporary file
+	return createTemporarySharedMemoryFile()
+}
+
+// sealSharedMemoryFile seals the size of a sized shared memory file, and
+// prevents any further changes to its seals.  Temporary files (used on kernels
+// without memfd_create) can't be sealed, so they're left as is.
+func sealSharedMemoryFile(file *os.File) error {
+	// Apply the seals
+	_, err := unix.FcntlInt(
+		file.Fd(),
+		unix.F_ADD_SEALS,
+		sharedMemorySizeSeals|unix.F_SEAL_SEAL,
+	)
+	if err == unix.EINVAL && !memfdSupported() {
+		return nil
+	}
+
+	// All done
+	return err
+}
+
+// verifySharedMemoryFile verifies that a shared memory file received from the
+// peer has its size sealed.  Unsealed files are only accepted on kernels
+// without memfd_create, where the peer couldn't have created a sealed file and
+// is trusted not to truncate it.
+func verifySharedMemoryFile(file *os.File) error {
+	// Query the seals
+	seals, err := unix.FcntlInt(file.Fd(), unix.F_GET_SEALS, 0)
+	if err == unix.EINVAL && !memfdSupported() {
+		return nil
+	} else if err != nil {
+		return ErrInvalidSharedMemory
+	}
+
+	// Verify that the size is sealed
+	if seals&sharedMemorySizeSeals != sharedMemorySizeSeals {
+		return ErrInvalidSharedMemory
+	}
+
+	// All done
+	return nil
+}
@@ -0,0 +1,68 @@
+// +build linux,!js
+
+package ipc
+
+// System imports
+import (
+	"encoding/binary"
+	"os"
+	"testing"
+)
+
+// Extended system imports
+import "golang.org/x/sys/unix"
+
+func TestSharedMemorySealed(t *testing.T) {
+	if !memfdSupported() {
+		t.Skip("memfd_create not supported")
+	}
+
+	// Offered regions should have their size sealed
+	region, err := createSharedMemoryRegion(sharedMemoryRegionSize(1024))
+	if err != nil {
+		t.Fatal("unable to create region:", err)
+	}
+	defer region.release()
+	if err := verifySharedMemoryFile(region.file); err != nil {
+		t.Fatal("region not sealed:", err)
+	} else if err := region.file.Truncate(0); err == nil {
+		t.Fatal("sealed region truncated")
+	}
+}
+
+func TestSharedMemoryRejectsUnsealed(t *testing.T) {
+	if !memfdSupported() {
+		t.Skip("memfd_create not supported")
+	}
+	offerSide, acceptSide := socketPair(t)
+	defer offerSide.Close()
+	defer acceptSide.Close()
+
+	// Create an otherwise valid region backed by an unsealed memfd
+	capacity := 1024
+	size := sharedMemoryRegionSize(capacity)
+	descriptor, err := unix.MemfdCreate("gib-test", unix.MFD_CLOEXEC)
+	if err != nil {
+		t.Fatal("unable to create memfd:", err)
+	} else if err := unix.Ftruncate(descriptor, int64(size)); err != nil {
+		t.Fatal("unable to size memfd:", err)
+	}
+	file := os.NewFile(uintptr(descriptor), "gib-test")
+	region, err := mapSharedMemoryFile(file, size)
+	if err != nil {
+		t.Fatal("unable to map region:", err)
+	}
+	region.file = file
+	defer region.release()
+	binary.LittleEndian.PutUint64(region.data[0:8], sharedMemoryMagic)
+	binary.LittleEndian.PutUint64(region.data[8:16], uint64(capacity))
+
+	// Offer it, which should be refused
+	offer := &sharedMemoryOffer{size: uint64(size)}
+	if err := sendSharedMemoryOffer(offerSide, offer, region); err != nil {
+		t.Fatal("unable to send offer:", err)
+	}
+	if _, err := AcceptSharedMemory(acceptSide); err != ErrInvalidSharedMemory {
+		t.Fatal("unsealed region accepted:", err)
+	}
+}
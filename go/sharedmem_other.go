@@ -0,0 +1,27 @@
+// +build !linux,!windows,!js
+
+package ipc
+
+// System imports
+import (
+	"os"
+)
+
+// createSharedMemoryFile creates an anonymous file to back a shared memory
+// region.  Without memfd_create, this is an unlinked temporary file.
+func createSharedMemoryFile() (*os.File, error) {
+	return createTemporarySharedMemoryFile()
+}
+
+// sealSharedMemoryFile is a no-op, since file sealing isn't available on this
+// platform.
+func sealSharedMemoryFile(file *os.File) error {
+	return nil
+}
+
+// verifySharedMemoryFile is a no-op, since file sealing isn't available on
+// this platform.  The peer is trusted not to truncate the file after offering
+// it.
+func verifySharedMemoryFile(file *os.File) error {
+	return nil
+}
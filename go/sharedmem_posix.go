@@ -0,0 +1,158 @@
+// +build !windows,!js
+
+package ipc
+
+// System imports
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"syscall"
+)
+
+// createSharedMemoryRegion creates and maps a shared memory region of the
+// specified size.  The backing file's size is sealed where supported, and the
+// file is retained in the region so that it can be passed to the peer.
+func createSharedMemoryRegion(size int) (*sharedMemoryRegion, error) {
+	// Create the backing file
+	file, err := createSharedMemoryFile()
+	if err != nil {
+		return nil, err
+	}
+
+	// Size the file
+	if err := file.Truncate(int64(size)); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	// Seal the size
+	if err := sealSharedMemoryFile(file); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	// Map the file
+	region, err := mapSharedMemoryFile(file, size)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	region.file = file
+
+	// All done
+	return region, nil
+}
+
+// mapSharedMemoryFile maps a file as a shared memory region.
+func mapSharedMemoryFile(file *os.File, size int) (*sharedMemoryRegion, error) {
+	// Map the file
+	data, err := syscall.Mmap(
+		int(file.Fd()),
+		0,
+		size,
+		syscall.PROT_READ|syscall.PROT_WRITE,
+		syscall.MAP_SHARED,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the region
+	region := &sharedMemoryRegion{data: data}
+	region.release = func() error {
+		region.handedOff()
+		return syscall.Munmap(data)
+	}
+
+	// All done
+	return region, nil
+}
+
+// sendSharedMemoryOffer sends an offer message to the peer along with the
+// region's file descriptor.
+func sendSharedMemoryOffer(
+	connection net.Conn,
+	offer *sharedMemoryOffer,
+	region *sharedMemoryRegion,
+) error {
+	_, err := WriteWithFiles(
+		connection,
+		offer.encode(),
+		[]*os.File{region.file},
+	)
+	return err
+}
+
+// receiveSharedMemoryOffer receives an offer message and file descriptor from
+// the peer and maps the region.
+func receiveSharedMemoryOffer(connection net.Conn) (*sharedMemoryRegion, error) {
+	// Receive the fixed-size portion of the offer message along with the file.
+	// The file is guaranteed to arrive with the first byte of the message, but
+	// the remainder of the fixed-size portion may arrive separately.
+	prefix := make([]byte, 10)
+	count, files, err := ReadWithFiles(connection, prefix, 1)
+	if err != nil {
+		for _, f := range files {
+			f.Close()
+		}
+		return nil, err
+	} else if len(files) != 1 {
+		for _, f := range files {
+			f.Close()
+		}
+		return nil, ErrInvalidSharedMemory
+	}
+	file := files[0]
+	defer file.Close()
+	if count < len(prefix) {
+		if _, err := io.ReadFull(connection, prefix[count:]); err != nil {
+			return nil, err
+		}
+	}
+
+	// Decode the offer
+	var offer sharedMemoryOffer
+	if err := offer.decode(prefix, connection); err != nil {
+		return nil, err
+	}
+
+	// Verify that the file's size is sealed (where supported) and that it is
+	// large enough to back the offered region, since mapping beyond the end of
+	// the file would result in SIGBUS on access
+	if err := verifySharedMemoryFile(file); err != nil {
+		return nil, err
+	}
+	if offer.size < sharedMemoryDataOffset ||
+		offer.size > uint64(sharedMemoryRegionSize(sharedMemoryMaximumCapacity)) {
+		return nil, ErrInvalidSharedMemory
+	}
+	if info, err := file.Stat(); err != nil {
+		return nil, err
+	} else if info.Size() < int64(offer.size) {
+		return nil, ErrInvalidSharedMemory
+	}
+
+	// Map the file.  The mapping remains valid after the file is closed.
+	return mapSharedMemoryFile(file, int(offer.size))
+}
+
+// createTemporarySharedMemoryFile creates an unlinked temporary file to back a
+// shared memory region.
+func createTemporarySharedMemoryFile() (*os.File, error) {
+	// Create the file
+	file, err := ioutil.TempFile("", "gib-shm-")
+	if err != nil {
+		return nil, err
+	}
+
+	// Unlink it so that it only exists as long as it's open or mapped
+	if err := os.Remove(file.Name()); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	// All done
+	return file, nil
+}
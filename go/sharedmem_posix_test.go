@@ -0,0 +1,224 @@
+// +build !windows,!js
+
+package ipc
+
+// System imports
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// socketPair creates a pair of connected Unix domain sockets.
+func socketPair(t *testing.T) (net.Conn, net.Conn) {
+	descriptors, err := syscall.Socketpair(
+		syscall.AF_UNIX,
+		syscall.SOCK_STREAM,
+		0,
+	)
+	if err != nil {
+		t.Fatal("unable to create socket pair:", err)
+	}
+	var connections [2]net.Conn
+	for i, descriptor := range descriptors {
+		file := os.NewFile(uintptr(descriptor), "socketpair")
+		connections[i], err = net.FileConn(file)
+		file.Close()
+		if err != nil {
+			t.Fatal("unable to wrap socket:", err)
+		}
+	}
+	return connections[0], connections[1]
+}
+
+// sharedMemoryPair creates a pair of shared memory connections with the
+// specified capacity that are closed when the test completes.
+func sharedMemoryPair(t *testing.T, capacity int) (net.Conn, net.Conn) {
+	offerSide, acceptSide := socketPair(t)
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		connection, err := AcceptSharedMemory(acceptSide)
+		if err != nil {
+			t.Error("unable to accept shared memory:", err)
+		}
+		accepted <- connection
+	}()
+	offerer, err := OfferSharedMemory(offerSide, capacity)
+	if err != nil {
+		t.Fatal("unable to offer shared memory:", err)
+	}
+	accepter := <-accepted
+	if accepter == nil {
+		t.FailNow()
+	}
+	t.Cleanup(func() {
+		offerer.Close()
+		accepter.Close()
+	})
+	return offerer, accepter
+}
+
+func TestSharedMemoryWraparound(t *testing.T) {
+	// Use a small ring so that transfers wrap many times
+	offerer, accepter := sharedMemoryPair(t, 61)
+
+	// Send random data in both directions with irregular write sizes
+	data := make([]byte, 64*1024)
+	rand.Read(data)
+	for _, pair := range [][2]net.Conn{{offerer, accepter}, {accepter, offerer}} {
+		writer, reader := pair[0], pair[1]
+		errs := make(chan error, 1)
+		go func() {
+			for remaining := data; len(remaining) > 0; {
+				size := 1 + rand.Intn(150)
+				if size > len(remaining) {
+					size = len(remaining)
+				}
+				if _, err := writer.Write(remaining[:size]); err != nil {
+					errs <- err
+					return
+				}
+				remaining = remaining[size:]
+			}
+			errs <- nil
+		}()
+		received := make([]byte, len(data))
+		if _, err := io.ReadFull(reader, received); err != nil {
+			t.Fatal("unable to read data:", err)
+		} else if err := <-errs; err != nil {
+			t.Fatal("unable to write data:", err)
+		} else if !bytes.Equal(received, data) {
+			t.Fatal("received data doesn't match")
+		}
+	}
+}
+
+func TestSharedMemoryCloseDrains(t *testing.T) {
+	offerer, accepter := sharedMemoryPair(t, 1024)
+
+	// Write data and close immediately
+	data := []byte("data written before close")
+	if _, err := offerer.Write(data); err != nil {
+		t.Fatal("unable to write data:", err)
+	} else if err := offerer.Close(); err != nil {
+		t.Fatal("unable to close connection:", err)
+	}
+
+	// The peer should still receive the data, followed by the end of stream
+	received, err := ioutil.ReadAll(accepter)
+	if err != nil {
+		t.Fatal("unable to read data:", err)
+	} else if !bytes.Equal(received, data) {
+		t.Fatal("received data doesn't match:", string(received))
+	}
+
+	// Writes to a closed peer should fail, as should local operations after
+	// closure
+	if _, err := accepter.Write(data); err == nil {
+		t.Fatal("write to closed peer succeeded")
+	}
+	if _, err := offerer.Read(make([]byte, 1)); err != ErrSharedMemoryClosed {
+		t.Fatal("unexpected read error after close:", err)
+	}
+}
+
+// isTimeoutError returns whether or not an error is a net.Error timeout.
+func isTimeoutError(err error) bool {
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
+
+func TestSharedMemoryDeadlines(t *testing.T) {
+	offerer, accepter := sharedMemoryPair(t, 64)
+
+	// An expired deadline should fail immediately
+	accepter.SetReadDeadline(time.Now().Add(-time.Second))
+	if _, err := accepter.Read(make([]byte, 1)); !isTimeoutError(err) {
+		t.Fatal("unexpected error with expired deadline:", err)
+	}
+
+	// Setting a deadline should wake a read that's already blocked
+	accepter.SetReadDeadline(time.Time{})
+	errs := make(chan error, 1)
+	go func() {
+		_, err := accepter.Read(make([]byte, 1))
+		errs <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	accepter.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	select {
+	case err := <-errs:
+		if !isTimeoutError(err) {
+			t.Fatal("unexpected read error:", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("blocked read not woken by deadline")
+	}
+
+	// The same goes for a write that's blocked on a full ring
+	go func() {
+		_, err := offerer.Write(make([]byte, 128))
+		errs <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	offerer.SetDeadline(time.Now().Add(50 * time.Millisecond))
+	select {
+	case err := <-errs:
+		if !isTimeoutError(err) {
+			t.Fatal("unexpected write error:", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("blocked write not woken by deadline")
+	}
+
+	// Clearing the deadlines should make the connection usable again
+	accepter.SetReadDeadline(time.Time{})
+	received := make([]byte, 64)
+	if _, err := io.ReadFull(accepter, received); err != nil {
+		t.Fatal("unable to read after deadline:", err)
+	}
+}
+
+func TestSharedMemoryRejectedOffer(t *testing.T) {
+	// A peer that refuses the region should cause the offer to fail
+	offerSide, acceptSide := socketPair(t)
+	defer offerSide.Close()
+	defer acceptSide.Close()
+	go func() {
+		_, files, _ := ReadWithFiles(acceptSide, make([]byte, 10), 1)
+		for _, f := range files {
+			f.Close()
+		}
+		acceptSide.Write([]byte{sharedMemoryReject})
+	}()
+	if _, err := OfferSharedMemory(offerSide, 1024); err != ErrSharedMemoryRejected {
+		t.Fatal("unexpected offer error:", err)
+	}
+
+	// An offered region without a valid header should be rejected by the
+	// accepter, which should notify the offerer
+	region, err := createSharedMemoryRegion(sharedMemoryRegionSize(1024))
+	if err != nil {
+		t.Fatal("unable to create region:", err)
+	}
+	defer region.release()
+	offer := &sharedMemoryOffer{size: uint64(len(region.data))}
+	if err := sendSharedMemoryOffer(offerSide, offer, region); err != nil {
+		t.Fatal("unable to send offer:", err)
+	}
+	if _, err := AcceptSharedMemory(acceptSide); err != ErrInvalidSharedMemory {
+		t.Fatal("unexpected accept error:", err)
+	}
+	response := make([]byte, 1)
+	if _, err := io.ReadFull(offerSide, response); err != nil {
+		t.Fatal("unable to read response:", err)
+	} else if response[0] != sharedMemoryReject {
+		t.Fatal("unexpected response:", response[0])
+	}
+}
@@ -0,0 +1,183 @@
+// +build windows,!js
+
+package ipc
+
+// System imports
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// Windows API functions not provided by the syscall package
+var (
+	procOpenFileMappingW = modkernel32.NewProc("OpenFileMappingW")
+	procVirtualQuery     = modkernel32.NewProc("VirtualQuery")
+)
+
+// memoryBasicInformation is the MEMORY_BASIC_INFORMATION structure.
+type memoryBasicInformation struct {
+	BaseAddress       uintptr
+	AllocationBase    uintptr
+	AllocationProtect uint32
+	RegionSize        uintptr
+	State             uint32
+	Protect           uint32
+	Type              uint32
+}
+
+// fileMapAllAccess is the FILE_MAP_ALL_ACCESS access right.
+const fileMapAllAccess = 0xf001f
+
+// sharedMemoryNamePrefix is the prefix for shared memory mapping names.  The
+// mappings are created in the session-local namespace.
+const sharedMemoryNamePrefix = "Local\\gib-shm-"
+
+// mapSharedMemoryView maps a view of a file mapping as a shared memory region,
+// taking ownership of the mapping handle.
+func mapSharedMemoryView(
+	mapping syscall.Handle,
+	name string,
+	size int,
+) (*sharedMemoryRegion, error) {
+	// Map the view
+	address, err := syscall.MapViewOfFile(mapping, fileMapAllAccess, 0, 0, 0)
+	if err != nil {
+		syscall.CloseHandle(mapping)
+		return nil, err
+	}
+
+	// Create a slice over the view
+	var data []byte
+	header := (*reflect.SliceHeader)(unsafe.Pointer(&data))
+	header.Data = address
+	header.Len = size
+	header.Cap = size
+
+	// Create the region
+	region := &sharedMemoryRegion{
+		data: data,
+		name: name,
+	}
+	region.release = func() error {
+		err := syscall.UnmapViewOfFile(address)
+		if closeErr := syscall.CloseHandle(mapping); err == nil {
+			err = closeErr
+		}
+		return err
+	}
+
+	// All done
+	return region, nil
+}
+
+// createSharedMemoryRegion creates and maps a shared memory region of the
+// specified size, backed by the paging file.  The mapping is given a random
+// name so that the peer can open it.
+func createSharedMemoryRegion(size int) (*sharedMemoryRegion, error) {
+	// Generate a name
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	name := sharedMemoryNamePrefix + hex.EncodeToString(nonce)
+	name16, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the mapping
+	mapping, err := syscall.CreateFileMapping(
+		syscall.InvalidHandle,
+		nil,
+		syscall.PAGE_READWRITE,
+		uint32(uint64(size)>>32),
+		uint32(size),
+		name16,
+	)
+	if err != nil {
+		return nil, err
+	} else if syscall.GetLastError() == syscall.ERROR_ALREADY_EXISTS {
+		syscall.CloseHandle(mapping)
+		return nil, errors.New("shared memory mapping name collision")
+	}
+
+	// Map it
+	return mapSharedMemoryView(mapping, name, size)
+}
+
+// sendSharedMemoryOffer sends an offer message containing the mapping name to
+// the peer.
+func sendSharedMemoryOffer(
+	connection net.Conn,
+	offer *sharedMemoryOffer,
+	region *sharedMemoryRegion,
+) error {
+	_, err := connection.Write(offer.encode())
+	return err
+}
+
+// receiveSharedMemoryOffer receives an offer message from the peer and opens
+// and maps the named region.
+func receiveSharedMemoryOffer(connection net.Conn) (*sharedMemoryRegion, error) {
+	// Receive and decode the offer
+	prefix := make([]byte, 10)
+	if _, err := io.ReadFull(connection, prefix); err != nil {
+		return nil, err
+	}
+	var offer sharedMemoryOffer
+	if err := offer.decode(prefix, connection); err != nil {
+		return nil, err
+	}
+
+	// Validate the offer.  We only open mappings in our own namespace.
+	if offer.size < sharedMemoryDataOffset ||
+		offer.size > uint64(sharedMemoryRegionSize(sharedMemoryMaximumCapacity)) {
+		return nil, ErrInvalidSharedMemory
+	} else if !strings.HasPrefix(offer.name, sharedMemoryNamePrefix) {
+		return nil, ErrInvalidSharedMemory
+	}
+	name16, err := syscall.UTF16PtrFromString(offer.name)
+	if err != nil {
+		return nil, ErrInvalidSharedMemory
+	}
+
+	// Open the mapping
+	result, _, err := procOpenFileMappingW.Call(
+		fileMapAllAccess,
+		0,
+		uintptr(unsafe.Pointer(name16)),
+	)
+	if result == 0 {
+		return nil, err
+	}
+	mapping := syscall.Handle(result)
+
+	// Verify that the mapping is large enough to back the offered region
+	region, err := mapSharedMemoryView(mapping, offer.name, int(offer.size))
+	if err != nil {
+		return nil, err
+	}
+	var information memoryBasicInformation
+	result, _, err = procVirtualQuery.Call(
+		uintptr(unsafe.Pointer(&region.data[0])),
+		uintptr(unsafe.Pointer(&information)),
+		unsafe.Sizeof(information),
+	)
+	if result == 0 {
+		region.release()
+		return nil, err
+	} else if uint64(information.RegionSize) < offer.size {
+		region.release()
+		return nil, ErrInvalidSharedMemory
+	}
+
+	// All done
+	return region, nil
+}
@@ -0,0 +1,686 @@
+// +build !js
+
+// Package supervisor provides native-side management of a backend process
+// that serves IPC connections.  A Supervisor launches the backend, waits for
+// it to report readiness over a control connection, monitors its health via
+// heartbeats, restarts it if it crashes or stops responding, and publishes the
+// endpoint on which the backend is currently listening so that the host can
+// pass it to the GopherJS side of the bridge (e.g. as the HostInitialize
+// message).  Backends cooperate with a supervisor by calling NotifyReady after
+// they begin listening.
+package supervisor
+
+// System imports
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// GopherJSIPCBridge imports
+import ipc "github.com/havoc-io/gopherjsipcbridge/go"
+
+// ControlEndpointEnvironmentVariable is the environment variable through which
+// a Supervisor passes its control endpoint to the backend process.
+const ControlEndpointEnvironmentVariable = "GIB_SUPERVISOR_ENDPOINT"
+
+// HeartbeatInterval is the interval at which backends send heartbeats to their
+// supervisor.
+const HeartbeatInterval = 1 * time.Second
+
+const (
+	// defaultReadyTimeout is the default time a backend has to report
+	// readiness after launch.
+	defaultReadyTimeout = 10 * time.Second
+
+	// defaultHeartbeatTimeout is the default time without a heartbeat after
+	// which a backend is considered unhealthy.
+	defaultHeartbeatTimeout = 3 * HeartbeatInterval
+
+	// defaultMinimumRestartDelay is the default delay before the first restart
+	// following a failure.
+	defaultMinimumRestartDelay = 100 * time.Millisecond
+
+	// defaultMaximumRestartDelay is the default cap on the delay between
+	// consecutive restarts.
+	defaultMaximumRestartDelay = 30 * time.Second
+)
+
+// ErrReadyTimeout is reported when a backend fails to report readiness within
+// the ready timeout.
+var ErrReadyTimeout = errors.New("backend did not become ready in time")
+
+// ErrUnhealthy is reported when a ready backend stops sending heartbeats.
+var ErrUnhealthy = errors.New("backend stopped sending heartbeats")
+
+// ErrControlConnectionLost is reported when a backend's control connection
+// fails while the backend is still running.
+var ErrControlConnectionLost = errors.New("backend control connection lost")
+
+// message types used in the control protocol
+const (
+	messageTypeReady     = "ready"
+	messageTypeHeartbeat = "heartbeat"
+)
+
+// message represents a single message sent from the backend to the supervisor.
+// Messages are encoded as a stream of JSON objects on the control connection.
+type message struct {
+	// The message type
+	Type string `json:"type"`
+
+	// The endpoint on which the backend is listening (ready messages only)
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// The process id of the backend (ready messages only)
+	PID int `json:"pid,omitempty"`
+}
+
+// State represents the state of a supervised backend.
+type State int
+
+const (
+	// StateStopped indicates that the supervisor is not running a backend.
+	StateStopped State = iota
+	// StateStarting indicates that a backend has been launched but has not yet
+	// reported readiness.
+	StateStarting
+	// StateReady indicates that the backend is listening and healthy.
+	StateReady
+	// StateUnhealthy indicates that the backend has missed heartbeats and is
+	// being restarted.
+	StateUnhealthy
+	// StateRestarting indicates that the backend exited or failed and that the
+	// supervisor is waiting before launching it again.
+	StateRestarting
+)
+
+func (s State) String() string {
+	switch s {
+	case StateStopped:
+		return "stopped"
+	case StateStarting:
+		return "starting"
+	case StateReady:
+		return "ready"
+	case StateUnhealthy:
+		return "unhealthy"
+	case StateRestarting:
+		return "restarting"
+	default:
+		return "unknown"
+	}
+}
+
+// Supervisor manages the lifecycle of a backend process.  The Command and
+// ControlEndpoint fields must be set before calling Start, other fields are
+// optional and their zero values select sensible defaults.
+type Supervisor struct {
+	// Command creates the command used to launch the backend.  It is invoked
+	// for each launch, since exec.Cmd values can't be reused.  The supervisor
+	// adds ControlEndpointEnvironmentVariable to the command's environment.
+	// The launched process itself (rather than a child of it) must call
+	// NotifyReady, since control connections are only accepted from the
+	// launched process id (verified using peer credentials where available).
+	Command func() *exec.Cmd
+
+	// ControlEndpoint is the IPC endpoint (in the format accepted by
+	// ipc.ListenIPC) on which the supervisor listens for the backend's control
+	// connection.
+	ControlEndpoint string
+
+	// ReadyTimeout is the time a backend has to report readiness after launch.
+	ReadyTimeout time.Duration
+
+	// HeartbeatTimeout is the time without a heartbeat after which a backend
+	// is considered unhealthy and is restarted.
+	HeartbeatTimeout time.Duration
+
+	// MinimumRestartDelay is the delay before the first restart following a
+	// failure.  Consecutive failures double the delay, up to
+	// MaximumRestartDelay.
+	MinimumRestartDelay time.Duration
+
+	// MaximumRestartDelay is the cap on the delay between restarts.
+	MaximumRestartDelay time.Duration
+
+	// EndpointChanged, if non-nil, is invoked whenever the backend's endpoint
+	// changes.  It receives the endpoint when the backend becomes ready and an
+	// empty string when the backend goes down.  Hosts can use this to
+	// (re-)create bridges with the endpoint as the initialization message.
+	EndpointChanged func(endpoint string)
+
+	// StateChanged, if non-nil, is invoked whenever the backend state changes.
+	StateChanged func(state State)
+
+	// Failed, if non-nil, is invoked with the reason each time a backend fails
+	// to launch, fails to become ready, exits, or becomes unhealthy.  It is
+	// not invoked for backends terminated by Stop.  The most recent reason is
+	// also available from LastError.
+	Failed func(err error)
+
+	// Lock for the fields below
+	lock sync.Mutex
+
+	// The control listener
+	listener net.Listener
+
+	// The current backend process, if any
+	process *os.Process
+
+	// The current backend endpoint, if ready
+	endpoint string
+
+	// The current state
+	state State
+
+	// The most recent backend failure
+	lastError error
+
+	// Channel closed when Stop is called
+	stop chan struct{}
+
+	// Channel closed when the run loop exits
+	done chan struct{}
+}
+
+// Start begins listening on the control endpoint and launches the backend.  It
+// returns once the control listener is established - readiness of the backend
+// is reported asynchronously via EndpointChanged and StateChanged.
+func (s *Supervisor) Start() error {
+	// Validate configuration
+	if s.Command == nil {
+		return errors.New("no backend command specified")
+	} else if s.ControlEndpoint == "" {
+		return errors.New("no control endpoint specified")
+	}
+
+	// Lock the supervisor
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// Make sure we're not already running
+	if s.stop != nil {
+		return errors.New("supervisor already started")
+	}
+
+	// Create the control listener
+	listener, err := ipc.ListenIPC(s.ControlEndpoint)
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+
+	// Start the run loop
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.run(listener, s.stop, s.done)
+
+	// All done
+	return nil
+}
+
+// Stop terminates the backend process and the control listener, waiting for
+// the supervision loop to exit.
+func (s *Supervisor) Stop() error {
+	// Lock the supervisor and signal the run loop
+	s.lock.Lock()
+	if s.stop == nil {
+		s.lock.Unlock()
+		return errors.New("supervisor not started")
+	}
+	close(s.stop)
+	done := s.done
+	s.stop = nil
+	s.done = nil
+	listener := s.listener
+	s.listener = nil
+	process := s.process
+	s.lock.Unlock()
+
+	// Close the listener (which unblocks any pending accept) and kill the
+	// current process (which unblocks any pending wait)
+	err := listener.Close()
+	if process != nil {
+		process.Kill()
+	}
+
+	// Wait for the run loop to exit
+	<-done
+
+	// All done
+	return err
+}
+
+// Endpoint returns the endpoint on which the backend is currently listening,
+// or an empty string if the backend is not ready.
+func (s *Supervisor) Endpoint() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.endpoint
+}
+
+// State returns the current backend state.
+func (s *Supervisor) State() State {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.state
+}
+
+// LastError returns the reason for the most recent backend failure, or nil if
+// no backend has failed.
+func (s *Supervisor) LastError() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.lastError
+}
+
+// fail records a backend failure and invokes any callback.
+func (s *Supervisor) fail(err error) {
+	// Record the error
+	s.lock.Lock()
+	s.lastError = err
+	s.lock.Unlock()
+
+	// Invoke the callback
+	if s.Failed != nil {
+		s.Failed(err)
+	}
+}
+
+// setState updates the state and endpoint and invokes any callbacks.
+func (s *Supervisor) setState(state State, endpoint string) {
+	// Update state
+	s.lock.Lock()
+	stateChanged := s.state != state
+	endpointChanged := s.endpoint != endpoint
+	s.state = state
+	s.endpoint = endpoint
+	s.lock.Unlock()
+
+	// Invoke callbacks
+	if endpointChanged && s.EndpointChanged != nil {
+		s.EndpointChanged(endpoint)
+	}
+	if stateChanged && s.StateChanged != nil {
+		s.StateChanged(state)
+	}
+}
+
+// control represents a verified control connection and the readiness message
+// that was received on it.
+type control struct {
+	// The control connection
+	connection net.Conn
+
+	// The decoder for subsequent messages
+	decoder *json.Decoder
+
+	// The readiness message
+	ready message
+}
+
+// run is the supervision loop.  It launches and monitors the backend until
+// the stop channel is closed.
+func (s *Supervisor) run(listener net.Listener, stop, done chan struct{}) {
+	// Signal completion on exit
+	defer close(done)
+	defer s.setState(StateStopped, "")
+
+	// Compute timeouts and restart delays
+	readyTimeout := s.ReadyTimeout
+	if readyTimeout <= 0 {
+		readyTimeout = defaultReadyTimeout
+	}
+	heartbeatTimeout := s.HeartbeatTimeout
+	if heartbeatTimeout <= 0 {
+		heartbeatTimeout = defaultHeartbeatTimeout
+	}
+	minimumDelay := s.MinimumRestartDelay
+	if minimumDelay <= 0 {
+		minimumDelay = defaultMinimumRestartDelay
+	}
+	maximumDelay := s.MaximumRestartDelay
+	if maximumDelay <= 0 {
+		maximumDelay = defaultMaximumRestartDelay
+	}
+	delay := minimumDelay
+
+	// Accept control connections for the lifetime of the loop.  A single
+	// accept loop ensures that an accept left over from one backend instance
+	// can't consume the control connection of the next.
+	controls := make(chan *control)
+	go s.accept(listener, readyTimeout, controls, stop)
+
+	// Loop until stopped
+	for {
+		// Run a single instance of the backend.  If it reached readiness, reset
+		// the restart delay.
+		if s.supervise(controls, stop, readyTimeout, heartbeatTimeout) {
+			delay = minimumDelay
+		}
+
+		// Wait before restarting, watching for termination
+		s.setState(StateRestarting, "")
+		select {
+		case <-stop:
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > maximumDelay {
+			delay = maximumDelay
+		}
+	}
+}
+
+// accept accepts control connections until the listener is closed, verifying
+// each in the background and forwarding those that pass.
+func (s *Supervisor) accept(
+	listener net.Listener,
+	readyTimeout time.Duration,
+	controls chan *control,
+	stop chan struct{},
+) {
+	for {
+		connection, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.verify(connection, readyTimeout, controls, stop)
+	}
+}
+
+// verify reads the readiness message from a control connection and forwards
+// the connection if the message is valid and the process id that it reports
+// matches the peer's credentials (where the platform provides them).  Invalid
+// connections are closed, as are connections that aren't claimed before the
+// supervisor stops.
+func (s *Supervisor) verify(
+	connection net.Conn,
+	readyTimeout time.Duration,
+	controls chan *control,
+	stop chan struct{},
+) {
+	// Read the readiness message, bounding the time that a silent peer can
+	// hold the connection open
+	decoder := json.NewDecoder(connection)
+	var ready message
+	connection.SetReadDeadline(time.Now().Add(readyTimeout))
+	err := decoder.Decode(&ready)
+	connection.SetReadDeadline(time.Time{})
+	if err != nil || ready.Type != messageTypeReady {
+		connection.Close()
+		return
+	}
+
+	// Verify the reported process id against the operating system's record of
+	// the peer, if available, so that another local process can't claim to be
+	// the backend
+	if credentials, err := ipc.PeerCredentialsOf(connection); err == nil {
+		if credentials.PID != -1 && credentials.PID != ready.PID {
+			connection.Close()
+			return
+		}
+	}
+
+	// Forward the connection
+	select {
+	case controls <- &control{connection, decoder, ready}:
+	case <-stop:
+		connection.Close()
+	}
+}
+
+// supervise launches a single instance of the backend and monitors it until it
+// exits, fails to become ready, or becomes unhealthy.  It returns true if the
+// backend reached readiness.
+func (s *Supervisor) supervise(
+	controls chan *control,
+	stop chan struct{},
+	readyTimeout time.Duration,
+	heartbeatTimeout time.Duration,
+) bool {
+	// Create and launch the backend
+	command := s.Command()
+	if command.Env == nil {
+		command.Env = os.Environ()
+	}
+	command.Env = append(
+		command.Env,
+		ControlEndpointEnvironmentVariable+"="+s.ControlEndpoint,
+	)
+	s.setState(StateStarting, "")
+	if err := command.Start(); err != nil {
+		s.fail(fmt.Errorf("unable to launch backend: %v", err))
+		return false
+	}
+
+	// Record the process so that Stop can terminate it.  If Stop was called
+	// while we were launching, terminate it ourselves.
+	s.lock.Lock()
+	s.process = command.Process
+	s.lock.Unlock()
+	defer func() {
+		s.lock.Lock()
+		s.process = nil
+		s.lock.Unlock()
+	}()
+	select {
+	case <-stop:
+		command.Process.Kill()
+		command.Wait()
+		return false
+	default:
+	}
+
+	// Watch for process exit
+	exited := make(chan struct{})
+	var exitErr error
+	go func() {
+		exitErr = command.Wait()
+		close(exited)
+	}()
+	exitError := func() error {
+		if exitErr != nil {
+			return fmt.Errorf("backend exited: %v", exitErr)
+		}
+		return errors.New("backend exited")
+	}
+
+	// Wait for a control connection from the process we launched, the process
+	// to exit, or the readiness deadline.  Verified connections from other
+	// processes (e.g. a previous instance that was slow to connect) are
+	// closed.  The deadline timer is reused as the health deadline once the
+	// backend is ready.
+	deadline := time.NewTimer(readyTimeout)
+	defer deadline.Stop()
+	var c *control
+	for c == nil {
+		select {
+		case c = <-controls:
+			if c.ready.PID != command.Process.Pid {
+				c.connection.Close()
+				c = nil
+			}
+		case <-exited:
+			s.fail(exitError())
+			return false
+		case <-deadline.C:
+			s.fail(ErrReadyTimeout)
+			command.Process.Kill()
+			<-exited
+			return false
+		case <-stop:
+			command.Process.Kill()
+			<-exited
+			return false
+		}
+	}
+	defer c.connection.Close()
+
+	// The backend is ready
+	s.setState(StateReady, c.ready.Endpoint)
+	if !deadline.Stop() {
+		<-deadline.C
+	}
+	deadline.Reset(heartbeatTimeout)
+
+	// Decode subsequent messages in the background
+	messages := make(chan message)
+	go func() {
+		defer close(messages)
+		for {
+			var m message
+			if c.decoder.Decode(&m) != nil {
+				return
+			}
+			select {
+			case messages <- m:
+			case <-exited:
+				return
+			}
+		}
+	}()
+
+	// Monitor the backend
+	for {
+		select {
+		case _, ok := <-messages:
+			// If the control connection fails, treat the backend as failed
+			if !ok {
+				select {
+				case <-exited:
+					s.fail(exitError())
+				default:
+					s.fail(ErrControlConnectionLost)
+					command.Process.Kill()
+					<-exited
+				}
+				return true
+			}
+
+			// Any message (including heartbeats) resets the health deadline
+			if !deadline.Stop() {
+				select {
+				case <-deadline.C:
+				default:
+				}
+			}
+			deadline.Reset(heartbeatTimeout)
+		case <-deadline.C:
+			// The backend is unhealthy
+			s.setState(StateUnhealthy, "")
+			s.fail(ErrUnhealthy)
+			command.Process.Kill()
+			<-exited
+			return true
+		case <-exited:
+			s.fail(exitError())
+			return true
+		case <-stop:
+			command.Process.Kill()
+			<-exited
+			return true
+		}
+	}
+}
+
+// Supervised returns whether or not the current process was launched by a
+// Supervisor.
+func Supervised() bool {
+	return os.Getenv(ControlEndpointEnvironmentVariable) != ""
+}
+
+// Notifier represents a backend's control connection to its supervisor.  It
+// sends heartbeats until closed.
+type Notifier struct {
+	// The control connection
+	connection net.Conn
+
+	// Channel closed to stop heartbeats
+	stop chan struct{}
+
+	// Ensures the connection is only closed once
+	closeOnce sync.Once
+}
+
+// NotifyReady should be invoked by backends once they are listening on the
+// specified endpoint.  It connects to the supervisor, reports readiness, and
+// begins sending heartbeats in the background.  If the process is not being
+// supervised, it does nothing and returns a nil Notifier (which is safe to
+// close).
+func NotifyReady(endpoint string) (*Notifier, error) {
+	// If we're not supervised, there's nothing to do
+	controlEndpoint := os.Getenv(ControlEndpointEnvironmentVariable)
+	if controlEndpoint == "" {
+		return nil, nil
+	}
+
+	// Connect to the supervisor
+	connection, err := ipc.DialIPC(controlEndpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send the readiness message
+	encoder := json.NewEncoder(connection)
+	err = encoder.Encode(message{
+		Type:     messageTypeReady,
+		Endpoint: endpoint,
+		PID:      os.Getpid(),
+	})
+	if err != nil {
+		connection.Close()
+		return nil, err
+	}
+
+	// Create the notifier and start heartbeats
+	notifier := &Notifier{
+		connection: connection,
+		stop:       make(chan struct{}),
+	}
+	go notifier.heartbeat(encoder)
+
+	// All done
+	return notifier, nil
+}
+
+// heartbeat sends heartbeats until the notifier is closed or the control
+// connection fails.
+func (n *Notifier) heartbeat(encoder *json.Encoder) {
+	ticker := time.NewTicker(HeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if encoder.Encode(message{Type: messageTypeHeartbeat}) != nil {
+				return
+			}
+		case <-n.stop:
+			return
+		}
+	}
+}
+
+// Close stops heartbeats and closes the control connection.  The supervisor
+// will treat this as the backend going down.
+func (n *Notifier) Close() error {
+	// Handle nil notifiers returned for unsupervised processes
+	if n == nil {
+		return nil
+	}
+
+	// Stop heartbeats and close the connection
+	var err error
+	n.closeOnce.Do(func() {
+		close(n.stop)
+		err = n.connection.Close()
+	})
+	return err
+}
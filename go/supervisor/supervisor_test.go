@@ -0,0 +1,281 @@
+// +build !windows,!js
+
+package supervisor
+
+// System imports
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// GopherJSIPCBridge imports
+import ipc "github.com/havoc-io/gopherjsipcbridge/go"
+
+// testModeEnvironmentVariable selects the backend behavior when the test
+// binary is launched as a backend.
+const testModeEnvironmentVariable = "GIB_SUPERVISOR_TEST_MODE"
+
+// testBackendEndpoint is the endpoint reported by test backends.
+const testBackendEndpoint = "test-endpoint"
+
+func TestMain(m *testing.M) {
+	// If we've been launched as a backend, act as one
+	switch os.Getenv(testModeEnvironmentVariable) {
+	case "":
+	case "ready":
+		if _, err := NotifyReady(testBackendEndpoint); err != nil {
+			os.Exit(1)
+		}
+		time.Sleep(time.Hour)
+		os.Exit(0)
+	case "silent":
+		time.Sleep(time.Hour)
+		os.Exit(0)
+	default:
+		os.Exit(1)
+	}
+
+	// Otherwise run the tests
+	os.Exit(m.Run())
+}
+
+// testBackend creates a command function that launches the test binary as a
+// backend in the specified mode.
+func testBackend(mode string) func() *exec.Cmd {
+	return func() *exec.Cmd {
+		command := exec.Command(os.Args[0])
+		command.Env = append(
+			os.Environ(),
+			testModeEnvironmentVariable+"="+mode,
+		)
+		return command
+	}
+}
+
+// testControlEndpoint creates a control endpoint in a temporary directory
+// that is removed when the test completes.
+func testControlEndpoint(t *testing.T) string {
+	directory, err := ioutil.TempDir("", "gib")
+	if err != nil {
+		t.Fatal("unable to create temporary directory:", err)
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(directory)
+	})
+	return filepath.Join(directory, "control.sock")
+}
+
+// stateRecorder records state transitions and their times.
+type stateRecorder struct {
+	lock   sync.Mutex
+	states []State
+	times  []time.Time
+}
+
+func (r *stateRecorder) record(state State) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.states = append(r.states, state)
+	r.times = append(r.times, time.Now())
+}
+
+// starts returns the times at which backends were launched.
+func (r *stateRecorder) starts() []time.Time {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var result []time.Time
+	for i, state := range r.states {
+		if state == StateStarting {
+			result = append(result, r.times[i])
+		}
+	}
+	return result
+}
+
+func TestSupervisorReady(t *testing.T) {
+	// Start a supervisor with a well-behaved backend
+	endpoints := make(chan string, 4)
+	supervisor := &Supervisor{
+		Command:         testBackend("ready"),
+		ControlEndpoint: testControlEndpoint(t),
+		EndpointChanged: func(endpoint string) {
+			endpoints <- endpoint
+		},
+	}
+	if err := supervisor.Start(); err != nil {
+		t.Fatal("unable to start supervisor:", err)
+	}
+
+	// Wait for readiness
+	select {
+	case endpoint := <-endpoints:
+		if endpoint != testBackendEndpoint {
+			t.Fatal("unexpected endpoint:", endpoint)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("backend didn't become ready")
+	}
+	if state := supervisor.State(); state != StateReady {
+		t.Fatal("unexpected state:", state)
+	}
+
+	// Stop the supervisor
+	if err := supervisor.Stop(); err != nil {
+		t.Fatal("unable to stop supervisor:", err)
+	}
+	if state := supervisor.State(); state != StateStopped {
+		t.Fatal("unexpected state after stop:", state)
+	} else if endpoint := supervisor.Endpoint(); endpoint != "" {
+		t.Fatal("endpoint still set after stop:", endpoint)
+	}
+}
+
+func TestSupervisorRestartsWithBackoff(t *testing.T) {
+	// Start a supervisor with a backend that exits immediately
+	recorder := &stateRecorder{}
+	failures := make(chan error, 64)
+	supervisor := &Supervisor{
+		Command:             testBackend("exit"),
+		ControlEndpoint:     testControlEndpoint(t),
+		MinimumRestartDelay: 50 * time.Millisecond,
+		MaximumRestartDelay: 200 * time.Millisecond,
+		StateChanged:        recorder.record,
+		Failed: func(err error) {
+			failures <- err
+		},
+	}
+	if err := supervisor.Start(); err != nil {
+		t.Fatal("unable to start supervisor:", err)
+	}
+	defer supervisor.Stop()
+
+	// Wait for several restarts
+	deadline := time.Now().Add(10 * time.Second)
+	for len(recorder.starts()) < 5 {
+		if time.Now().After(deadline) {
+			t.Fatal("backend wasn't restarted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Verify that the restart delay grew and was capped
+	starts := recorder.starts()
+	gaps := make([]time.Duration, len(starts)-1)
+	for i := range gaps {
+		gaps[i] = starts[i+1].Sub(starts[i])
+	}
+	if gaps[0] < 50*time.Millisecond {
+		t.Error("first restart too early:", gaps[0])
+	}
+	if gaps[2] < 200*time.Millisecond {
+		t.Error("restart delay didn't grow:", gaps)
+	}
+	if gaps[3] > 2*time.Second {
+		t.Error("restart delay wasn't capped:", gaps)
+	}
+
+	// Verify that failures were reported
+	select {
+	case err := <-failures:
+		if !strings.HasPrefix(err.Error(), "backend exited") {
+			t.Error("unexpected failure:", err)
+		}
+	default:
+		t.Error("no failure reported")
+	}
+	if supervisor.LastError() == nil {
+		t.Error("no last error recorded")
+	}
+}
+
+func TestSupervisorReportsLaunchFailure(t *testing.T) {
+	// Start a supervisor with a backend that can't be launched
+	failures := make(chan error, 4)
+	supervisor := &Supervisor{
+		Command: func() *exec.Cmd {
+			return exec.Command(filepath.Join(os.TempDir(), "gib-nonexistent"))
+		},
+		ControlEndpoint: testControlEndpoint(t),
+		Failed: func(err error) {
+			failures <- err
+		},
+	}
+	if err := supervisor.Start(); err != nil {
+		t.Fatal("unable to start supervisor:", err)
+	}
+	defer supervisor.Stop()
+
+	// Wait for the failure
+	select {
+	case err := <-failures:
+		if !strings.HasPrefix(err.Error(), "unable to launch backend") {
+			t.Fatal("unexpected failure:", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("launch failure not reported")
+	}
+}
+
+func TestSupervisorRejectsImpostor(t *testing.T) {
+	// Start a supervisor with a backend that never connects
+	controlEndpoint := testControlEndpoint(t)
+	failures := make(chan error, 4)
+	supervisor := &Supervisor{
+		Command:         testBackend("silent"),
+		ControlEndpoint: controlEndpoint,
+		ReadyTimeout:    500 * time.Millisecond,
+		Failed: func(err error) {
+			failures <- err
+		},
+	}
+	if err := supervisor.Start(); err != nil {
+		t.Fatal("unable to start supervisor:", err)
+	}
+	defer supervisor.Stop()
+
+	// Claim readiness from this process, both with our own process id (which
+	// isn't the launched backend's) and with a forged one (which doesn't match
+	// our credentials)
+	for _, pid := range []int{os.Getpid(), os.Getpid() + 1} {
+		connection, err := ipc.DialIPC(controlEndpoint)
+		if err != nil {
+			t.Fatal("unable to connect to supervisor:", err)
+		}
+		defer connection.Close()
+		err = json.NewEncoder(connection).Encode(message{
+			Type:     messageTypeReady,
+			Endpoint: "impostor",
+			PID:      pid,
+		})
+		if err != nil {
+			t.Fatal("unable to send readiness message:", err)
+		}
+
+		// The supervisor should close the connection
+		connection.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if _, err := connection.Read(make([]byte, 1)); err == nil {
+			t.Fatal("impostor connection not closed")
+		}
+	}
+
+	// The backend should time out rather than becoming ready
+	select {
+	case err := <-failures:
+		if !errors.Is(err, ErrReadyTimeout) {
+			t.Fatal("unexpected failure:", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("ready timeout not reported")
+	}
+	if endpoint := supervisor.Endpoint(); endpoint == "impostor" {
+		t.Fatal("impostor endpoint published")
+	}
+}
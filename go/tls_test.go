@@ -0,0 +1,167 @@
+// +build !windows,!js
+
+package ipc
+
+// System imports
+import (
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+// testTLSIdentity generates an identity or fails the test.
+func testTLSIdentity(t *testing.T) *TLSIdentity {
+	identity, err := GenerateTLSIdentity()
+	if err != nil {
+		t.Fatal("unable to generate identity:", err)
+	}
+	return identity
+}
+
+// secureHandshake performs a handshake over a socket pair with the server
+// presenting the specified identity and the client pinning the specified
+// fingerprint.  On success, it verifies that data can be exchanged.  It
+// returns the client's handshake error.  An in-memory pipe can't be used,
+// since it lacks the buffering that the handshake relies on when it fails.
+func secureHandshake(t *testing.T, identity *TLSIdentity, fingerprint string) error {
+	client, server := socketPair(t)
+	defer client.Close()
+	defer server.Close()
+
+	// Run the server side, closing the pipe on failure so that the client
+	// doesn't block
+	serverConnections := make(chan net.Conn, 1)
+	go func() {
+		secured, err := SecureServer(server, identity)
+		if err != nil {
+			server.Close()
+		}
+		serverConnections <- secured
+	}()
+
+	// Run the client side
+	secured, err := SecureClient(client, fingerprint)
+	if err != nil {
+		client.Close()
+		<-serverConnections
+		return err
+	}
+	serverSecured := <-serverConnections
+	if serverSecured == nil {
+		t.Fatal("server handshake failed after client success")
+	}
+
+	// Exchange data
+	go secured.Write([]byte("secret"))
+	received := make([]byte, 6)
+	if _, err := io.ReadFull(serverSecured, received); err != nil {
+		t.Fatal("unable to read secured data:", err)
+	} else if string(received) != "secret" {
+		t.Fatal("unexpected secured data:", string(received))
+	}
+
+	// All done
+	return nil
+}
+
+func TestSecureMatchingFingerprint(t *testing.T) {
+	identity := testTLSIdentity(t)
+	if err := secureHandshake(t, identity, identity.Fingerprint); err != nil {
+		t.Fatal("handshake failed:", err)
+	}
+}
+
+func TestSecureUppercaseFingerprint(t *testing.T) {
+	identity := testTLSIdentity(t)
+	fingerprint := strings.ToUpper(identity.Fingerprint)
+	if err := secureHandshake(t, identity, fingerprint); err != nil {
+		t.Fatal("handshake failed:", err)
+	}
+}
+
+func TestSecureMismatchedFingerprint(t *testing.T) {
+	identity := testTLSIdentity(t)
+	other := testTLSIdentity(t)
+	err := secureHandshake(t, identity, other.Fingerprint)
+	if !errors.Is(err, ErrTLSFingerprintMismatch) {
+		t.Fatal("unexpected handshake error:", err)
+	}
+}
+
+func TestSecureEmptyCertificateChain(t *testing.T) {
+	identity := testTLSIdentity(t)
+	verify := verifyPinnedCertificate(identity.Fingerprint)
+	if err := verify(nil, nil); err != ErrTLSFingerprintMismatch {
+		t.Fatal("empty certificate chain accepted:", err)
+	}
+	certificate := identity.Certificate.Certificate[0]
+	if err := verify([][]byte{certificate}, nil); err != nil {
+		t.Fatal("pinned certificate rejected:", err)
+	}
+}
+
+// dialSecure dials a secured endpoint in the background.
+func dialSecure(endpoint, fingerprint string) (chan net.Conn, chan error) {
+	connections := make(chan net.Conn, 1)
+	errs := make(chan error, 1)
+	go func() {
+		connection, err := DialIPCSecure(endpoint, fingerprint)
+		if err != nil {
+			errs <- err
+			return
+		}
+		connections <- connection
+	}()
+	return connections, errs
+}
+
+func TestListenIPCSecure(t *testing.T) {
+	identity := testTLSIdentity(t)
+	endpoint := testEndpoint(t)
+	listener, err := ListenIPCSecure(endpoint, identity)
+	if err != nil {
+		t.Fatal("unable to listen:", err)
+	}
+	defer listener.Close()
+
+	// Connect with the wrong fingerprint, which should fail.  The server side
+	// of the handshake is performed lazily, so we need to drive it with a read.
+	accepted := acceptOne(listener)
+	_, errs := dialSecure(endpoint, testTLSIdentity(t).Fingerprint)
+	server := receiveConn(t, accepted)
+	if _, err := server.Read(make([]byte, 1)); err == nil {
+		t.Fatal("server handshake succeeded with mismatched fingerprint")
+	}
+	server.Close()
+	if err := <-errs; !errors.Is(err, ErrTLSFingerprintMismatch) {
+		t.Fatal("unexpected client error:", err)
+	}
+
+	// Connect with the correct fingerprint
+	accepted = acceptOne(listener)
+	connections, errs := dialSecure(endpoint, identity.Fingerprint)
+	server = receiveConn(t, accepted)
+	defer server.Close()
+	serverErrors := make(chan error, 1)
+	go func() {
+		_, err := server.Write([]byte("secret"))
+		serverErrors <- err
+	}()
+	var connection net.Conn
+	select {
+	case connection = <-connections:
+	case err := <-errs:
+		t.Fatal("unable to connect:", err)
+	}
+	defer connection.Close()
+	received := make([]byte, 6)
+	if _, err := io.ReadFull(connection, received); err != nil {
+		t.Fatal("unable to read:", err)
+	} else if string(received) != "secret" {
+		t.Fatal("unexpected data:", string(received))
+	} else if err := <-serverErrors; err != nil {
+		t.Fatal("unable to write:", err)
+	}
+}
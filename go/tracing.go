@@ -0,0 +1,417 @@
+package ipc
+
+// System imports
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// TraceEvent describes a single bridge operation.  The same event is passed to
+// a Tracer's StartOperation and FinishOperation methods, with result fields
+// populated before the latter.
+type TraceEvent struct {
+	// Operation is the operation name (see the Operation constants).
+	Operation string
+
+	// Endpoint is the endpoint for connect and listen operations.
+	Endpoint string
+
+	// ConnectionId is the connection identifier for connection operations.
+	// For connect and accept operations, it is populated on completion.
+	ConnectionId int
+
+	// ListenerId is the listener identifier for listener operations.  For
+	// listen operations, it is populated on completion.
+	ListenerId int
+
+	// Length is the requested length for reads and the data length for
+	// writes.
+	Length int
+
+	// Count is the number of bytes transferred by reads and writes, populated
+	// on completion.
+	Count int
+
+	// Start is the time at which the operation was dispatched.
+	Start time.Time
+
+	// Finish is the time at which the operation's result arrived.
+	Finish time.Time
+
+	// Err is the operation's error, populated on completion.
+	Err error
+
+	// Data is available for the tracer to associate its own state with the
+	// event between StartOperation and FinishOperation.
+	Data interface{}
+}
+
+// Duration returns the duration of a finished operation.
+func (e *TraceEvent) Duration() time.Duration {
+	return e.Finish.Sub(e.Start)
+}
+
+// Tracer receives start and finish events for bridge operations.  Methods may
+// be invoked concurrently for different operations and must not block.
+type Tracer interface {
+	// StartOperation is invoked before an operation is dispatched.
+	StartOperation(event *TraceEvent)
+
+	// FinishOperation is invoked when an operation's result arrives, before it
+	// is delivered to the caller.
+	FinishOperation(event *TraceEvent)
+}
+
+// logTracer implements Tracer by logging finished operations.
+type logTracer struct {
+	// The underlying logger
+	logger *log.Logger
+}
+
+// NewLogTracer creates a tracer that logs each finished operation as a single
+// line of space-separated key=value pairs to the specified logger (or the
+// standard logger if nil).
+func NewLogTracer(logger *log.Logger) Tracer {
+	return &logTracer{logger}
+}
+
+func (t *logTracer) StartOperation(event *TraceEvent) {}
+
+func (t *logTracer) FinishOperation(event *TraceEvent) {
+	// Format the common fields
+	line := fmt.Sprintf(
+		"ipc operation=%s duration=%s",
+		event.Operation,
+		event.Duration(),
+	)
+
+	// Format operation-specific fields
+	switch event.Operation {
+	case OperationConnect, OperationListen, OperationConnectPacket,
+		OperationListenPacket:
+		line += fmt.Sprintf(" endpoint=%q", event.Endpoint)
+	}
+	switch event.Operation {
+	case OperationConnect, OperationListenerAccept, OperationConnectionRead,
+		OperationConnectionWrite, OperationConnectionClose,
+		OperationConnectionCloseRead, OperationConnectionCloseWrite,
+		OperationConnectPacket, OperationConnectionReadMessage,
+		OperationConnectionWriteMessage:
+		line += fmt.Sprintf(" connection=%d", event.ConnectionId)
+	}
+	switch event.Operation {
+	case OperationListen, OperationListenerAccept, OperationListenerClose,
+		OperationListenPacket:
+		line += fmt.Sprintf(" listener=%d", event.ListenerId)
+	}
+	switch event.Operation {
+	case OperationConnectionRead, OperationConnectionWrite,
+		OperationConnectionReadMessage, OperationConnectionWriteMessage:
+		line += fmt.Sprintf(" length=%d count=%d", event.Length, event.Count)
+	}
+	if event.Err != nil {
+		line += fmt.Sprintf(" error=%q", event.Err.Error())
+	}
+
+	// Log
+	if t.logger != nil {
+		t.logger.Println(line)
+	} else {
+		log.Println(line)
+	}
+}
+
+// traceParentVersion is the W3C Trace Context version we generate and accept.
+const traceParentVersion = "00"
+
+// traceParentLength is the length of an encoded version 00 traceparent value.
+const traceParentLength = 55
+
+// ErrInvalidTraceParent is returned when a traceparent value is malformed.
+var ErrInvalidTraceParent = errors.New("invalid traceparent")
+
+// TraceContext identifies a span within a trace, using the identifier formats
+// of the W3C Trace Context specification (and thus OpenTelemetry).
+type TraceContext struct {
+	// TraceID identifies the trace.
+	TraceID [16]byte
+
+	// SpanID identifies the span within the trace.
+	SpanID [8]byte
+
+	// Flags are the trace flags (bit 0 indicates sampling).
+	Flags byte
+}
+
+// NewTraceContext creates a new sampled trace context with random trace and
+// span identifiers.
+func NewTraceContext() (TraceContext, error) {
+	var context TraceContext
+	if _, err := io.ReadFull(rand.Reader, context.TraceID[:]); err != nil {
+		return TraceContext{}, err
+	}
+	if _, err := io.ReadFull(rand.Reader, context.SpanID[:]); err != nil {
+		return TraceContext{}, err
+	}
+	context.Flags = 1
+	return context, nil
+}
+
+// IsValid returns whether or not the trace and span identifiers are non-zero,
+// as required by the specification.
+func (c TraceContext) IsValid() bool {
+	return c.TraceID != [16]byte{} && c.SpanID != [8]byte{}
+}
+
+// String encodes the trace context as a W3C traceparent value.
+func (c TraceContext) String() string {
+	return fmt.Sprintf(
+		"%s-%s-%s-%02x",
+		traceParentVersion,
+		hex.EncodeToString(c.TraceID[:]),
+		hex.EncodeToString(c.SpanID[:]),
+		c.Flags,
+	)
+}
+
+// isLowerHex returns whether or not a string consists solely of lowercase
+// hexadecimal digits.
+func isLowerHex(value string) bool {
+	for i := 0; i < len(value); i++ {
+		if c := value[i]; !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseTraceParent decodes a W3C traceparent value.  As required by the
+// specification, identifiers and flags must be encoded as lowercase
+// hexadecimal.
+func ParseTraceParent(value string) (TraceContext, error) {
+	// Validate the overall structure
+	if len(value) != traceParentLength ||
+		value[:2] != traceParentVersion ||
+		value[2] != '-' || value[35] != '-' || value[52] != '-' ||
+		!isLowerHex(value[3:35]) || !isLowerHex(value[36:52]) ||
+		!isLowerHex(value[53:]) {
+		return TraceContext{}, ErrInvalidTraceParent
+	}
+
+	// Decode fields
+	var context TraceContext
+	if _, err := hex.Decode(context.TraceID[:], []byte(value[3:35])); err != nil {
+		return TraceContext{}, ErrInvalidTraceParent
+	}
+	if _, err := hex.Decode(context.SpanID[:], []byte(value[36:52])); err != nil {
+		return TraceContext{}, ErrInvalidTraceParent
+	}
+	flags, err := strconv.ParseUint(value[53:], 16, 8)
+	if err != nil {
+		return TraceContext{}, ErrInvalidTraceParent
+	}
+	context.Flags = byte(flags)
+
+	// Validate identifiers
+	if !context.IsValid() {
+		return TraceContext{}, ErrInvalidTraceParent
+	}
+
+	// All done
+	return context, nil
+}
+
+// InjectTraceContext writes a trace context to the start of a connection's
+// stream as a fixed-length traceparent value, allowing the peer to recover it
+// with ExtractTraceContext and parent its own spans accordingly.  Both sides
+// must agree to perform the exchange, typically immediately after connecting.
+func InjectTraceContext(connection net.Conn, context TraceContext) error {
+	_, err := io.WriteString(connection, context.String())
+	return err
+}
+
+// ExtractTraceContext reads a trace context written by InjectTraceContext.
+func ExtractTraceContext(connection net.Conn) (TraceContext, error) {
+	value := make([]byte, traceParentLength)
+	if _, err := io.ReadFull(connection, value); err != nil {
+		return TraceContext{}, err
+	}
+	return ParseTraceParent(string(value))
+}
+
+// Span is a finished span.  Its JSON encoding uses OpenTelemetry field names
+// and conventions (hex-encoded identifiers and nanosecond Unix timestamps), so
+// exported spans can be ingested by OpenTelemetry tooling with minimal
+// translation.
+type Span struct {
+	// Name is the span name, which is the operation name.
+	Name string `json:"name"`
+
+	// TraceID is the hex-encoded trace identifier.
+	TraceID string `json:"traceId"`
+
+	// SpanID is the hex-encoded span identifier.
+	SpanID string `json:"spanId"`
+
+	// ParentSpanID is the hex-encoded identifier of the parent span.
+	ParentSpanID string `json:"parentSpanId,omitempty"`
+
+	// StartTimeUnixNano is the span start time.
+	StartTimeUnixNano int64 `json:"startTimeUnixNano"`
+
+	// EndTimeUnixNano is the span end time.
+	EndTimeUnixNano int64 `json:"endTimeUnixNano"`
+
+	// Attributes are the span attributes.
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+
+	// StatusMessage is the error message for failed operations.
+	StatusMessage string `json:"statusMessage,omitempty"`
+}
+
+// Context returns the span's trace context, suitable for propagation with
+// InjectTraceContext.
+func (s *Span) Context() TraceContext {
+	var context TraceContext
+	hex.Decode(context.TraceID[:], []byte(s.TraceID))
+	hex.Decode(context.SpanID[:], []byte(s.SpanID))
+	context.Flags = 1
+	return context
+}
+
+// SpanExporter receives finished spans.  ExportSpan may be invoked
+// concurrently and must not block.
+type SpanExporter interface {
+	ExportSpan(span *Span)
+}
+
+// jsonSpanExporter implements SpanExporter by writing spans as JSON.
+type jsonSpanExporter struct {
+	// Lock serializing writes
+	lock sync.Mutex
+
+	// The JSON encoder
+	encoder *json.Encoder
+}
+
+// NewJSONSpanExporter creates a span exporter that writes each span to the
+// specified writer as a single line of JSON.
+func NewJSONSpanExporter(writer io.Writer) SpanExporter {
+	return &jsonSpanExporter{
+		encoder: json.NewEncoder(writer),
+	}
+}
+
+func (e *jsonSpanExporter) ExportSpan(span *Span) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.encoder.Encode(span)
+}
+
+// SpanTracer implements Tracer by creating a span for each operation and
+// passing it to an exporter when the operation finishes.  All spans are
+// children of the tracer's parent context.
+type SpanTracer struct {
+	// The span exporter
+	exporter SpanExporter
+
+	// The parent trace context
+	parent TraceContext
+}
+
+// NewSpanTracer creates a new span tracer exporting to the specified exporter.
+// If the parent context is invalid (e.g. the zero value), a new trace is
+// started.
+func NewSpanTracer(
+	exporter SpanExporter,
+	parent TraceContext,
+) (*SpanTracer, error) {
+	// Start a new trace if necessary
+	if !parent.IsValid() {
+		var err error
+		if parent, err = NewTraceContext(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Create the tracer
+	return &SpanTracer{
+		exporter: exporter,
+		parent:   parent,
+	}, nil
+}
+
+// Parent returns the tracer's parent context.  Passing this to
+// InjectTraceContext allows the peer to join the trace.
+func (t *SpanTracer) Parent() TraceContext {
+	return t.parent
+}
+
+func (t *SpanTracer) StartOperation(event *TraceEvent) {
+	// Generate a span identifier.  If this fails, the span is dropped.
+	var spanId [8]byte
+	if _, err := io.ReadFull(rand.Reader, spanId[:]); err != nil {
+		return
+	}
+	event.Data = spanId
+}
+
+func (t *SpanTracer) FinishOperation(event *TraceEvent) {
+	// Grab the span identifier
+	spanId, ok := event.Data.([8]byte)
+	if !ok {
+		return
+	}
+
+	// Create the span
+	span := &Span{
+		Name:              event.Operation,
+		TraceID:           hex.EncodeToString(t.parent.TraceID[:]),
+		SpanID:            hex.EncodeToString(spanId[:]),
+		ParentSpanID:      hex.EncodeToString(t.parent.SpanID[:]),
+		StartTimeUnixNano: event.Start.UnixNano(),
+		EndTimeUnixNano:   event.Finish.UnixNano(),
+		Attributes:        make(map[string]interface{}),
+	}
+
+	// Add attributes
+	switch event.Operation {
+	case OperationConnect, OperationListen, OperationConnectPacket,
+		OperationListenPacket:
+		span.Attributes["ipc.endpoint"] = event.Endpoint
+	}
+	switch event.Operation {
+	case OperationConnect, OperationListenerAccept, OperationConnectionRead,
+		OperationConnectionWrite, OperationConnectionClose,
+		OperationConnectionCloseRead, OperationConnectionCloseWrite,
+		OperationConnectPacket, OperationConnectionReadMessage,
+		OperationConnectionWriteMessage:
+		span.Attributes["ipc.connection_id"] = event.ConnectionId
+	}
+	switch event.Operation {
+	case OperationListen, OperationListenerAccept, OperationListenerClose,
+		OperationListenPacket:
+		span.Attributes["ipc.listener_id"] = event.ListenerId
+	}
+	switch event.Operation {
+	case OperationConnectionRead, OperationConnectionWrite,
+		OperationConnectionReadMessage, OperationConnectionWriteMessage:
+		span.Attributes["ipc.length"] = event.Length
+		span.Attributes["ipc.count"] = event.Count
+	}
+	if event.Err != nil {
+		span.StatusMessage = event.Err.Error()
+	}
+
+	// Export
+	t.exporter.ExportSpan(span)
+}
@@ -0,0 +1,281 @@
+package ipc
+
+// System imports
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testTraceParent is the example traceparent value from the W3C Trace Context
+// specification.
+const testTraceParent = "00-" +
+	"4bf92f3577b34da6a3ce929d0e0e4736-" +
+	"00f067aa0ba902b7-" +
+	"01"
+
+func TestParseTraceParent(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		valid bool
+	}{
+		{"valid", testTraceParent, true},
+		{"unsampled", testTraceParent[:53] + "00", true},
+		{"empty", "", false},
+		{"short", testTraceParent[:54], false},
+		{"long", testTraceParent + "0", false},
+		{"unknown version", "01" + testTraceParent[2:], false},
+		{
+			"bad separator",
+			strings.Replace(testTraceParent, "-", "_", 1),
+			false,
+		},
+		{
+			"uppercase trace id",
+			strings.Replace(testTraceParent, "bf92f", "BF92F", 1),
+			false,
+		},
+		{
+			"uppercase span id",
+			strings.Replace(testTraceParent, "f067aa", "F067AA", 1),
+			false,
+		},
+		{"uppercase flags", testTraceParent[:53] + "0A", false},
+		{"signed flags", testTraceParent[:53] + "+1", false},
+		{
+			"non-hex trace id",
+			strings.Replace(testTraceParent, "4bf9", "4bg9", 1),
+			false,
+		},
+		{
+			"zero trace id",
+			"00-" + strings.Repeat("0", 32) + testTraceParent[35:],
+			false,
+		},
+		{
+			"zero span id",
+			testTraceParent[:36] + strings.Repeat("0", 16) + "-01",
+			false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			context, err := ParseTraceParent(test.value)
+			if !test.valid {
+				if err != ErrInvalidTraceParent {
+					t.Fatal("unexpected error:", err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal("unable to parse valid value:", err)
+			}
+			if !context.IsValid() {
+				t.Error("parsed context is invalid")
+			}
+			if encoded := context.String(); encoded != test.value {
+				t.Errorf("value does not round trip: %s != %s", encoded, test.value)
+			}
+		})
+	}
+}
+
+func TestTraceContextPropagation(t *testing.T) {
+	expected, err := NewTraceContext()
+	if err != nil {
+		t.Fatal("unable to create trace context:", err)
+	}
+	tests := []struct {
+		name  string
+		write func(net.Conn) error
+		err   error
+	}{
+		{"valid", func(c net.Conn) error {
+			return InjectTraceContext(c, expected)
+		}, nil},
+		{"invalid", func(c net.Conn) error {
+			_, err := io.WriteString(c, strings.ToUpper(expected.String()))
+			return err
+		}, ErrInvalidTraceParent},
+		{"truncated", func(c net.Conn) error {
+			_, err := io.WriteString(c, expected.String()[:20])
+			return err
+		}, io.ErrUnexpectedEOF},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			defer server.Close()
+			written := make(chan error, 1)
+			go func() {
+				written <- test.write(client)
+				client.Close()
+			}()
+			context, err := ExtractTraceContext(server)
+			if err != test.err {
+				t.Fatal("unexpected extraction error:", err)
+			} else if err == nil && context != expected {
+				t.Error("extracted context mismatch:", context, expected)
+			}
+			if err := <-written; err != nil {
+				t.Fatal("unable to write trace context:", err)
+			}
+		})
+	}
+}
+
+// spanRecorder is a SpanExporter that records exported spans.
+type spanRecorder struct {
+	spans []*Span
+}
+
+func (r *spanRecorder) ExportSpan(span *Span) {
+	r.spans = append(r.spans, span)
+}
+
+func TestSpanTracer(t *testing.T) {
+	parent, err := ParseTraceParent(testTraceParent)
+	if err != nil {
+		t.Fatal("unable to parse parent:", err)
+	}
+	start := time.Unix(100, 0)
+	tests := []struct {
+		event      TraceEvent
+		attributes map[string]interface{}
+		status     string
+	}{
+		{
+			TraceEvent{
+				Operation:    OperationConnect,
+				Endpoint:     "endpoint",
+				ConnectionId: 3,
+			},
+			map[string]interface{}{
+				"ipc.endpoint":      "endpoint",
+				"ipc.connection_id": 3,
+			},
+			"",
+		},
+		{
+			TraceEvent{
+				Operation:    OperationConnectionWrite,
+				ConnectionId: 3,
+				Length:       10,
+				Count:        4,
+				Err:          errors.New("broken pipe"),
+			},
+			map[string]interface{}{
+				"ipc.connection_id": 3,
+				"ipc.length":        10,
+				"ipc.count":         4,
+			},
+			"broken pipe",
+		},
+		{
+			TraceEvent{
+				Operation:    OperationListenerAccept,
+				ListenerId:   2,
+				ConnectionId: 5,
+			},
+			map[string]interface{}{
+				"ipc.listener_id":   2,
+				"ipc.connection_id": 5,
+			},
+			"",
+		},
+		{
+			TraceEvent{Operation: OperationPing},
+			map[string]interface{}{},
+			"",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.event.Operation, func(t *testing.T) {
+			recorder := &spanRecorder{}
+			tracer, err := NewSpanTracer(recorder, parent)
+			if err != nil {
+				t.Fatal("unable to create tracer:", err)
+			}
+
+			// Trace the event
+			event := test.event
+			event.Start = start
+			tracer.StartOperation(&event)
+			event.Finish = start.Add(time.Second)
+			tracer.FinishOperation(&event)
+
+			// Verify the span
+			if len(recorder.spans) != 1 {
+				t.Fatal("unexpected span count:", len(recorder.spans))
+			}
+			span := recorder.spans[0]
+			if span.Name != test.event.Operation {
+				t.Error("unexpected span name:", span.Name)
+			}
+			if span.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" ||
+				span.ParentSpanID != "00f067aa0ba902b7" {
+				t.Error("span is not a child of the parent:", span)
+			}
+			if len(span.SpanID) != 16 || span.SpanID == span.ParentSpanID {
+				t.Error("unexpected span identifier:", span.SpanID)
+			}
+			if span.Context().TraceID != parent.TraceID {
+				t.Error("span context is not part of the parent trace")
+			}
+			if span.StartTimeUnixNano != start.UnixNano() ||
+				span.EndTimeUnixNano != start.Add(time.Second).UnixNano() {
+				t.Error("unexpected span times:",
+					span.StartTimeUnixNano, span.EndTimeUnixNano)
+			}
+			if !reflect.DeepEqual(span.Attributes, test.attributes) {
+				t.Error("unexpected attributes:", span.Attributes)
+			}
+			if span.StatusMessage != test.status {
+				t.Error("unexpected status message:", span.StatusMessage)
+			}
+		})
+	}
+}
+
+func TestSpanTracerNewTrace(t *testing.T) {
+	// A tracer with an invalid parent should start a new trace
+	var output bytes.Buffer
+	tracer, err := NewSpanTracer(NewJSONSpanExporter(&output), TraceContext{})
+	if err != nil {
+		t.Fatal("unable to create tracer:", err)
+	} else if !tracer.Parent().IsValid() {
+		t.Fatal("tracer did not start a new trace")
+	}
+
+	// An event that wasn't started shouldn't produce a span
+	tracer.FinishOperation(&TraceEvent{Operation: OperationPing})
+	if output.Len() != 0 {
+		t.Fatal("unstarted event produced a span")
+	}
+
+	// A started event should be exported as a single line of JSON
+	event := &TraceEvent{Operation: OperationPing, Start: time.Now()}
+	tracer.StartOperation(event)
+	event.Finish = time.Now()
+	tracer.FinishOperation(event)
+	line, err := output.ReadBytes('\n')
+	if err != nil {
+		t.Fatal("unable to read exported span:", err)
+	} else if output.Len() != 0 {
+		t.Error("span export is not a single line")
+	}
+	var span Span
+	if err := json.Unmarshal(line, &span); err != nil {
+		t.Fatal("unable to decode exported span:", err)
+	}
+	if span.Name != OperationPing ||
+		span.Context().TraceID != tracer.Parent().TraceID {
+		t.Error("unexpected exported span:", span)
+	}
+}
@@ -1,13 +1,40 @@
 #include "ipc_connection_manager.h"
 
+// C standard includes
+#include <cerrno>
+
 // Standard includes
+#include <memory>
 #include <stdexcept>
+#include <system_error>
+#include <utility>
 
 // POSIX includes
 #include <unistd.h>
+#include <sys/stat.h>
+#include <sys/socket.h>
 
 
 gib::IPCConnectionManager::IPCConnectionManager() :
+IPCConnectionManager(false, std::vector<std::string>()) {
+
+}
+
+
+gib::IPCConnectionManager::IPCConnectionManager(
+    std::vector<std::string> allowed_endpoints
+) :
+IPCConnectionManager(true, std::move(allowed_endpoints)) {
+
+}
+
+
+gib::IPCConnectionManager::IPCConnectionManager(
+    bool restricted,
+    std::vector<std::string> allowed_endpoints
+) :
+_restricted(restricted),
+_allowed_endpoints(std::move(allowed_endpoints)),
 _io_service(),
 _io_service_pump([this]() {
     // Create a work object to keep the I/O service loop from exiting when there
@@ -41,11 +68,204 @@ gib::IPCConnectionManager::~IPCConnectionManager() {
     // manually only so we can remove their endpoints - we let connections be
     // closed when their map destructs)
     _listeners.clear();
+    _packet_listeners.clear();
 
     // Iterate over endpoint paths and remove them from disk and the map
     for (auto&& endpoint : _listener_endpoints) {
-        unlink(endpoint.second.c_str());
+        remove_endpoint(endpoint.second);
+    }
+}
+
+
+std::string gib::IPCConnectionManager::resolve_endpoint(
+    const std::string & endpoint,
+    bool create_directory
+) {
+    // Handle abstract namespace endpoints
+    if (!endpoint.empty() && endpoint[0] == '@') {
+#if defined(__linux__)
+        return std::string(1, '\0') + endpoint.substr(1);
+#else
+        throw std::invalid_argument(
+            "abstract namespace sockets not supported on this platform"
+        );
+#endif
+    }
+
+    // If this isn't a logical endpoint, then there's nothing to resolve
+    const std::string prefix = "ipc:";
+    if (endpoint.compare(0, prefix.size(), prefix) != 0) {
+        return endpoint;
+    }
+
+    // Extract and validate the name
+    std::string name = endpoint.substr(prefix.size());
+    if (name.empty()) {
+        throw std::invalid_argument("empty logical endpoint name");
+    }
+    for (char c : name) {
+        bool valid = (c >= 'a' && c <= 'z') ||
+                     (c >= 'A' && c <= 'Z') ||
+                     (c >= '0' && c <= '9') ||
+                     c == '.' || c == '-' || c == '_';
+        if (!valid) {
+            throw std::invalid_argument("invalid logical endpoint name");
+        }
+    }
+
+    // Compute the runtime directory.  This needs to stay in sync with the
+    // runtimeDirectory function in the Go package.
+    std::string directory;
+    bool responsible = false;
+    const char * runtime_directory = std::getenv("XDG_RUNTIME_DIR");
+    const char * temporary_directory = std::getenv("TMPDIR");
+    if (temporary_directory == nullptr || *temporary_directory == '\0') {
+        temporary_directory = "/tmp";
+    }
+    if (runtime_directory != nullptr && *runtime_directory != '\0') {
+        directory = runtime_directory;
+    } else {
+#if defined(__APPLE__)
+        directory = temporary_directory;
+#else
+        directory = std::string(temporary_directory) +
+                    "/gib-" +
+                    std::to_string(getuid());
+        responsible = true;
+#endif
+    }
+
+    // Strip any trailing separators (OS X's TMPDIR has one)
+    while (directory.size() > 1 && directory.back() == '/') {
+        directory.pop_back();
+    }
+
+    // If we're responsible for the directory, create it if requested and
+    // necessary, and then verify that it's a real directory that's private to
+    // us (since another user could have created it first in order to plant or
+    // intercept sockets)
+    if (responsible) {
+        if (create_directory &&
+            mkdir(directory.c_str(), 0700) != 0 &&
+            errno != EEXIST) {
+            throw std::system_error(
+                errno,
+                std::system_category(),
+                "unable to create runtime directory"
+            );
+        }
+        struct stat info;
+        if (lstat(directory.c_str(), &info) != 0) {
+            throw std::system_error(
+                errno,
+                std::system_category(),
+                "unable to inspect runtime directory"
+            );
+        } else if (!S_ISDIR(info.st_mode)) {
+            throw std::runtime_error("runtime directory is not a directory");
+        } else if (info.st_uid != getuid()) {
+            throw std::runtime_error(
+                "runtime directory not owned by current user"
+            );
+        } else if ((info.st_mode & 0077) != 0) {
+            throw std::runtime_error(
+                "runtime directory accessible by other users"
+            );
+        }
+    }
+
+    // All done
+    return directory + "/" + name + ".sock";
+}
+
+
+bool gib::IPCConnectionManager::contains_traversal(
+    const std::string & endpoint
+) {
+    // Check each segment, treating both kinds of slashes as separators
+    std::size_t start = 0;
+    for (std::size_t i = 0; i <= endpoint.size(); ++i) {
+        if (i == endpoint.size() || endpoint[i] == '/' || endpoint[i] == '\\') {
+            if (endpoint.compare(start, i - start, "..") == 0) {
+                return true;
+            }
+            start = i + 1;
+        }
+    }
+
+    // No traversal
+    return false;
+}
+
+
+bool gib::IPCConnectionManager::match_endpoint_pattern(
+    const std::string & pattern,
+    const std::string & endpoint
+) {
+    // Track our positions in each string, as well as the most recent star (and
+    // the endpoint position it was tried against) for backtracking
+    std::size_t p = 0, e = 0;
+    std::size_t star = std::string::npos, star_endpoint = 0;
+
+    // Walk the endpoint
+    while (e < endpoint.size()) {
+        if (p < pattern.size() &&
+            (pattern[p] == '?' || pattern[p] == endpoint[e])) {
+            ++p;
+            ++e;
+        } else if (p < pattern.size() && pattern[p] == '*') {
+            star = p;
+            star_endpoint = e;
+            ++p;
+        } else if (star != std::string::npos) {
+            p = star + 1;
+            e = ++star_endpoint;
+        } else {
+            return false;
+        }
+    }
+
+    // Any remaining pattern characters must all be stars
+    while (p < pattern.size() && pattern[p] == '*') {
+        ++p;
+    }
+    return p == pattern.size();
+}
+
+
+bool gib::IPCConnectionManager::endpoint_permitted(
+    const std::string & endpoint
+) const {
+    // If we're unrestricted, everything is permitted
+    if (!_restricted) {
+        return true;
+    }
+
+    // Reject path traversal outright, since stars would otherwise match it
+    if (contains_traversal(endpoint)) {
+        return false;
+    }
+
+    // Check each pattern
+    for (auto&& pattern : _allowed_endpoints) {
+        if (match_endpoint_pattern(pattern, endpoint)) {
+            return true;
+        }
     }
+
+    // No match
+    return false;
+}
+
+
+void gib::IPCConnectionManager::remove_endpoint(const std::string & path) {
+    // Abstract namespace sockets disappear when closed
+    if (path.empty() || path[0] == '\0') {
+        return;
+    }
+
+    // Remove the socket file
+    unlink(path.c_str());
 }
 
 
@@ -53,6 +273,21 @@ void gib::IPCConnectionManager::connect_async(
     const std::string & endpoint,
     std::function<void(std::int32_t, const std::string &)> handler
 ) {
+    // Enforce the endpoint allowlist
+    if (!endpoint_permitted(endpoint)) {
+        handler(-1, "endpoint not permitted");
+        return;
+    }
+
+    // Resolve the endpoint
+    std::string path;
+    try {
+        path = resolve_endpoint(endpoint, false);
+    } catch (const std::exception & e) {
+        handler(-1, e.what());
+        return;
+    }
+
     // Lock the maps
     std::lock_guard<std::mutex> lock(_lock);
 
@@ -80,7 +315,7 @@ void gib::IPCConnectionManager::connect_async(
 
     // Connect asynchronously
     _connections.find(connection_id)->second.async_connect(
-        asio::local::stream_protocol::endpoint(endpoint),
+        asio::local::stream_protocol::endpoint(path),
         [this, connection_id, handler](const asio::error_code & error) {
             // Check for an error
             if (error) {
@@ -117,11 +352,15 @@ void gib::IPCConnectionManager::connection_read_async(
     // Lock the maps
     std::lock_guard<std::mutex> lock(_lock);
 
-    // Verify that the connection exists
+    // Verify that the connection exists and is a stream connection
     auto connection_entry = _connections.find(connection_id);
     if (connection_entry == _connections.end()) {
         // Call the handler with the error
-        handler(0, "invalid connection id");
+        if (_packet_connections.count(connection_id) != 0) {
+            handler(0, "stream read on packet connection");
+        } else {
+            handler(0, "invalid connection id");
+        }
 
         // Bail
         return;
@@ -181,10 +420,18 @@ void gib::IPCConnectionManager::connection_write_async(
     // Lock the maps
     std::lock_guard<std::mutex> lock(_lock);
 
-    // Verify that the connection exists
+    // Verify that the connection exists and is a stream connection
     auto connection_entry = _connections.find(connection_id);
     if (connection_entry == _connections.end()) {
-        handler(0, "invalid connection id");
+        // Call the handler with the error
+        if (_packet_connections.count(connection_id) != 0) {
+            handler(0, "stream write on packet connection");
+        } else {
+            handler(0, "invalid connection id");
+        }
+
+        // Bail
+        return;
     }
 
     // Handle the case of 0 write length.  It's technically not an error, but
@@ -229,17 +476,85 @@ void gib::IPCConnectionManager::connection_close_async(
     // Lock the maps
     std::lock_guard<std::mutex> lock(_lock);
 
-    // Verify that the connection exists
+    // Verify that the connection exists, routing packet connections to the
+    // packet connection map
     auto connection_entry = _connections.find(connection_id);
-    if (connection_entry == _connections.end()) {
-        handler("invalid connection id");
+    if (connection_entry != _connections.end()) {
+        // There is no asynchronous close method for sockets, so just close it
+        connection_entry->second.close();
+
+        // Remove it from the connection map
+        _connections.erase(connection_entry);
+    } else {
+        // Check whether or not this is a packet connection
+        auto packet_connection_entry = _packet_connections.find(connection_id);
+        if (packet_connection_entry == _packet_connections.end()) {
+            handler("invalid connection id");
+            return;
+        }
+
+        // Close it and remove it from the packet connection map
+        packet_connection_entry->second.close();
+        _packet_connections.erase(packet_connection_entry);
     }
 
-    // There is no asynchronous close method for sockets, so just close it
-    connection_entry->second.close();
+    // Notify the handler
+    handler("");
+}
 
-    // Remove it from the connection map
-    _connections.erase(connection_entry);
+
+void gib::IPCConnectionManager::connection_close_read_async(
+    std::int32_t connection_id,
+    std::function<void(const std::string &)> handler
+) {
+    connection_shutdown_async(
+        connection_id,
+        asio::socket_base::shutdown_receive,
+        handler
+    );
+}
+
+
+void gib::IPCConnectionManager::connection_close_write_async(
+    std::int32_t connection_id,
+    std::function<void(const std::string &)> handler
+) {
+    connection_shutdown_async(
+        connection_id,
+        asio::socket_base::shutdown_send,
+        handler
+    );
+}
+
+
+void gib::IPCConnectionManager::connection_shutdown_async(
+    std::int32_t connection_id,
+    asio::socket_base::shutdown_type what,
+    std::function<void(const std::string &)> handler
+) {
+    // Lock the maps
+    std::lock_guard<std::mutex> lock(_lock);
+
+    // Verify that the connection exists
+    // NOTE: Packet connections support shutdown as well
+    asio::error_code error;
+    auto connection_entry = _connections.find(connection_id);
+    if (connection_entry != _connections.end()) {
+        // There is no asynchronous shutdown method for sockets, so just shut it
+        // down.  The connection remains in the map until it is closed.
+        connection_entry->second.shutdown(what, error);
+    } else {
+        auto packet_connection_entry = _packet_connections.find(connection_id);
+        if (packet_connection_entry == _packet_connections.end()) {
+            handler("invalid connection id");
+            return;
+        }
+        packet_connection_entry->second.shutdown(what, error);
+    }
+    if (error) {
+        handler(error.message());
+        return;
+    }
 
     // Notify the handler
     handler("");
@@ -250,6 +565,21 @@ void gib::IPCConnectionManager::listen_async(
     const std::string & endpoint,
     std::function<void(std::int32_t, const std::string &)> handler
 ) {
+    // Enforce the endpoint allowlist
+    if (!endpoint_permitted(endpoint)) {
+        handler(-1, "endpoint not permitted");
+        return;
+    }
+
+    // Resolve the endpoint
+    std::string path;
+    try {
+        path = resolve_endpoint(endpoint, true);
+    } catch (const std::exception & e) {
+        handler(-1, e.what());
+        return;
+    }
+
     // Lock the maps
     std::lock_guard<std::mutex> lock(_lock);
 
@@ -268,7 +598,7 @@ void gib::IPCConnectionManager::listen_async(
         opened = true;
 
         // Bind the listener
-        listener.bind(asio::local::stream_protocol::endpoint(endpoint));
+        listener.bind(asio::local::stream_protocol::endpoint(path));
         bound = true;
 
         // Start listening
@@ -282,7 +612,7 @@ void gib::IPCConnectionManager::listen_async(
         // Remove its endpoint if it is bound (if it isn't bound, it may have
         // failed because it is in use by another process)
         if (bound) {
-            unlink(endpoint.c_str());
+            remove_endpoint(path);
         }
 
         // Notify the handler
@@ -296,7 +626,7 @@ void gib::IPCConnectionManager::listen_async(
     // overflow the maximum value, because we use -1 as the invalid identifier.
     if (_next_listener_id < 0) {
         listener.close();
-        unlink(endpoint.c_str());
+        remove_endpoint(path);
         handler(-1, "listener ids exhausted");
         return;
     }
@@ -310,7 +640,7 @@ void gib::IPCConnectionManager::listen_async(
     );
 
     // Store the endpoint for later cleanup
-    _listener_endpoints[listener_id] = endpoint;
+    _listener_endpoints[listener_id] = path;
 
     // Notify the handler
     handler(listener_id, "");
@@ -327,6 +657,16 @@ void gib::IPCConnectionManager::listener_accept_async(
     // Verify that the listener exists
     auto listener_entry = _listeners.find(listener_id);
     if (listener_entry == _listeners.end()) {
+        // Check whether or not this is a packet listener
+        auto packet_listener_entry = _packet_listeners.find(listener_id);
+        if (packet_listener_entry != _packet_listeners.end()) {
+            packet_listener_accept_async(
+                packet_listener_entry->second,
+                handler
+            );
+            return;
+        }
+
         // Call the handler with the error
         handler(-1, "invalid listener id");
 
@@ -395,15 +735,24 @@ void gib::IPCConnectionManager::listener_close_async(
 
     // Verify that the listener exists
     auto listener_entry = _listeners.find(listener_id);
-    if (listener_entry == _listeners.end()) {
-        handler("invalid listener id");
-    }
-
-    // There is no asynchronous close method for listeners, so just close it
-    listener_entry->second.close();
+    if (listener_entry != _listeners.end()) {
+        // There is no asynchronous close method for listeners, so just close it
+        listener_entry->second.close();
+
+        // Remove it from the listener map
+        _listeners.erase(listener_entry);
+    } else {
+        // Check whether or not this is a packet listener
+        auto packet_listener_entry = _packet_listeners.find(listener_id);
+        if (packet_listener_entry == _packet_listeners.end()) {
+            handler("invalid listener id");
+            return;
+        }
 
-    // Remove it from the listener map
-    _listeners.erase(listener_entry);
+        // Close it and remove it from the packet listener map
+        packet_listener_entry->second.close();
+        _packet_listeners.erase(packet_listener_entry);
+    }
 
     // Get the listener path
     auto listener_endpoint_entry = _listener_endpoints.find(listener_id);
@@ -414,7 +763,7 @@ void gib::IPCConnectionManager::listener_close_async(
     }
 
     // Remove the listener endpoint from disk
-    unlink(listener_endpoint_entry->second.c_str());
+    remove_endpoint(listener_endpoint_entry->second);
 
     // Remove it from listener endpoint map
     _listener_endpoints.erase(listener_endpoint_entry);
@@ -422,3 +771,323 @@ void gib::IPCConnectionManager::listener_close_async(
     // Notify the handler
     handler("");
 }
+
+
+void gib::IPCConnectionManager::ping_async(
+    std::function<void(const std::string &)> handler
+) {
+    // Round-trip through the I/O service
+    _io_service.post([handler]() {
+        handler("");
+    });
+}
+
+
+void gib::IPCConnectionManager::connect_packet_async(
+    const std::string & endpoint,
+    std::function<void(std::int32_t, const std::string &)> handler
+) {
+#if defined(__linux__)
+    // Enforce the endpoint allowlist
+    if (!endpoint_permitted(endpoint)) {
+        handler(-1, "endpoint not permitted");
+        return;
+    }
+
+    // Resolve the endpoint
+    std::string path;
+    try {
+        path = resolve_endpoint(endpoint, false);
+    } catch (const std::exception & e) {
+        handler(-1, e.what());
+        return;
+    }
+
+    // Lock the maps
+    std::lock_guard<std::mutex> lock(_lock);
+
+    // Compute the next connection id.  Watch for overflow, because we use -1 as
+    // the invalid identifier.
+    if (_next_connection_id < 0) {
+        handler(-1, "connection ids exhausted");
+        return;
+    }
+    std::int32_t connection_id = _next_connection_id++;
+
+    // Create the socket in-place (see the note in connect_async)
+    _packet_connections.emplace(
+        std::piecewise_construct,
+        std::forward_as_tuple(connection_id),
+        std::forward_as_tuple(_io_service)
+    );
+
+    // Connect asynchronously.  The generic endpoint takes its address family
+    // from the Unix domain socket endpoint and its socket type (SOCK_SEQPACKET)
+    // from the protocol.
+    _packet_connections.find(connection_id)->second.async_connect(
+        asio::generic::seq_packet_protocol::endpoint(
+            asio::local::stream_protocol::endpoint(path)
+        ),
+        [this, connection_id, handler](const asio::error_code & error) {
+            // Check for an error
+            if (error) {
+                // Lock the maps (see the note in connect_async)
+                std::lock_guard<std::mutex> lock(_lock);
+
+                // Erase the entry
+                _packet_connections.erase(connection_id);
+
+                // Notify the handler of the error
+                handler(-1, error.message());
+            } else {
+                // Notify the handler of success
+                handler(connection_id, "");
+            }
+        }
+    );
+#else
+    handler(-1, "packet mode IPC not supported on this platform");
+#endif
+}
+
+
+void gib::IPCConnectionManager::connection_read_message_async(
+    std::int32_t connection_id,
+    void * buffer,
+    std::size_t length,
+    std::function<void(std::size_t, const std::string &)> handler
+) {
+    // Lock the maps
+    std::lock_guard<std::mutex> lock(_lock);
+
+    // Verify that the connection exists and is a packet connection
+    auto connection_entry = _packet_connections.find(connection_id);
+    if (connection_entry == _packet_connections.end()) {
+        if (_connections.count(connection_id) != 0) {
+            handler(0, "message read on stream connection");
+        } else {
+            handler(0, "invalid connection id");
+        }
+        return;
+    }
+
+    // Create storage for the received message flags, which needs to persist
+    // until the handler is invoked
+    auto flags = std::make_shared<asio::socket_base::message_flags>(0);
+
+    // Receive asynchronously.  Each receive on a SOCK_SEQPACKET socket returns
+    // at most one message, and if the message doesn't fit in the buffer, the
+    // remainder is discarded and MSG_TRUNC is set.
+    connection_entry->second.async_receive(
+        asio::buffer(buffer, length),
+        *flags,
+        [flags, handler](
+            const asio::error_code & error,
+            std::size_t bytes_transferred
+        ) {
+            // Check for an error
+            if (error) {
+                handler(0, error.message());
+                return;
+            }
+
+            // Check for truncated or oversized messages
+            if ((*flags & MSG_TRUNC) != 0 ||
+                bytes_transferred > maximum_message_size) {
+                handler(0, "message too large");
+                return;
+            }
+
+            // Notify the handler.  A zero-length message indicates that the
+            // peer has closed the connection, since empty messages can't be
+            // sent.
+            handler(bytes_transferred, "");
+        }
+    );
+}
+
+
+void gib::IPCConnectionManager::connection_write_message_async(
+    std::int32_t connection_id,
+    const void * buffer,
+    std::size_t length,
+    std::function<void(std::size_t, const std::string &)> handler
+) {
+    // Lock the maps
+    std::lock_guard<std::mutex> lock(_lock);
+
+    // Verify that the connection exists and is a packet connection
+    auto connection_entry = _packet_connections.find(connection_id);
+    if (connection_entry == _packet_connections.end()) {
+        if (_connections.count(connection_id) != 0) {
+            handler(0, "message write on stream connection");
+        } else {
+            handler(0, "invalid connection id");
+        }
+        return;
+    }
+
+    // Validate the message size.  Empty messages are indistinguishable from
+    // end-of-stream, so they're rejected.
+    if (length == 0) {
+        handler(0, "empty messages not supported");
+        return;
+    } else if (length > maximum_message_size) {
+        handler(0, "message too large");
+        return;
+    }
+
+    // Send asynchronously.  Sends on a SOCK_SEQPACKET socket are atomic, so
+    // there's no need to loop as asio::async_write would.
+    connection_entry->second.async_send(
+        asio::buffer(buffer, length),
+        0,
+        [handler](
+            const asio::error_code & error,
+            std::size_t bytes_transferred
+        ) {
+            // Check for an error
+            std::string error_message = "";
+            if (error) {
+                error_message = error.message();
+            }
+
+            // Notify the handler
+            handler(bytes_transferred, error_message);
+        }
+    );
+}
+
+
+void gib::IPCConnectionManager::listen_packet_async(
+    const std::string & endpoint,
+    std::function<void(std::int32_t, const std::string &)> handler
+) {
+#if defined(__linux__)
+    // Enforce the endpoint allowlist
+    if (!endpoint_permitted(endpoint)) {
+        handler(-1, "endpoint not permitted");
+        return;
+    }
+
+    // Resolve the endpoint
+    std::string path;
+    try {
+        path = resolve_endpoint(endpoint, true);
+    } catch (const std::exception & e) {
+        handler(-1, e.what());
+        return;
+    }
+
+    // Lock the maps
+    std::lock_guard<std::mutex> lock(_lock);
+
+    // Create the listener
+    asio::generic::seq_packet_protocol::endpoint listener_endpoint(
+        asio::local::stream_protocol::endpoint(path)
+    );
+    asio::generic::seq_packet_protocol::acceptor listener(_io_service);
+
+    // Try to initialize the listener, cleaning up if initialization fails (see
+    // the notes in listen_async)
+    bool opened = false;
+    bool bound = false;
+    try {
+        // Open the listener
+        listener.open(listener_endpoint.protocol());
+        opened = true;
+
+        // Bind the listener
+        listener.bind(listener_endpoint);
+        bound = true;
+
+        // Start listening
+        listener.listen();
+    } catch (const asio::system_error & e) {
+        // Close the listener if it is open
+        if (opened) {
+            listener.close();
+        }
+
+        // Remove its endpoint if it is bound
+        if (bound) {
+            remove_endpoint(path);
+        }
+
+        // Notify the handler
+        handler(-1, e.what());
+
+        // Bail
+        return;
+    }
+
+    // Compute the next listener id.  Packet listeners share the listener id
+    // space so that listener_accept_async and listener_close_async can handle
+    // both types.
+    if (_next_listener_id < 0) {
+        listener.close();
+        remove_endpoint(path);
+        handler(-1, "listener ids exhausted");
+        return;
+    }
+    std::int32_t listener_id = _next_listener_id++;
+
+    // Store the listener
+    _packet_listeners.emplace(
+        std::piecewise_construct,
+        std::forward_as_tuple(listener_id),
+        std::forward_as_tuple(std::move(listener))
+    );
+
+    // Store the endpoint for later cleanup
+    _listener_endpoints[listener_id] = path;
+
+    // Notify the handler
+    handler(listener_id, "");
+#else
+    handler(-1, "packet mode IPC not supported on this platform");
+#endif
+}
+
+
+void gib::IPCConnectionManager::packet_listener_accept_async(
+    asio::generic::seq_packet_protocol::acceptor & listener,
+    std::function<void(std::int32_t, const std::string &)> handler
+) {
+    // Compute the next connection id.  Watch for overflow, because we use -1 as
+    // the invalid identifier.
+    if (_next_connection_id < 0) {
+        handler(-1, "connection ids exhausted");
+        return;
+    }
+    std::int32_t connection_id = _next_connection_id++;
+
+    // Create the socket that will represent the accepted connection in-place
+    // (see the note in listener_accept_async)
+    _packet_connections.emplace(
+        std::piecewise_construct,
+        std::forward_as_tuple(connection_id),
+        std::forward_as_tuple(_io_service)
+    );
+
+    // Accept asynchronously
+    listener.async_accept(
+        _packet_connections.find(connection_id)->second,
+        [this, connection_id, handler](const asio::error_code & error) {
+            // Check for an error
+            if (error) {
+                // Lock the maps (see the note in listener_accept_async)
+                std::lock_guard<std::mutex> lock(_lock);
+
+                // Erase the connection
+                _packet_connections.erase(connection_id);
+
+                // Notify the handler of the error
+                handler(-1, error.message());
+            } else {
+                // Notify the handler of success
+                handler(connection_id, "");
+            }
+        }
+    );
+}
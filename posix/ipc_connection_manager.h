@@ -12,6 +12,7 @@
 #include <thread>
 #include <mutex>
 #include <map>
+#include <vector>
 
 // asio includes
 #define ASIO_STANDALONE
@@ -33,9 +34,19 @@ class IPCConnectionManager final {
 
 public:
 
-    // Constructor
+    // Constructor.  The resulting connection manager permits connections and
+    // listeners on any endpoint.
     IPCConnectionManager();
 
+    // Constructor.  The resulting connection manager only permits connections
+    // and listeners on endpoints matching one of the specified patterns, and
+    // fails other requests with the error "endpoint not permitted".  Patterns
+    // are matched against endpoints before resolution, with '*' matching any
+    // sequence of characters and '?' matching any single byte, using the same
+    // rules as the Go EndpointPolicy type.  Endpoints containing ".." path
+    // segments are never permitted.
+    explicit IPCConnectionManager(std::vector<std::string> allowed_endpoints);
+
     // Disable copying
     IPCConnectionManager(const IPCConnectionManager &) = delete;
     IPCConnectionManager& operator=(const IPCConnectionManager &) = delete;
@@ -43,6 +54,11 @@ public:
     // Destructor
     ~IPCConnectionManager();
 
+    // The largest message that can be sent or received over a packet
+    // connection.  This needs to stay in sync with MaximumMessageSize in the Go
+    // package.
+    static const std::size_t maximum_message_size = 64 * 1024;
+
     // Asynchronously create a new connection
     void connect_async(
         const std::string & endpoint,
@@ -75,6 +91,19 @@ public:
         std::function<void(const std::string &)> handler
     );
 
+    // Asynchronously shut down the read side of a connection
+    void connection_close_read_async(
+        std::int32_t connection_id,
+        std::function<void(const std::string &)> handler
+    );
+
+    // Asynchronously shut down the write side of a connection, signaling
+    // end-of-stream to the peer
+    void connection_close_write_async(
+        std::int32_t connection_id,
+        std::function<void(const std::string &)> handler
+    );
+
     // Asynchronously begin listening
     void listen_async(
         const std::string & endpoint,
@@ -93,8 +122,118 @@ public:
         std::function<void(const std::string &)> handler
     );
 
+    // Asynchronously create a new packet connection.  Packet connections use
+    // SOCK_SEQPACKET sockets and preserve message boundaries.  They are only
+    // supported on Linux - on other platforms the handler is invoked with the
+    // error "packet mode IPC not supported on this platform".  Packet
+    // connections share the connection id space with stream connections and
+    // are closed with connection_close_async.
+    void connect_packet_async(
+        const std::string & endpoint,
+        std::function<void(std::int32_t, const std::string &)> handler
+    );
+
+    // Asynchronously read a single message from a packet connection.  The
+    // client is responsible for ensuring that the underlying buffer persists
+    // for the duration of the read.  The buffer should be larger than
+    // maximum_message_size so that oversized messages can be detected - if a
+    // message doesn't fit, it is discarded and the handler is invoked with the
+    // error "message too large".  End-of-stream is indicated by a zero-length
+    // read without an error.
+    void connection_read_message_async(
+        std::int32_t connection_id,
+        void * buffer,
+        std::size_t length,
+        std::function<void(std::size_t, const std::string &)> handler
+    );
+
+    // Asynchronously write a single message to a packet connection.  The
+    // client is responsible for ensuring that the underlying buffer persists
+    // for the duration of the write.
+    void connection_write_message_async(
+        std::int32_t connection_id,
+        const void * buffer,
+        std::size_t length,
+        std::function<void(std::size_t, const std::string &)> handler
+    );
+
+    // Asynchronously begin listening for packet connections.  Accepted
+    // connections are packet connections.  Packet listeners share the listener
+    // id space with stream listeners and are accepted from and closed with
+    // listener_accept_async and listener_close_async.
+    void listen_packet_async(
+        const std::string & endpoint,
+        std::function<void(std::int32_t, const std::string &)> handler
+    );
+
+    // Asynchronously verify that the connection manager is responsive.  The
+    // handler is always invoked from the I/O pumping thread (with an empty
+    // error), so a prompt response indicates that the pump isn't stalled.
+    void ping_async(std::function<void(const std::string &)> handler);
+
 private:
 
+    // Common constructor implementation
+    IPCConnectionManager(
+        bool restricted,
+        std::vector<std::string> allowed_endpoints
+    );
+
+    // Resolves logical endpoints (those of the form "ipc:NAME") to socket
+    // paths in a per-user runtime directory, using the same rules as the Go
+    // ResolveEndpoint function.  On Linux, abstract namespace endpoints (those
+    // with a leading '@') are converted to the leading null byte form expected
+    // by the socket API, and on other platforms they are rejected with
+    // std::invalid_argument.  Other endpoints are returned unchanged.  If
+    // create_directory is true, the runtime directory will be created if it
+    // doesn't exist and we are responsible for it.  A runtime directory that we
+    // are responsible for must be a real directory owned by the current user
+    // and inaccessible to others.  Throws std::invalid_argument for malformed
+    // logical endpoints, std::system_error if directory creation or inspection
+    // fails, and std::runtime_error if the directory isn't private.
+    static std::string resolve_endpoint(
+        const std::string & endpoint,
+        bool create_directory
+    );
+
+    // Checks whether or not an endpoint contains a ".." path segment
+    static bool contains_traversal(const std::string & endpoint);
+
+    // Matches an endpoint against an allowlist pattern
+    static bool match_endpoint_pattern(
+        const std::string & pattern,
+        const std::string & endpoint
+    );
+
+    // Checks whether or not an endpoint is permitted by the allowlist
+    bool endpoint_permitted(const std::string & endpoint) const;
+
+    // Common implementation of connection_close_read_async and
+    // connection_close_write_async
+    void connection_shutdown_async(
+        std::int32_t connection_id,
+        asio::socket_base::shutdown_type what,
+        std::function<void(const std::string &)> handler
+    );
+
+    // Accepts a connection on a packet listener.  The caller must hold the
+    // lock.
+    void packet_listener_accept_async(
+        asio::generic::seq_packet_protocol::acceptor & listener,
+        std::function<void(std::int32_t, const std::string &)> handler
+    );
+
+    // Removes a listener's socket file from disk.  Resolved abstract namespace
+    // endpoints (which begin with a null byte) have no filesystem presence and
+    // are ignored.
+    static void remove_endpoint(const std::string & path);
+
+    // Whether or not endpoints are restricted to the allowlist
+    const bool _restricted;
+
+    // The endpoint allowlist patterns
+    const std::vector<std::string> _allowed_endpoints;
+
     // The underlying I/O service
     asio::io_service _io_service;
 
@@ -116,7 +255,20 @@ private:
     // Map from listener id to acceptor
     std::map<std::int32_t, asio::local::stream_protocol::acceptor> _listeners;
 
-    // Map from listener id to endpoint (socket filesystem path)
+    // Map from connection id to packet connection socket
+    std::map<
+        std::int32_t,
+        asio::generic::seq_packet_protocol::socket
+    > _packet_connections;
+
+    // Map from listener id to packet acceptor
+    std::map<
+        std::int32_t,
+        asio::generic::seq_packet_protocol::acceptor
+    > _packet_listeners;
+
+    // Map from listener id to endpoint (socket filesystem path or abstract
+    // namespace name) for both stream and packet listeners
     // NOTE: We have to manually track this so that we can clean up socket paths
     // from disk.  Asio doesn't do this by default, but Go does, so to keep
     // consistency we perform this removal on listener creation failure,
@@ -1,5 +1,6 @@
 using System;
 using System.Collections.Generic;
+using System.IO;
 using System.IO.Pipes;
 using System.Threading;
 using System.Threading.Tasks;
@@ -8,36 +9,232 @@ namespace GopherJSIPCBridge
 {
     public class IPCConnectionManager
     {
+        // The largest message that can be sent or received over a packet
+        // connection.  This needs to stay in sync with MaximumMessageSize in
+        // the Go package.
+        public const Int32 MaximumMessageSize = 64 * 1024;
+
         // The next connection id
         private Int32 _nextConnectionId;
 
         // Map from connection id to pipe stream.  Values may be either a
-        // NamedPipeClientStream or NamedPipeServerStream.
+        // NamedPipeClientStream or NamedPipeServerStream.  Packet connections
+        // are stored here as well, and can be distinguished by their message
+        // read mode.
         private Dictionary<Int32, PipeStream> _connections;
 
         // The next listener id
         private Int32 _nextListenerId;
 
-        // Map from listener id to named pipe name.  A new NamedPipeServerStream
-        // has to be created for each accept call, so we simply store the
-        // endpoint's name (i.e. NAME in \\server\pipe\NAME).
-        private Dictionary<Int32, string> _listeners;
+        // Map from listener id to named pipe name and transmission mode.  A new
+        // NamedPipeServerStream has to be created for each accept call, so we
+        // simply store the endpoint's name (i.e. NAME in \\server\pipe\NAME)
+        // and the mode (message mode for packet listeners) to create it with.
+        private Dictionary<
+            Int32,
+            Tuple<string, PipeTransmissionMode>
+        > _listeners;
+
+        // The endpoint allowlist patterns, or null if all endpoints are
+        // permitted
+        private List<string> _allowedEndpoints;
+
+        // Constructor.  The resulting connection manager permits connections
+        // and listeners on any endpoint.
+        public IPCConnectionManager() : this(null)
+        {
+        }
 
-        // Constructor
-        public IPCConnectionManager()
+        // Constructor.  The resulting connection manager only permits
+        // connections and listeners on endpoints matching one of the specified
+        // patterns, and fails other requests with the error "endpoint not
+        // permitted".  Patterns are matched against endpoints before
+        // resolution, with '*' matching any sequence of characters and '?'
+        // matching any single character, using the same rules as the Go
+        // EndpointPolicy type.  Endpoints containing ".." path segments are
+        // never permitted.  Passing null permits all endpoints.
+        public IPCConnectionManager(IEnumerable<string> allowedEndpoints)
         {
+            // Store the allowlist
+            if (allowedEndpoints != null)
+            {
+                _allowedEndpoints = new List<string>(allowedEndpoints);
+            }
+
             // Set up identifiers
             _nextConnectionId = 0;
             _nextListenerId = 0;
 
             // Create our maps
             _connections = new Dictionary<Int32, PipeStream>();
-            _listeners = new Dictionary<Int32, string>();
+            _listeners =
+                new Dictionary<Int32, Tuple<string, PipeTransmissionMode>>();
+        }
+
+        // Checks whether or not an endpoint contains a ".." path segment
+        private static bool ContainsTraversal(string endpoint)
+        {
+            // Check each segment, treating both kinds of slashes as
+            // separators
+            foreach (var segment in endpoint.Split('/', '\\'))
+            {
+                if (segment == "..")
+                {
+                    return true;
+                }
+            }
+
+            // No traversal
+            return false;
+        }
+
+        // Matches an endpoint against an allowlist pattern
+        private static bool MatchEndpointPattern(string pattern, string endpoint)
+        {
+            // Track our positions in each string, as well as the most recent
+            // star (and the endpoint position it was tried against) for
+            // backtracking
+            int p = 0, e = 0;
+            int star = -1, starEndpoint = 0;
+
+            // Walk the endpoint
+            while (e < endpoint.Length)
+            {
+                if (p < pattern.Length &&
+                    (pattern[p] == '?' || pattern[p] == endpoint[e]))
+                {
+                    p++;
+                    e++;
+                }
+                else if (p < pattern.Length && pattern[p] == '*')
+                {
+                    star = p;
+                    starEndpoint = e;
+                    p++;
+                }
+                else if (star != -1)
+                {
+                    p = star + 1;
+                    e = ++starEndpoint;
+                }
+                else
+                {
+                    return false;
+                }
+            }
+
+            // Any remaining pattern characters must all be stars
+            while (p < pattern.Length && pattern[p] == '*')
+            {
+                p++;
+            }
+            return p == pattern.Length;
+        }
+
+        // Checks whether or not an endpoint is permitted by the allowlist
+        private bool EndpointPermitted(string endpoint)
+        {
+            // If there's no allowlist, everything is permitted
+            if (_allowedEndpoints == null)
+            {
+                return true;
+            }
+
+            // Reject path traversal outright, since stars would otherwise
+            // match it
+            if (ContainsTraversal(endpoint))
+            {
+                return false;
+            }
+
+            // Check each pattern
+            foreach (var pattern in _allowedEndpoints)
+            {
+                if (MatchEndpointPattern(pattern, endpoint))
+                {
+                    return true;
+                }
+            }
+
+            // No match
+            return false;
+        }
+
+        // Resolves logical endpoints (those of the form "ipc:NAME") to
+        // per-user named pipe names (\\.\pipe\USER.NAME), using the same
+        // rules as the Go ResolveEndpoint function.  Other endpoints are
+        // returned unchanged.  The first item of the result is the resolved
+        // endpoint and the second is an error message (empty on success).
+        private static Tuple<string, string> ResolveEndpoint(string endpoint)
+        {
+            // If this isn't a logical endpoint, then there's nothing to
+            // resolve
+            const string prefix = "ipc:";
+            if (!endpoint.StartsWith(prefix, StringComparison.Ordinal))
+            {
+                return Tuple.Create(endpoint, "");
+            }
+
+            // Extract and validate the name
+            string name = endpoint.Substring(prefix.Length);
+            if (name.Length == 0)
+            {
+                return Tuple.Create("", "empty logical endpoint name");
+            }
+            foreach (char c in name)
+            {
+                bool valid = (c >= 'a' && c <= 'z') ||
+                    (c >= 'A' && c <= 'Z') ||
+                    (c >= '0' && c <= '9') ||
+                    c == '.' || c == '-' || c == '_';
+                if (!valid)
+                {
+                    return Tuple.Create("", "invalid logical endpoint name");
+                }
+            }
+
+            // Compute the pipe name
+            return Tuple.Create(
+                @"\\.\pipe\" + Environment.UserName + "." + name,
+                ""
+            );
         }
 
         // Asynchronously create a new connection
-        public async Task<Tuple<Int32, string>> ConnectAsync(string endpoint)
+        public Task<Tuple<Int32, string>> ConnectAsync(string endpoint)
+        {
+            return connectAsync(endpoint, PipeTransmissionMode.Byte);
+        }
+
+        // Asynchronously create a new packet connection.  Packet connections
+        // use message-mode named pipes and preserve message boundaries.  They
+        // share the connection id space with stream connections and are
+        // closed with ConnectionClose.
+        public Task<Tuple<Int32, string>> ConnectPacketAsync(string endpoint)
         {
+            return connectAsync(endpoint, PipeTransmissionMode.Message);
+        }
+
+        // Common implementation of ConnectAsync/ConnectPacketAsync
+        private async Task<Tuple<Int32, string>> connectAsync(
+            string endpoint,
+            PipeTransmissionMode mode
+        )
+        {
+            // Enforce the endpoint allowlist
+            if (!EndpointPermitted(endpoint))
+            {
+                return Tuple.Create(-1, "endpoint not permitted");
+            }
+
+            // Resolve the endpoint
+            var resolved = ResolveEndpoint(endpoint);
+            if (resolved.Item2 != "")
+            {
+                return Tuple.Create(-1, resolved.Item2);
+            }
+            endpoint = resolved.Item1;
+
             // Parse the endpoint.  It should be formatted as
             // "\\server\pipe\name".
             string [] components = endpoint.Split(new char[] { '\\' });
@@ -58,13 +255,17 @@ namespace GopherJSIPCBridge
                 PipeOptions.Asynchronous
             );
 
-            // Try to connect asynchronously
+            // Try to connect asynchronously and set the read mode.  The read
+            // mode can only be set once connected, and setting it to message
+            // mode fails if the server end isn't a message-mode pipe.
             try
             {
                 await connection.ConnectAsync();
+                connection.ReadMode = mode;
             }
             catch (Exception e)
             {
+                connection.Close();
                 return Tuple.Create(-1, e.Message);
             }
 
@@ -193,6 +394,126 @@ namespace GopherJSIPCBridge
             return Tuple.Create(buffer.Length, "");
         }
 
+        // Gets a packet connection
+        private PipeStream packetConnection(Int32 connectionId)
+        {
+            lock (this)
+            {
+                PipeStream connection = null;
+                if (_connections.TryGetValue(connectionId, out connection) &&
+                    connection.ReadMode == PipeTransmissionMode.Message)
+                {
+                    return connection;
+                }
+            }
+            return null;
+        }
+
+        // Asynchronously read a single message from a packet connection.  An
+        // empty message with no error indicates end-of-stream.  Messages
+        // larger than MaximumMessageSize are discarded and reported with the
+        // error "message too large".
+        public async Task<Tuple<byte[], string>> ConnectionReadMessageAsync(
+            Int32 connectionId
+        )
+        {
+            // Get the connection
+            PipeStream connection = packetConnection(connectionId);
+            if (connection == null)
+            {
+                return Tuple.Create(new byte[0], "invalid connection id");
+            }
+
+            // Read until the end of the message.  A message may require
+            // multiple reads if it doesn't fit in the buffer, in which case
+            // IsMessageComplete will be false after each partial read.  We
+            // continue draining oversized messages so that the next read starts
+            // on a message boundary.
+            var message = new MemoryStream();
+            var buffer = new byte[MaximumMessageSize + 1];
+            bool tooLarge = false;
+            try
+            {
+                do
+                {
+                    Int32 count = await connection.ReadAsync(
+                        buffer,
+                        0,
+                        buffer.Length
+                    );
+                    if (count == 0)
+                    {
+                        break;
+                    }
+                    if (!tooLarge)
+                    {
+                        message.Write(buffer, 0, count);
+                        tooLarge = message.Length > MaximumMessageSize;
+                    }
+                } while (!connection.IsMessageComplete);
+            }
+            catch (Exception e)
+            {
+                return Tuple.Create(new byte[0], e.Message);
+            }
+
+            // Check for oversized messages
+            if (tooLarge)
+            {
+                return Tuple.Create(new byte[0], "message too large");
+            }
+
+            // All done
+            return Tuple.Create(message.ToArray(), "");
+        }
+
+        // Asynchronously write a single message to a packet connection
+        public async Task<Tuple<Int32, string>> ConnectionWriteMessageAsync(
+            Int32 connectionId,
+            byte[] buffer
+        )
+        {
+            // Get the connection
+            PipeStream connection = packetConnection(connectionId);
+            if (connection == null)
+            {
+                return Tuple.Create(0, "invalid connection id");
+            }
+
+            // Validate the message size.  Empty messages are indistinguishable
+            // from end-of-stream, so they're rejected.
+            if (buffer.Length == 0)
+            {
+                return Tuple.Create(0, "empty messages not supported");
+            }
+            else if (buffer.Length > MaximumMessageSize)
+            {
+                return Tuple.Create(0, "message too large");
+            }
+
+            // Write the message.  Each write to a message-mode pipe is
+            // delivered as a single message.
+            try
+            {
+                await connection.WriteAsync(buffer, 0, buffer.Length);
+            }
+            catch (Exception e)
+            {
+                return Tuple.Create(0, e.Message);
+            }
+
+            // All done
+            return Tuple.Create(buffer.Length, "");
+        }
+
+        // Asynchronously verify that the connection manager is responsive.
+        // This simply round-trips through the thread pool that services
+        // asynchronous I/O continuations.
+        public Task<string> PingAsync()
+        {
+            return Task.Run(() => "");
+        }
+
         // Synchronously (but instantly) close a connection
         public string ConnectionClose(Int32 connectionId)
         {
@@ -224,9 +545,67 @@ namespace GopherJSIPCBridge
             return "";
         }
 
+        // Shut down the read side of a connection.  Named pipes don't support
+        // half-closing, so this always fails (after validating the connection
+        // id, for consistency with other hosts).
+        public string ConnectionCloseRead(Int32 connectionId)
+        {
+            return connectionShutdownUnsupported(connectionId);
+        }
+
+        // Shut down the write side of a connection.  See ConnectionCloseRead.
+        public string ConnectionCloseWrite(Int32 connectionId)
+        {
+            return connectionShutdownUnsupported(connectionId);
+        }
+
+        // Common implementation of ConnectionCloseRead/ConnectionCloseWrite
+        private string connectionShutdownUnsupported(Int32 connectionId)
+        {
+            lock (this)
+            {
+                if (!_connections.ContainsKey(connectionId))
+                {
+                    return "invalid connection id";
+                }
+            }
+            return "operation not supported";
+        }
+
         // Synchronously (but instantly) create a new listener
         public Tuple<Int32, string> Listen(string endpoint)
         {
+            return listen(endpoint, PipeTransmissionMode.Byte);
+        }
+
+        // Synchronously (but instantly) create a new packet listener.
+        // Connections accepted from packet listeners are packet connections.
+        // Packet listeners share the listener id space with stream listeners.
+        public Tuple<Int32, string> ListenPacket(string endpoint)
+        {
+            return listen(endpoint, PipeTransmissionMode.Message);
+        }
+
+        // Common implementation of Listen/ListenPacket
+        private Tuple<Int32, string> listen(
+            string endpoint,
+            PipeTransmissionMode mode
+        )
+        {
+            // Enforce the endpoint allowlist
+            if (!EndpointPermitted(endpoint))
+            {
+                return Tuple.Create(-1, "endpoint not permitted");
+            }
+
+            // Resolve the endpoint
+            var resolved = ResolveEndpoint(endpoint);
+            if (resolved.Item2 != "")
+            {
+                return Tuple.Create(-1, resolved.Item2);
+            }
+            endpoint = resolved.Item1;
+
             // Parse the endpoint.  It should be formatted as
             // "\\server\pipe\name".
             string[] components = endpoint.Split(new char[] { '\\' });
@@ -248,7 +627,7 @@ namespace GopherJSIPCBridge
                 listenerId = _nextListenerId++;
 
                 // Do the storage
-                _listeners[listenerId] = components[4];
+                _listeners[listenerId] = Tuple.Create(components[4], mode);
             }
 
             // All done
@@ -259,14 +638,14 @@ namespace GopherJSIPCBridge
             int listenerId
         )
         {
-            // Get the listener (which is just a pipe name)
+            // Get the listener (which is just a pipe name and mode)
             // TODO: I guess that technically we should do some sort of check to
             // make sure that no connections are being accepted on this
             // endpoint.  Perhaps change listener map to add cancellation token?
-            string pipeName = null;
+            Tuple<string, PipeTransmissionMode> listener = null;
             lock (this)
             {
-                if (!_listeners.TryGetValue(listenerId, out pipeName))
+                if (!_listeners.TryGetValue(listenerId, out listener))
                 {
                     return Tuple.Create(0, "invalid listener id");
                 }
@@ -278,10 +657,10 @@ namespace GopherJSIPCBridge
             // (and I don't mean they'll call await and halt - I mean they'll
             // never return a Task object)
             var connection = new NamedPipeServerStream(
-                pipeName,
+                listener.Item1,
                 PipeDirection.InOut,
                 NamedPipeServerStream.MaxAllowedServerInstances,
-                PipeTransmissionMode.Byte,
+                listener.Item2,
                 PipeOptions.Asynchronous
             );
 
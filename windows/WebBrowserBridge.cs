@@ -1,4 +1,5 @@
 using System;
+using System.Collections.Generic;
 using System.Security.Permissions;
 using System.Windows.Forms;
 
@@ -18,10 +19,24 @@ namespace GopherJSIPCBridge
         public WebBrowserBridge(
             WebBrowser browser,
             string initializationMessage
+        ) : this(browser, initializationMessage, null)
+        {
+        }
+
+        // Constructor.  The bridge will only permit connections and listeners
+        // on endpoints matching one of the specified patterns (see
+        // IPCConnectionManager).  This should mirror the policy provided in
+        // ClientOptions in GopherJS, since any script in the page can call the
+        // bridge directly through window.external.  Passing null
+        // permits all endpoints.
+        public WebBrowserBridge(
+            WebBrowser browser,
+            string initializationMessage,
+            IEnumerable<string> allowedEndpoints
         )
         {
             // Create connection manager
-            _connectionManager = new IPCConnectionManager();
+            _connectionManager = new IPCConnectionManager(allowedEndpoints);
 
             // Store the browser
             _browser = browser;
@@ -153,6 +168,34 @@ namespace GopherJSIPCBridge
             );
         }
 
+        // Method for shutting down the read side of a connection
+        public void ConnectionCloseRead(int connectionId, int sequence)
+        {
+            // Use the connection manager to shut down the connection and then
+            // forward the result back across the bridge
+            invokeOnMainThread(
+                "_GIBWebBrowserBridgeRespondConnectionCloseRead",
+                new object[] {
+                    sequence,
+                    _connectionManager.ConnectionCloseRead(connectionId)
+                }
+            );
+        }
+
+        // Method for shutting down the write side of a connection
+        public void ConnectionCloseWrite(int connectionId, int sequence)
+        {
+            // Use the connection manager to shut down the connection and then
+            // forward the result back across the bridge
+            invokeOnMainThread(
+                "_GIBWebBrowserBridgeRespondConnectionCloseWrite",
+                new object[] {
+                    sequence,
+                    _connectionManager.ConnectionCloseWrite(connectionId)
+                }
+            );
+        }
+
         // Method for starting a listener
         public void Listen(string endpoint, int sequence)
         {
@@ -206,5 +249,114 @@ namespace GopherJSIPCBridge
                 }
             );
         }
+
+        // Method for asynchronously pinging the host
+        public void Ping(int sequence)
+        {
+            // Forward the request to the connection manager with an appropriate
+            // continuation
+            _connectionManager.PingAsync().ContinueWith(
+                (task) =>
+                {
+                    // Do the response invocation on the main thread
+                    invokeOnMainThread(
+                        "_GIBWebBrowserBridgeRespondPing",
+                        new object[] { sequence, task.Result }
+                    );
+                }
+            );
+        }
+
+        // Method for asynchronously creating a packet connection
+        public void ConnectPacket(string endpoint, int sequence)
+        {
+            // Forward the request to the connection manager with an appropriate
+            // continuation
+            _connectionManager.ConnectPacketAsync(endpoint).ContinueWith(
+                (task) =>
+                {
+                    // Extract the result
+                    var result = task.Result;
+
+                    // Do the response invocation on the main thread
+                    invokeOnMainThread(
+                        "_GIBWebBrowserBridgeRespondConnectPacket",
+                        new object[] { sequence, result.Item1, result.Item2 }
+                    );
+                }
+            );
+        }
+
+        // Method for asynchronously reading a message from a packet connection
+        public void ConnectionReadMessage(int connectionId, int sequence)
+        {
+            // Forward the request to the connection manager with an appropriate
+            // continuation
+            _connectionManager.ConnectionReadMessageAsync(
+                connectionId
+            ).ContinueWith(
+                (task) =>
+                {
+                    // Extract the result
+                    var result = task.Result;
+
+                    // Base64-encode the data
+                    string data64 = Convert.ToBase64String(result.Item1);
+
+                    // Do the response invocation on the main thread
+                    invokeOnMainThread(
+                        "_GIBWebBrowserBridgeRespondConnectionReadMessage",
+                        new object[] { sequence, data64, result.Item2 }
+                    );
+                }
+            );
+        }
+
+        // Method for asynchronously writing a message to a packet connection
+        public void ConnectionWriteMessage(
+            int connectionId,
+            string data64,
+            int sequence
+        )
+        {
+            // Decode the data
+            byte[] buffer = Convert.FromBase64String(data64);
+
+            // Forward the request to the connection manager with an appropriate
+            // continuation
+            _connectionManager.ConnectionWriteMessageAsync(
+                connectionId,
+                buffer
+            ).ContinueWith(
+                (task) =>
+                {
+                    // Extract the result
+                    var result = task.Result;
+
+                    // Do the response invocation on the main thread
+                    invokeOnMainThread(
+                        "_GIBWebBrowserBridgeRespondConnectionWriteMessage",
+                        new object[] { sequence, result.Item1, result.Item2 }
+                    );
+                }
+            );
+        }
+
+        // Method for starting a packet listener
+        public void ListenPacket(string endpoint, int sequence)
+        {
+            // Use the connection manager to listen and then forward the result
+            // back across the bridge
+            Tuple<Int32, string> result =
+                _connectionManager.ListenPacket(endpoint);
+            invokeOnMainThread(
+                "_GIBWebBrowserBridgeRespondListenPacket",
+                new object[] {
+                    sequence,
+                    result.Item1,
+                    result.Item2
+                }
+            );
+        }
     }
 }